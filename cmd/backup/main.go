@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/backup"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/storage"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Logger is a global variable that holds a pointer to an instance of slog.Logger for logging application messages.
+var Logger *slog.Logger
+
+// writeBackup writes the backup archive of the given user, with the covers in store, to path.
+func writeBackup(m *models.Models, path, username string, store storage.Backend) error {
+	userId, err := m.Users.RetrieveId(username)
+	if err != nil {
+		return err
+	}
+
+	data, err := m.Backups.Collect(userId)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			Logger.Error(err.Error())
+		}
+	}()
+
+	if err := backup.Write(context.Background(), out, username, data, store); err != nil {
+		return err
+	}
+	Logger.Info("Backup written", "file", path, "books", len(data.Books), "reviews", len(data.Reviews), "notes", len(data.Notes))
+	return nil
+}
+
+// restoreBackup restores the backup archive at path into the library of the given user, saving covers with save.
+func restoreBackup(m *models.Models, path, username string, save func(ctx context.Context, r io.Reader) (string, error)) error {
+	userId, err := m.Users.RetrieveId(username)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			Logger.Error(err.Error())
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	archive, err := backup.Open(file, info.Size())
+	if err != nil {
+		return err
+	}
+	Logger.Info("Restoring backup", "file", path, "version", archive.Version, "created_at", archive.CreatedAt, "from", archive.Username)
+
+	result, err := m.Backups.Restore(userId, archive.Backup, func(b models.BackupBook) (string, error) {
+		return archive.SaveCover(context.Background(), b, save)
+	})
+	if err != nil {
+		return err
+	}
+	Logger.Info("Backup restored",
+		"created", result.Created,
+		"linked", result.Linked,
+		"skipped", result.Skipped,
+		"reviews", result.Reviews,
+		"notes", result.Notes,
+	)
+	return nil
+}
+
+// main is the entry point of the backup command; it writes the backup archive of a user,
+// or restores one when -restore is set.
+func main() {
+	Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	file := flag.String("file", "backup.zip", "path to the backup archive")
+	username := flag.String("user", "admin", "username of the user to back up or restore into")
+	restore := flag.Bool("restore", false, "restore the archive instead of writing one")
+
+	config := app.NewConfig()
+	db, err := app.CreateDatabaseConnection(config.Dsn)
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+	Logger.Info("Database connection established")
+	m := models.NewModels(db, Logger)
+
+	store, err := app.NewStorage(config.Storage, config)
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if *restore {
+		// Covers are saved like uploaded covers, which needs the application
+		a := app.NewApp(config, db, store)
+		err = restoreBackup(m, *file, *username, a.SaveImage)
+	} else {
+		err = writeBackup(m, *file, *username, store)
+	}
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
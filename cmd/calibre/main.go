@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/calibre"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Logger is a global variable that holds a pointer to an instance of slog.Logger for logging application messages.
+var Logger *slog.Logger
+
+// importLibrary imports the Calibre library in dir into the library of the given user, saving covers with save.
+func importLibrary(m *models.Models, dir, username string, save func(ctx context.Context, r io.Reader) (string, error)) error {
+	userId, err := m.Users.RetrieveId(username)
+	if err != nil {
+		return err
+	}
+
+	library, err := calibre.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := library.Close(); err != nil {
+			Logger.Error(err.Error())
+		}
+	}()
+
+	books, err := library.Books()
+	if err != nil {
+		return err
+	}
+	Logger.Info("Read Calibre library", "dir", dir, "books", len(books))
+
+	var covers []string
+	result, err := m.Calibre.Import(userId, calibre.ImportBooks(books), func(b models.CalibreBook) (string, error) {
+		imageUrl, err := calibre.CopyCover(context.Background(), b, save)
+		if err == nil {
+			covers = append(covers, imageUrl)
+		}
+		return imageUrl, err
+	})
+	if err != nil {
+		// Nothing was saved, the covers copied so far are removed by the upload sweeper.
+		return err
+	}
+
+	Logger.Info("Calibre import finished",
+		"created", result.Created,
+		"linked", result.Linked,
+		"updated", result.Updated,
+		"skipped", result.Skipped,
+		"covers", len(covers),
+	)
+	return nil
+}
+
+// main is the entry point of the Calibre import command; it imports a Calibre library directory for an existing user.
+func main() {
+	Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	dir := flag.String("library", "Calibre Library", "path to the Calibre library directory containing metadata.db")
+	username := flag.String("user", "admin", "username of the user the books are imported for")
+
+	config := app.NewConfig()
+	db, err := app.CreateDatabaseConnection(config.Dsn)
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+	Logger.Info("Database connection established")
+	m := models.NewModels(db, Logger)
+
+	store, err := app.NewStorage(config.Storage, config)
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Covers are saved like uploaded covers, which needs the application
+	a := app.NewApp(config, db, store)
+	err = importLibrary(m, *dir, *username, a.SaveImage)
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/goodreads"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"log/slog"
+	"os"
+)
+
+// Logger is a global variable that holds a pointer to an instance of slog.Logger for logging application messages.
+var Logger *slog.Logger
+
+// importLibrary reads a Goodreads export from path and imports it into the library of the given user.
+// With dryRun set the import is only previewed and nothing is saved.
+func importLibrary(m *models.Models, path, username string, dryRun bool) error {
+	userId, err := m.Users.RetrieveId(username)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			Logger.Error(err.Error())
+		}
+	}()
+
+	records, err := goodreads.Parse(file)
+	if err != nil {
+		return err
+	}
+	Logger.Info("Read Goodreads export", "file", path, "books", len(records))
+
+	result, err := m.Books.Import(userId, goodreads.ImportBooks(records), dryRun)
+	if err != nil {
+		return err
+	}
+	Logger.Info("Goodreads import finished",
+		"dry_run", dryRun,
+		"created", result.Created,
+		"linked", result.Linked,
+		"skipped", result.Skipped,
+		"reviews", result.Reviews,
+	)
+	return nil
+}
+
+// exportLibrary writes the library of the given user to path as a Goodreads CSV file, or to stdout when path is "-".
+func exportLibrary(m *models.Models, path, username string) error {
+	userId, err := m.Users.RetrieveId(username)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if path != "-" {
+		out, err = os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := out.Close(); err != nil {
+				Logger.Error(err.Error())
+			}
+		}()
+	}
+
+	writer := goodreads.NewWriter(out)
+	if err := writer.WriteHeader(); err != nil {
+		return err
+	}
+	count := 0
+	err = m.Books.EachInLibrary(userId, func(entry models.LibraryEntry) error {
+		count++
+		return writer.Write(entry)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	Logger.Info("Goodreads export finished", "file", path, "books", count)
+	return nil
+}
+
+// main is the entry point of the Goodreads command; it imports a library export CSV for an existing user,
+// or writes the user's library in the same format when -export is set.
+func main() {
+	Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	file := flag.String("file", "goodreads_library_export.csv", "path to the Goodreads library export")
+	username := flag.String("user", "admin", "username of the user the books are imported for")
+	dryRun := flag.Bool("dry-run", false, "preview the import without saving anything")
+	export := flag.Bool("export", false, "export the library to -file instead of importing it, use - for stdout")
+
+	config := app.NewConfig()
+	db, err := app.CreateDatabaseConnection(config.Dsn)
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+	Logger.Info("Database connection established")
+	m := models.NewModels(db, Logger)
+
+	if *export {
+		err = exportLibrary(m, *file, *username)
+	} else {
+		err = importLibrary(m, *file, *username, *dryRun)
+	}
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"log/slog"
+	"os"
+)
+
+// Logger is a global variable that holds a pointer to an instance of slog.Logger for logging application messages.
+var Logger *slog.Logger
+
+// updateRecommendations computes the books readers rate alike from the ratings of their reviews, and the books
+// alike in content among those that changed, which the app recommends books from, without waiting for the app.
+func updateRecommendations(config *app.Config) error {
+	db, err := app.CreateDatabaseConnection(config.Dsn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			Logger.Error(err.Error())
+		}
+	}()
+
+	a := app.NewApp(config, db, nil)
+	n, err := a.Recommender.Update()
+	if err != nil {
+		return err
+	}
+	Logger.Info("Book similarities updated", "similarities", n)
+
+	// Compute the content similarities of every changed book, a batch at a time
+	total := 0
+	for {
+		n, err := a.Recommender.UpdateContent()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	Logger.Info("Book content similarities updated", "books", total)
+	return nil
+}
+
+// main is the entry point of the recommend command; it computes the similarities of books once.
+func main() {
+	Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	config := app.NewConfig()
+	if err := updateRecommendations(config); err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
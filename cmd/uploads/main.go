@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/storage"
+	"log/slog"
+	"os"
+)
+
+// Logger is a global variable that holds a pointer to an instance of slog.Logger for logging application messages.
+var Logger *slog.Logger
+
+// moveUploads moves the uploaded files from one storage backend to another. Image URLs do not name
+// the backend, so books keep their covers once the app is started with the new backend.
+func moveUploads(config *app.Config, from, to string, keep bool) error {
+	src, err := app.NewStorage(from, config)
+	if err != nil {
+		return err
+	}
+	dst, err := app.NewStorage(to, config)
+	if err != nil {
+		return err
+	}
+
+	n, err := storage.Migrate(context.Background(), src, dst, keep)
+	if err != nil {
+		Logger.Error("Moving uploaded files failed", "moved", n)
+		return err
+	}
+	Logger.Info("Uploaded files moved", "from", from, "to", to, "files", n, "kept", keep)
+	return nil
+}
+
+// sweepUploads removes the uploaded files no book uses that are older than the grace period,
+// or with dryRun set only reports them.
+func sweepUploads(config *app.Config, dryRun bool) error {
+	db, err := app.CreateDatabaseConnection(config.Dsn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			Logger.Error(err.Error())
+		}
+	}()
+
+	store, err := app.NewStorage(config.Storage, config)
+	if err != nil {
+		return err
+	}
+
+	a := app.NewApp(config, db, store)
+	report, err := a.Sweeper.Sweep(context.Background(), dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range report.Orphans {
+		Logger.Info("Unused file", "key", o.Key, "size", o.Size, "modified", o.Modified)
+	}
+	Logger.Info("Uploaded files swept",
+		"dry_run", dryRun,
+		"files", report.Files,
+		"referenced", report.Referenced,
+		"recent", report.Recent,
+		"unused", len(report.Orphans),
+		"removed", report.Removed,
+		"bytes", report.Bytes,
+	)
+	return nil
+}
+
+// main is the entry point of the uploads command; it moves the uploaded files from one storage backend
+// to another, or with -sweep removes the ones no book uses.
+func main() {
+	Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	from := flag.String("from", app.StorageLocal, "storage backend to move the uploaded files from, local or s3")
+	to := flag.String("to", app.StorageS3, "storage backend to move the uploaded files to, local or s3")
+	keep := flag.Bool("keep", false, "copy the files, keeping them in the backend they are moved from")
+	sweep := flag.Bool("sweep", false, "remove the uploaded files no book uses instead of moving files")
+	dryRun := flag.Bool("dry-run", false, "with -sweep, report the unused files without removing them")
+
+	config := app.NewConfig()
+
+	var err error
+	if *sweep {
+		err = sweepUploads(config, *dryRun)
+	} else if *from == *to {
+		Logger.Error("the backends to move the files from and to are the same", "backend", *from)
+		os.Exit(1)
+	} else {
+		err = moveUploads(config, *from, *to, *keep)
+	}
+	if err != nil {
+		Logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
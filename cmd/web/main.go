@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/madalinpopa/go-bookreview/internal/app"
 	"github.com/madalinpopa/go-bookreview/internal/routes"
@@ -29,21 +30,36 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Create a new app instance using the configuration.
-	a := app.NewApp(config, db)
-
-	// Create file uploads directory
-	if err := os.MkdirAll(config.UploadDir, 0755); err != nil {
-		a.Logger.Error(err.Error())
-		os.Exit(1)
+	// Open the storage of uploaded files, creating the uploads directory if they are stored locally.
+	store, err := app.NewStorage(config.Storage, config)
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	// Create a new app instance using the configuration.
+	a := app.NewApp(config, db, store)
+
 	// Load templates into the app to set up rendering for HTML views.
 	if err := a.LoadTemplates(); err != nil {
 		a.Logger.Error(err.Error())
 		os.Exit(1)
 	}
 
+	// Download missing covers in the background.
+	if a.Covers != nil {
+		go a.Covers.Run(context.Background())
+	}
+
+	// Remove the uploaded files no book uses any more from time to time.
+	if config.SweepInterval > 0 {
+		go a.Sweeper.Run(context.Background())
+	}
+
+	// Compute the books readers rate alike for recommendations from time to time.
+	if config.RecommendInterval > 0 {
+		go a.Recommender.Run(context.Background())
+	}
+
 	// Create and configure the HTTP server.
 	s := http.Server{
 		Addr:         fmt.Sprintf("%s:%d", config.Addr, config.Port),
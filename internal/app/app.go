@@ -2,6 +2,8 @@ package app
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -9,15 +11,24 @@ import (
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"github.com/madalinpopa/go-bookreview/internal/covers"
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/metadata"
 	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/recommend"
+	"github.com/madalinpopa/go-bookreview/internal/storage"
 	"github.com/madalinpopa/go-bookreview/ui"
 	"html/template"
+	"io"
 	"io/fs"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,6 +38,9 @@ type contextKey string
 // IsAuthenticatedContextKey is a context key used to store and retrieve authentication status in a strongly typed manner.
 const IsAuthenticatedContextKey = contextKey("isAuthenticated")
 
+// APIUserIdContextKey is a context key used to store the ID of a user authenticated with HTTP Basic or an API token.
+const APIUserIdContextKey = contextKey("apiUserId")
+
 // functions is a template.FuncMap providing custom date formatting functions for use in HTML templates.
 var functions = template.FuncMap{
 	"humanDate": func(t time.Time) string {
@@ -63,6 +77,14 @@ var functions = template.FuncMap{
 		}
 		return b
 	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%d%%", int(math.Floor(f*100)))
+	},
+	"highlight": func(snippet string) template.HTML {
+		escaped := template.HTMLEscapeString(snippet)
+		escaped = strings.ReplaceAll(escaped, models.HighlightStart, `<mark class="bg-teal-100 text-slate-800 rounded-sm">`)
+		return template.HTML(strings.ReplaceAll(escaped, models.HighlightEnd, "</mark>"))
+	},
 }
 
 // TemplateData holds data passed to templates, including form state, page title, and CSRF token for security.
@@ -117,11 +139,60 @@ type TemplateData struct {
 	// PageSize specifies the number of items displayed per page in paginated data.
 	PageSize int
 
+	// Filter narrows down and orders the book list, and Facets holds the options of its filters with their counts.
+	Filter models.BookFilter
+	Facets models.BookFacets
+
+	// BooksURL is the URL of the book list as shown, with its filters and page.
+	BooksURL string
+
+	// Scroll indicates that the book list loads more books as it is scrolled instead of having numbered pages,
+	// and NextCursor is the cursor of the books after those shown, empty when there are none.
+	Scroll     bool
+	NextCursor string
+
 	// Total represents the total number of records or items available for a specific query or list.
 	Total int
 
 	// TotalPages represents the total number of pages available based on the total items and current pagination settings.
 	TotalPages int
+
+	// KindleTitles holds the Kindle titles with clippings that are waiting to be matched to a book.
+	KindleTitles []models.KindleTitle
+
+	// ImportResult holds the outcome of a library import, or of its preview when ImportPreview is set.
+	ImportResult *models.ImportResult
+
+	// ImportPreview indicates that ImportResult comes from a dry run and nothing was saved yet.
+	ImportPreview bool
+
+	// APITokens holds the API tokens of the authenticated user, used by e-reader apps to read the OPDS catalog.
+	APITokens []models.APIToken
+
+	// NewAPIToken holds a token that was just created; it is shown once and cannot be retrieved again.
+	NewAPIToken string
+
+	// KosyncDocuments holds the documents synced by KOReader that are waiting to be matched to a book.
+	KosyncDocuments []models.KosyncDocument
+
+	// Search is the search entered in the books header, and SearchResults the books matching it, best matches first.
+	Search        string
+	SearchResults []models.SearchResult
+
+	// Suggestions holds the matches of the search being typed, and RecentSearches the last searches of the user,
+	// suggested before anything is typed.
+	Suggestions    models.Suggestions
+	RecentSearches []string
+
+	// SmartShelves holds the smart shelves of the authenticated user, and SmartShelf the one the book list shows.
+	SmartShelves []models.SmartShelf
+	SmartShelf   models.SmartShelf
+
+	// Stats holds the reading statistics of the authenticated user for the year the statistics page shows.
+	Stats models.ReadingStats
+
+	// Charts holds the charts of the page by name, rendered to SVG.
+	Charts map[string]template.HTML
 }
 
 // App represents the core application structure including database, configuration, and logging layout.
@@ -149,10 +220,32 @@ type App struct {
 
 	// FormDecoder is used to decode form values into Go structs, supporting custom type decoding and validation.
 	FormDecoder *form.Decoder
+
+	// Metadata looks up book details by ISBN to prefill the book form; it is nil when lookups are disabled.
+	Metadata metadata.Provider
+
+	// Covers downloads missing covers in the background; it is nil when downloads are disabled.
+	Covers *covers.Fetcher
+
+	// Storage stores uploaded files, such as covers.
+	Storage storage.Backend
+
+	// Sweeper removes the uploaded files no book uses any more.
+	Sweeper *storage.Sweeper
+
+	// Recommender computes the similarities of books recommendations are made from.
+	Recommender *recommend.Job
+
+	// Signer signs the URLs private uploads are shared through.
+	Signer *storage.Signer
+
+	// srcsets caches the srcset attributes of uploaded images by their URL.
+	srcsets sync.Map
 }
 
-// NewApp initializes and returns a pointer to an App struct with the provided database connection and configuration.
-func NewApp(config *Config, db *sql.DB) *App {
+// NewApp initializes and returns a pointer to an App struct with the provided database connection, configuration
+// and storage for uploaded files.
+func NewApp(config *Config, db *sql.DB, store storage.Backend) *App {
 
 	// Initialize session manager with SQLite3-backed session store
 	sessionManager := scs.New()
@@ -168,14 +261,60 @@ func NewApp(config *Config, db *sql.DB) *App {
 	// Logger
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	return &App{
+	a := &App{
 		db:             db,
 		Config:         config,
 		Logger:         logger,
 		FormDecoder:    formDecoder,
 		SessionManager: sessionManager,
 		Models:         models.NewModels(db, logger),
+		Storage:        store,
+		Signer:         storage.NewSigner(uploadSecret(config.UploadSecret)),
+	}
+
+	// Book lookups are remembered in the database, books for a month and unknown ISBNs for a day
+	if config.MetadataURL != "" {
+		a.Metadata = &metadata.Cached{
+			Provider:    metadata.NewOpenLibrary(config.MetadataURL, config.MetadataTimeout),
+			Cache:       &a.Models.MetadataCache,
+			Logger:      logger,
+			TTL:         30 * 24 * time.Hour,
+			NotFoundTTL: 24 * time.Hour,
+		}
+	}
+
+	// Covers are downloaded a few at a time and saved like uploaded covers
+	if config.CoverURL != "" {
+		a.Covers = &covers.Fetcher{
+			URLTemplate: config.CoverURL,
+			Client:      &http.Client{Timeout: 30 * time.Second},
+			Store:       &a.Models.Covers,
+			Logger:      logger,
+			Save:        a.SaveImage,
+			Interval:    config.CoverInterval,
+			Poll:        5 * time.Minute,
+		}
+	}
+
+	a.Sweeper = &storage.Sweeper{
+		Backend:    store,
+		Logger:     logger,
+		References: a.uploadReferences,
+		Grace:      config.SweepGrace,
+		Interval:   config.SweepInterval,
 	}
+
+	// Every book keeps its 20 most similar books, compared by their ratings when at least 2 readers rated both,
+	// and by their content within a minute of a change
+	a.Recommender = &recommend.Job{
+		Store:     &a.Models.Recommendations,
+		Logger:    logger,
+		Neighbors: 20,
+		MinRaters: 2,
+		Interval:  config.RecommendInterval,
+		Poll:      time.Minute,
+	}
+	return a
 }
 
 // IsAuthenticated checks
@@ -194,6 +333,12 @@ func (a *App) GetAuthenticatedUserId(r *http.Request) int {
 	return userId
 }
 
+// GetAPIUserId retrieves the ID of the user authenticated with HTTP Basic or an API token from the request context.
+func (a *App) GetAPIUserId(r *http.Request) int {
+	userId, _ := r.Context().Value(APIUserIdContextKey).(int)
+	return userId
+}
+
 // GetAuthenticatedUserName retrieves the authenticated user's name from the session data using the HTTP request context.
 func (a *App) GetAuthenticatedUserName(r *http.Request) string {
 	userName := a.SessionManager.GetString(r.Context(), "authenticatedUsername")
@@ -204,7 +349,7 @@ func (a *App) GetAuthenticatedUserName(r *http.Request) string {
 func (a *App) LoadTemplates() error {
 	cache := map[string]*template.Template{}
 
-	baseTmpl, err := template.New("base").Funcs(functions).ParseFS(ui.Files,
+	baseTmpl, err := template.New("base").Funcs(functions).Funcs(template.FuncMap{"srcset": a.srcset}).ParseFS(ui.Files,
 		"html/base.tmpl",
 		"html/partials/layout/*.tmpl",
 		"html/partials/components/*.tmpl",
@@ -332,3 +477,167 @@ func (a *App) HtmxLocation(w http.ResponseWriter, r *http.Request, url string, t
 	w.Header().Set("HX-Location", string(locationJSON))
 	w.WriteHeader(http.StatusOK)
 }
+
+// SaveImage processes an uploaded image with images.Process and stores it, with its variants, under a key derived
+// from its content, so an image uploaded twice is stored once. It returns the URL the image is served at.
+// Images no book uses any more are removed by the upload sweeper.
+func (a *App) SaveImage(ctx context.Context, src io.Reader) (string, error) {
+	img, err := images.Process(src)
+	if err != nil {
+		return "", err
+	}
+
+	files := imageFiles(storage.ContentKey(img.Original, img.Ext), img)
+	if err := a.putFiles(ctx, files); err != nil {
+		return "", err
+	}
+	url := storage.URLPrefix + files[0].key
+	a.srcsets.Store(url, srcsetOf(url, func(width int) bool {
+		_, ok := img.Variants[width]
+		return ok
+	}))
+	return url, nil
+}
+
+// SavePrivateImage saves an image like SaveImage, but only its owner may see it, or whoever is given a URL of it
+// signed with SignedUploadURL. Its key is derived from the owner too, so it is never shared with public uploads.
+func (a *App) SavePrivateImage(ctx context.Context, userId int, src io.Reader) (string, error) {
+	img, err := images.Process(src)
+	if err != nil {
+		return "", err
+	}
+
+	owned := append([]byte(fmt.Sprintf("user:%d\n", userId)), img.Original...)
+	files := imageFiles(storage.ContentKey(owned, img.Ext), img)
+	keys := make([]string, 0, len(files))
+	for _, f := range files {
+		keys = append(keys, f.key)
+	}
+
+	// The files are marked before they are stored, so they are never served to others.
+	if err := a.Models.Uploads.SetPrivate(userId, keys...); err != nil {
+		return "", err
+	}
+	if err := a.putFiles(ctx, files); err != nil {
+		return "", err
+	}
+	return storage.URLPrefix + files[0].key, nil
+}
+
+// SignedUploadURL returns a URL of a private upload of a user that is valid for ttl, so clients without the session
+// of the user, such as OPDS readers, can load it. Returns models.ErrNoRecord when the file is not a private upload
+// of the user, so nobody is handed a URL of a file of someone else.
+func (a *App) SignedUploadURL(userId int, imageUrl string, ttl time.Duration) (string, error) {
+	key := storage.Key(imageUrl)
+	owner, err := a.Models.Uploads.Owner(key)
+	if err != nil {
+		return "", err
+	}
+	if owner != userId {
+		return "", models.ErrNoRecord
+	}
+	return a.Signer.URL(key, ttl)
+}
+
+// imageFile is a file of a processed image to store.
+type imageFile struct {
+	key  string
+	data []byte
+}
+
+// imageFiles returns the files of an image stored at key: the image itself first, then its variants.
+func imageFiles(key string, img *images.Image) []imageFile {
+	files := []imageFile{{key: key, data: img.Original}}
+	for width, data := range img.Variants {
+		files = append(files, imageFile{key: images.VariantName(key, width), data: data})
+	}
+	return files
+}
+
+// putFiles stores files in the storage backend.
+func (a *App) putFiles(ctx context.Context, files []imageFile) error {
+	for _, f := range files {
+		if err := a.Storage.Put(ctx, f.key, bytes.NewReader(f.data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadReferences returns the keys of the stored files in use: the covers of books and smart shelves,
+// and the variants of the covers.
+func (a *App) uploadReferences(ctx context.Context) (map[string]bool, error) {
+	imageUrls, err := a.Models.Books.ImageReferences()
+	if err != nil {
+		return nil, err
+	}
+	shelfImageUrls, err := a.Models.SmartShelves.ImageReferences()
+	if err != nil {
+		return nil, err
+	}
+	for imageUrl, count := range shelfImageUrls {
+		imageUrls[imageUrl] += count
+	}
+
+	references := make(map[string]bool)
+	for imageUrl := range imageUrls {
+		if !strings.HasPrefix(imageUrl, storage.URLPrefix) {
+			continue
+		}
+		key := storage.Key(imageUrl)
+		references[key] = true
+		for _, width := range images.Widths {
+			references[images.VariantName(key, width)] = true
+		}
+	}
+	return references, nil
+}
+
+// srcset returns the srcset attribute of an uploaded image, listing the variants stored by SaveImage,
+// or an empty string for images without variants. Which variants exist is remembered, as uploads are never changed,
+// and looked up without reading them from the storage backend where it can.
+func (a *App) srcset(url string) string {
+	if !strings.HasPrefix(url, storage.URLPrefix) {
+		return ""
+	}
+	if v, ok := a.srcsets.Load(url); ok {
+		return v.(string)
+	}
+
+	var lookupErr error
+	srcset := srcsetOf(url, func(width int) bool {
+		exists, err := storage.Exists(context.Background(), a.Storage, storage.Key(images.VariantName(url, width)))
+		if err != nil && lookupErr == nil {
+			lookupErr = err
+		}
+		return exists
+	})
+	if lookupErr != nil {
+		// The variants may exist, so they are looked for again next time.
+		a.Logger.Error(lookupErr.Error())
+		return ""
+	}
+	a.srcsets.Store(url, srcset)
+	return srcset
+}
+
+// srcsetOf returns the srcset attribute of the image at url listing the variants for which exists reports true.
+func srcsetOf(url string, exists func(width int) bool) string {
+	var candidates []string
+	for _, width := range images.Widths {
+		if exists(width) {
+			candidates = append(candidates, fmt.Sprintf("%s %dw", images.VariantName(url, width), width))
+		}
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// uploadSecret returns the secret upload URLs are signed with, or a random one if secret is empty.
+func uploadSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	random := make([]byte, 32)
+	_, _ = rand.Read(random)
+	return random
+}
@@ -1,6 +1,10 @@
 package app
 
-import "flag"
+import (
+	"flag"
+	"os"
+	"time"
+)
 
 // Config represents the configuration settings for application.
 type Config struct {
@@ -14,8 +18,48 @@ type Config struct {
 	// port specifies the numerical network port used for the service to listen on or connect to.
 	Port int
 
+	// Storage is the name of the backend uploaded files are stored in, StorageLocal or StorageS3.
+	Storage string
+
 	// UploadDir specifies the directory path where uploaded files are stored for the application.
 	UploadDir string
+
+	// S3Endpoint, S3Bucket and S3Region locate the bucket of an S3 compatible object store uploaded files are stored in.
+	S3Endpoint string
+	S3Bucket   string
+	S3Region   string
+
+	// S3AccessKey and S3SecretKey are the credentials of the object store. They are read from the environment,
+	// so they do not show up in the list of processes.
+	S3AccessKey string
+	S3SecretKey string
+
+	// UploadSecret signs the URLs private uploads are shared through. It is read from the environment;
+	// when it is empty a random secret is used, so signed URLs stop working when the application restarts.
+	UploadSecret string
+
+	// MetadataURL is the base URL of the Open Library compatible catalog books are looked up in by ISBN.
+	// Lookups are disabled when it is empty.
+	MetadataURL string
+
+	// MetadataTimeout limits how long a lookup in the catalog may take before the user is left to fill in the book.
+	MetadataTimeout time.Duration
+
+	// CoverURL is the URL template covers are downloaded from for books with an ISBN and no cover,
+	// with {isbn} standing for the ISBN. Downloads are disabled when it is empty.
+	CoverURL string
+
+	// CoverInterval is the least time between two cover downloads from the same host.
+	CoverInterval time.Duration
+
+	// SweepInterval is the time between two sweeps of the uploaded files no book uses, which are
+	// removed once they are older than SweepGrace. Sweeps are disabled when the interval is 0.
+	SweepInterval time.Duration
+	SweepGrace    time.Duration
+
+	// RecommendInterval is the time between two computations of the books readers rate alike, which
+	// recommendations are made from. Computations are disabled when the interval is 0.
+	RecommendInterval time.Duration
 }
 
 // NewConfig initializes and returns a pointer to a config struct populated with default CLI flags and values.
@@ -25,8 +69,23 @@ func NewConfig() *Config {
 	flag.StringVar(&config.Addr, "addr", "localhost", "address to listen on")
 	flag.IntVar(&config.Port, "port", 4000, "port to listen on")
 	flag.StringVar(&config.Dsn, "dsn", "db.sqlite", "database connection string")
+	flag.StringVar(&config.Storage, "storage", StorageLocal, "storage backend for uploaded files, local or s3")
 	flag.StringVar(&config.UploadDir, "upload-dir", "uploads", "directory for uploaded files")
+	flag.StringVar(&config.S3Endpoint, "s3-endpoint", "https://s3.amazonaws.com", "endpoint of the S3 compatible object store")
+	flag.StringVar(&config.S3Bucket, "s3-bucket", "", "bucket of the object store to store uploaded files in")
+	flag.StringVar(&config.S3Region, "s3-region", "us-east-1", "region of the object store")
+	flag.StringVar(&config.MetadataURL, "metadata-url", "https://openlibrary.org", "base URL of the catalog to look up books by ISBN, empty to disable")
+	flag.DurationVar(&config.MetadataTimeout, "metadata-timeout", 5*time.Second, "timeout of book lookups in the catalog")
+	flag.StringVar(&config.CoverURL, "cover-url", "https://covers.openlibrary.org/b/isbn/{isbn}-L.jpg?default=false", "URL template to download missing covers from, {isbn} is replaced, empty to disable")
+	flag.DurationVar(&config.CoverInterval, "cover-interval", 2*time.Second, "least time between two cover downloads from the same host")
+	flag.DurationVar(&config.SweepInterval, "sweep-interval", 6*time.Hour, "time between two sweeps of unused uploaded files, 0 to disable")
+	flag.DurationVar(&config.SweepGrace, "sweep-grace", 24*time.Hour, "least age of an unused uploaded file that is removed")
+	flag.DurationVar(&config.RecommendInterval, "recommend-interval", 6*time.Hour, "time between two computations of book recommendations, 0 to disable")
 	flag.Parse()
 
+	config.S3AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	config.S3SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	config.UploadSecret = os.Getenv("UPLOAD_SECRET")
+
 	return config
 }
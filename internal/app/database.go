@@ -2,6 +2,7 @@ package app
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/madalinpopa/go-bookreview/migrations"
 	_ "github.com/mattn/go-sqlite3"
@@ -40,9 +41,26 @@ func CreateDatabaseConnection(dsn string) (*sql.DB, error) {
 		return nil, handleDatabaseError(db, err)
 	}
 
+	if err = checkFTS5(db); err != nil {
+		return nil, handleDatabaseError(db, err)
+	}
+
 	return db, nil
 }
 
+// checkFTS5 returns an error if SQLite was built without FTS5, which the full-text search tables need.
+// The SQLite of go-sqlite3 only has FTS5 when the application is built with the sqlite_fts5 tag.
+func checkFTS5(db *sql.DB) error {
+	var enabled bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.New("SQLite was built without FTS5, build the application with -tags sqlite_fts5")
+	}
+	return nil
+}
+
 // handleDatabaseError ensures the database is closed and returns the error.
 func handleDatabaseError(db *sql.DB, err error) error {
 	if db != nil {
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/storage"
+)
+
+// Names of the storage backends for uploaded files.
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// NewStorage returns the storage backend for uploaded files with the given name, configured by config.
+func NewStorage(name string, config *Config) (storage.Backend, error) {
+	switch name {
+	case StorageLocal:
+		return storage.NewLocal(config.UploadDir)
+	case StorageS3:
+		return storage.NewS3(config.S3Endpoint, config.S3Bucket, config.S3Region, config.S3AccessKey, config.S3SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", name)
+	}
+}
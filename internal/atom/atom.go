@@ -0,0 +1,197 @@
+// Package atom writes Atom 1.0 syndication feeds and answers conditional requests for them.
+package atom
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of the documents written by this package.
+const ContentType = "application/atom+xml; charset=utf-8"
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// Entry is an item of a feed, such as a review.
+type Entry struct {
+	// ID identifies the entry permanently; it must not change when the entry is updated.
+	ID        string
+	Title     string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+
+	// Link leads to the page of the entry in the web interface.
+	Link string
+
+	// Content is the plain text body of the entry.
+	Content string
+}
+
+// Feed is an Atom feed. Relative links are resolved against Base.
+type Feed struct {
+	ID       string
+	Title    string
+	Subtitle string
+	Updated  time.Time
+
+	// Base is the absolute URL of the site, Self links to this feed and Alternate to its page in the web interface.
+	Base      string
+	Self      string
+	Alternate string
+
+	Entries []Entry
+}
+
+// link is an Atom link element.
+type link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// text is an Atom text construct.
+type text struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// person is an Atom person construct.
+type person struct {
+	Name string `xml:"name"`
+}
+
+// entry is the XML representation of an Entry.
+type entry struct {
+	ID        string  `xml:"id"`
+	Title     string  `xml:"title"`
+	Updated   string  `xml:"updated"`
+	Published string  `xml:"published,omitempty"`
+	Author    *person `xml:"author,omitempty"`
+	Links     []link  `xml:"link"`
+	Content   *text   `xml:"content,omitempty"`
+}
+
+// feed is the XML representation of a Feed.
+type feed struct {
+	XMLName  xml.Name `xml:"feed"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Base     string   `xml:"xml:base,attr,omitempty"`
+	ID       string   `xml:"id"`
+	Title    string   `xml:"title"`
+	Subtitle string   `xml:"subtitle,omitempty"`
+	Updated  string   `xml:"updated"`
+	Author   person   `xml:"author"`
+	Links    []link   `xml:"link"`
+	Entries  []entry  `xml:"entry"`
+}
+
+// Write writes the feed as an Atom document. The feed is updated when its newest entry was,
+// or at Updated if it has no entries, and entries without an author are credited to the feed title.
+func (f *Feed) Write(w io.Writer) error {
+	doc := feed{
+		Xmlns:    atomNamespace,
+		Base:     f.Base,
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Subtitle,
+		Updated:  atomTime(f.updated()),
+		Author:   person{Name: f.Title},
+		Links:    []link{{Rel: "self", Href: f.Self, Type: "application/atom+xml"}},
+	}
+	if f.Alternate != "" {
+		doc.Links = append(doc.Links, link{Rel: "alternate", Href: f.Alternate, Type: "text/html"})
+	}
+
+	for _, e := range f.Entries {
+		item := entry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Updated: atomTime(e.Updated),
+			Links:   []link{{Rel: "alternate", Href: e.Link, Type: "text/html"}},
+		}
+		if !e.Published.IsZero() {
+			item.Published = atomTime(e.Published)
+		}
+		if e.Author != "" {
+			item.Author = &person{Name: e.Author}
+		}
+		if e.Content != "" {
+			item.Content = &text{Type: "text", Value: e.Content}
+		}
+		doc.Entries = append(doc.Entries, item)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+// updated returns the time the feed last changed.
+func (f *Feed) updated() time.Time {
+	updated := f.Updated
+	for _, e := range f.Entries {
+		if e.Updated.After(updated) {
+			updated = e.Updated
+		}
+	}
+	return updated
+}
+
+// Version describes the state of the data behind a feed, so it can be compared without building the feed.
+type Version struct {
+	// Count is the number of items the feed is built from, which changes when one is removed.
+	Count int
+
+	// Updated is when the most recently changed item changed.
+	Updated time.Time
+}
+
+// ETag returns the entity tag of a feed in this version.
+func (v Version) ETag() string {
+	var updated int64
+	if !v.Updated.IsZero() {
+		updated = v.Updated.Unix()
+	}
+	return fmt.Sprintf(`W/"%d-%d"`, v.Count, updated)
+}
+
+// NotModified sets the ETag and Last-Modified headers of a feed in version v and, when the client already
+// has this version according to If-None-Match or If-Modified-Since, responds with 304 Not Modified and returns true.
+func NotModified(w http.ResponseWriter, r *http.Request, v Version) bool {
+	etag := v.ETag()
+	w.Header().Set("ETag", etag)
+	if !v.Updated.IsZero() {
+		w.Header().Set("Last-Modified", v.Updated.UTC().Format(http.TimeFormat))
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !v.Updated.IsZero() {
+		if !v.Updated.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// atomTime formats a time as an RFC 3339 date in UTC.
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
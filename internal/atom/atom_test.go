@@ -0,0 +1,116 @@
+package atom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestFeed_Write verifies the Atom document of a feed, including its updated time and entry links.
+func TestFeed_Write(t *testing.T) {
+	feed := &Feed{
+		ID:        "urn:test:reviews",
+		Title:     "Recent reviews",
+		Updated:   time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Base:      "https://example.com/",
+		Self:      "/feeds/reviews.atom",
+		Alternate: "/",
+		Entries: []Entry{
+			{
+				ID:        "urn:test:review:2",
+				Title:     "reader rated Dune 5/5",
+				Author:    "reader",
+				Published: time.Date(2024, time.January, 10, 9, 0, 0, 0, time.UTC),
+				Updated:   time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC),
+				Link:      "/books/1",
+				Content:   "A <classic>.",
+			},
+			{
+				ID:      "urn:test:review:1",
+				Title:   "other rated Emma 3/5",
+				Updated: time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC),
+				Link:    "/books/2",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	testutil.NoError(t, feed.Write(&buf))
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Base    string   `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Links   []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Updated   string `xml:"updated"`
+			Published string `xml:"published"`
+			Author    string `xml:"author>name"`
+			Content   string `xml:"content"`
+			Link      struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	testutil.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	testutil.Equal(t, doc.Base, "https://example.com/")
+	testutil.Equal(t, doc.ID, "urn:test:reviews")
+	testutil.Equal(t, doc.Updated, "2024-01-15T10:00:00Z")
+	testutil.Equal(t, doc.Links[0].Rel, "self")
+	testutil.Equal(t, doc.Links[0].Href, "/feeds/reviews.atom")
+
+	testutil.Equal(t, len(doc.Entries), 2)
+	testutil.Equal(t, doc.Entries[0].Published, "2024-01-10T09:00:00Z")
+	testutil.Equal(t, doc.Entries[0].Author, "reader")
+	testutil.Equal(t, doc.Entries[0].Content, "A <classic>.")
+	testutil.Equal(t, doc.Entries[0].Link.Href, "/books/1")
+	testutil.Equal(t, doc.Entries[1].Published, "")
+	testutil.Equal(t, doc.Entries[1].Author, "")
+}
+
+// TestNotModified tests conditional requests against the entity tag and the last modification time of a feed.
+func TestNotModified(t *testing.T) {
+	v := Version{Count: 3, Updated: time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name         string
+		header       string
+		value        string
+		wantModified bool
+	}{
+		{name: "unconditional", wantModified: true},
+		{name: "same entity tag", header: "If-None-Match", value: v.ETag(), wantModified: false},
+		{name: "strong form of the entity tag", header: "If-None-Match", value: `"other", "3-1705312800"`, wantModified: false},
+		{name: "other entity tag", header: "If-None-Match", value: `W/"2-1705312800"`, wantModified: true},
+		{name: "not modified since", header: "If-Modified-Since", value: "Mon, 15 Jan 2024 10:00:00 GMT", wantModified: false},
+		{name: "modified since", header: "If-Modified-Since", value: "Mon, 15 Jan 2024 09:59:59 GMT", wantModified: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/feeds/reviews.atom", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+
+			notModified := NotModified(w, r, v)
+			testutil.Equal(t, notModified, !tt.wantModified)
+			testutil.Equal(t, w.Header().Get("ETag"), `W/"3-1705312800"`)
+			testutil.Equal(t, w.Header().Get("Last-Modified"), "Mon, 15 Jan 2024 10:00:00 GMT")
+			if notModified {
+				testutil.Equal(t, w.Code, http.StatusNotModified)
+			}
+		})
+	}
+}
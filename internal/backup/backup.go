@@ -0,0 +1,338 @@
+// Package backup reads and writes account backups: zip archives with the JSON data of a user and their covers.
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/storage"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Version is the archive schema version written by this build. Archives of this or an older version can be read.
+// Fields are only ever added to the schema, so older archives decode into the current types.
+// Version 2 added the reading progress of books.
+// Version 3 added the number of pages of books.
+const Version = 3
+
+// appName identifies archives written by this application in the manifest.
+const appName = "go-bookreview"
+
+const (
+	manifestFile = "manifest.json"
+	booksFile    = "books.json"
+	reviewsFile  = "reviews.json"
+	notesFile    = "notes.json"
+	coversDir    = "covers/"
+)
+
+// maxJSONSize limits the uncompressed size of a JSON file in an archive.
+const maxJSONSize = 64 << 20
+
+// maxCoverSize limits the uncompressed size of a cover image in an archive.
+const maxCoverSize = 10 << 20
+
+var (
+	// ErrInvalidArchive is returned when a file is not a backup archive written by this application.
+	ErrInvalidArchive = errors.New("backup: invalid archive")
+
+	// ErrUnsupportedVersion is returned when an archive was written by a newer version of the application.
+	ErrUnsupportedVersion = errors.New("backup: unsupported archive version")
+)
+
+// manifest describes an archive and is always its first file.
+type manifest struct {
+	App       string    `json:"app"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Username  string    `json:"username"`
+}
+
+// book is the archive representation of a book in the user's library.
+type book struct {
+	ID              int        `json:"id"`
+	Title           string     `json:"title"`
+	Author          string     `json:"author"`
+	ISBN            string     `json:"isbn,omitempty"`
+	PublicationYear int        `json:"publication_year,omitempty"`
+	PageCount       int        `json:"page_count,omitempty"`
+	Status          string     `json:"status"`
+	Progress        int        `json:"progress,omitempty"`
+	Cover           string     `json:"cover,omitempty"`
+	Publisher       string     `json:"publisher,omitempty"`
+	Language        string     `json:"language,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	AddedAt         *time.Time `json:"added_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// review is the archive representation of a review.
+type review struct {
+	BookId    int       `json:"book_id"`
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// note is the archive representation of a note.
+type note struct {
+	BookId     int       `json:"book_id"`
+	Text       string    `json:"text"`
+	PageNumber int       `json:"page_number,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Write writes the backup of a user as a zip archive to w.
+// Covers are read from store; covers that no longer exist there are left out.
+func Write(ctx context.Context, w io.Writer, username string, data models.Backup, store storage.Backend) error {
+	zw := zip.NewWriter(w)
+
+	err := writeJSON(zw, manifestFile, manifest{App: appName, Version: Version, CreatedAt: time.Now().UTC(), Username: username})
+	if err != nil {
+		return err
+	}
+
+	books := make([]book, 0, len(data.Books))
+	written := make(map[string]bool)
+	for _, b := range data.Books {
+		entry := book{
+			ID:              b.ID,
+			Title:           b.Title,
+			Author:          b.Author,
+			ISBN:            b.ISBN,
+			PublicationYear: b.PublicationYear,
+			PageCount:       b.PageCount,
+			Status:          b.Status,
+			Progress:        b.Progress,
+			Publisher:       b.Publisher,
+			Language:        b.Language,
+			Description:     b.Description,
+			AddedAt:         timePtr(b.AddedAt),
+			FinishedAt:      timePtr(b.FinishedAt),
+		}
+
+		if b.ImageURL != "" {
+			name := coversDir + path.Base(b.ImageURL)
+			if !written[name] {
+				ok, err := writeCover(ctx, zw, name, store, storage.Key(b.ImageURL))
+				if err != nil {
+					return err
+				}
+				written[name] = ok
+			}
+			if written[name] {
+				entry.Cover = name
+			}
+		}
+		books = append(books, entry)
+	}
+	if err := writeJSON(zw, booksFile, books); err != nil {
+		return err
+	}
+
+	reviews := make([]review, 0, len(data.Reviews))
+	for _, r := range data.Reviews {
+		reviews = append(reviews, review{BookId: r.BookId, Rating: r.Rating, Text: r.ReviewText, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt})
+	}
+	if err := writeJSON(zw, reviewsFile, reviews); err != nil {
+		return err
+	}
+
+	notes := make([]note, 0, len(data.Notes))
+	for _, n := range data.Notes {
+		notes = append(notes, note{BookId: n.BookId, Text: n.NoteText, PageNumber: n.PageNumber, CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt})
+	}
+	if err := writeJSON(zw, notesFile, notes); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeJSON adds a JSON encoded file to the archive.
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeCover copies the cover stored under key in store into the archive as name.
+// It reports false, without an error, when the cover does not exist.
+func writeCover(ctx context.Context, zw *zip.Writer, name string, store storage.Backend, key string) (bool, error) {
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer rc.Close()
+
+	// Images are already compressed, so covers are stored as they are.
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Archive is an opened backup archive ready to be restored.
+type Archive struct {
+	Version   int
+	CreatedAt time.Time
+	Username  string
+	Backup    models.Backup
+
+	covers map[string]*zip.File
+}
+
+// Open reads a backup archive of the given size from r and checks its schema version.
+// Book image URLs of the returned backup refer to cover files inside the archive, see SaveCover.
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var m manifest
+	if err := readJSON(files, manifestFile, &m); err != nil {
+		return nil, err
+	}
+	if m.App != appName || m.Version < 1 {
+		return nil, ErrInvalidArchive
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("%w: archive version %d, supported up to %d", ErrUnsupportedVersion, m.Version, Version)
+	}
+
+	var books []book
+	var reviews []review
+	var notes []note
+	if err := readJSON(files, booksFile, &books); err != nil {
+		return nil, err
+	}
+	if err := readJSON(files, reviewsFile, &reviews); err != nil {
+		return nil, err
+	}
+	if err := readJSON(files, notesFile, &notes); err != nil {
+		return nil, err
+	}
+
+	a := &Archive{Version: m.Version, CreatedAt: m.CreatedAt, Username: m.Username, covers: make(map[string]*zip.File)}
+	for _, b := range books {
+		cover := ""
+		if f, ok := files[b.Cover]; ok && strings.HasPrefix(b.Cover, coversDir) {
+			cover = b.Cover
+			a.covers[cover] = f
+		}
+		a.Backup.Books = append(a.Backup.Books, models.BackupBook{
+			ID:              b.ID,
+			Title:           strings.TrimSpace(b.Title),
+			Author:          strings.TrimSpace(b.Author),
+			ISBN:            b.ISBN,
+			PublicationYear: b.PublicationYear,
+			PageCount:       b.PageCount,
+			Status:          b.Status,
+			Progress:        b.Progress,
+			ImageURL:        cover,
+			Publisher:       b.Publisher,
+			Language:        b.Language,
+			Description:     b.Description,
+			AddedAt:         timeValue(b.AddedAt),
+			FinishedAt:      timeValue(b.FinishedAt),
+		})
+	}
+	for _, r := range reviews {
+		a.Backup.Reviews = append(a.Backup.Reviews, models.BackupReview{BookId: r.BookId, Rating: r.Rating, ReviewText: r.Text, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt})
+	}
+	for _, n := range notes {
+		a.Backup.Notes = append(a.Backup.Notes, models.BackupNote{BookId: n.BookId, NoteText: n.Text, PageNumber: n.PageNumber, CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt})
+	}
+	return a, nil
+}
+
+// readJSON decodes a JSON file of the archive into v, limiting how much data is read.
+func readJSON(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrInvalidArchive, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := json.NewDecoder(io.LimitReader(rc, maxJSONSize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	return nil
+}
+
+// SaveCover saves the cover of a restored book with save, which processes it like an uploaded cover, and returns
+// its image URL. Covers that are not a supported image are left out, so the book is restored without one.
+// It is meant to be passed to BackupModel.Restore; covers of a failed restore are left to the upload sweeper.
+func (a *Archive) SaveCover(ctx context.Context, b models.BackupBook, save func(ctx context.Context, r io.Reader) (string, error)) (string, error) {
+	f, ok := a.covers[b.ImageURL]
+	if !ok {
+		return "", nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	cover, err := io.ReadAll(io.LimitReader(rc, maxCoverSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(cover) > maxCoverSize {
+		return "", fmt.Errorf("%w: cover %s is too large", ErrInvalidArchive, f.Name)
+	}
+
+	imageUrl, err := save(ctx, bytes.NewReader(cover))
+	if errors.Is(err, images.ErrUnsupported) || errors.Is(err, images.ErrTooLarge) {
+		return "", nil
+	}
+	return imageUrl, err
+}
+
+// timePtr returns nil for the zero time so it is left out of the JSON.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// timeValue dereferences an optional time, returning the zero time for nil.
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/storage"
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestWriteOpen verifies that a written archive can be opened again with its data and covers intact.
+func TestWriteOpen(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocal(t.TempDir())
+	testutil.NoError(t, err)
+	var cover bytes.Buffer
+	testutil.NoError(t, png.Encode(&cover, image.NewRGBA(image.Rect(0, 0, 2, 3))))
+	testutil.NoError(t, store.Put(ctx, "1-dune.png", bytes.NewReader(cover.Bytes())))
+	testutil.NoError(t, store.Put(ctx, "2-emma.html", strings.NewReader("<script>alert(1)</script>")))
+
+	finished := time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)
+	data := models.Backup{
+		Books: []models.BackupBook{
+			{ID: 7, Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Status: "finished", ImageURL: "/uploads/1-dune.png", Publisher: "Ace", PageCount: 412, Progress: 100, FinishedAt: finished},
+			{ID: 9, Title: "The Hobbit", Author: "J.R.R. Tolkien", Status: "reading", ImageURL: "/uploads/missing.jpg"},
+			{ID: 11, Title: "Emma", Author: "Jane Austen", Status: "reading", ImageURL: "/uploads/2-emma.html"},
+		},
+		Reviews: []models.BackupReview{{BookId: 7, Rating: 5, ReviewText: "Great"}},
+		Notes:   []models.BackupNote{{BookId: 9, NoteText: "Riddles", PageNumber: 70}},
+	}
+
+	var buf bytes.Buffer
+	testutil.NoError(t, Write(ctx, &buf, "reader", data, store))
+
+	archive, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	testutil.NoError(t, err)
+	testutil.Equal(t, archive.Version, Version)
+	testutil.Equal(t, archive.Username, "reader")
+	testutil.Equal(t, len(archive.Backup.Books), 3)
+	testutil.Equal(t, archive.Backup.Books[0].ImageURL, "covers/1-dune.png")
+	testutil.Equal(t, archive.Backup.Books[0].FinishedAt, finished)
+	testutil.Equal(t, archive.Backup.Books[0].Publisher, "Ace")
+	testutil.Equal(t, archive.Backup.Books[0].Progress, 100)
+	testutil.Equal(t, archive.Backup.Books[0].PageCount, 412)
+	testutil.Equal(t, archive.Backup.Books[1].ImageURL, "")
+	testutil.Equal(t, archive.Backup.Reviews[0].ReviewText, "Great")
+	testutil.Equal(t, archive.Backup.Notes[0].PageNumber, 70)
+
+	// Covers are processed like uploaded covers, and those that are not an image are left out.
+	var saved int
+	save := func(ctx context.Context, r io.Reader) (string, error) {
+		img, err := images.Process(r)
+		if err != nil {
+			return "", err
+		}
+		saved++
+		return storage.URLPrefix + "restored" + img.Ext, nil
+	}
+	url, err := archive.SaveCover(ctx, archive.Backup.Books[0], save)
+	testutil.NoError(t, err)
+	testutil.Equal(t, url, "/uploads/restored.png")
+	url, err = archive.SaveCover(ctx, archive.Backup.Books[2], save)
+	testutil.NoError(t, err)
+	testutil.Equal(t, url, "")
+	testutil.Equal(t, saved, 1)
+}
+
+// TestOpen_Version ensures archives from newer versions and foreign zip files are rejected.
+func TestOpen_Version(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  error
+	}{
+		{name: "newer version", manifest: `{"app":"go-bookreview","version":99}`, wantErr: ErrUnsupportedVersion},
+		{name: "other app", manifest: `{"app":"other","version":1}`, wantErr: ErrInvalidArchive},
+		{name: "broken manifest", manifest: `{`, wantErr: ErrInvalidArchive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			w, err := zw.Create(manifestFile)
+			testutil.NoError(t, err)
+			_, err = w.Write([]byte(tt.manifest))
+			testutil.NoError(t, err)
+			testutil.NoError(t, zw.Close())
+
+			_, err = Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			testutil.Equal(t, errors.Is(err, tt.wantErr), true)
+		})
+	}
+}
+
+// TestOpen_Version1 ensures archives written before reading progress was added can still be restored.
+func TestOpen_Version1(t *testing.T) {
+	files := map[string]string{
+		manifestFile: `{"app":"go-bookreview","version":1,"username":"reader"}`,
+		booksFile:    `[{"id":7,"title":"Dune","author":"Frank Herbert","status":"reading"}]`,
+		reviewsFile:  `[]`,
+		notesFile:    `[]`,
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		testutil.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		testutil.NoError(t, err)
+	}
+	testutil.NoError(t, zw.Close())
+
+	archive, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	testutil.NoError(t, err)
+	testutil.Equal(t, archive.Version, 1)
+	testutil.Equal(t, len(archive.Backup.Books), 1)
+	testutil.Equal(t, archive.Backup.Books[0].Status, "reading")
+	testutil.Equal(t, archive.Backup.Books[0].Progress, 0)
+}
@@ -0,0 +1,211 @@
+// Package barcode finds and decodes EAN-13 barcodes, such as the ISBN on the back cover of a book, in photos.
+package barcode
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// ErrNotFound is returned when no barcode could be read in an image.
+var ErrNotFound = errors.New("barcode: no EAN-13 barcode found")
+
+// maxSide is the largest width or height images are scanned at; larger photos are scaled down first,
+// which also evens out the noise of their sensor.
+const maxSide = 1600
+
+// Barcodes are looked for along scan lines across the whole image, every angleStep degrees so rotated
+// barcodes are found too, with lines lines at every angle.
+const (
+	angleStep = 10
+	lines     = 48
+)
+
+// A barcode is only returned when at least minVotes scan lines read it, as a single read may be a misread.
+// The scan stops early once enoughVotes scan lines agree.
+const (
+	minVotes    = 2
+	enoughVotes = 3
+)
+
+// maxVariance is the largest difference between the measured widths of a digit and those of its pattern,
+// relative to the width of the digit, that is still read as the digit.
+const maxVariance = 0.4
+
+// Widths of the bars and spaces of the digits, in modules. Digits on the left are encoded with the L or
+// the G patterns, starting with a space, and those on the right with the L patterns, starting with a bar.
+var (
+	lPatterns = [10][4]float64{
+		{3, 2, 1, 1}, {2, 2, 2, 1}, {2, 1, 2, 2}, {1, 4, 1, 1}, {1, 1, 3, 2},
+		{1, 2, 3, 1}, {1, 1, 1, 4}, {1, 3, 1, 2}, {1, 2, 1, 3}, {3, 1, 1, 2},
+	}
+	gPatterns = [10][4]float64{
+		{1, 1, 2, 3}, {1, 2, 2, 2}, {2, 2, 1, 2}, {1, 1, 4, 1}, {2, 3, 1, 1},
+		{1, 3, 2, 1}, {4, 1, 1, 1}, {2, 1, 3, 1}, {3, 1, 2, 1}, {2, 1, 1, 3},
+	}
+)
+
+// firstDigits maps the patterns of the digits on the left, G for those encoded with a G pattern,
+// to the first digit of the barcode, which is not encoded with bars of its own.
+var firstDigits = map[string]byte{
+	"LLLLLL": '0', "LLGLGG": '1', "LLGGLG": '2', "LLGGGL": '3', "LGLLGG": '4',
+	"LGGLLG": '5', "LGGGLL": '6', "LGLGLG": '7', "LGLGGL": '8', "LGGLGL": '9',
+}
+
+// Decode returns the 13 digits of the EAN-13 barcode in img, or ErrNotFound if none could be read.
+// When scan lines read different barcodes, the one read by the most lines wins.
+func Decode(img image.Image) (string, error) {
+	g := newGray(img)
+
+	votes := make(map[string]int)
+	var order []string
+	for angle := 0; angle < 180; angle += angleStep {
+		for _, profile := range g.profiles(float64(angle) * math.Pi / 180) {
+			code, ok := decodeProfile(profile)
+			if !ok {
+				continue
+			}
+			if votes[code] == 0 {
+				order = append(order, code)
+			}
+			votes[code]++
+			if votes[code] >= enoughVotes {
+				return code, nil
+			}
+		}
+	}
+
+	best := ""
+	for _, code := range order {
+		if votes[code] > votes[best] {
+			best = code
+		}
+	}
+	if votes[best] < minVotes {
+		return "", ErrNotFound
+	}
+	return best, nil
+}
+
+// decodeProfile reads a barcode from the brightness of the pixels along a scan line, in either direction.
+func decodeProfile(profile []float64) (string, bool) {
+	widths, bars := runs(profile)
+	if code, ok := decodeRuns(widths, bars); ok {
+		return code, true
+	}
+
+	for i, j := 0, len(widths)-1; i < j; i, j = i+1, j-1 {
+		widths[i], widths[j] = widths[j], widths[i]
+		bars[i], bars[j] = bars[j], bars[i]
+	}
+	return decodeRuns(widths, bars)
+}
+
+// decodeRuns looks for a barcode in the widths of the bars and spaces along a scan line.
+// A barcode is a start guard, six digits, a middle guard, six more digits and an end guard:
+// 59 bars and spaces 95 modules wide, with a quiet zone on either side.
+func decodeRuns(widths []float64, bars []bool) (string, bool) {
+	for i := 1; i+59 < len(widths); i++ {
+		if !bars[i] {
+			continue
+		}
+
+		total := 0.0
+		for _, w := range widths[i : i+59] {
+			total += w
+		}
+		module := total / 95
+		if widths[i-1] < 3*module || widths[i+59] < 3*module {
+			continue
+		}
+		if !guard(widths[i:i+3], module) || !guard(widths[i+27:i+32], module) || !guard(widths[i+56:i+59], module) {
+			continue
+		}
+
+		code := make([]byte, 13)
+		parity := make([]byte, 6)
+		ok := true
+		for d := 0; d < 12 && ok; d++ {
+			start := i + 3 + 4*d
+			if d >= 6 {
+				start = i + 32 + 4*(d-6)
+			}
+			digit, g, variance := matchDigit(widths[start:start+4], d < 6)
+			if variance > maxVariance {
+				ok = false
+				break
+			}
+			code[d+1] = '0' + byte(digit)
+			if d < 6 {
+				parity[d] = 'L'
+				if g {
+					parity[d] = 'G'
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		first, found := firstDigits[string(parity)]
+		if !found {
+			continue
+		}
+		code[0] = first
+		if checksum(code) {
+			return string(code), true
+		}
+	}
+	return "", false
+}
+
+// guard reports whether bars and spaces are each about one module wide, as in the guards of a barcode.
+func guard(widths []float64, module float64) bool {
+	total := 0.0
+	for _, w := range widths {
+		if w < 0.4*module || w > 2*module {
+			return false
+		}
+		total += w
+	}
+	n := float64(len(widths))
+	return total > 0.7*n*module && total < 1.3*n*module
+}
+
+// matchDigit returns the digit whose pattern the widths of the bars and spaces of a digit match best,
+// whether it is a G pattern, and how much the widths differ from it. Only digits on the left may have G patterns.
+func matchDigit(widths []float64, left bool) (int, bool, float64) {
+	total := widths[0] + widths[1] + widths[2] + widths[3]
+	best, bestG, bestVariance := 0, false, math.Inf(1)
+
+	try := func(patterns *[10][4]float64, g bool) {
+		for digit, pattern := range patterns {
+			variance := 0.0
+			for k, w := range widths {
+				variance += math.Abs(w - pattern[k]*total/7)
+			}
+			variance /= total
+			if variance < bestVariance {
+				best, bestG, bestVariance = digit, g, variance
+			}
+		}
+	}
+	try(&lPatterns, false)
+	if left {
+		try(&gPatterns, true)
+	}
+	return best, bestG, bestVariance
+}
+
+// checksum reports whether the last digit of a barcode is the check digit of the others.
+func checksum(code []byte) bool {
+	sum := 0
+	for i, c := range code {
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,101 @@
+package barcode
+
+import (
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestDecode tests reading the barcodes of the fixture images, drawn by testdata/gen.go: straight, rotated by
+// any angle, blurred and noisy as in photos taken with a phone, and a page without a barcode.
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+		err  error
+	}{
+		{name: "Straight", file: "straight.png", want: "9780441013593"},
+		{name: "Rotated", file: "rotated.jpg", want: "9780141439587"},
+		{name: "Upside down", file: "upside-down.jpg", want: "9780261102217"},
+		{name: "Vertical", file: "vertical.jpg", want: "9781593279288"},
+		{name: "Blurred", file: "blurred.jpg", want: "9780306406157"},
+		{name: "Tilted and small", file: "tilted.jpg", want: "9780140449136"},
+		{name: "Not a book", file: "product.png", want: "4006381333931"},
+		{name: "No barcode", file: "no-barcode.jpg", err: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			testutil.NoError(t, err)
+			defer f.Close()
+			img, _, err := image.Decode(f)
+			testutil.NoError(t, err)
+
+			code, err := Decode(img)
+			if tt.err != nil {
+				testutil.Equal(t, errors.Is(err, tt.err), true)
+				return
+			}
+			testutil.NoError(t, err)
+			testutil.Equal(t, code, tt.want)
+		})
+	}
+}
+
+// TestDecodeRuns tests that a barcode is read from the widths of its bars and spaces in either direction,
+// and that a wrong check digit is refused.
+func TestDecodeRuns(t *testing.T) {
+	widths, bars := modules("9780441013593")
+	code, ok := decodeRuns(widths, bars)
+	testutil.Equal(t, ok, true)
+	testutil.Equal(t, code, "9780441013593")
+
+	// Modules 1.3 times as wide as drawn, with bars spread into the spaces as ink does.
+	for i := range widths {
+		widths[i] *= 1.3
+		if bars[i] {
+			widths[i] += 0.4
+		} else {
+			widths[i] -= 0.4
+		}
+	}
+	code, ok = decodeRuns(widths, bars)
+	testutil.Equal(t, ok, true)
+	testutil.Equal(t, code, "9780441013593")
+
+	widths, bars = modules("9780441013594")
+	_, ok = decodeRuns(widths, bars)
+	testutil.Equal(t, ok, false)
+}
+
+// modules returns the widths of the bars and spaces of the barcode of code, one module wide each,
+// with quiet zones on either side.
+func modules(code string) ([]float64, []bool) {
+	widths := []float64{10, 1, 1, 1}
+	parity := []string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+	for i := 1; i <= 12; i++ {
+		pattern := lPatterns[code[i]-'0']
+		if i <= 6 && parity[code[0]-'0'][i-1] == 'G' {
+			pattern = gPatterns[code[i]-'0']
+		}
+		widths = append(widths, pattern[:]...)
+		if i == 6 {
+			widths = append(widths, 1, 1, 1, 1, 1)
+		}
+	}
+	widths = append(widths, 1, 1, 1, 10)
+
+	bars := make([]bool, len(widths))
+	for i := range bars {
+		bars[i] = i%2 == 1
+	}
+	return widths, bars
+}
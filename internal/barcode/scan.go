@@ -0,0 +1,193 @@
+package barcode
+
+import (
+	"image"
+	"math"
+)
+
+// minContrast is the least difference in brightness between a bar and the spaces next to it. Smaller differences
+// are taken for noise, while a fraction of the contrast of a scan line still counts, as blur lightens narrow bars.
+const minContrast = 8
+
+// gray is an image as the brightness of its pixels, from 0 for black to 255 for white.
+type gray struct {
+	w, h int
+	pix  []float64
+}
+
+// newGray returns the brightness of the pixels of img, scaled down by averaging blocks of pixels so that
+// neither side is longer than maxSide.
+func newGray(img image.Image) *gray {
+	b := img.Bounds()
+	factor := (max(b.Dx(), b.Dy()) + maxSide - 1) / maxSide
+	factor = max(factor, 1)
+
+	g := &gray{w: b.Dx() / factor, h: b.Dy() / factor}
+	g.pix = make([]float64, g.w*g.h)
+	rgba, _ := img.(*image.RGBA)
+
+	for y := 0; y < g.h*factor; y++ {
+		for x := 0; x < g.w*factor; x++ {
+			var r, gr, bl uint32
+			if rgba != nil {
+				i := rgba.PixOffset(b.Min.X+x, b.Min.Y+y)
+				r, gr, bl = uint32(rgba.Pix[i])<<8, uint32(rgba.Pix[i+1])<<8, uint32(rgba.Pix[i+2])<<8
+			} else {
+				r, gr, bl, _ = img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			}
+			g.pix[(y/factor)*g.w+x/factor] += (0.299*float64(r) + 0.587*float64(gr) + 0.114*float64(bl)) / 257
+		}
+	}
+
+	area := float64(factor * factor)
+	for i := range g.pix {
+		g.pix[i] /= area
+	}
+	return g
+}
+
+// at returns the brightness at a point between pixels, interpolated from the four pixels around it.
+func (g *gray) at(x, y float64) float64 {
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, g.w-1), min(y0+1, g.h-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	top := g.pix[y0*g.w+x0]*(1-fx) + g.pix[y0*g.w+x1]*fx
+	bottom := g.pix[y1*g.w+x0]*(1-fx) + g.pix[y1*g.w+x1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// profiles returns the brightness along parallel scan lines at an angle across the whole image,
+// sampled a pixel apart.
+func (g *gray) profiles(angle float64) [][]float64 {
+	dx, dy := math.Cos(angle), math.Sin(angle)
+	cx, cy := float64(g.w-1)/2, float64(g.h-1)/2
+	radius := math.Hypot(float64(g.w), float64(g.h)) / 2
+	spacing := 2 * radius / lines
+
+	var profiles [][]float64
+	for offset := -radius + spacing/2; offset < radius; offset += spacing {
+		ox, oy := cx-offset*dy, cy+offset*dx
+
+		var profile []float64
+		for t := -radius; t <= radius; t++ {
+			x, y := ox+t*dx, oy+t*dy
+			if x < 0 || y < 0 || x > float64(g.w-1) || y > float64(g.h-1) {
+				continue
+			}
+			profile = append(profile, g.at(x, y))
+		}
+		if len(profile) >= 95 {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+// extremum is the darkest point of a bar or the brightest point of a space along a scan line.
+type extremum struct {
+	pos  int
+	v    float64
+	dark bool
+}
+
+// runs returns the widths of the bars and spaces along a scan line and which of them are bars, between the edges
+// found by edgeThreshold.
+func runs(profile []float64) ([]float64, []bool) {
+	smooth := make([]float64, len(profile))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range profile {
+		prev, next := profile[max(i-1, 0)], profile[min(i+1, len(profile)-1)]
+		smooth[i] = (prev + 6*profile[i] + next) / 8
+		lo, hi = min(lo, smooth[i]), max(hi, smooth[i])
+	}
+	delta := max(minContrast, (hi-lo)/14)
+
+	extrema := findExtrema(smooth, delta)
+	if len(extrema) < 2 {
+		return nil, nil
+	}
+
+	edges := make([]float64, len(extrema)-1)
+	for k := range edges {
+		a, b := extrema[k], extrema[k+1]
+		threshold := edgeThreshold(extrema, k)
+		edges[k] = float64(b.pos)
+		for i := a.pos; i < b.pos; i++ {
+			if (smooth[i]-threshold)*(smooth[i+1]-threshold) <= 0 && smooth[i] != smooth[i+1] {
+				edges[k] = float64(i) + (threshold-smooth[i])/(smooth[i+1]-smooth[i])
+				break
+			}
+		}
+	}
+
+	// The first and the last run reach the ends of the scan line.
+	widths := make([]float64, len(extrema))
+	bars := make([]bool, len(extrema))
+	for k, e := range extrema {
+		start, end := 0.0, float64(len(smooth))
+		if k > 0 {
+			start = edges[k-1]
+		}
+		if k < len(edges) {
+			end = edges[k]
+		}
+		widths[k] = end - start
+		bars[k] = e.dark
+	}
+	return widths, bars
+}
+
+// edgeThreshold returns the brightness at the edge between the extrema k and k+1: halfway between the darkest
+// bar and the brightest space nearby, as that is where an edge blurred by the lens is. Narrow bars and spaces do not
+// get as dark or bright as wide ones, so the threshold is kept between their extrema, or they would be lost.
+func edgeThreshold(extrema []extremum, k int) float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, e := range extrema[max(k-3, 0):min(k+5, len(extrema))] {
+		if e.dark {
+			lo = min(lo, e.v)
+		} else {
+			hi = max(hi, e.v)
+		}
+	}
+
+	a, b := min(extrema[k].v, extrema[k+1].v), max(extrema[k].v, extrema[k+1].v)
+	return min(max((lo+hi)/2, a+(b-a)/4), b-(b-a)/4)
+}
+
+// findExtrema returns the alternating darkest and brightest points along a scan line, ignoring changes in
+// brightness smaller than delta.
+func findExtrema(p []float64, delta float64) []extremum {
+	var extrema []extremum
+	lo, hi := p[0], p[0]
+	loPos, hiPos := 0, 0
+
+	// dir is 1 while looking for the brightest point after a bar, -1 for the darkest point after a space,
+	// and 0 until the first change.
+	dir := 0
+	for i, v := range p {
+		if v > hi {
+			hi, hiPos = v, i
+		}
+		if v < lo {
+			lo, loPos = v, i
+		}
+
+		switch {
+		case dir >= 0 && hi-v > delta:
+			extrema = append(extrema, extremum{pos: hiPos, v: hi})
+			dir, lo, loPos = -1, v, i
+		case dir <= 0 && v-lo > delta:
+			extrema = append(extrema, extremum{pos: loPos, v: lo, dark: true})
+			dir, hi, hiPos = 1, v, i
+		}
+	}
+
+	switch dir {
+	case 1:
+		extrema = append(extrema, extremum{pos: hiPos, v: hi})
+	case -1:
+		extrema = append(extrema, extremum{pos: loPos, v: lo, dark: true})
+	}
+	return extrema
+}
@@ -0,0 +1,202 @@
+//go:build ignore
+
+// gen draws the fixture images of the barcode tests: barcodes on a page with lines of text, photographed at an
+// angle, out of focus and with the noise of a phone camera. Run it from the package directory with
+// go run testdata/gen.go.
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fixture describes an image to draw.
+type fixture struct {
+	name   string
+	code   string
+	module float64
+	angle  float64
+	blur   float64
+	noise  float64
+	ink    float64
+	paper  float64
+}
+
+var fixtures = []fixture{
+	{name: "straight.png", code: "9780441013593", module: 3, ink: 20, paper: 240},
+	{name: "rotated.jpg", code: "9780141439587", module: 3, angle: 33, blur: 0.6, noise: 4, ink: 30, paper: 230},
+	{name: "upside-down.jpg", code: "9780261102217", module: 2.5, angle: 188, blur: 0.6, noise: 4, ink: 30, paper: 230},
+	{name: "vertical.jpg", code: "9781593279288", module: 3, angle: 90, blur: 0.8, noise: 5, ink: 40, paper: 220},
+	{name: "blurred.jpg", code: "9780306406157", module: 3.5, angle: -12, blur: 2, noise: 6, ink: 70, paper: 200},
+	{name: "tilted.jpg", code: "9780140449136", module: 2.5, angle: 47, blur: 1.4, noise: 6, ink: 60, paper: 210},
+	{name: "product.png", code: "4006381333931", module: 3, ink: 20, paper: 240},
+	{name: "no-barcode.jpg", module: 3, angle: 20, blur: 0.8, noise: 5, ink: 30, paper: 230},
+}
+
+// Patterns of the digits, as in the decoder, with 1 for a bar module.
+var (
+	lCodes = []string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+	parity = []string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+)
+
+const width, height = 640, 480
+
+func main() {
+	for _, f := range fixtures {
+		rng := rand.New(rand.NewSource(int64(len(f.name))))
+		page := draw(f, rng)
+		if f.blur > 0 {
+			page = blur(page, f.blur)
+		}
+
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for i, v := range page {
+			v += rng.NormFloat64() * f.noise
+			img.Pix[i] = uint8(math.Max(0, math.Min(255, v)))
+		}
+
+		out, err := os.Create(filepath.Join("testdata", f.name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if strings.HasSuffix(f.name, ".png") {
+			err = png.Encode(out, img)
+		} else {
+			err = jpeg.Encode(out, img, &jpeg.Options{Quality: 80})
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// modules returns the 95 modules of the barcode of code.
+func modules(code string) string {
+	first := code[0] - '0'
+	m := "101"
+	for i := 1; i <= 6; i++ {
+		c := lCodes[code[i]-'0']
+		if parity[first][i-1] == 'G' {
+			c = reverse(invert(c))
+		}
+		m += c
+	}
+	m += "01010"
+	for i := 7; i <= 12; i++ {
+		m += invert(lCodes[code[i]-'0'])
+	}
+	return m + "101"
+}
+
+// draw returns the brightness of the page: the barcode in the middle, turned by the angle of the fixture,
+// and blocks of text around it.
+func draw(f fixture, rng *rand.Rand) []float64 {
+	var bars string
+	if f.code != "" {
+		bars = modules(f.code)
+	}
+	barWidth := 95 * f.module
+	barHeight := 60 * f.module
+
+	// Lines of text are blocks of random words.
+	type word struct{ x0, y0, x1, y1 float64 }
+	var words []word
+	for y := -220.0; y < 220; y += 18 {
+		for x := -300.0; x < 300; {
+			w := 10 + rng.Float64()*40
+			if math.Abs(y) > barHeight/2+20 || math.Abs(x) > barWidth/2+40 && math.Abs(x+w) > barWidth/2+40 {
+				words = append(words, word{x, y, x + w, y + 9})
+			}
+			x += w + 8
+		}
+	}
+
+	sin, cos := math.Sincos(f.angle * math.Pi / 180)
+	page := make([]float64, width*height)
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			// Supersample every pixel, so edges are smooth as in a photo.
+			sum := 0.0
+			for sy := 0; sy < 3; sy++ {
+				for sx := 0; sx < 3; sx++ {
+					dx := float64(px) + (float64(sx)+0.5)/3 - width/2
+					dy := float64(py) + (float64(sy)+0.5)/3 - height/2
+					x, y := dx*cos+dy*sin, -dx*sin+dy*cos
+
+					dark := false
+					if bars != "" && math.Abs(y) < barHeight/2 && x >= -barWidth/2 && x < barWidth/2 {
+						dark = bars[int((x+barWidth/2)/f.module)] == '1'
+					}
+					for _, w := range words {
+						if x >= w.x0 && x < w.x1 && y >= w.y0 && y < w.y1 && int(x)%3 != 0 {
+							dark = true
+						}
+					}
+					if dark {
+						sum += f.ink
+					} else {
+						sum += f.paper
+					}
+				}
+			}
+			page[py*width+px] = sum / 9
+		}
+	}
+	return page
+}
+
+// blur blurs the page with a Gaussian of the given standard deviation, as a lens out of focus does.
+func blur(page []float64, sigma float64) []float64 {
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	total := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+
+	pass := func(src []float64, dx, dy int) []float64 {
+		dst := make([]float64, len(src))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := 0.0
+				for i, k := range kernel {
+					sx := min(max(x+(i-radius)*dx, 0), width-1)
+					sy := min(max(y+(i-radius)*dy, 0), height-1)
+					v += k * src[sy*width+sx]
+				}
+				dst[y*width+x] = v
+			}
+		}
+		return dst
+	}
+	return pass(pass(page, 1, 0), 0, 1)
+}
+
+// invert swaps bars and spaces.
+func invert(s string) string {
+	return strings.Map(func(r rune) rune { return '0' + '1' - r }, s)
+}
+
+// reverse reverses the order of the modules.
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,213 @@
+// Package calibre reads the books of a Calibre library directory from its metadata.db database.
+package calibre
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	_ "github.com/mattn/go-sqlite3"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// metadataFile is the name of the database Calibre keeps at the root of a library directory.
+const metadataFile = "metadata.db"
+
+// coverFile is the name of the cover image Calibre stores in the directory of each book.
+const coverFile = "cover.jpg"
+
+// ErrNotLibrary is returned when a directory does not contain a Calibre metadata.db file.
+var ErrNotLibrary = errors.New("calibre: not a calibre library")
+
+// Book represents a book of a Calibre library.
+type Book struct {
+	UUID            string
+	Title           string
+	Authors         []string
+	ISBN            string
+	PublicationYear int
+	Tags            []string
+	LastModified    string
+	CoverPath       string
+}
+
+// Author returns the authors of the book joined the way Calibre displays them.
+func (b Book) Author() string {
+	return strings.Join(b.Authors, " & ")
+}
+
+// ImportBooks converts Calibre books into books that can be imported into a user's library.
+func ImportBooks(books []Book) []models.CalibreBook {
+	result := make([]models.CalibreBook, 0, len(books))
+	for _, b := range books {
+		result = append(result, models.CalibreBook{
+			UUID:            b.UUID,
+			Title:           strings.TrimSpace(b.Title),
+			Author:          b.Author(),
+			ISBN:            b.ISBN,
+			PublicationYear: b.PublicationYear,
+			Tags:            b.Tags,
+			LastModified:    b.LastModified,
+			CoverPath:       b.CoverPath,
+		})
+	}
+	return result
+}
+
+// CopyCover saves the cover of a Calibre book with save, which processes it like an uploaded cover, and returns
+// its image URL. Covers that cannot be processed are left out. It is meant to be passed to CalibreModel.Import.
+func CopyCover(ctx context.Context, book models.CalibreBook, save func(ctx context.Context, r io.Reader) (string, error)) (string, error) {
+	src, err := os.Open(book.CoverPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	imageUrl, err := save(ctx, src)
+	if errors.Is(err, images.ErrUnsupported) || errors.Is(err, images.ErrTooLarge) {
+		return "", nil
+	}
+	return imageUrl, err
+}
+
+// Library is an opened Calibre library.
+type Library struct {
+	dir string
+	db  *sql.DB
+}
+
+// Open opens the Calibre library in dir. The metadata database is opened read-only,
+// so the library can be imported while Calibre is running.
+func Open(dir string) (*Library, error) {
+	path, err := filepath.Abs(filepath.Join(dir, metadataFile))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s has no %s", ErrNotLibrary, dir, metadataFile)
+		}
+		return nil, err
+	}
+
+	dsn := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path), RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Library{dir: dir, db: db}, nil
+}
+
+// Close closes the metadata database.
+func (l *Library) Close() error {
+	return l.db.Close()
+}
+
+// Books returns every book of the library ordered by title, with its authors, ISBN, tags and cover path.
+// Calibre stores unknown publication dates as the year 101, which is reported as 0.
+func (l *Library) Books() ([]Book, error) {
+	stmt := `
+		SELECT b.id, COALESCE(b.uuid, ''), b.title, COALESCE(b.isbn, ''), CAST(substr(COALESCE(b.pubdate, ''), 1, 4) AS INTEGER),
+		       CAST(COALESCE(b.last_modified, '') AS TEXT), b.path, b.has_cover,
+		       COALESCE((SELECT val FROM identifiers WHERE book = b.id AND type = 'isbn' LIMIT 1), '')
+		FROM books b
+		ORDER BY b.sort, b.id`
+
+	rows, err := l.db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	var books []Book
+	for rows.Next() {
+		var id int
+		var book Book
+		var path, identifier string
+		var hasCover bool
+		err := rows.Scan(&id, &book.UUID, &book.Title, &book.ISBN, &book.PublicationYear, &book.LastModified, &path, &hasCover, &identifier)
+		if err != nil {
+			return nil, err
+		}
+
+		if identifier != "" {
+			book.ISBN = identifier
+		}
+		book.ISBN = normalizeISBN(book.ISBN)
+		if book.PublicationYear <= 101 {
+			book.PublicationYear = 0
+		}
+		if hasCover {
+			cover := filepath.Join(l.dir, filepath.FromSlash(path), coverFile)
+			if _, err := os.Stat(cover); err == nil {
+				book.CoverPath = cover
+			}
+		}
+
+		ids = append(ids, id)
+		books = append(books, book)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	authors, err := l.names(`SELECT l.book, a.name FROM books_authors_link l JOIN authors a ON a.id = l.author ORDER BY l.book, l.id`)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := l.names(`SELECT l.book, t.name FROM books_tags_link l JOIN tags t ON t.id = l.tag ORDER BY l.book, t.name`)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		books[i].Authors = authors[id]
+		books[i].Tags = tags[id]
+	}
+	return books, nil
+}
+
+// names runs a query returning book IDs and names and groups the names by book.
+func (l *Library) names(stmt string) (map[int][]string, error) {
+	rows, err := l.db.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[int][]string)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = append(names[id], name)
+	}
+	return names, rows.Err()
+}
+
+// normalizeISBN removes separators from an ISBN, keeping digits and the X check digit.
+func normalizeISBN(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package calibre
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// newTestLibrary creates a Calibre library directory with a reduced metadata.db schema and two books.
+func newTestLibrary(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, metadataFile))
+	testutil.NoError(t, err)
+	defer db.Close()
+
+	statements := []string{
+		`CREATE TABLE books (id INTEGER PRIMARY KEY, title TEXT NOT NULL DEFAULT 'Unknown', sort TEXT,
+			timestamp TIMESTAMP, pubdate TIMESTAMP DEFAULT CURRENT_TIMESTAMP, isbn TEXT DEFAULT '', path TEXT NOT NULL DEFAULT '',
+			uuid TEXT, has_cover BOOL DEFAULT 0, last_modified TIMESTAMP NOT NULL DEFAULT '2000-01-01 00:00:00+00:00')`,
+		`CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT NOT NULL, sort TEXT)`,
+		`CREATE TABLE books_authors_link (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, author INTEGER NOT NULL)`,
+		`CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`,
+		`CREATE TABLE books_tags_link (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, tag INTEGER NOT NULL)`,
+		`CREATE TABLE identifiers (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, type TEXT NOT NULL DEFAULT 'isbn', val TEXT NOT NULL)`,
+		`INSERT INTO books (id, title, sort, pubdate, path, uuid, has_cover, last_modified) VALUES
+			(1, 'Good Omens', 'Good Omens', '1990-05-01 00:00:00+00:00', 'Terry Pratchett/Good Omens (1)', 'uuid-1', 1, '2024-01-01 10:00:00+00:00'),
+			(2, 'Dune', 'Dune', '0101-01-01 00:00:00+00:00', 'Frank Herbert/Dune (2)', 'uuid-2', 0, '2024-01-02 10:00:00+00:00')`,
+		`INSERT INTO authors (id, name) VALUES (1, 'Terry Pratchett'), (2, 'Neil Gaiman'), (3, 'Frank Herbert')`,
+		`INSERT INTO books_authors_link (book, author) VALUES (1, 1), (1, 2), (2, 3)`,
+		`INSERT INTO tags (id, name) VALUES (1, 'Fantasy'), (2, 'Humor'), (3, 'Science Fiction')`,
+		`INSERT INTO books_tags_link (book, tag) VALUES (1, 2), (1, 1), (2, 3)`,
+		`INSERT INTO identifiers (book, type, val) VALUES (1, 'goodreads', '12067'), (2, 'isbn', '978-0-441-01359-3')`,
+	}
+	for _, stmt := range statements {
+		_, err := db.Exec(stmt)
+		testutil.NoError(t, err)
+	}
+
+	bookDir := filepath.Join(dir, "Terry Pratchett", "Good Omens (1)")
+	testutil.NoError(t, os.MkdirAll(bookDir, 0755))
+	testutil.NoError(t, os.WriteFile(filepath.Join(bookDir, coverFile), []byte("cover"), 0644))
+	return dir
+}
+
+// TestLibrary_Books verifies that books are read with their authors, ISBN, tags and cover.
+func TestLibrary_Books(t *testing.T) {
+	dir := newTestLibrary(t)
+
+	library, err := Open(dir)
+	testutil.NoError(t, err)
+	defer library.Close()
+
+	books, err := library.Books()
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(books), 2)
+
+	dune, omens := books[0], books[1]
+	testutil.Equal(t, dune.Title, "Dune")
+	testutil.Equal(t, dune.ISBN, "9780441013593")
+	testutil.Equal(t, dune.PublicationYear, 0)
+	testutil.Equal(t, dune.CoverPath, "")
+
+	testutil.Equal(t, omens.UUID, "uuid-1")
+	testutil.Equal(t, omens.Author(), "Terry Pratchett & Neil Gaiman")
+	testutil.Equal(t, omens.ISBN, "")
+	testutil.Equal(t, omens.PublicationYear, 1990)
+	testutil.Equal(t, omens.LastModified, "2024-01-01 10:00:00+00:00")
+	testutil.Equal(t, len(omens.Tags), 2)
+	testutil.Equal(t, omens.Tags[0], "Fantasy")
+	testutil.Equal(t, omens.CoverPath, filepath.Join(dir, "Terry Pratchett", "Good Omens (1)", coverFile))
+
+	// The database is opened read-only and must reject writes.
+	_, err = library.db.Exec(`DELETE FROM books`)
+	testutil.Error(t, err)
+}
+
+// TestOpen_NotLibrary ensures directories without a metadata.db are rejected.
+func TestOpen_NotLibrary(t *testing.T) {
+	_, err := Open(t.TempDir())
+	testutil.Equal(t, errors.Is(err, ErrNotLibrary), true)
+}
+
+// TestCopyCover verifies that covers are saved through the given saver, and left out when they are not an image.
+func TestCopyCover(t *testing.T) {
+	dir := t.TempDir()
+	var cover bytes.Buffer
+	testutil.NoError(t, png.Encode(&cover, image.NewRGBA(image.Rect(0, 0, 2, 3))))
+	testutil.NoError(t, os.WriteFile(filepath.Join(dir, "cover.png"), cover.Bytes(), 0644))
+	testutil.NoError(t, os.WriteFile(filepath.Join(dir, "cover.html"), []byte("<script>alert(1)</script>"), 0644))
+
+	save := func(ctx context.Context, r io.Reader) (string, error) {
+		img, err := images.Process(r)
+		if err != nil {
+			return "", err
+		}
+		return "/uploads/cover" + img.Ext, nil
+	}
+
+	url, err := CopyCover(context.Background(), models.CalibreBook{CoverPath: filepath.Join(dir, "cover.png")}, save)
+	testutil.NoError(t, err)
+	testutil.Equal(t, url, "/uploads/cover.png")
+
+	url, err = CopyCover(context.Background(), models.CalibreBook{CoverPath: filepath.Join(dir, "cover.html")}, save)
+	testutil.NoError(t, err)
+	testutil.Equal(t, url, "")
+}
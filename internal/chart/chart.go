@@ -0,0 +1,149 @@
+// Package chart renders bar charts, line charts and calendar heatmaps to SVG, to be inlined in pages by templates
+// without any script. Every chart is an image with a title and a description for screen readers, and every bar,
+// point and day has a title of its own, read out and shown on hover.
+package chart
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Size of the bar and line charts, in the units of their view box. Charts scale to the width of their container.
+const (
+	width   = 600
+	height  = 200
+	padding = 24
+)
+
+// Colors of the charts, from the palette of the pages.
+const (
+	axisColor  = "#cbd5e1"
+	labelColor = "#475569"
+	fontSize   = 11
+)
+
+// Point is a value of a chart: a bar of a bar chart or a point of a line chart.
+type Point struct {
+	// Label is written under the bar or point, and Text is its title, such as "March: 2 books".
+	Label string
+	Value float64
+	Text  string
+}
+
+// svg writes the opening of a chart of the given size, with its title and description, to b.
+// id must be unique in the page, as the title and description are referred to by it.
+func svg(b *strings.Builder, id, title, description string, w, h int) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" role="img" aria-labelledby="%s-title %s-desc" font-family="sans-serif" font-size="%d">`+"\n",
+		w, h, escape(id), escape(id), fontSize)
+	fmt.Fprintf(b, `<title id="%s-title">%s</title>`+"\n", escape(id), escape(title))
+	fmt.Fprintf(b, `<desc id="%s-desc">%s</desc>`+"\n", escape(id), escape(description))
+}
+
+// escape escapes text for SVG.
+func escape(s string) string {
+	return template.HTMLEscapeString(s)
+}
+
+// num formats a number with at most one decimal.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
+
+// most returns the largest value of points, or 1 when there are none above 0, so that values can be scaled by it.
+func most(points []Point) float64 {
+	m := 0.0
+	for _, p := range points {
+		m = max(m, p.Value)
+	}
+	if m == 0 {
+		return 1
+	}
+	return m
+}
+
+// axis writes the baseline of a chart and the largest of its values at the top, to b.
+func axis(b *strings.Builder, top float64) {
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", padding, height-padding, width-padding, height-padding, axisColor)
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4 4"/>`+"\n", padding, padding, width-padding, padding, axisColor)
+	fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end" fill="%s">%s</text>`+"\n", padding-4, padding+4, labelColor, num(top))
+}
+
+// BarChart is a chart of values side by side, such as the books finished every month.
+type BarChart struct {
+	ID          string
+	Title       string
+	Description string
+	Color       string
+	Points      []Point
+}
+
+// SVG renders the bar chart.
+func (c BarChart) SVG() template.HTML {
+	var b strings.Builder
+	svg(&b, c.ID, c.Title, c.Description, width, height)
+
+	top := most(c.Points)
+	axis(&b, top)
+	if len(c.Points) > 0 {
+		slot := float64(width-2*padding) / float64(len(c.Points))
+		barWidth := slot * 0.7
+		for i, p := range c.Points {
+			x := padding + slot*float64(i) + (slot-barWidth)/2
+			barHeight := p.Value / top * (height - 2*padding)
+			y := height - padding - barHeight
+			fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" rx="2" fill="%s"><title>%s</title></rect>`+"\n",
+				num(x), num(y), num(barWidth), num(barHeight), escape(c.Color), escape(p.Text))
+			if p.Value > 0 {
+				fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="middle" fill="%s" aria-hidden="true">%s</text>`+"\n",
+					num(x+barWidth/2), num(y-4), labelColor, num(p.Value))
+			}
+			fmt.Fprintf(&b, `<text x="%s" y="%d" text-anchor="middle" fill="%s" aria-hidden="true">%s</text>`+"\n",
+				num(x+barWidth/2), height-padding+14, labelColor, escape(p.Label))
+		}
+	}
+	b.WriteString(`</svg>` + "\n")
+	return template.HTML(b.String())
+}
+
+// LineChart is a chart of values joined by a line, such as the books finished every year.
+type LineChart struct {
+	ID          string
+	Title       string
+	Description string
+	Color       string
+	Points      []Point
+}
+
+// SVG renders the line chart.
+func (c LineChart) SVG() template.HTML {
+	var b strings.Builder
+	svg(&b, c.ID, c.Title, c.Description, width, height)
+
+	top := most(c.Points)
+	axis(&b, top)
+	if len(c.Points) > 0 {
+		slot := float64(width-2*padding) / float64(len(c.Points))
+		xs := make([]float64, len(c.Points))
+		ys := make([]float64, len(c.Points))
+		var line []string
+		for i, p := range c.Points {
+			xs[i] = padding + slot*float64(i) + slot/2
+			ys[i] = height - padding - p.Value/top*(height-2*padding)
+			line = append(line, num(xs[i])+","+num(ys[i]))
+		}
+		if len(line) > 1 {
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(line, " "), escape(c.Color))
+		}
+		for i, p := range c.Points {
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="4" fill="%s"><title>%s</title></circle>`+"\n",
+				num(xs[i]), num(ys[i]), escape(c.Color), escape(p.Text))
+			fmt.Fprintf(&b, `<text x="%s" y="%d" text-anchor="middle" fill="%s" aria-hidden="true">%s</text>`+"\n",
+				num(xs[i]), height-padding+14, labelColor, escape(p.Label))
+		}
+	}
+	b.WriteString(`</svg>` + "\n")
+	return template.HTML(b.String())
+}
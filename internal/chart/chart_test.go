@@ -0,0 +1,83 @@
+package chart
+
+import (
+	"flag"
+	"html/template"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// update rewrites the golden files with the charts rendered, to be run with go test ./internal/chart -update.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// date returns midnight of a day in UTC.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// TestCharts tests that charts render the SVG of their golden files in testdata.
+func TestCharts(t *testing.T) {
+	months := []Point{
+		{Label: "Jan", Value: 2, Text: "January: 2 books"},
+		{Label: "Feb", Value: 0, Text: "February: 0 books"},
+		{Label: "Mar", Value: 1, Text: "March: 1 book"},
+	}
+
+	tests := []struct {
+		name  string
+		chart interface{ SVG() template.HTML }
+	}{
+		{name: "bar", chart: BarChart{ID: "months", Title: "Books per month", Description: "3 books finished in 2024.",
+			Color: "#14b8a6", Points: months}},
+		{name: "bar-empty", chart: BarChart{ID: "empty", Title: "Books per month", Description: "No books <yet> & none rated.",
+			Color: "#14b8a6"}},
+		{name: "line", chart: LineChart{ID: "years", Title: "Books per year", Description: "Books finished every year.",
+			Color: "#0d9488", Points: []Point{{Label: "2023", Value: 1, Text: "2023: 1 book"}, {Label: "2024", Value: 3, Text: "2024: 3 books"}}}},
+		{name: "line-single", chart: LineChart{ID: "year", Title: "Books per year", Description: "Books finished every year.",
+			Color: "#0d9488", Points: []Point{{Label: "2024", Value: 3, Text: "2024: 3 books"}}}},
+		{name: "heatmap", chart: Heatmap{ID: "days", Title: "Reading activity", Description: "Days with reading activity in early 2024.",
+			Start: date(2024, time.January, 1), End: date(2024, time.February, 10), Days: []Day{
+				{Date: date(2024, time.January, 5), Count: 1, Text: "5 Jan 2024: 1 activity"},
+				{Date: date(2024, time.January, 6), Count: 4, Text: "6 Jan 2024: 4 activities"},
+				{Date: date(2024, time.February, 1), Count: 2, Text: "1 Feb 2024: 2 activities"},
+				{Date: date(2024, time.March, 1), Count: 9, Text: "outside of the calendar"},
+			}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(tt.chart.SVG())
+			golden := filepath.Join("testdata", tt.name+".svg")
+			if *update {
+				testutil.NoError(t, os.WriteFile(golden, []byte(got), 0o644))
+			}
+
+			want, err := os.ReadFile(golden)
+			testutil.NoError(t, err)
+			testutil.Equal(t, got, string(want))
+		})
+	}
+}
+
+// TestLevel tests that the counts of days are colored relative to the most active day.
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		count, most, want int
+	}{
+		{count: 0, most: 0, want: 0},
+		{count: 0, most: 5, want: 0},
+		{count: 1, most: 8, want: 1},
+		{count: 3, most: 8, want: 2},
+		{count: 5, most: 8, want: 3},
+		{count: 8, most: 8, want: 4},
+		{count: 1, most: 1, want: 4},
+	}
+
+	for _, tt := range tests {
+		testutil.Equal(t, level(tt.count, tt.most), tt.want)
+	}
+}
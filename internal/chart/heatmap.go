@@ -0,0 +1,107 @@
+package chart
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+)
+
+// Layout of the heatmap: the size of a day and the gap between days, and the room for the labels of the weekdays
+// on the left, of the months on top and of the legend below.
+const (
+	cell      = 11
+	step      = cell + 2
+	left      = 30
+	top       = 18
+	legendRow = 22
+)
+
+// legendWidth is the width of the legend, from "Less" to "More".
+const legendWidth = 28 + len(levels)*step + 30
+
+// levels are the colors of days from no activity at all to the most active days.
+var levels = [5]string{"#e2e8f0", "#99f6e4", "#2dd4bf", "#0d9488", "#115e59"}
+
+// Day is the activity of a day of a heatmap.
+type Day struct {
+	Date  time.Time
+	Count int
+	// Text is the title of the day, such as "5 Jan 2024: 2 books"; the date alone when empty.
+	Text string
+}
+
+// Heatmap is a calendar of the days from Start to End, a column a week, colored by how active every day was.
+type Heatmap struct {
+	ID          string
+	Title       string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Days        []Day
+}
+
+// level returns the color level of a count of a day, from 0 for none to 4 for the most of any day.
+func level(count, most int) int {
+	if count <= 0 || most <= 0 {
+		return 0
+	}
+	return min(4, int(math.Ceil(4*float64(count)/float64(most))))
+}
+
+// SVG renders the heatmap. Weeks start on Sunday.
+func (h Heatmap) SVG() template.HTML {
+	start := time.Date(h.Start.Year(), h.Start.Month(), h.Start.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(h.End.Year(), h.End.Month(), h.End.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := make(map[string]Day, len(h.Days))
+	most := 0
+	for _, d := range h.Days {
+		key := d.Date.Format(time.DateOnly)
+		if key < start.Format(time.DateOnly) || key > end.Format(time.DateOnly) {
+			continue
+		}
+		days[key] = d
+		most = max(most, d.Count)
+	}
+	first := start.AddDate(0, 0, -int(start.Weekday()))
+	weeks := 1
+	if !end.Before(first) {
+		weeks = int(end.Sub(first).Hours()/24)/7 + 1
+	}
+	w, ht := max(left+weeks*step, left+legendWidth), top+7*step+legendRow
+
+	var b strings.Builder
+	svg(&b, h.ID, h.Title, h.Description, w, ht)
+
+	for i, name := range []string{"Mon", "Wed", "Fri"} {
+		fmt.Fprintf(&b, `<text x="0" y="%d" fill="%s" aria-hidden="true">%s</text>`+"\n", top+(2*i+1)*step+cell-1, labelColor, name)
+	}
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		week := int(day.Sub(first).Hours()/24) / 7
+		x, y := left+week*step, top+int(day.Weekday())*step
+		if day.Day() == 1 || day.Equal(start) && day.Day() < 24 {
+			fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s" aria-hidden="true">%s</text>`+"\n", x, top-6, labelColor, day.Format("Jan"))
+		}
+
+		d := days[day.Format(time.DateOnly)]
+		text := d.Text
+		if text == "" {
+			text = day.Format("2 Jan 2006")
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"><title>%s</title></rect>`+"\n",
+			x, y, cell, cell, levels[level(d.Count, most)], escape(text))
+	}
+
+	x := left + 28
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s" aria-hidden="true">Less</text>`+"\n", left, ht-legendRow/2+4, labelColor)
+	for i, color := range levels {
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s" aria-hidden="true"/>`+"\n", x+i*step, ht-legendRow/2-cell/2, cell, cell, color)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s" aria-hidden="true">More</text>`+"\n", x+len(levels)*step+2, ht-legendRow/2+4, labelColor)
+
+	b.WriteString(`</svg>` + "\n")
+	return template.HTML(b.String())
+}
@@ -0,0 +1,212 @@
+// Package covers downloads the covers of books that have an ISBN but no cover, in the background.
+package covers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCoverSize limits the size of a downloaded cover.
+const maxCoverSize = 10 << 20
+
+// batchSize is the number of books a round of downloads handles.
+const batchSize = 20
+
+// Backoff of failed downloads: covers that are missing are looked for again after a month,
+// while other failures are retried after an hour, doubling up to a week.
+const (
+	notFoundDelay = 30 * 24 * time.Hour
+	retryDelay    = time.Hour
+	maxRetryDelay = 7 * 24 * time.Hour
+)
+
+// errNotFound is returned when the cover provider has no cover for an ISBN.
+var errNotFound = errors.New("covers: no cover for this ISBN")
+
+// coverTypes holds the accepted image types.
+var coverTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// Store finds the books without a cover and records the outcome of downloads. It is implemented by models.CoverModel.
+type Store interface {
+	Pending(now time.Time, limit int) ([]models.CoverCandidate, error)
+	SetImage(bookId int, imageURL string) (bool, error)
+	RecordFailure(bookId, attempts int, nextAttempt time.Time, reason string) error
+}
+
+// Fetcher downloads the covers of books by ISBN from a cover provider.
+type Fetcher struct {
+	// URLTemplate is the URL of the cover of a book, with {isbn} standing for its ISBN.
+	URLTemplate string
+
+	Client *http.Client
+	Store  Store
+	Logger *slog.Logger
+
+	// Save processes and stores a downloaded cover the way uploaded covers are stored and returns its URL.
+	// Covers that are not needed after all are left to the upload sweeper.
+	Save func(ctx context.Context, r io.Reader) (string, error)
+
+	// Interval is the least time between two requests to the same host, and Poll the time between rounds.
+	Interval time.Duration
+	Poll     time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// Run downloads covers in rounds until ctx is done.
+func (f *Fetcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.Poll)
+	defer ticker.Stop()
+
+	for {
+		if _, err := f.FetchPending(ctx); err != nil && ctx.Err() == nil {
+			f.Logger.Error("cover download failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FetchPending downloads the covers of the books that are due and returns how many were set.
+// Failed downloads are recorded so they are retried later.
+func (f *Fetcher) FetchPending(ctx context.Context) (int, error) {
+	candidates, err := f.Store.Pending(time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	set := 0
+	for _, c := range candidates {
+		if ctx.Err() != nil {
+			return set, ctx.Err()
+		}
+
+		imageURL, err := f.fetch(ctx, c.ISBN)
+		if err != nil {
+			if ctx.Err() != nil {
+				return set, ctx.Err()
+			}
+			attempts := c.Attempts + 1
+			if err := f.Store.RecordFailure(c.BookId, attempts, time.Now().Add(backoff(attempts, err)), err.Error()); err != nil {
+				return set, err
+			}
+			continue
+		}
+
+		ok, err := f.Store.SetImage(c.BookId, imageURL)
+		if err != nil {
+			return set, err
+		}
+		if ok {
+			set++
+		}
+	}
+	return set, nil
+}
+
+// fetch downloads the cover of an ISBN and saves it, returning its URL.
+func (f *Fetcher) fetch(ctx context.Context, isbn string) (string, error) {
+	coverURL := strings.ReplaceAll(f.URLTemplate, "{isbn}", url.PathEscape(isbn))
+	u, err := url.Parse(coverURL)
+	if err != nil {
+		return "", err
+	}
+	if err := f.wait(ctx, u.Host); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, coverURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "go-bookreview")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("covers: provider responded with %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxCoverSize {
+		return "", fmt.Errorf("covers: cover larger than %d bytes", maxCoverSize)
+	}
+
+	// Providers answer with a placeholder, such as a blank GIF, when they have no cover.
+	if !coverTypes[http.DetectContentType(body)] {
+		return "", errNotFound
+	}
+	imageURL, err := f.Save(ctx, bytes.NewReader(body))
+	if errors.Is(err, images.ErrUnsupported) || errors.Is(err, images.ErrTooLarge) {
+		// The provider will keep sending the same image, so it is treated as no cover at all.
+		return "", fmt.Errorf("%w: %v", errNotFound, err)
+	}
+	return imageURL, err
+}
+
+// wait blocks until a request to host is allowed by the rate limit, or ctx is done.
+func (f *Fetcher) wait(ctx context.Context, host string) error {
+	f.mu.Lock()
+	if f.next == nil {
+		f.next = make(map[string]time.Time)
+	}
+	now := time.Now()
+	at := f.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	f.next[host] = at.Add(f.Interval)
+	f.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns how long to wait before trying again to download a cover after a number of failed attempts.
+func backoff(attempts int, err error) time.Duration {
+	if errors.Is(err, errNotFound) {
+		return notFoundDelay
+	}
+	delay := retryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,141 @@
+package covers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// failure is a failed download recorded by memoryStore.
+type failure struct {
+	attempts    int
+	nextAttempt time.Time
+}
+
+// memoryStore is a Store kept in memory.
+type memoryStore struct {
+	candidates []models.CoverCandidate
+	images     map[int]string
+	failures   map[int]failure
+	taken      map[int]bool
+}
+
+// Pending returns all candidates.
+func (s *memoryStore) Pending(time.Time, int) ([]models.CoverCandidate, error) {
+	return s.candidates, nil
+}
+
+// SetImage records the cover of a book, unless the book is taken.
+func (s *memoryStore) SetImage(bookId int, imageURL string) (bool, error) {
+	if s.taken[bookId] {
+		return false, nil
+	}
+	s.images[bookId] = imageURL
+	return true, nil
+}
+
+// RecordFailure records a failed download.
+func (s *memoryStore) RecordFailure(bookId, attempts int, nextAttempt time.Time, _ string) error {
+	s.failures[bookId] = failure{attempts: attempts, nextAttempt: nextAttempt}
+	return nil
+}
+
+// TestFetcher_FetchPending tests that covers are saved for the books the provider has one for, that missing covers
+// and placeholders are retried after a month, other failures with a growing delay, and that requests are spaced out.
+func TestFetcher_FetchPending(t *testing.T) {
+	var cover bytes.Buffer
+	testutil.NoError(t, png.Encode(&cover, image.NewRGBA(image.Rect(0, 0, 2, 3))))
+
+	var mu sync.Mutex
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/b/isbn/9780441013593-L.jpg", "/b/isbn/9780141439587-L.jpg":
+			_, _ = w.Write(cover.Bytes())
+		case "/b/isbn/9780261102217-L.jpg":
+			_, _ = w.Write([]byte("GIF89a\x01\x00\x01\x00"))
+		case "/b/isbn/9780000000002-L.jpg":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{
+		candidates: []models.CoverCandidate{
+			{BookId: 1, ISBN: "9780441013593"},
+			{BookId: 2, ISBN: "9780306406157"},
+			{BookId: 3, ISBN: "9780261102217"},
+			{BookId: 4, ISBN: "9780000000002", Attempts: 2},
+			{BookId: 5, ISBN: "9780141439587"},
+		},
+		images:   map[int]string{},
+		failures: map[int]failure{},
+		taken:    map[int]bool{5: true},
+	}
+
+	var saved []string
+	f := &Fetcher{
+		URLTemplate: srv.URL + "/b/isbn/{isbn}-L.jpg",
+		Client:      srv.Client(),
+		Store:       store,
+		Logger:      slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		Save: func(ctx context.Context, r io.Reader) (string, error) {
+			_, format, err := image.DecodeConfig(r)
+			if err != nil {
+				return "", err
+			}
+			saved = append(saved, format)
+			return fmt.Sprintf("/uploads/%d.%s", len(saved), format), nil
+		},
+		Interval: 20 * time.Millisecond,
+	}
+
+	start := time.Now()
+	set, err := f.FetchPending(context.Background())
+	elapsed := time.Since(start)
+	testutil.NoError(t, err)
+	testutil.Equal(t, set, 1)
+	testutil.Equal(t, store.images[1], "/uploads/1.png")
+	testutil.Equal(t, strings.Join(saved, ","), "png,png")
+	testutil.Equal(t, store.images[5], "")
+
+	testutil.Equal(t, store.failures[2].attempts, 1)
+	testutil.Equal(t, store.failures[2].nextAttempt.Sub(start) > 29*24*time.Hour, true)
+	testutil.Equal(t, store.failures[3].nextAttempt.Sub(start) > 29*24*time.Hour, true)
+	testutil.Equal(t, store.failures[4].attempts, 3)
+	testutil.Equal(t, store.failures[4].nextAttempt.Sub(start) > 4*time.Hour-time.Minute, true)
+	testutil.Equal(t, store.failures[4].nextAttempt.Sub(start) < 4*time.Hour+time.Minute, true)
+
+	// The five requests go to the same host, so the last one waits at least four intervals after the first.
+	testutil.Equal(t, requests, 5)
+	testutil.Equal(t, elapsed >= 4*f.Interval-5*time.Millisecond, true)
+}
+
+// TestBackoff tests that retries of failed downloads wait twice as long each time, up to a week.
+func TestBackoff(t *testing.T) {
+	testutil.Equal(t, backoff(1, errors.New("timeout")), time.Hour)
+	testutil.Equal(t, backoff(2, errors.New("timeout")), 2*time.Hour)
+	testutil.Equal(t, backoff(20, errors.New("timeout")), maxRetryDelay)
+	testutil.Equal(t, backoff(1, errNotFound), notFoundDelay)
+}
@@ -0,0 +1,406 @@
+// Package epub reads the metadata and cover image of EPUB files from their OPF package document.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// containerFile is the file every EPUB uses to point at its OPF package document.
+const containerFile = "META-INF/container.xml"
+
+// maxFiles limits the number of files an EPUB may contain.
+const maxFiles = 10000
+
+// maxXMLSize limits the uncompressed size of the container and package documents.
+const maxXMLSize = 4 << 20
+
+// maxCoverSize limits the uncompressed size of the cover image.
+const maxCoverSize = 10 << 20
+
+// maxRatio limits the compression ratio of the files that are read, rejecting zip bombs early.
+const maxRatio = 100
+
+var (
+	// ErrInvalidEPUB is returned when a file is not a readable EPUB.
+	ErrInvalidEPUB = errors.New("epub: invalid file")
+
+	// ErrTooLarge is returned when an EPUB exceeds one of the size or compression limits.
+	ErrTooLarge = errors.New("epub: file exceeds size limits")
+)
+
+// coverTypes holds the accepted cover media types.
+var coverTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// Book holds the metadata read from an EPUB package document.
+type Book struct {
+	Title           string
+	Authors         []string
+	ISBN            string
+	Language        string
+	Publisher       string
+	Description     string
+	PublicationYear int
+
+	cover []byte
+}
+
+// Author returns the authors of the book joined by commas.
+func (b *Book) Author() string {
+	return strings.Join(b.Authors, ", ")
+}
+
+// HasCover reports whether a JPEG or PNG cover image was found in the EPUB.
+func (b *Book) HasCover() bool {
+	return len(b.cover) > 0
+}
+
+// SaveCover saves the cover image with save, which processes it like an uploaded cover, and returns its image URL.
+// Returns an empty URL when the book has no cover, or one that cannot be processed.
+func (b *Book) SaveCover(ctx context.Context, save func(ctx context.Context, r io.Reader) (string, error)) (string, error) {
+	if !b.HasCover() {
+		return "", nil
+	}
+	imageUrl, err := save(ctx, bytes.NewReader(b.cover))
+	if errors.Is(err, images.ErrUnsupported) || errors.Is(err, images.ErrTooLarge) {
+		return "", nil
+	}
+	return imageUrl, err
+}
+
+// container is the META-INF/container.xml document.
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// opfCreator is a dc:creator element. EPUB 2 sets the role as an attribute, EPUB 3 through a refining meta.
+type opfCreator struct {
+	ID    string `xml:"id,attr"`
+	Role  string `xml:"http://www.idpf.org/2007/opf role,attr"`
+	Value string `xml:",chardata"`
+}
+
+// opfIdentifier is a dc:identifier element.
+type opfIdentifier struct {
+	Scheme string `xml:"http://www.idpf.org/2007/opf scheme,attr"`
+	Value  string `xml:",chardata"`
+}
+
+// opfMeta is a meta element of the package metadata, in either its EPUB 2 or EPUB 3 form.
+type opfMeta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// opfItem is an item of the package manifest.
+type opfItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// opfMetadata is the metadata element of the package document.
+type opfMetadata struct {
+	Titles       []string        `xml:"http://purl.org/dc/elements/1.1/ title"`
+	Creators     []opfCreator    `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Identifiers  []opfIdentifier `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+	Languages    []string        `xml:"http://purl.org/dc/elements/1.1/ language"`
+	Publishers   []string        `xml:"http://purl.org/dc/elements/1.1/ publisher"`
+	Descriptions []string        `xml:"http://purl.org/dc/elements/1.1/ description"`
+	Dates        []string        `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Metas        []opfMeta       `xml:"meta"`
+}
+
+// opfPackage is the OPF package document.
+type opfPackage struct {
+	Metadata opfMetadata `xml:"metadata"`
+	Items    []opfItem   `xml:"manifest>item"`
+}
+
+// Read parses the EPUB of the given size from r and returns its metadata and cover.
+// Only the container, the package document and the cover are decompressed, each with a size
+// and compression ratio limit, so a crafted archive cannot exhaust memory.
+func Read(r io.ReaderAt, size int64) (*Book, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEPUB, err)
+	}
+	if len(zr.File) > maxFiles {
+		return nil, fmt.Errorf("%w: more than %d files", ErrTooLarge, maxFiles)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var c container
+	if err := readXML(files, containerFile, &c); err != nil {
+		return nil, err
+	}
+	opfPath := ""
+	for _, rf := range c.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			opfPath = rf.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		return nil, fmt.Errorf("%w: no package document", ErrInvalidEPUB)
+	}
+
+	var pkg opfPackage
+	if err := readXML(files, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	book := &Book{
+		Title:           first(pkg.Metadata.Titles),
+		Authors:         authors(pkg),
+		ISBN:            isbn(pkg.Metadata.Identifiers),
+		Language:        first(pkg.Metadata.Languages),
+		Publisher:       first(pkg.Metadata.Publishers),
+		Description:     stripHTML(first(pkg.Metadata.Descriptions)),
+		PublicationYear: year(first(pkg.Metadata.Dates)),
+	}
+
+	if item, ok := coverItem(pkg); ok {
+		name, err := resolve(opfPath, item.Href)
+		if err != nil {
+			return nil, err
+		}
+		f, ok := files[name]
+		if !ok {
+			return book, nil
+		}
+		data, err := readFile(f, maxCoverSize)
+		if err != nil {
+			return nil, err
+		}
+		// The declared media type is not trusted; the cover is kept only when its content is JPEG or PNG.
+		if coverTypes[http.DetectContentType(data)] {
+			book.cover = data
+		}
+	}
+	return book, nil
+}
+
+// readXML decodes an XML file of the archive into v.
+func readXML(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrInvalidEPUB, name)
+	}
+	data, err := readFile(f, maxXMLSize)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidEPUB, name, err)
+	}
+	return nil
+}
+
+// readFile decompresses a file of the archive, refusing files larger than limit or compressed
+// more than maxRatio. The sizes in the zip headers can lie, so the limit is also enforced while reading.
+func readFile(f *zip.File, limit int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("%w: %s is too large", ErrTooLarge, f.Name)
+	}
+	if f.CompressedSize64 > 0 && f.UncompressedSize64/f.CompressedSize64 > maxRatio {
+		return nil, fmt.Errorf("%w: %s is compressed too much", ErrTooLarge, f.Name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEPUB, f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidEPUB, f.Name, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s is too large", ErrTooLarge, f.Name)
+	}
+	return data, nil
+}
+
+// resolve returns the archive path of a manifest href, which is relative to the package document.
+// Hrefs leaving the archive root are rejected.
+func resolve(opfPath, href string) (string, error) {
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+	name := path.Join(path.Dir(opfPath), href)
+	if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+		return "", fmt.Errorf("%w: invalid path %q", ErrInvalidEPUB, href)
+	}
+	return name, nil
+}
+
+// authors returns the creators of the book that are authors. Creators without a role are taken
+// to be authors; when every creator has another role, all of them are returned.
+func authors(pkg opfPackage) []string {
+	roles := make(map[string]string)
+	for _, m := range pkg.Metadata.Metas {
+		if m.Property == "role" && strings.HasPrefix(m.Refines, "#") {
+			roles[strings.TrimPrefix(m.Refines, "#")] = strings.TrimSpace(m.Value)
+		}
+	}
+
+	var result, all []string
+	for _, c := range pkg.Metadata.Creators {
+		name := collapse(c.Value)
+		if name == "" {
+			continue
+		}
+		all = append(all, name)
+		role := c.Role
+		if role == "" && c.ID != "" {
+			role = roles[c.ID]
+		}
+		if role == "" || role == "aut" {
+			result = append(result, name)
+		}
+	}
+	if len(result) == 0 {
+		return all
+	}
+	return result
+}
+
+// isbn returns the first identifier that is an ISBN, without separators.
+func isbn(identifiers []opfIdentifier) string {
+	for _, id := range identifiers {
+		value := strings.TrimSpace(id.Value)
+		lower := strings.ToLower(value)
+		switch {
+		case strings.EqualFold(id.Scheme, "isbn"):
+		case strings.HasPrefix(lower, "urn:isbn:"):
+			value = value[len("urn:isbn:"):]
+		case strings.HasPrefix(lower, "isbn:"):
+			value = value[len("isbn:"):]
+		default:
+			continue
+		}
+		if n := normalizeISBN(value); len(n) == 10 || len(n) == 13 {
+			return n
+		}
+	}
+	return ""
+}
+
+// normalizeISBN removes separators from an ISBN, keeping digits and the X check digit.
+func normalizeISBN(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		}
+	}
+	return b.String()
+}
+
+// coverItem finds the manifest item of the cover image: the EPUB 3 cover-image property,
+// the EPUB 2 cover meta, or an image item named cover.
+func coverItem(pkg opfPackage) (opfItem, bool) {
+	for _, item := range pkg.Items {
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") {
+			return item, true
+		}
+	}
+
+	ids := []string{"cover", "cover-image"}
+	for _, m := range pkg.Metadata.Metas {
+		if m.Name == "cover" && m.Content != "" {
+			ids = append([]string{m.Content}, ids...)
+			break
+		}
+	}
+	for _, id := range ids {
+		for _, item := range pkg.Items {
+			if item.ID == id && strings.HasPrefix(item.MediaType, "image/") {
+				return item, true
+			}
+		}
+	}
+	return opfItem{}, false
+}
+
+// year returns the year of a dc:date value such as 2005, 2005-04 or 2005-04-12T00:00:00Z.
+func year(date string) int {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return 0
+	}
+	y, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return y
+}
+
+var (
+	// tagRX matches the markup descriptions are often written in.
+	tagRX = regexp.MustCompile(`<[^>]*>`)
+
+	// breakRX matches the paragraph ends and line breaks of a description.
+	breakRX = regexp.MustCompile(`(?i)</p>|<br\s*/?>`)
+)
+
+// stripHTML turns an HTML description into plain text, keeping paragraph breaks.
+func stripHTML(s string) string {
+	s = breakRX.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(tagRX.ReplaceAllString(s, ""))
+
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = collapse(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// collapse trims a string and replaces runs of whitespace with a single space.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// first returns the first non-blank value, trimmed.
+func first(values []string) string {
+	for _, v := range values {
+		if v = collapse(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+const testContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+const testEPUB3 = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:0f1e2d3c</dc:identifier>
+    <dc:identifier>urn:isbn:978-0-441-01359-3</dc:identifier>
+    <dc:title>Dune</dc:title>
+    <dc:creator id="author">Frank Herbert</dc:creator>
+    <meta refines="#author" property="role" scheme="marc:relators">aut</meta>
+    <dc:creator id="translator">Someone Else</dc:creator>
+    <meta refines="#translator" property="role" scheme="marc:relators">trl</meta>
+    <dc:language>en</dc:language>
+    <dc:publisher>Ace</dc:publisher>
+    <dc:date>1965-08-01</dc:date>
+    <dc:description>&lt;p&gt;Set on the desert planet &lt;i&gt;Arrakis&lt;/i&gt;.&lt;/p&gt;&lt;p&gt;A classic &amp;amp; more.&lt;/p&gt;</dc:description>
+  </metadata>
+  <manifest>
+    <item id="img" href="images/cover%20art.png" media-type="image/png" properties="cover-image"/>
+  </manifest>
+</package>`
+
+const testEPUB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:opf="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>The Hobbit</dc:title>
+    <dc:creator opf:role="ill">Alan Lee</dc:creator>
+    <dc:creator opf:role="aut">J.R.R. Tolkien</dc:creator>
+    <dc:identifier opf:scheme="ISBN">0-261-10221-4</dc:identifier>
+    <meta name="cover" content="cover-jpg"/>
+  </metadata>
+  <manifest>
+    <item id="cover-jpg" href="../cover.jpg" media-type="image/jpeg"/>
+  </manifest>
+</package>`
+
+// pngData is the start of a PNG file, enough for content sniffing.
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// buildEPUB returns a zip archive with the given files.
+func buildEPUB(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		testutil.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		testutil.NoError(t, err)
+	}
+	testutil.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// TestRead verifies that EPUB 3 metadata and the cover image are read from the package document.
+func TestRead(t *testing.T) {
+	data := buildEPUB(t, map[string]string{
+		"mimetype":                   "application/epub+zip",
+		containerFile:                testContainer,
+		"OEBPS/content.opf":          testEPUB3,
+		"OEBPS/images/cover art.png": string(pngData),
+	})
+
+	book, err := Read(bytes.NewReader(data), int64(len(data)))
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Title, "Dune")
+	testutil.Equal(t, book.Author(), "Frank Herbert")
+	testutil.Equal(t, book.ISBN, "9780441013593")
+	testutil.Equal(t, book.Language, "en")
+	testutil.Equal(t, book.Publisher, "Ace")
+	testutil.Equal(t, book.PublicationYear, 1965)
+	testutil.Equal(t, book.Description, "Set on the desert planet Arrakis.\n\nA classic & more.")
+	testutil.Equal(t, book.HasCover(), true)
+
+	var saved []byte
+	url, err := book.SaveCover(context.Background(), func(ctx context.Context, r io.Reader) (string, error) {
+		saved, err = io.ReadAll(r)
+		return "/uploads/cover.png", err
+	})
+	testutil.NoError(t, err)
+	testutil.Equal(t, url, "/uploads/cover.png")
+	testutil.Equal(t, bytes.Equal(saved, pngData), true)
+}
+
+// TestRead_EPUB2 verifies creator roles, ISBN schemes and cover metas of EPUB 2 packages,
+// and that covers outside the archive root are rejected.
+func TestRead_EPUB2(t *testing.T) {
+	data := buildEPUB(t, map[string]string{
+		containerFile:       testContainer,
+		"OEBPS/content.opf": testEPUB2,
+	})
+
+	book, err := Read(bytes.NewReader(data), int64(len(data)))
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Title, "The Hobbit")
+	testutil.Equal(t, book.Author(), "J.R.R. Tolkien")
+	testutil.Equal(t, book.ISBN, "0261102214")
+	testutil.Equal(t, book.HasCover(), false)
+
+	escaping := strings.Replace(testEPUB2, "../cover.jpg", "../../cover.jpg", 1)
+	data = buildEPUB(t, map[string]string{
+		containerFile:       testContainer,
+		"OEBPS/content.opf": escaping,
+	})
+	_, err = Read(bytes.NewReader(data), int64(len(data)))
+	testutil.Equal(t, errors.Is(err, ErrInvalidEPUB), true)
+}
+
+// TestRead_Invalid ensures non-EPUB files and highly compressed files are rejected.
+func TestRead_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{
+			name: "not a zip",
+			data: []byte("plain text"),
+			want: ErrInvalidEPUB,
+		},
+		{
+			name: "missing container",
+			data: buildEPUB(t, map[string]string{"mimetype": "application/epub+zip"}),
+			want: ErrInvalidEPUB,
+		},
+		{
+			name: "compression bomb",
+			data: buildEPUB(t, map[string]string{
+				containerFile:       testContainer,
+				"OEBPS/content.opf": testEPUB3 + "<!--" + strings.Repeat(" ", 1<<20) + "-->",
+			}),
+			want: ErrTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(tt.data), int64(len(tt.data)))
+			testutil.Equal(t, errors.Is(err, tt.want), true)
+		})
+	}
+}
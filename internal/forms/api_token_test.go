@@ -0,0 +1,39 @@
+package forms
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+	"strings"
+	"testing"
+)
+
+// TestAPITokenForm_Validate tests the validation logic of the APITokenForm for valid, blank and overlong names.
+func TestAPITokenForm_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		form      APITokenForm
+		wantValid bool
+	}{
+		{
+			name:      "valid name",
+			form:      APITokenForm{Name: "KOReader"},
+			wantValid: true,
+		},
+		{
+			name:      "blank name",
+			form:      APITokenForm{Name: "   "},
+			wantValid: false,
+		},
+		{
+			name:      "name too long",
+			form:      APITokenForm{Name: strings.Repeat("a", 101)},
+			wantValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Validate()
+			testutil.Equal(t, tt.form.Valid(), tt.wantValid)
+		})
+	}
+}
@@ -4,12 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/madalinpopa/go-bookreview/internal/app"
-	"io"
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/rules"
 	"net/http"
-	"os"
-	"path/filepath"
-	"slices"
-	"time"
 )
 
 // UserLoginForm represents the structure for capturing user login data submitted via a form.
@@ -51,6 +49,10 @@ type BookForm struct {
 	ISBN            string `form:"isbn"`
 	PublicationYear int    `form:"publication_year"`
 	Status          string `form:"status"`
+	Publisher       string `form:"publisher"`
+	Language        string `form:"language"`
+	Description     string `form:"description"`
+	PageCount       int    `form:"page_count"`
 	ImageURL        string `form:"-"`
 	CurrentImageURL string `form:"-"`
 	Base            `form:"-"`
@@ -61,10 +63,11 @@ func (cb *BookForm) Validate() {
 	cb.CheckField(NotBlank(cb.Title), "title", "Title is required")
 	cb.CheckField(NotBlank(cb.Author), "author", "Author is required")
 	cb.CheckField(NotBlank(cb.ISBN), "isbn", "ISBN is required")
+	cb.CheckField(cb.PageCount >= 0, "page_count", "Number of pages cannot be negative")
 }
 
-// HandleFileUpload processes an uploaded image file, validates its type, and saves it to the server's upload directory.
-// Returns an error for invalid file types, missing files, or file handling issues.
+// HandleFileUpload processes an uploaded image file, validates its type by its content, and saves it with its resized
+// variants to the server's upload directory. Returns an error for invalid file types, missing files, or file handling issues.
 func (cb *BookForm) HandleFileUpload(app *app.App, r *http.Request) error {
 	existingImageUrl := r.FormValue("current_image_url")
 
@@ -87,40 +90,21 @@ func (cb *BookForm) HandleFileUpload(app *app.App, r *http.Request) error {
 	}()
 
 	if file != nil && fileHeader != nil {
-		acceptedTypes := []string{"image/jpeg", "image/png"}
-		fileType := fileHeader.Header.Get("Content-Type")
-		if !slices.Contains(acceptedTypes, fileType) {
-			cb.AddNonFieldError("Invalid file type")
-			return ErrInvalidFileType
-		}
-
-		filename := fmt.Sprintf("%d-%s", time.Now().UnixNano(), fileHeader.Filename)
-		imageUrl := fmt.Sprintf("/uploads/%s", filename)
-		fullPath := filepath.Join(app.Config.UploadDir, filename)
-
-		dst, err := os.Create(fullPath)
+		imageUrl, err := app.SaveImage(r.Context(), file)
 		if err != nil {
-			return err
-		}
-		defer func() {
-			if err := dst.Close(); err != nil {
-				app.Logger.Error(err.Error())
+			if errors.Is(err, images.ErrUnsupported) {
+				cb.AddNonFieldError("Invalid file type")
+				return ErrInvalidFileType
+			}
+			if errors.Is(err, images.ErrTooLarge) {
+				cb.AddNonFieldError("The image is too large")
+				return ErrInvalidFileType
 			}
-		}()
-
-		if _, err := io.Copy(dst, file); err != nil {
 			return err
 		}
 
+		// The old image may be the cover of other books too, so it is left to the upload sweeper.
 		cb.ImageURL = imageUrl
-
-		// delete old image
-		if existingImageUrl != "" {
-			oldPath := filepath.Join(app.Config.UploadDir, filepath.Base(existingImageUrl))
-			if err := os.Remove(oldPath); err != nil {
-				app.Logger.Error("Failed to remove old image: " + err.Error())
-			}
-		}
 	}
 
 	return nil
@@ -154,3 +138,107 @@ type BookNoteForm struct {
 func (bn *BookNoteForm) Validate() {
 	bn.CheckField(NotBlank(bn.NoteText), "note_text", "Note text is required")
 }
+
+// KindleMatchForm represents the manual matching step of a Kindle clippings import.
+// Titles, Authors and BookIds are parallel lists; a book ID of 0 leaves the title pending and -1 skips it.
+type KindleMatchForm struct {
+	Titles  []string `form:"title"`
+	Authors []string `form:"author"`
+	BookIds []int    `form:"book_id"`
+	Base    `form:"-"`
+}
+
+// Validate ensures every submitted title has an author and a matching book selection.
+func (km *KindleMatchForm) Validate() {
+	if len(km.Titles) != len(km.BookIds) || len(km.Titles) != len(km.Authors) {
+		km.AddNonFieldError("Every title needs a book selection.")
+	}
+	for i, title := range km.Titles {
+		km.CheckField(NotBlank(title), fmt.Sprintf("title_%d", i), "Title is required")
+	}
+}
+
+// APITokenForm represents the form data for creating or revoking an API token.
+type APITokenForm struct {
+	Id   int    `form:"id"`
+	Name string `form:"name"`
+	Base `form:"-"`
+}
+
+// Validate ensures the token has a name of at most 100 characters.
+func (at *APITokenForm) Validate() {
+	at.CheckField(NotBlank(at.Name), "name", "Name is required")
+	at.CheckField(MaxChars(at.Name, 100), "name", "Name must be at most 100 characters.")
+}
+
+// KosyncMatchForm represents the confirmation of which book a KOReader document is.
+// A book ID of -1 sets the document aside.
+type KosyncMatchForm struct {
+	Id     int `form:"id"`
+	BookId int `form:"book_id"`
+	Base   `form:"-"`
+}
+
+// Validate ensures a document and a book or the choice to set the document aside were submitted.
+func (km *KosyncMatchForm) Validate() {
+	km.CheckField(km.Id > 0, "id", "Document is required")
+	km.CheckField(km.BookId != 0, "book_id", "Book is required")
+}
+
+// SmartShelfForm represents the form data for saving a search or the filters of the book list as a smart shelf.
+// ImageURL is the cover the shelf keeps, filled in from the saved shelf rather than the request.
+type SmartShelfForm struct {
+	Id          int    `form:"id"`
+	Name        string `form:"name"`
+	Rule        string `form:"rule"`
+	Sort        string `form:"sort"`
+	RemoveImage bool   `form:"remove_image"`
+	ImageURL    string `form:"-"`
+	Base        `form:"-"`
+}
+
+// Validate ensures the shelf has a name of at most 100 characters, a known order and a rule without mistakes,
+// reporting the first mistake of the rule and where it is.
+func (ss *SmartShelfForm) Validate() {
+	ss.CheckField(NotBlank(ss.Name), "name", "Name is required")
+	ss.CheckField(MaxChars(ss.Name, 100), "name", "Name must be at most 100 characters.")
+	ss.CheckField(PermittedValue(ss.Sort, "", models.SortAdded, models.SortTitle, models.SortAuthor, models.SortYear, models.SortRating),
+		"sort", "Order is not valid")
+	if _, err := rules.Parse(ss.Rule); err != nil {
+		ss.AddFieldError("rule", err.Error())
+	}
+}
+
+// HandleFileUpload saves an uploaded cover of the shelf as a private image of the user, so it is only shown to them.
+// Without an upload the shelf keeps ImageURL. Returns an error for invalid file types or file handling issues.
+func (ss *SmartShelfForm) HandleFileUpload(app *app.App, r *http.Request, userId int) error {
+	file, _, err := r.FormFile("image_upload")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrFormBadRequest, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			app.Logger.Error(err.Error())
+		}
+	}()
+
+	imageUrl, err := app.SavePrivateImage(r.Context(), userId, file)
+	if err != nil {
+		if errors.Is(err, images.ErrUnsupported) {
+			ss.AddNonFieldError("Invalid file type")
+			return ErrInvalidFileType
+		}
+		if errors.Is(err, images.ErrTooLarge) {
+			ss.AddNonFieldError("The image is too large")
+			return ErrInvalidFileType
+		}
+		return err
+	}
+
+	// The old cover may be the cover of other shelves of the user too, so it is left to the upload sweeper.
+	ss.ImageURL = imageUrl
+	return nil
+}
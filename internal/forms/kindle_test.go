@@ -0,0 +1,68 @@
+package forms
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+	"testing"
+)
+
+// TestKindleMatchForm_Validate tests the validation logic of the KindleMatchForm for matched, mismatched and blank input.
+func TestKindleMatchForm_Validate(t *testing.T) {
+	tests := []struct {
+		name              string
+		form              KindleMatchForm
+		wantValid         bool
+		wantNonFieldError bool
+	}{
+		{
+			name: "valid matches",
+			form: KindleMatchForm{
+				Titles:  []string{"The Hobbit", "Dune"},
+				Authors: []string{"J.R.R. Tolkien", ""},
+				BookIds: []int{1, -1},
+			},
+			wantValid: true,
+		},
+		{
+			name:      "empty form",
+			form:      KindleMatchForm{},
+			wantValid: true,
+		},
+		{
+			name: "missing book selection",
+			form: KindleMatchForm{
+				Titles:  []string{"The Hobbit", "Dune"},
+				Authors: []string{"J.R.R. Tolkien", ""},
+				BookIds: []int{1},
+			},
+			wantValid:         false,
+			wantNonFieldError: true,
+		},
+		{
+			name: "missing author",
+			form: KindleMatchForm{
+				Titles:  []string{"The Hobbit", "Dune"},
+				Authors: []string{"J.R.R. Tolkien"},
+				BookIds: []int{1, -1},
+			},
+			wantValid:         false,
+			wantNonFieldError: true,
+		},
+		{
+			name: "blank title",
+			form: KindleMatchForm{
+				Titles:  []string{"  "},
+				Authors: []string{""},
+				BookIds: []int{1},
+			},
+			wantValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Validate()
+			testutil.Equal(t, tt.form.Valid(), tt.wantValid)
+			testutil.Equal(t, len(tt.form.NonFieldErrors) > 0, tt.wantNonFieldError)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package forms
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+	"testing"
+)
+
+// TestKosyncMatchForm_Validate tests the validation logic of the KosyncMatchForm for matched, ignored and incomplete documents.
+func TestKosyncMatchForm_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		form      KosyncMatchForm
+		wantValid bool
+	}{
+		{
+			name:      "matched to a book",
+			form:      KosyncMatchForm{Id: 1, BookId: 3},
+			wantValid: true,
+		},
+		{
+			name:      "set aside",
+			form:      KosyncMatchForm{Id: 1, BookId: -1},
+			wantValid: true,
+		},
+		{
+			name:      "no book chosen",
+			form:      KosyncMatchForm{Id: 1},
+			wantValid: false,
+		},
+		{
+			name:      "no document",
+			form:      KosyncMatchForm{BookId: 3},
+			wantValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Validate()
+			testutil.Equal(t, tt.form.Valid(), tt.wantValid)
+		})
+	}
+}
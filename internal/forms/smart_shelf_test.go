@@ -0,0 +1,59 @@
+package forms
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+	"strings"
+	"testing"
+)
+
+// TestSmartShelfForm_Validate tests the validation logic of the SmartShelfForm, reporting mistakes in rules
+// as errors of the rule field.
+func TestSmartShelfForm_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		form      SmartShelfForm
+		wantValid bool
+		wantRule  string
+	}{
+		{
+			name:      "valid shelf",
+			form:      SmartShelfForm{Name: "Unread sci-fi", Rule: "status:want_to_read tag:sci-fi year>2015 rating>=4", Sort: "rating"},
+			wantValid: true,
+		},
+		{
+			name:      "blank name",
+			form:      SmartShelfForm{Name: "   ", Rule: "tag:sci-fi"},
+			wantValid: false,
+		},
+		{
+			name:      "name too long",
+			form:      SmartShelfForm{Name: strings.Repeat("a", 101), Rule: "tag:sci-fi"},
+			wantValid: false,
+		},
+		{
+			name:      "unknown order",
+			form:      SmartShelfForm{Name: "Sci-fi", Rule: "tag:sci-fi", Sort: "random"},
+			wantValid: false,
+		},
+		{
+			name:      "blank rule",
+			form:      SmartShelfForm{Name: "Sci-fi"},
+			wantValid: false,
+			wantRule:  "The rule is empty at character 1",
+		},
+		{
+			name:      "mistake in the rule",
+			form:      SmartShelfForm{Name: "Sci-fi", Rule: "tag:sci-fi year>soon"},
+			wantValid: false,
+			wantRule:  "The year field needs a number from 0 to 9999 at character 17",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Validate()
+			testutil.Equal(t, tt.form.Valid(), tt.wantValid)
+			testutil.Equal(t, tt.form.FieldErrors["rule"], tt.wantRule)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package goodreads
+
+import (
+	"encoding/csv"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header lists the columns of a Goodreads library export in the order Goodreads writes them.
+var Header = []string{
+	"Book Id", "Title", "Author", "Author l-f", "Additional Authors", "ISBN", "ISBN13", "My Rating",
+	"Average Rating", "Publisher", "Binding", "Number of Pages", "Year Published", "Original Publication Year",
+	"Date Read", "Date Added", "Bookshelves", "Bookshelves with positions", "Exclusive Shelf", "My Review",
+	"Spoiler", "Private Notes", "Read Count", "Owned Copies",
+}
+
+// Writer writes library entries as a Goodreads compatible CSV file.
+type Writer struct {
+	csv         *csv.Writer
+	wroteHeader bool
+}
+
+// NewWriter returns a Writer that writes to w. Rows are buffered and passed on to w in small chunks.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{csv: csv.NewWriter(w)}
+}
+
+// Write writes a single library entry, preceded by the header for the first entry.
+func (w *Writer) Write(entry models.LibraryEntry) error {
+	if err := w.WriteHeader(); err != nil {
+		return err
+	}
+
+	isbn10, isbn13 := splitISBN(NormalizeISBN(entry.ISBN))
+	shelf := Shelf(entry.Status)
+	readCount := "0"
+	if shelf == ShelfRead {
+		readCount = "1"
+	}
+	year := ""
+	if entry.PublicationYear > 0 {
+		year = strconv.Itoa(entry.PublicationYear)
+	}
+	pages := ""
+	if entry.PageCount > 0 {
+		pages = strconv.Itoa(entry.PageCount)
+	}
+
+	// The Book Id column holds the ID of a book at Goodreads, which books here do not have, so it is left blank
+	// rather than filled with an ID Goodreads would take for one of its own books.
+	return w.csv.Write([]string{
+		"",
+		entry.Title,
+		entry.Author,
+		authorLastFirst(entry.Author),
+		"",
+		`="` + isbn10 + `"`,
+		`="` + isbn13 + `"`,
+		strconv.Itoa(entry.Rating),
+		"",
+		"",
+		"",
+		pages,
+		year,
+		year,
+		formatDate(entry.FinishedAt),
+		formatDate(entry.AddedAt),
+		shelfList(shelf),
+		"",
+		shelf,
+		strings.ReplaceAll(entry.Review, "\n", "<br/>"),
+		"",
+		"",
+		readCount,
+		"0",
+	})
+}
+
+// WriteHeader writes the column header unless it was already written.
+func (w *Writer) WriteHeader() error {
+	if w.wroteHeader {
+		return nil
+	}
+	w.wroteHeader = true
+	return w.csv.Write(Header)
+}
+
+// Flush writes any buffered rows to the underlying writer and reports any write error.
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// Shelf maps a reading status to the exclusive Goodreads shelf holding it.
+func Shelf(status string) string {
+	switch status {
+	case "finished":
+		return ShelfRead
+	case "reading":
+		return ShelfCurrentlyReading
+	default:
+		return ShelfToRead
+	}
+}
+
+// shelfList returns the value of the "Bookshelves" column, which leaves the read shelf implicit.
+func shelfList(shelf string) string {
+	if shelf == ShelfRead {
+		return ""
+	}
+	return shelf
+}
+
+// splitISBN returns the ISBN-10 and ISBN-13 forms of an ISBN, deriving the missing one where possible.
+func splitISBN(isbn string) (string, string) {
+	switch len(isbn) {
+	case 10:
+		return isbn, isbn10To13(isbn)
+	case 13:
+		if strings.HasPrefix(isbn, "978") {
+			return isbn13To10(isbn), isbn
+		}
+		return "", isbn
+	default:
+		return "", ""
+	}
+}
+
+// isbn10To13 converts an ISBN-10 into its ISBN-13 form with the 978 prefix.
+func isbn10To13(isbn string) string {
+	body := "978" + isbn[:9]
+	sum := 0
+	for i, r := range body {
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return body + strconv.Itoa((10-sum%10)%10)
+}
+
+// isbn13To10 converts an ISBN-13 with the 978 prefix into its ISBN-10 form.
+func isbn13To10(isbn string) string {
+	body := isbn[3:12]
+	sum := 0
+	for i, r := range body {
+		sum += (10 - i) * int(r-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X"
+	}
+	return body + strconv.Itoa(check)
+}
+
+// authorLastFirst turns "Frank Herbert" into "Herbert, Frank" as used by the "Author l-f" column.
+func authorLastFirst(author string) string {
+	author = strings.TrimSpace(author)
+	i := strings.LastIndex(author, " ")
+	if i < 0 {
+		return author
+	}
+	return author[i+1:] + ", " + author[:i]
+}
+
+// formatDate formats a date in the layout Goodreads uses, returning an empty string for the zero time.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateLayouts[0])
+}
@@ -0,0 +1,82 @@
+package goodreads
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestWriter verifies that written rows follow the Goodreads layout and can be read back by Parse.
+func TestWriter(t *testing.T) {
+	entries := []models.LibraryEntry{
+		{
+			BookId:          1,
+			Title:           "Dune",
+			Author:          "Frank Herbert",
+			ISBN:            "978-0-441-01359-3",
+			PublicationYear: 1965,
+			Status:          "finished",
+			Rating:          5,
+			Review:          "Great\nbook",
+			AddedAt:         time.Date(2023, time.December, 1, 10, 0, 0, 0, time.UTC),
+			FinishedAt:      time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{BookId: 2, Title: "The Hobbit", Author: "J.R.R. Tolkien", Status: "reading"},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, entry := range entries {
+		testutil.NoError(t, w.Write(entry))
+	}
+	testutil.NoError(t, w.Flush())
+
+	rows, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(rows), 3)
+	testutil.Equal(t, len(rows[0]), len(Header))
+	testutil.Equal(t, rows[1][0], "")
+	testutil.Equal(t, rows[1][3], "Herbert, Frank")
+	testutil.Equal(t, rows[1][5], `="0441013597"`)
+	testutil.Equal(t, rows[1][6], `="9780441013593"`)
+	testutil.Equal(t, rows[1][19], "Great<br/>book")
+	testutil.Equal(t, rows[2][5], `=""`)
+
+	records, err := Parse(bytes.NewReader(buf.Bytes()))
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(records), 2)
+	testutil.Equal(t, records[0].ISBN, "9780441013593")
+	testutil.Equal(t, records[0].Status(), "finished")
+	testutil.Equal(t, records[0].Review, "Great\nbook")
+	testutil.Equal(t, records[0].DateRead, entries[0].FinishedAt)
+	testutil.Equal(t, records[0].DateAdded, time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC))
+	testutil.Equal(t, records[1].Status(), "reading")
+}
+
+// TestSplitISBN checks that the missing ISBN form is derived with a valid check digit.
+func TestSplitISBN(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		isbn10 string
+		isbn13 string
+	}{
+		{name: "isbn13", input: "9780441013593", isbn10: "0441013597", isbn13: "9780441013593"},
+		{name: "isbn10", input: "0441013597", isbn10: "0441013597", isbn13: "9780441013593"},
+		{name: "check digit x", input: "9780804429573", isbn10: "080442957X", isbn13: "9780804429573"},
+		{name: "979 prefix", input: "9791032305690", isbn13: "9791032305690"},
+		{name: "invalid", input: "12345"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isbn10, isbn13 := splitISBN(tt.input)
+			testutil.Equal(t, isbn10, tt.isbn10)
+			testutil.Equal(t, isbn13, tt.isbn13)
+		})
+	}
+}
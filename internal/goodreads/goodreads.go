@@ -0,0 +1,195 @@
+// Package goodreads reads the library export CSV produced by Goodreads.
+package goodreads
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shelf names Goodreads uses for its exclusive shelves.
+const (
+	ShelfToRead           = "to-read"
+	ShelfCurrentlyReading = "currently-reading"
+	ShelfRead             = "read"
+)
+
+// dateLayouts lists the date formats found in the "Date Read" and "Date Added" columns.
+var dateLayouts = []string{"2006/01/02", "2006-01-02", "2006/01", "2006"}
+
+// tagRX matches the HTML tags Goodreads leaves in exported review text.
+var tagRX = regexp.MustCompile(`<[^>]*>`)
+
+// breakRX matches the line break tags used in exported review text.
+var breakRX = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+// ErrMissingColumn is returned when the CSV header lacks a column the import cannot work without.
+var ErrMissingColumn = errors.New("goodreads: missing required column")
+
+// Record represents a single book row of a Goodreads library export.
+type Record struct {
+	Line            int
+	GoodreadsId     string
+	Title           string
+	Author          string
+	ISBN            string
+	PublicationYear int
+	PageCount       int
+	Rating          int
+	Review          string
+	Shelf           string
+	DateRead        time.Time
+	DateAdded       time.Time
+}
+
+// Status maps the exclusive shelf of the record to a reading status.
+// Custom exclusive shelves are treated as books the user wants to read.
+func (r Record) Status() string {
+	switch r.Shelf {
+	case ShelfRead:
+		return "finished"
+	case ShelfCurrentlyReading:
+		return "reading"
+	default:
+		return "want_to_read"
+	}
+}
+
+// ImportBooks converts export records into books that can be imported into a user's library.
+func ImportBooks(records []Record) []models.ImportBook {
+	books := make([]models.ImportBook, 0, len(records))
+	for _, r := range records {
+		books = append(books, models.ImportBook{
+			Title:           r.Title,
+			Author:          r.Author,
+			ISBN:            r.ISBN,
+			PublicationYear: r.PublicationYear,
+			PageCount:       r.PageCount,
+			Status:          r.Status(),
+			Rating:          r.Rating,
+			Review:          r.Review,
+			AddedAt:         r.DateAdded,
+			FinishedAt:      r.DateRead,
+		})
+	}
+	return books
+}
+
+// Parse reads a Goodreads export and returns its rows in file order.
+// Columns are looked up by header name, so exports with extra or reordered columns are accepted.
+func Parse(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, "Title")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, name := range []string{"Title", "Author"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		record := Record{
+			Line:        line,
+			GoodreadsId: field(row, "Book Id"),
+			Title:       field(row, "Title"),
+			Author:      field(row, "Author"),
+			ISBN:        NormalizeISBN(field(row, "ISBN13")),
+			PageCount:   atoi(field(row, "Number of Pages")),
+			Rating:      atoi(field(row, "My Rating")),
+			Review:      cleanReview(field(row, "My Review")),
+			Shelf:       field(row, "Exclusive Shelf"),
+			DateRead:    parseDate(field(row, "Date Read")),
+			DateAdded:   parseDate(field(row, "Date Added")),
+		}
+		if record.ISBN == "" {
+			record.ISBN = NormalizeISBN(field(row, "ISBN"))
+		}
+		record.PublicationYear = atoi(field(row, "Original Publication Year"))
+		if record.PublicationYear == 0 {
+			record.PublicationYear = atoi(field(row, "Year Published"))
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// NormalizeISBN removes the spreadsheet formula quoting Goodreads wraps around ISBNs, e.g. ="0439023483",
+// together with hyphens and spaces. Returns an empty string when no ISBN is left.
+func NormalizeISBN(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "=")
+	s = strings.Trim(s, `"`)
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		}
+	}
+	return b.String()
+}
+
+// cleanReview turns the HTML line breaks of an exported review into newlines and drops any other markup.
+func cleanReview(s string) string {
+	s = breakRX.ReplaceAllString(s, "\n")
+	s = tagRX.ReplaceAllString(s, "")
+	return strings.TrimSpace(s)
+}
+
+// parseDate parses an export date, returning the zero time for empty or unknown values.
+func parseDate(s string) time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// atoi converts a numeric column, returning 0 for empty or invalid values.
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
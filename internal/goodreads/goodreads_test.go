@@ -0,0 +1,104 @@
+package goodreads
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestParse verifies that the columns of a Goodreads export are mapped onto records.
+func TestParse(t *testing.T) {
+	input := "\ufeffBook Id,Title,Author,ISBN,ISBN13,My Rating,Year Published,Original Publication Year,Date Read,Date Added,Exclusive Shelf,My Review\n" +
+		`2767052,"The Hunger Games (The Hunger Games, #1)",Suzanne Collins,"=""0439023483""","=""9780439023481""",4,2008,2008,2024/01/15,2023/12/01,read,"Loved it.<br/><br/>Would <b>read</b> again."` + "\n" +
+		`5907,The Hobbit,J.R.R. Tolkien,"=""""","=""""",0,2002,1937,,2024/02/03,currently-reading,` + "\n" +
+		`11,Dune,Frank Herbert,"=""0441013597""","=""""",0,2005,,,2024/02/04,did-not-finish,` + "\n"
+
+	records, err := Parse(strings.NewReader(input))
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(records), 3)
+
+	tests := []struct {
+		name      string
+		got       Record
+		title     string
+		isbn      string
+		year      int
+		rating    int
+		review    string
+		status    string
+		dateRead  time.Time
+		dateAdded time.Time
+	}{
+		{
+			name:      "finished with review",
+			got:       records[0],
+			title:     "The Hunger Games (The Hunger Games, #1)",
+			isbn:      "9780439023481",
+			year:      2008,
+			rating:    4,
+			review:    "Loved it.\n\nWould read again.",
+			status:    "finished",
+			dateRead:  time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC),
+			dateAdded: time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "no isbn uses original year",
+			got:       records[1],
+			title:     "The Hobbit",
+			year:      1937,
+			status:    "reading",
+			dateAdded: time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "isbn10 fallback and custom shelf",
+			got:       records[2],
+			title:     "Dune",
+			isbn:      "0441013597",
+			year:      2005,
+			status:    "want_to_read",
+			dateAdded: time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Equal(t, tt.got.Title, tt.title)
+			testutil.Equal(t, tt.got.ISBN, tt.isbn)
+			testutil.Equal(t, tt.got.PublicationYear, tt.year)
+			testutil.Equal(t, tt.got.Rating, tt.rating)
+			testutil.Equal(t, tt.got.Review, tt.review)
+			testutil.Equal(t, tt.got.Status(), tt.status)
+			testutil.Equal(t, tt.got.DateRead, tt.dateRead)
+			testutil.Equal(t, tt.got.DateAdded, tt.dateAdded)
+		})
+	}
+}
+
+// TestParse_MissingColumn ensures files that are not Goodreads exports are rejected.
+func TestParse_MissingColumn(t *testing.T) {
+	_, err := Parse(strings.NewReader("Name,Writer\nDune,Herbert\n"))
+	testutil.Equal(t, errors.Is(err, ErrMissingColumn), true)
+}
+
+// TestNormalizeISBN checks the removal of formula quoting and separators.
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "formula quoted", input: `="0439023483"`, want: "0439023483"},
+		{name: "empty formula", input: `=""`, want: ""},
+		{name: "hyphenated", input: "978-0-13-419044-0", want: "9780134190440"},
+		{name: "check digit x", input: `="080442957x"`, want: "080442957X"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Equal(t, NormalizeISBN(tt.input), tt.want)
+		})
+	}
+}
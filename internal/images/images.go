@@ -0,0 +1,221 @@
+// Package images decodes uploaded cover images, strips their metadata and resizes them into variants.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// MaxDimension is the largest width or height of an accepted image, and MaxPixels the largest area.
+// They are checked before an image is decoded, so small files that decode into huge images are refused.
+const (
+	MaxDimension = 8000
+	MaxPixels    = 40_000_000
+)
+
+// maxFileSize limits the size of an image file.
+const maxFileSize = 20 << 20
+
+// jpegQuality is the quality JPEG images are encoded with.
+const jpegQuality = 85
+
+// Widths are the widths of the variants made of every image, from the thumbnail shown on book cards
+// to the cover on the book page. Images are never enlarged, so narrower images get fewer variants.
+var Widths = []int{320, 800}
+
+var (
+	// ErrUnsupported is returned for files that are not JPEG or PNG images.
+	ErrUnsupported = errors.New("images: unsupported image format")
+
+	// ErrTooLarge is returned for images over MaxDimension, MaxPixels or the file size limit.
+	ErrTooLarge = errors.New("images: image too large")
+)
+
+// Image is an image that was decoded and encoded again, without the metadata of the original file.
+type Image struct {
+	// Ext is the file extension of the image format, ".jpg" or ".png".
+	Ext string
+
+	// Width and Height are the size of the image upright.
+	Width  int
+	Height int
+
+	// Original is the image in its full size.
+	Original []byte
+
+	// Variants are the smaller versions of the image by width.
+	Variants map[int][]byte
+}
+
+// Process reads a JPEG or PNG image, recognized by its content rather than its name or declared type,
+// turns it upright according to its EXIF orientation and encodes it again in its full size and in each
+// of Widths narrower than itself. Encoding drops all metadata, such as EXIF with the location of a photo.
+func Process(r io.Reader) (*Image, error) {
+	img, ext, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Image{
+		Ext:      ext,
+		Width:    img.Bounds().Dx(),
+		Height:   img.Bounds().Dy(),
+		Variants: make(map[int][]byte),
+	}
+	if result.Original, err = encode(img, ext); err != nil {
+		return nil, err
+	}
+	for _, width := range Widths {
+		if width >= result.Width {
+			continue
+		}
+		if result.Variants[width], err = encode(resize(img, width), ext); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Decode reads a JPEG or PNG image, recognized by its content, within the size limits and turns it upright
+// according to its EXIF orientation. It returns the image with the file extension of its format.
+func Decode(r io.Reader) (*image.RGBA, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxFileSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxFileSize {
+		return nil, "", ErrTooLarge
+	}
+
+	var ext string
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	default:
+		return nil, "", ErrUnsupported
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension || config.Width*config.Height > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	img := toRGBA(decoded)
+	if ext == ".jpg" {
+		img = orient(img, orientation(data))
+	}
+	return img, ext, nil
+}
+
+// VariantName returns the file name of the variant of width of the image stored as name.
+func VariantName(name string, width int) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%dw%s", strings.TrimSuffix(name, ext), width, ext)
+}
+
+// encode encodes an image in the format of ext.
+func encode(img image.Image, ext string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if ext == ".png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toRGBA converts an image to RGBA with its top left corner at the origin.
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// resize scales an image down to width, keeping its aspect ratio. Every pixel of the result is the
+// average of the pixels it covers, which keeps the detail of covers that are scaled down a lot.
+func resize(src *image.RGBA, width int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	height := max(1, int(math.Round(float64(h)*float64(width)/float64(w))))
+
+	// Scale the rows first, then the columns of the result.
+	cols := weights(w, width)
+	tmp := image.NewRGBA(image.Rect(0, 0, width, h))
+	for y := 0; y < h; y++ {
+		row := src.Pix[y*src.Stride:]
+		out := tmp.Pix[y*tmp.Stride:]
+		for x, c := range cols {
+			blend(out[x*4:], row, c, 4)
+		}
+	}
+
+	rows := weights(h, height)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, r := range rows {
+		out := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			blend(out[x*4:], tmp.Pix[x*4:], r, tmp.Stride)
+		}
+	}
+	return dst
+}
+
+// contribution is the part of the source pixels covered by a pixel of a scaled image.
+type contribution struct {
+	start   int
+	weights []float64
+}
+
+// weights returns for every pixel of a line scaled from n to m pixels which source pixels it covers and how much.
+func weights(n, m int) []contribution {
+	scale := float64(n) / float64(m)
+	contributions := make([]contribution, m)
+	for i := range contributions {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		c := contribution{start: int(lo)}
+		for j := c.start; j < n && float64(j) < hi; j++ {
+			overlap := math.Min(hi, float64(j+1)) - math.Max(lo, float64(j))
+			c.weights = append(c.weights, overlap/scale)
+		}
+		contributions[i] = c
+	}
+	return contributions
+}
+
+// blend writes to dst the weighted average of the pixels of src covered by c, which are step bytes apart.
+func blend(dst, src []byte, c contribution, step int) {
+	var sum [4]float64
+	for k, weight := range c.weights {
+		p := src[(c.start+k)*step:]
+		for i := range sum {
+			sum[i] += float64(p[i]) * weight
+		}
+	}
+	for i, v := range sum {
+		dst[i] = uint8(min(255, math.Round(v)))
+	}
+}
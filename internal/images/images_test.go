@@ -0,0 +1,154 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// testImage returns an image of the given size with a red top left corner on white.
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			if x < w/4 && y < h/4 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// withExif inserts an EXIF block with an orientation and a camera make into a JPEG file.
+func withExif(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(8))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(2))
+	// Make, ASCII, stored after the directory.
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{0x010F, 2})
+	_ = binary.Write(&tiff, binary.BigEndian, []uint32{8, 38})
+	// Orientation, SHORT.
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{orientationTag, 3})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString("Phone\x00\x00\x00")
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+// pngHeader returns the start of a PNG file that declares an image of the given size.
+func pngHeader(w, h uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	chunk := []byte("IHDR")
+	chunk = binary.BigEndian.AppendUint32(chunk, w)
+	chunk = binary.BigEndian.AppendUint32(chunk, h)
+	chunk = append(chunk, 8, 2, 0, 0, 0)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(chunk)-4))
+	buf.Write(chunk)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+// TestProcess tests that images are recognized by their content, stripped of metadata, turned upright and resized.
+func TestProcess(t *testing.T) {
+	var jpegFile bytes.Buffer
+	testutil.NoError(t, jpeg.Encode(&jpegFile, testImage(1200, 1600), nil))
+	var pngFile bytes.Buffer
+	testutil.NoError(t, png.Encode(&pngFile, testImage(600, 800)))
+	var gifFile bytes.Buffer
+	testutil.NoError(t, gif.Encode(&gifFile, testImage(60, 80), nil))
+
+	t.Run("JPEG with EXIF", func(t *testing.T) {
+		img, err := Process(bytes.NewReader(withExif(t, jpegFile.Bytes(), 1)))
+		testutil.NoError(t, err)
+		testutil.Equal(t, img.Ext, ".jpg")
+		testutil.Equal(t, img.Width, 1200)
+		testutil.Equal(t, img.Height, 1600)
+		testutil.Equal(t, bytes.Contains(img.Original, []byte("Exif")), false)
+		testutil.Equal(t, bytes.Contains(img.Original, []byte("Phone")), false)
+		testutil.Equal(t, len(img.Variants), 2)
+
+		thumb, err := jpeg.DecodeConfig(bytes.NewReader(img.Variants[320]))
+		testutil.NoError(t, err)
+		testutil.Equal(t, thumb.Width, 320)
+		testutil.Equal(t, thumb.Height, 427)
+	})
+
+	t.Run("rotated JPEG", func(t *testing.T) {
+		img, err := Process(bytes.NewReader(withExif(t, jpegFile.Bytes(), 6)))
+		testutil.NoError(t, err)
+		testutil.Equal(t, img.Width, 1600)
+		testutil.Equal(t, img.Height, 1200)
+
+		// The red corner was top left before turning the image clockwise, so it is top right now.
+		decoded, err := jpeg.Decode(bytes.NewReader(img.Original))
+		testutil.NoError(t, err)
+		r, g, _, _ := decoded.At(1550, 50).RGBA()
+		testutil.Equal(t, r>>8 > 200 && g>>8 < 50, true)
+	})
+
+	t.Run("PNG narrower than a variant", func(t *testing.T) {
+		img, err := Process(bytes.NewReader(pngFile.Bytes()))
+		testutil.NoError(t, err)
+		testutil.Equal(t, img.Ext, ".png")
+		testutil.Equal(t, len(img.Variants), 1)
+		_, err = png.Decode(bytes.NewReader(img.Variants[320]))
+		testutil.NoError(t, err)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := Process(bytes.NewReader(gifFile.Bytes()))
+		testutil.Equal(t, errors.Is(err, ErrUnsupported), true)
+		_, err = Process(bytes.NewReader([]byte("<html>not an image</html>")))
+		testutil.Equal(t, errors.Is(err, ErrUnsupported), true)
+	})
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		_, err := Process(bytes.NewReader(pngHeader(50000, 50000)))
+		testutil.Equal(t, errors.Is(err, ErrTooLarge), true)
+		_, err = Process(bytes.NewReader(pngHeader(MaxDimension, MaxDimension)))
+		testutil.Equal(t, errors.Is(err, ErrTooLarge), true)
+	})
+}
+
+// TestResize tests that scaled images keep their colors.
+func TestResize(t *testing.T) {
+	img := resize(testImage(1000, 1000), 100)
+	testutil.Equal(t, img.Bounds().Dx(), 100)
+	testutil.Equal(t, img.Bounds().Dy(), 100)
+	testutil.Equal(t, img.RGBAAt(10, 10), color.RGBA{R: 255, A: 255})
+	testutil.Equal(t, img.RGBAAt(80, 80), color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	img = resize(testImage(301, 7), 200)
+	testutil.Equal(t, img.Bounds().Dy(), 5)
+	testutil.Equal(t, img.RGBAAt(199, 4), color.RGBA{R: 255, G: 255, B: 255, A: 255})
+}
+
+// TestVariantName tests the file names of variants.
+func TestVariantName(t *testing.T) {
+	testutil.Equal(t, VariantName("/uploads/1-dune.jpg", 320), "/uploads/1-dune-320w.jpg")
+	testutil.Equal(t, VariantName("1-cover.png", 800), "1-cover-800w.png")
+}
@@ -0,0 +1,385 @@
+// Package kindle parses the "My Clippings.txt" file written by Kindle e-readers.
+package kindle
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Kind identifies the type of a clipping entry such as a highlight, a note or a bookmark.
+type Kind string
+
+const (
+	KindHighlight Kind = "highlight"
+	KindNote      Kind = "note"
+	KindBookmark  Kind = "bookmark"
+)
+
+// separator is the line Kindle devices write between two clipping entries.
+const separator = "=========="
+
+// Clipping represents a single highlight, note or bookmark parsed from a clippings file.
+type Clipping struct {
+	Title         string
+	Author        string
+	Kind          Kind
+	Page          int
+	LocationStart int
+	LocationEnd   int
+	AddedAt       time.Time
+	Text          string
+}
+
+// Hash returns a stable identifier for the clipping, used to skip entries that were already imported.
+// The added date is left out on purpose so the same highlight synced from another device is not imported twice.
+func (c Clipping) Hash() string {
+	key := strings.Join([]string{
+		strings.ToLower(c.Title),
+		strings.ToLower(c.Author),
+		string(c.Kind),
+		strconv.Itoa(c.Page),
+		strconv.Itoa(c.LocationStart),
+		strconv.Itoa(c.LocationEnd),
+		strings.Join(strings.Fields(c.Text), " "),
+	}, "\x1f")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Location returns the human-readable location of the clipping, such as "180-182", or an empty string if unknown.
+func (c Clipping) Location() string {
+	switch {
+	case c.LocationStart == 0:
+		return ""
+	case c.LocationEnd == 0 || c.LocationEnd == c.LocationStart:
+		return strconv.Itoa(c.LocationStart)
+	default:
+		return fmt.Sprintf("%d-%d", c.LocationStart, c.LocationEnd)
+	}
+}
+
+// Parse reads a Kindle clippings file and returns the entries it contains in file order.
+// Malformed entries are skipped, while read errors are returned to the caller.
+func Parse(r io.Reader) ([]Clipping, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var clippings []Clipping
+	var entry []string
+
+	flush := func() {
+		if c, ok := parseEntry(entry); ok {
+			clippings = append(clippings, c)
+		}
+		entry = entry[:0]
+	}
+
+	for scanner.Scan() {
+		line := cleanLine(scanner.Text())
+		if strings.TrimSpace(line) == separator {
+			flush()
+			continue
+		}
+		entry = append(entry, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return clippings, nil
+}
+
+// cleanLine removes byte order marks and carriage returns which Kindle devices write inconsistently.
+func cleanLine(line string) string {
+	line = strings.ReplaceAll(line, "\ufeff", "")
+	return strings.TrimRight(line, "\r")
+}
+
+// parseEntry converts the lines between two separators into a Clipping.
+func parseEntry(lines []string) (Clipping, bool) {
+	// Skip the leading blank lines left behind by the previous separator.
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) < 2 {
+		return Clipping{}, false
+	}
+
+	var c Clipping
+	c.Title, c.Author = parseTitleLine(lines[0])
+	if c.Title == "" {
+		return Clipping{}, false
+	}
+
+	if !parseMetaLine(lines[1], &c) {
+		return Clipping{}, false
+	}
+
+	c.Text = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	if c.Kind != KindBookmark && c.Text == "" {
+		return Clipping{}, false
+	}
+
+	return c, true
+}
+
+// parseTitleLine splits a title line such as "The Go Programming Language (Donovan, Alan)" into title and author.
+// The author is taken from the last parenthesised group, so parentheses inside the title are kept.
+func parseTitleLine(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if !strings.HasSuffix(line, ")") {
+		return line, ""
+	}
+
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				title := strings.TrimSpace(line[:i])
+				author := strings.TrimSpace(line[i+1 : len(line)-1])
+				if title == "" {
+					return line, ""
+				}
+				return title, author
+			}
+		}
+	}
+	return line, ""
+}
+
+var (
+	// kindWords maps the localized words used on the meta line to a clipping kind.
+	// Bookmarks and notes are checked before highlights because some languages share word stems.
+	kindWords = []struct {
+		kind  Kind
+		words []string
+	}{
+		{KindBookmark, []string{"bookmark", "lesezeichen", "signet", "marcador", "segnalibro", "bladwijzer", "ブックマーク", "书签"}},
+		{KindNote, []string{"note", "notiz", "nota", "notitie", "メモ", "笔记"}},
+		{KindHighlight, []string{"highlight", "markierung", "surlignement", "subrayado", "evidenziazione", "destaque", "markering", "ハイライト", "标注"}},
+	}
+
+	// pageWords and locationWords are the localized labels preceding page and location numbers.
+	pageWords     = []string{"page", "seite", "página", "pagina", "bladzijde", "ページ", "页"}
+	locationWords = []string{"location", "loc.", "position", "emplacement", "posición", "posizione", "posição", "locatie", "位置"}
+
+	// addedWords are the localized labels preceding the date a clipping was created.
+	addedWords = []string{"added", "hinzugefügt", "ajouté", "añadido", "aggiunto", "adicionado", "toegevoegd", "追加", "添加"}
+
+	// rangeRX matches a single number or a range such as "180-182", "180-82" or "180–182".
+	rangeRX = regexp.MustCompile(`(\d+)(?:\s*[-–]\s*(\d+))?`)
+)
+
+// parseMetaLine reads kind, page, location and date from a line such as
+// "- Your Highlight on page 12 | Location 180-182 | Added on Monday, 1 January 2024 12:00:00".
+func parseMetaLine(line string, c *Clipping) bool {
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "-"))
+	lower := strings.ToLower(line)
+
+	for _, kw := range kindWords {
+		if containsAny(firstSegment(lower), kw.words) {
+			c.Kind = kw.kind
+			break
+		}
+	}
+	if c.Kind == "" {
+		return false
+	}
+
+	for _, segment := range strings.Split(line, "|") {
+		segmentLower := strings.ToLower(segment)
+
+		if rest, ok := after(segmentLower, addedWords); ok {
+			c.AddedAt = parseDate(rest)
+			continue
+		}
+		if rest, ok := after(segmentLower, pageWords); ok {
+			if m := rangeRX.FindStringSubmatch(rest); m != nil {
+				c.Page, _ = strconv.Atoi(m[1])
+			}
+		}
+		if rest, ok := after(segmentLower, locationWords); ok {
+			if m := rangeRX.FindStringSubmatch(rest); m != nil {
+				c.LocationStart, _ = strconv.Atoi(m[1])
+				if m[2] != "" {
+					c.LocationEnd = expandRangeEnd(m[1], m[2])
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// expandRangeEnd resolves the abbreviated range ends written by older devices, where "1180-82" means 1180 to 1182.
+func expandRangeEnd(start, end string) int {
+	s, _ := strconv.Atoi(start)
+	e, _ := strconv.Atoi(end)
+	if e >= s || len(end) >= len(start) {
+		return e
+	}
+
+	e, _ = strconv.Atoi(start[:len(start)-len(end)] + end)
+	if e < s {
+		step, _ := strconv.Atoi("1" + strings.Repeat("0", len(end)))
+		e += step
+	}
+	return e
+}
+
+var (
+	// months maps localized month names to their numeric value.
+	months = map[string]time.Month{
+		"january": 1, "february": 2, "march": 3, "april": 4, "may": 5, "june": 6,
+		"july": 7, "august": 8, "september": 9, "october": 10, "november": 11, "december": 12,
+		"januar": 1, "februar": 2, "märz": 3, "maerz": 3, "mai": 5, "juni": 6,
+		"juli": 7, "oktober": 10, "dezember": 12,
+		"janvier": 1, "février": 2, "fevrier": 2, "mars": 3, "avril": 4, "juin": 6,
+		"juillet": 7, "août": 8, "aout": 8, "septembre": 9, "octobre": 10, "novembre": 11, "décembre": 12, "decembre": 12,
+		"enero": 1, "febrero": 2, "marzo": 3, "abril": 4, "mayo": 5, "junio": 6,
+		"julio": 7, "agosto": 8, "septiembre": 9, "setiembre": 9, "octubre": 10, "noviembre": 11, "diciembre": 12,
+		"gennaio": 1, "febbraio": 2, "aprile": 4, "maggio": 5, "giugno": 6,
+		"luglio": 7, "settembre": 9, "ottobre": 10, "dicembre": 12,
+		"janeiro": 1, "fevereiro": 2, "março": 3, "marco": 3, "maio": 5, "junho": 6,
+		"julho": 7, "setembro": 9, "outubro": 10, "novembro": 11, "dezembro": 12,
+		"januari": 1, "februari": 2, "maart": 3, "mei": 5, "augustus": 8,
+	}
+
+	// cjkDateRX matches the Japanese and Chinese date layout, e.g. "2024年1月1日".
+	cjkDateRX = regexp.MustCompile(`(\d{4})年(\d{1,2})月(\d{1,2})日`)
+
+	// clockRX matches a time of day such as "9:05" or "21:05:33".
+	clockRX = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?::(\d{2}))?$`)
+)
+
+// parseDate makes a best-effort attempt at reading the localized date written after the "Added on" label.
+// It returns the zero time when the date cannot be understood.
+func parseDate(s string) time.Time {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	var year, day, hour, minute, second int
+	var month time.Month
+	clock, pm, am := false, false, false
+
+	if m := cjkDateRX.FindStringSubmatch(s); m != nil {
+		year, _ = strconv.Atoi(m[1])
+		mo, _ := strconv.Atoi(m[2])
+		month = time.Month(mo)
+		day, _ = strconv.Atoi(m[3])
+		s = s[strings.Index(s, m[0])+len(m[0]):]
+	}
+
+	tokens := strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == '/'
+	})
+
+	for _, token := range tokens {
+		token = strings.TrimSuffix(token, ".")
+		switch {
+		case clockRX.MatchString(token):
+			m := clockRX.FindStringSubmatch(token)
+			hour, _ = strconv.Atoi(m[1])
+			minute, _ = strconv.Atoi(m[2])
+			second, _ = strconv.Atoi(m[3])
+			clock = true
+		// The meridiem markers are only meaningful after the time, since German writes "am Montag".
+		case clock && (token == "pm" || token == "p.m"):
+			pm = true
+		case clock && (token == "am" || token == "a.m"):
+			am = true
+		case months[token] != 0 && month == 0:
+			month = months[token]
+		case isDigits(token) && len(token) == 4 && year == 0:
+			year, _ = strconv.Atoi(token)
+		case isDigits(token) && len(token) <= 2 && day == 0:
+			day, _ = strconv.Atoi(token)
+		}
+	}
+
+	if pm && hour < 12 {
+		hour += 12
+	}
+	if am && hour == 12 {
+		hour = 0
+	}
+
+	if year == 0 || month == 0 || day < 1 || day > 31 {
+		return time.Time{}
+	}
+	return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+}
+
+// NormalizeTitle reduces a title to lowercase words without subtitles, bracketed series names or punctuation,
+// so that "The Hobbit: Or There and Back Again (Kindle Edition)" and "The Hobbit" compare as equal.
+func NormalizeTitle(title string) string {
+	title = strings.ToLower(title)
+	if i := strings.IndexAny(title, "(["); i > 0 {
+		title = title[:i]
+	}
+	if i := strings.Index(title, ":"); i > 0 {
+		title = title[:i]
+	}
+
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, title)
+
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// firstSegment returns the part of the meta line before the first "|" separator.
+func firstSegment(s string) string {
+	if i := strings.Index(s, "|"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// containsAny reports whether s contains at least one of the given words.
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// after returns the text following the first of the given words found in s.
+func after(s string, words []string) (string, bool) {
+	for _, w := range words {
+		if i := strings.Index(s, w); i >= 0 {
+			return s[i+len(w):], true
+		}
+	}
+	return "", false
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package kindle
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestParse verifies that entries in the different layouts written by Kindle devices are parsed correctly.
+func TestParse(t *testing.T) {
+	input := "\ufeffThe Go Programming Language (Donovan, Alan A. A.;Kernighan, Brian W.)\r\n" +
+		"- Your Highlight on page 12 | Location 180-182 | Added on Monday, 1 January 2024 14:05:09\r\n" +
+		"\r\n" +
+		"Go is an open source programming language.\r\n" +
+		"==========\r\n" +
+		"\ufeffThe Go Programming Language (Donovan, Alan A. A.;Kernighan, Brian W.)\r\n" +
+		"- Your Note on page 12 | Location 182 | Added on Monday, January 1, 2024 2:06:00 PM\r\n" +
+		"\r\n" +
+		"Remember this.\r\n" +
+		"==========\r\n" +
+		"Der Hobbit (J.R.R. Tolkien)\r\n" +
+		"- Ihre Lesezeichen auf Seite 7 | bei Position 99 | Hinzugefügt am Dienstag, 2. Januar 2024 08:00:00\r\n" +
+		"\r\n" +
+		"\r\n" +
+		"==========\r\n" +
+		"Old Device Book (Someone)\r\n" +
+		"- Highlight Loc. 1180-82 | Added on Wednesday, March 3, 2010, 09:15 AM\r\n" +
+		"\r\n" +
+		"First line\r\n" +
+		"second line\r\n" +
+		"==========\r\n" +
+		"Broken entry without meta\r\n" +
+		"==========\r\n"
+
+	clippings, err := Parse(strings.NewReader(input))
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(clippings), 4)
+
+	tests := []struct {
+		name          string
+		got           Clipping
+		title         string
+		author        string
+		kind          Kind
+		page          int
+		locationStart int
+		locationEnd   int
+		addedAt       time.Time
+		text          string
+	}{
+		{
+			name:          "english highlight with bom",
+			got:           clippings[0],
+			title:         "The Go Programming Language",
+			author:        "Donovan, Alan A. A.;Kernighan, Brian W.",
+			kind:          KindHighlight,
+			page:          12,
+			locationStart: 180,
+			locationEnd:   182,
+			addedAt:       time.Date(2024, time.January, 1, 14, 5, 9, 0, time.UTC),
+			text:          "Go is an open source programming language.",
+		},
+		{
+			name:          "us date note",
+			got:           clippings[1],
+			title:         "The Go Programming Language",
+			author:        "Donovan, Alan A. A.;Kernighan, Brian W.",
+			kind:          KindNote,
+			page:          12,
+			locationStart: 182,
+			addedAt:       time.Date(2024, time.January, 1, 14, 6, 0, 0, time.UTC),
+			text:          "Remember this.",
+		},
+		{
+			name:          "german bookmark",
+			got:           clippings[2],
+			title:         "Der Hobbit",
+			author:        "J.R.R. Tolkien",
+			kind:          KindBookmark,
+			page:          7,
+			locationStart: 99,
+			addedAt:       time.Date(2024, time.January, 2, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "old device abbreviated range",
+			got:           clippings[3],
+			title:         "Old Device Book",
+			author:        "Someone",
+			kind:          KindHighlight,
+			locationStart: 1180,
+			locationEnd:   1182,
+			addedAt:       time.Date(2010, time.March, 3, 9, 15, 0, 0, time.UTC),
+			text:          "First line\nsecond line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Equal(t, tt.got.Title, tt.title)
+			testutil.Equal(t, tt.got.Author, tt.author)
+			testutil.Equal(t, tt.got.Kind, tt.kind)
+			testutil.Equal(t, tt.got.Page, tt.page)
+			testutil.Equal(t, tt.got.LocationStart, tt.locationStart)
+			testutil.Equal(t, tt.got.LocationEnd, tt.locationEnd)
+			testutil.Equal(t, tt.got.AddedAt, tt.addedAt)
+			testutil.Equal(t, tt.got.Text, tt.text)
+		})
+	}
+}
+
+// TestParseDate checks the localized date lines Kindle devices write after the "Added on" label.
+func TestParseDate(t *testing.T) {
+	want := time.Date(2024, time.January, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{name: "english uk", input: " on Monday, 1 January 2024 12:30:00", want: want},
+		{name: "english us", input: " on Monday, January 1, 2024 12:30:00 PM", want: want},
+		{name: "german", input: " am Montag, 1. Januar 2024 12:30:00", want: want},
+		{name: "french", input: " le lundi 1 janvier 2024 12:30:00", want: want},
+		{name: "spanish", input: " el lunes, 1 de enero de 2024 12:30:00", want: want},
+		{name: "italian", input: " in data lunedì 1 gennaio 2024 12:30:00", want: want},
+		{name: "portuguese", input: ": segunda-feira, 1 de janeiro de 2024 12:30:00", want: want},
+		{name: "japanese", input: "日： 2024年1月1日月曜日 12:30:00", want: want},
+		{name: "midnight am", input: " on Monday, January 1, 2024 12:30:00 AM", want: want.Add(-12 * time.Hour)},
+		{name: "unknown", input: " sometime last week", want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Equal(t, parseDate(tt.input), tt.want)
+		})
+	}
+}
+
+// TestClipping_Hash ensures that the hash ignores the added date and whitespace differences but not the content.
+func TestClipping_Hash(t *testing.T) {
+	a := Clipping{Title: "Book", Author: "Author", Kind: KindHighlight, LocationStart: 10, Text: "Some  text"}
+	b := a
+	b.AddedAt = time.Now()
+	b.Text = "Some text"
+	c := a
+	c.Text = "Other text"
+
+	testutil.Equal(t, a.Hash(), b.Hash())
+	testutil.Equal(t, a.Hash() == c.Hash(), false)
+}
+
+// TestNormalizeTitle verifies that titles are reduced to comparable lowercase words.
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain", input: "The Hobbit", want: "the hobbit"},
+		{name: "subtitle", input: "The Hobbit: Or There and Back Again", want: "the hobbit"},
+		{name: "edition", input: "The Hobbit (Kindle Edition)", want: "the hobbit"},
+		{name: "punctuation", input: "Don't Make Me Think, Revisited", want: "don t make me think revisited"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Equal(t, NormalizeTitle(tt.input), tt.want)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Cache stores lookup results by key.
+type Cache interface {
+	// Get returns the value stored under key and when it was stored, or an error if there is none.
+	Get(key string) ([]byte, time.Time, error)
+
+	// Set stores a value under key, replacing any value stored before.
+	Set(key string, value []byte) error
+}
+
+// cachedLookup is a lookup result as it is stored in a Cache. Book is nil for ISBNs the catalog did not know.
+type cachedLookup struct {
+	Book *Book `json:"book"`
+}
+
+// Cached is a Provider that remembers the results of another provider, including the ISBNs it did not know,
+// so repeated lookups do not reach the catalog. Failed lookups are not remembered.
+type Cached struct {
+	Provider Provider
+	Cache    Cache
+	Logger   *slog.Logger
+
+	// TTL is how long books are remembered and NotFoundTTL how long unknown ISBNs are.
+	TTL         time.Duration
+	NotFoundTTL time.Duration
+}
+
+// LookupISBN returns the remembered result for an ISBN while it is fresh and asks the provider otherwise.
+// Cache failures only cost a lookup in the catalog.
+func (c *Cached) LookupISBN(ctx context.Context, isbn string) (*Book, error) {
+	key := "isbn:" + isbn
+
+	if value, stored, err := c.Cache.Get(key); err == nil {
+		var lookup cachedLookup
+		if err := json.Unmarshal(value, &lookup); err == nil {
+			ttl := c.TTL
+			if lookup.Book == nil {
+				ttl = c.NotFoundTTL
+			}
+			if time.Since(stored) < ttl {
+				if lookup.Book == nil {
+					return nil, ErrNotFound
+				}
+				return lookup.Book, nil
+			}
+		}
+	}
+
+	book, err := c.Provider.LookupISBN(ctx, isbn)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	value, marshalErr := json.Marshal(cachedLookup{Book: book})
+	if marshalErr == nil {
+		marshalErr = c.Cache.Set(key, value)
+	}
+	if marshalErr != nil && c.Logger != nil {
+		c.Logger.Error("metadata cache failed", "error", marshalErr)
+	}
+	return book, err
+}
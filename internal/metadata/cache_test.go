@@ -0,0 +1,80 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// memoryCache is a Cache kept in memory.
+type memoryCache struct {
+	values map[string][]byte
+	stored map[string]time.Time
+}
+
+// Get returns the value stored under key.
+func (c *memoryCache) Get(key string) ([]byte, time.Time, error) {
+	value, ok := c.values[key]
+	if !ok {
+		return nil, time.Time{}, errors.New("no value")
+	}
+	return value, c.stored[key], nil
+}
+
+// Set stores a value under key.
+func (c *memoryCache) Set(key string, value []byte) error {
+	c.values[key] = value
+	c.stored[key] = time.Now()
+	return nil
+}
+
+// countingProvider is a Provider that knows a single book and counts its lookups, failing while err is set.
+type countingProvider struct {
+	lookups int
+	err     error
+}
+
+// LookupISBN returns Dune for its ISBN.
+func (p *countingProvider) LookupISBN(_ context.Context, isbn string) (*Book, error) {
+	p.lookups++
+	if p.err != nil {
+		return nil, p.err
+	}
+	if isbn != "9780441013593" {
+		return nil, ErrNotFound
+	}
+	return &Book{Title: "Dune", Author: "Frank Herbert", ISBN: isbn}, nil
+}
+
+// TestCached_LookupISBN tests that found and unknown ISBNs are remembered until they expire and failures are not.
+func TestCached_LookupISBN(t *testing.T) {
+	provider := &countingProvider{}
+	cache := &memoryCache{values: map[string][]byte{}, stored: map[string]time.Time{}}
+	cached := &Cached{Provider: provider, Cache: cache, TTL: time.Hour, NotFoundTTL: time.Hour}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		book, err := cached.LookupISBN(ctx, "9780441013593")
+		testutil.NoError(t, err)
+		testutil.Equal(t, book.Title, "Dune")
+
+		_, err = cached.LookupISBN(ctx, "9780141439587")
+		testutil.Equal(t, errors.Is(err, ErrNotFound), true)
+	}
+	testutil.Equal(t, provider.lookups, 2)
+
+	cache.stored["isbn:9780441013593"] = time.Now().Add(-2 * time.Hour)
+	provider.err = errors.New("catalog unavailable")
+	_, err := cached.LookupISBN(ctx, "9780441013593")
+	testutil.Equal(t, errors.Is(err, ErrNotFound), false)
+	testutil.Equal(t, err != nil, true)
+
+	provider.err = nil
+	book, err := cached.LookupISBN(ctx, "9780441013593")
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Author, "Frank Herbert")
+	testutil.Equal(t, provider.lookups, 4)
+}
@@ -0,0 +1,76 @@
+// Package metadata looks up the details of books by ISBN in online catalogs, to spare users typing them in.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrNotFound is returned when a catalog has no book with the ISBN looked up.
+var ErrNotFound = errors.New("metadata: book not found")
+
+// ErrInvalidISBN is returned for ISBNs of the wrong length or with a wrong check digit.
+var ErrInvalidISBN = errors.New("metadata: invalid ISBN")
+
+// Book holds the details a catalog knows about a book. Fields the catalog does not know are left empty.
+type Book struct {
+	Title           string `json:"title"`
+	Author          string `json:"author"`
+	ISBN            string `json:"isbn"`
+	Publisher       string `json:"publisher,omitempty"`
+	Language        string `json:"language,omitempty"`
+	Description     string `json:"description,omitempty"`
+	PublicationYear int    `json:"publication_year,omitempty"`
+	PageCount       int    `json:"page_count,omitempty"`
+}
+
+// Provider looks up books in a catalog.
+type Provider interface {
+	// LookupISBN returns the book with a normalized ISBN, or ErrNotFound if the catalog has none.
+	LookupISBN(ctx context.Context, isbn string) (*Book, error)
+}
+
+// NormalizeISBN removes hyphens and spaces from an ISBN-10 or ISBN-13 and checks its check digit.
+// Returns ErrInvalidISBN if it is not a valid ISBN.
+func NormalizeISBN(isbn string) (string, error) {
+	isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+
+	switch len(isbn) {
+	case 10:
+		sum := 0
+		for i, c := range isbn {
+			var d int
+			switch {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case c == 'X' && i == 9:
+				d = 10
+			default:
+				return "", ErrInvalidISBN
+			}
+			sum += (10 - i) * d
+		}
+		if sum%11 != 0 {
+			return "", ErrInvalidISBN
+		}
+	case 13:
+		sum := 0
+		for i, c := range isbn {
+			if c < '0' || c > '9' {
+				return "", ErrInvalidISBN
+			}
+			d := int(c - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		if sum%10 != 0 {
+			return "", ErrInvalidISBN
+		}
+	default:
+		return "", ErrInvalidISBN
+	}
+	return isbn, nil
+}
@@ -0,0 +1,38 @@
+package metadata
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestNormalizeISBN tests that hyphenated ISBN-10 and ISBN-13 are normalized and wrong check digits are rejected.
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		name    string
+		isbn    string
+		want    string
+		wantErr bool
+	}{
+		{name: "isbn-13", isbn: "9780441013593", want: "9780441013593"},
+		{name: "hyphenated isbn-13", isbn: " 978-0-441-01359-3 ", want: "9780441013593"},
+		{name: "isbn-10", isbn: "0-441-01359-7", want: "0441013597"},
+		{name: "isbn-10 with check digit x", isbn: "080442957x", want: "080442957X"},
+		{name: "wrong check digit", isbn: "9780441013594", wantErr: true},
+		{name: "wrong length", isbn: "97804410135", wantErr: true},
+		{name: "letters", isbn: "97804410135AB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeISBN(tt.isbn)
+			if tt.wantErr {
+				testutil.Equal(t, errors.Is(err, ErrInvalidISBN), true)
+				return
+			}
+			testutil.NoError(t, err)
+			testutil.Equal(t, got, tt.want)
+		})
+	}
+}
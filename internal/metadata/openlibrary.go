@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponseSize limits the size of a catalog response.
+const maxResponseSize = 1 << 20
+
+// yearRX finds the year in the free form publication dates of Open Library, such as "June 1965".
+var yearRX = regexp.MustCompile(`\b(\d{4})\b`)
+
+// OpenLibrary is a Provider backed by the Books API of Open Library, or of a service with the same API at BaseURL.
+type OpenLibrary struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewOpenLibrary returns a provider for the Books API at baseURL, giving up on requests that take longer than timeout.
+func NewOpenLibrary(baseURL string, timeout time.Duration) *OpenLibrary {
+	return &OpenLibrary{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// openLibraryName is a named object of the Books API, such as an author or a publisher.
+type openLibraryName struct {
+	Name string `json:"name"`
+}
+
+// openLibraryBook is a book as returned by the Books API with jscmd=data.
+type openLibraryBook struct {
+	Title       string            `json:"title"`
+	Subtitle    string            `json:"subtitle"`
+	Authors     []openLibraryName `json:"authors"`
+	Publishers  []openLibraryName `json:"publishers"`
+	PublishDate string            `json:"publish_date"`
+	Pages       int               `json:"number_of_pages"`
+	Notes       json.RawMessage   `json:"notes"`
+}
+
+// LookupISBN returns the book with a normalized ISBN from the Books API, or ErrNotFound if it has none.
+func (o *OpenLibrary) LookupISBN(ctx context.Context, isbn string) (*Book, error) {
+	key := "ISBN:" + isbn
+	query := url.Values{"bibkeys": {key}, "format": {"json"}, "jscmd": {"data"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.BaseURL+"/api/books?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "go-bookreview")
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: open library request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata: open library responded with %s", resp.Status)
+	}
+
+	var result map[string]openLibraryBook
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("metadata: invalid open library response: %w", err)
+	}
+
+	found, ok := result[key]
+	if !ok || found.Title == "" {
+		return nil, ErrNotFound
+	}
+
+	book := &Book{Title: found.Title, ISBN: isbn}
+	if found.Subtitle != "" {
+		book.Title += ": " + found.Subtitle
+	}
+
+	var authors []string
+	for _, a := range found.Authors {
+		if a.Name != "" {
+			authors = append(authors, a.Name)
+		}
+	}
+	book.Author = strings.Join(authors, ", ")
+
+	if len(found.Publishers) > 0 {
+		book.Publisher = found.Publishers[0].Name
+	}
+	if m := yearRX.FindString(found.PublishDate); m != "" {
+		book.PublicationYear, _ = strconv.Atoi(m)
+	}
+	book.PageCount = found.Pages
+
+	// Notes are either a plain string or a text object with a type and a value.
+	var notes string
+	if err := json.Unmarshal(found.Notes, &notes); err != nil {
+		var text struct {
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(found.Notes, &text); err == nil {
+			notes = text.Value
+		}
+	}
+	book.Description = strings.TrimSpace(notes)
+
+	return book, nil
+}
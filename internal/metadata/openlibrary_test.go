@@ -0,0 +1,62 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestOpenLibrary_LookupISBN tests lookups against a stand-in for the Books API, including unknown ISBNs,
+// server errors and a catalog that does not answer in time.
+func TestOpenLibrary_LookupISBN(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.Equal(t, r.URL.Path, "/api/books")
+		testutil.Equal(t, r.URL.Query().Get("jscmd"), "data")
+
+		switch r.URL.Query().Get("bibkeys") {
+		case "ISBN:9780441013593":
+			_, _ = w.Write([]byte(`{"ISBN:9780441013593": {
+				"title": "Dune",
+				"authors": [{"name": "Frank Herbert"}],
+				"publishers": [{"name": "Ace Books"}],
+				"publish_date": "August 1990",
+				"number_of_pages": 535,
+				"notes": {"type": "/type/text", "value": "Sequel: Dune Messiah."}
+			}}`))
+		case "ISBN:9780000000002":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case "ISBN:9780000000019":
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	provider := NewOpenLibrary(srv.URL+"/", 100*time.Millisecond)
+
+	book, err := provider.LookupISBN(context.Background(), "9780441013593")
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Title, "Dune")
+	testutil.Equal(t, book.Author, "Frank Herbert")
+	testutil.Equal(t, book.Publisher, "Ace Books")
+	testutil.Equal(t, book.PublicationYear, 1990)
+	testutil.Equal(t, book.PageCount, 535)
+	testutil.Equal(t, book.Description, "Sequel: Dune Messiah.")
+	testutil.Equal(t, book.ISBN, "9780441013593")
+
+	_, err = provider.LookupISBN(context.Background(), "9780141439587")
+	testutil.Equal(t, errors.Is(err, ErrNotFound), true)
+
+	_, err = provider.LookupISBN(context.Background(), "9780000000002")
+	testutil.Equal(t, err != nil && !errors.Is(err, ErrNotFound), true)
+
+	_, err = provider.LookupISBN(context.Background(), "9780000000019")
+	testutil.Equal(t, err != nil && !errors.Is(err, ErrNotFound), true)
+}
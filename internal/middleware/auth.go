@@ -2,11 +2,16 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"github.com/madalinpopa/go-bookreview/internal/app"
 	"github.com/madalinpopa/go-bookreview/internal/models"
 	"net/http"
+	"strings"
 )
 
+// apiRealm is the realm announced to clients that have to authenticate with HTTP Basic.
+const apiRealm = `Basic realm="go-bookreview", charset="UTF-8"`
+
 // LoginRequired is middleware that enforces user authentication by redirecting unauthenticated requests to the login page.
 func (m *Middleware) LoginRequired(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -81,3 +86,79 @@ func (m *Middleware) Authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// APIAuthenticate is middleware for clients that cannot use sessions and CSRF tokens, such as e-reader apps.
+// It accepts HTTP Basic credentials, where the password may also be an API token of the user,
+// or an API token as a Bearer token, and responds with 401 Unauthorized otherwise.
+func (m *Middleware) APIAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, err := m.apiUserId(r)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", apiRealm)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			m.app.ServerError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		ctx := context.WithValue(r.Context(), app.APIUserIdContextKey, userId)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiUserId returns the ID of the user the credentials of a request belong to,
+// or models.ErrInvalidCredentials when they are missing or wrong.
+func (m *Middleware) apiUserId(r *http.Request) (int, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return m.app.Models.APITokens.Authenticate(strings.TrimSpace(token))
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	userId, err := m.app.Models.Users.Authenticate(username, password)
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		return userId, err
+	}
+
+	tokenUserId, err := m.app.Models.APITokens.Authenticate(password)
+	if err != nil {
+		return 0, err
+	}
+	userId, err = m.app.Models.Users.RetrieveId(username)
+	if err != nil || userId != tokenUserId {
+		if err == nil || errors.Is(err, models.ErrNoRecord) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+	return userId, nil
+}
+
+// KosyncAuthenticate is middleware for the KOReader sync protocol, which sends the username in the x-auth-user header
+// and the MD5 hash of the password in x-auth-key. The password has to be an API token of the user,
+// since passwords are only stored as bcrypt hashes. It responds with 401 Unauthorized otherwise.
+func (m *Middleware) KosyncAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, err := m.app.Models.APITokens.AuthenticateSyncKey(r.Header.Get("x-auth-user"), r.Header.Get("x-auth-key"))
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"code":2001,"message":"Unauthorized"}` + "\n"))
+				return
+			}
+			m.app.ServerError(w, r, err)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		ctx := context.WithValue(r.Context(), app.APIUserIdContextKey, userId)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
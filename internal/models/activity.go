@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// Kinds of activity of a user.
+const (
+	ActivityReview   = "review"
+	ActivityFinished = "finished"
+)
+
+// Activity represents something a user did: reviewing a book or finishing it.
+// ID is the ID of the review, or of the user's library entry of the finished book.
+type Activity struct {
+	Kind       string
+	ID         int
+	BookId     int
+	BookTitle  string
+	BookAuthor string
+	Rating     int
+	ReviewText string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// ActivityModel provides methods to read the public activity of users.
+type ActivityModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// ForUser returns the most recent activity of a user up to limit, newest first.
+// Books finished before the finishing time was recorded count as finished when they were added.
+func (m *ActivityModel) ForUser(userId, limit int) ([]Activity, error) {
+	stmt := `SELECT 'review', r.id, r.book_id, b.title, b.author, COALESCE(r.rating, 0), COALESCE(r.review_text, ''),
+               r.created_at, r.updated_at
+        FROM reviews r
+        JOIN books b ON r.book_id = b.id
+        WHERE r.user_id = ?
+        UNION ALL
+        SELECT 'finished', ub.id, ub.book_id, b.title, b.author, 0, '', COALESCE(ub.finished_at, ub.added_at),
+               COALESCE(ub.finished_at, ub.added_at)
+        FROM user_books ub
+        JOIN books b ON ub.book_id = b.id
+        WHERE ub.user_id = ? AND ub.status = 'finished'
+        ORDER BY 8 DESC, 2 DESC
+        LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, userId, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var activities []Activity
+	for rows.Next() {
+		var a Activity
+		err := rows.Scan(&a.Kind, &a.ID, &a.BookId, &a.BookTitle, &a.BookAuthor, &a.Rating, &a.ReviewText, &a.CreatedAt, &a.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return activities, nil
+}
+
+// Version returns the number of activities of a user and when the most recently changed one changed,
+// so clients that already have the activity feed can be answered without building it.
+func (m *ActivityModel) Version(userId int) (int, time.Time, error) {
+	stmt := `SELECT COUNT(*), COALESCE(MAX(updated), 0) FROM (
+            SELECT CAST(strftime('%s', updated_at) AS INTEGER) AS updated FROM reviews WHERE user_id = ?
+            UNION ALL
+            SELECT CAST(strftime('%s', COALESCE(finished_at, added_at)) AS INTEGER) FROM user_books
+            WHERE user_id = ? AND status = 'finished'
+        )`
+
+	var count int
+	var updated int64
+	if err := m.DB.QueryRow(stmt, userId, userId).Scan(&count, &updated); err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, unixTime(updated), nil
+}
+
+// unixTime converts seconds since the Unix epoch to a time in UTC, where 0 stands for no time at all.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestReviewModel_Feed tests that review feeds list the reviews of the site or of a book, newest first,
+// and that their version changes when a review is added or edited.
+func TestReviewModel_Feed(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := ReviewModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	duneId, err := books.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	emmaId, err := books.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	count, updated, err := model.FeedVersion(0)
+	testutil.NoError(t, err)
+	testutil.Equal(t, count, 0)
+	testutil.Equal(t, updated.IsZero(), true)
+
+	_, err = db.Exec(`INSERT INTO reviews (user_id, book_id, rating, review_text, created_at, updated_at) VALUES
+		(1, ?, 5, 'A classic.', '2024-01-10 09:00:00', '2024-01-10 09:00:00'),
+		(1, ?, 3, NULL, '2024-01-12 09:00:00', '2024-01-12 09:00:00')`, duneId, emmaId)
+	testutil.NoError(t, err)
+
+	reviews, err := model.Feed(0, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(reviews), 2)
+	testutil.Equal(t, reviews[0].BookTitle, "Emma")
+	testutil.Equal(t, reviews[0].ReviewText, "")
+	testutil.Equal(t, reviews[1].Username, "reader")
+
+	reviews, err = model.Feed(duneId, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(reviews), 1)
+	testutil.Equal(t, reviews[0].ReviewText, "A classic.")
+
+	count, updated, err = model.FeedVersion(duneId)
+	testutil.NoError(t, err)
+	testutil.Equal(t, count, 1)
+	testutil.Equal(t, updated.Format("2006-01-02 15:04:05"), "2024-01-10 09:00:00")
+
+	testutil.NoError(t, model.Update(reviews[0].ID, 1, 4, "Still a classic."))
+	_, edited, err := model.FeedVersion(duneId)
+	testutil.NoError(t, err)
+	testutil.Equal(t, edited.After(updated), true)
+}
+
+// TestActivityModel_ForUser tests that the activity of a user combines their reviews and finished books, newest first.
+func TestActivityModel_ForUser(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := ActivityModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+	duneId, err := books.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = books.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	_, err = db.Exec(`UPDATE user_books SET finished_at = '2024-01-05 08:00:00' WHERE book_id = ?`, duneId)
+	testutil.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO reviews (user_id, book_id, rating, review_text, created_at, updated_at) VALUES
+		(1, ?, 5, 'A classic.', '2024-01-10 09:00:00', '2024-01-10 09:00:00'),
+		(2, ?, 2, 'Not for me.', '2024-01-11 09:00:00', '2024-01-11 09:00:00')`, duneId, duneId)
+	testutil.NoError(t, err)
+
+	activities, err := model.ForUser(1, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(activities), 2)
+	testutil.Equal(t, activities[0].Kind, ActivityReview)
+	testutil.Equal(t, activities[0].Rating, 5)
+	testutil.Equal(t, activities[1].Kind, ActivityFinished)
+	testutil.Equal(t, activities[1].BookAuthor, "Frank Herbert")
+	testutil.Equal(t, activities[1].UpdatedAt.Format("2006-01-02 15:04:05"), "2024-01-05 08:00:00")
+
+	count, updated, err := model.Version(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, count, 2)
+	testutil.Equal(t, updated.Format("2006-01-02 15:04:05"), "2024-01-10 09:00:00")
+
+	count, _, err = model.Version(2)
+	testutil.NoError(t, err)
+	testutil.Equal(t, count, 1)
+}
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// BackupBook represents a book of a user's library as stored in a backup, keyed by its ID in the source instance.
+type BackupBook struct {
+	ID              int
+	Title           string
+	Author          string
+	ISBN            string
+	PublicationYear int
+	PageCount       int
+	Status          string
+	Progress        int
+	ImageURL        string
+	Publisher       string
+	Language        string
+	Description     string
+	AddedAt         time.Time
+	FinishedAt      time.Time
+}
+
+// BackupReview represents a review in a backup. BookId refers to BackupBook.ID.
+type BackupReview struct {
+	BookId     int
+	Rating     int
+	ReviewText string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// BackupNote represents a note in a backup. BookId refers to BackupBook.ID.
+type BackupNote struct {
+	BookId     int
+	NoteText   string
+	PageNumber int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Backup holds everything a user owns: the books in their library with shelf data, their reviews and notes.
+type Backup struct {
+	Books   []BackupBook
+	Reviews []BackupReview
+	Notes   []BackupNote
+}
+
+// BackupModel provides methods to collect and restore the data of a user for account backups.
+type BackupModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Collect gathers the library, reviews and notes of a user.
+func (m *BackupModel) Collect(userId int) (Backup, error) {
+	var backup Backup
+
+	books := BookModel{DB: m.DB, Logger: m.Logger}
+	err := books.EachInLibrary(userId, func(entry LibraryEntry) error {
+		backup.Books = append(backup.Books, BackupBook{
+			ID:              entry.BookId,
+			Title:           entry.Title,
+			Author:          entry.Author,
+			ISBN:            entry.ISBN,
+			PublicationYear: entry.PublicationYear,
+			PageCount:       entry.PageCount,
+			Status:          entry.Status,
+			Progress:        entry.Progress,
+			ImageURL:        entry.ImageURL,
+			Publisher:       entry.Publisher,
+			Language:        entry.Language,
+			Description:     entry.Description,
+			AddedAt:         entry.AddedAt,
+			FinishedAt:      entry.FinishedAt,
+		})
+		return nil
+	})
+	if err != nil {
+		return Backup{}, err
+	}
+
+	backup.Reviews, err = m.reviews(userId)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	backup.Notes, err = m.notes(userId)
+	if err != nil {
+		return Backup{}, err
+	}
+	return backup, nil
+}
+
+// reviews returns every review written by a user, oldest first.
+func (m *BackupModel) reviews(userId int) ([]BackupReview, error) {
+	stmt := `SELECT book_id, COALESCE(rating, 0), COALESCE(review_text, ''), created_at, updated_at
+		FROM reviews WHERE user_id = ? ORDER BY created_at, id`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var reviews []BackupReview
+	for rows.Next() {
+		var review BackupReview
+		err := rows.Scan(&review.BookId, &review.Rating, &review.ReviewText, &review.CreatedAt, &review.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// notes returns every note written by a user, oldest first.
+func (m *BackupModel) notes(userId int) ([]BackupNote, error) {
+	stmt := `SELECT book_id, note_text, COALESCE(page_number, 0), created_at, updated_at
+		FROM notes WHERE user_id = ? ORDER BY created_at, id`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var notes []BackupNote
+	for rows.Next() {
+		var note BackupNote
+		err := rows.Scan(&note.BookId, &note.NoteText, &note.PageNumber, &note.CreatedAt, &note.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// Restore imports a backup into the library of a user within a single transaction.
+// Books are linked to existing ones by ISBN, or by title and author when the ISBN is missing, and the IDs of the
+// backup are remapped onto the IDs of this instance. Books already in the user's library are skipped together
+// with their reviews and notes, so restoring the same backup twice does not duplicate anything.
+// saveCover is called for new books that have a cover and returns the image URL to store, it may be nil.
+func (m *BackupModel) Restore(userId int, backup Backup, saveCover func(BackupBook) (string, error)) (ImportResult, error) {
+	var result ImportResult
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	ids := make(map[int]int64, len(backup.Books))
+	for _, book := range backup.Books {
+		if book.Title == "" || book.Author == "" {
+			result.Skipped++
+			continue
+		}
+
+		var bookId int64
+		bookId, err = findImportBook(tx, book.Title, book.Author, book.ISBN)
+		if err != nil {
+			return ImportResult{}, err
+		}
+
+		if bookId == 0 {
+			imageUrl := ""
+			if book.ImageURL != "" && saveCover != nil {
+				imageUrl, err = saveCover(book)
+				if err != nil {
+					return ImportResult{}, err
+				}
+			}
+
+			var isbn sql.NullString
+			if book.ISBN != "" {
+				isbn = sql.NullString{String: book.ISBN, Valid: true}
+			}
+
+			var res sql.Result
+			res, err = tx.Exec(`INSERT INTO books (title, author, isbn, publication_year, page_count, image_url, publisher, language, description)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				book.Title, book.Author, isbn, book.PublicationYear, max(book.PageCount, 0), imageUrl, book.Publisher, book.Language, book.Description)
+			if err != nil {
+				var sqliteError sqlite3.Error
+				if errors.As(err, &sqliteError) {
+					return ImportResult{}, sqliteError
+				}
+				return ImportResult{}, err
+			}
+			bookId, err = res.LastInsertId()
+			if err != nil {
+				return ImportResult{}, err
+			}
+			result.Created++
+		} else {
+			var exists bool
+			err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_books WHERE user_id = ? AND book_id = ?)`, userId, bookId).Scan(&exists)
+			if err != nil {
+				return ImportResult{}, err
+			}
+			if exists {
+				result.Skipped++
+				continue
+			}
+			result.Linked++
+		}
+
+		// Unknown statuses, as in a hand-edited backup, are restored as want to read instead of failing the restore.
+		status := "want_to_read"
+		switch book.Status {
+		case "reading", "finished":
+			status = book.Status
+		}
+		progress := min(max(book.Progress, 0), 100)
+		_, err = tx.Exec(`INSERT INTO user_books (user_id, book_id, status, progress, added_at, finished_at)
+			VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?)`,
+			userId, bookId, status, progress, nullTime(book.AddedAt), nullTime(book.FinishedAt))
+		if err != nil {
+			return ImportResult{}, err
+		}
+		ids[book.ID] = bookId
+	}
+
+	for _, review := range backup.Reviews {
+		bookId, ok := ids[review.BookId]
+		var rating sql.NullInt64
+		if review.Rating >= 1 && review.Rating <= 5 {
+			rating = sql.NullInt64{Int64: int64(review.Rating), Valid: true}
+		}
+		if !ok || !rating.Valid && strings.TrimSpace(review.ReviewText) == "" {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO reviews (user_id, book_id, rating, review_text, created_at, updated_at)
+			VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))`,
+			userId, bookId, rating, review.ReviewText, nullTime(review.CreatedAt), nullTime(review.UpdatedAt))
+		if err != nil {
+			return ImportResult{}, err
+		}
+		result.Reviews++
+	}
+
+	for _, note := range backup.Notes {
+		bookId, ok := ids[note.BookId]
+		if !ok || note.NoteText == "" {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO notes (user_id, book_id, note_text, page_number, created_at, updated_at)
+			VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))`,
+			userId, bookId, note.NoteText, note.PageNumber, nullTime(note.CreatedAt), nullTime(note.UpdatedAt))
+		if err != nil {
+			return ImportResult{}, err
+		}
+		result.Notes++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
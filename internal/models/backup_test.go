@@ -0,0 +1,104 @@
+package models
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestBackupModel_Restore tests that a collected backup restores into another database with remapped IDs.
+func TestBackupModel_Restore(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	source := testutil.NewTestDB(t)
+	defer source.Close()
+	sourceUsers := UserModel{DB: source, Logger: logger}
+	sourceBooks := BookModel{DB: source, Logger: logger}
+	sourceNotes := NoteModel{DB: source, Logger: logger}
+	sourceReviews := ReviewModel{DB: source, Logger: logger}
+
+	testutil.NoError(t, sourceUsers.Create("reader", "reader@example.com", "password123"))
+	_, err := sourceBooks.Create("Filler", "Nobody", "0000000000", "reading", "/uploads/filler.jpg", 2000, 1, BookDetails{PageCount: 300})
+	testutil.NoError(t, err)
+	duneId, err := sourceBooks.Create("Dune", "Frank Herbert", "9780441013593", "finished", "/uploads/dune.jpg", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = sourceReviews.Create(1, duneId, 5, "Great")
+	testutil.NoError(t, err)
+	_, err = sourceNotes.Create(1, duneId, "Spice", 12)
+	testutil.NoError(t, err)
+
+	backup, err := (&BackupModel{DB: source, Logger: logger}).Collect(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(backup.Books), 2)
+	testutil.Equal(t, len(backup.Reviews), 1)
+	testutil.Equal(t, len(backup.Notes), 1)
+
+	target := testutil.NewTestDB(t)
+	defer target.Close()
+	targetUsers := UserModel{DB: target, Logger: logger}
+	targetBooks := BookModel{DB: target, Logger: logger}
+	model := BackupModel{DB: target, Logger: logger}
+
+	testutil.NoError(t, targetUsers.Create("other", "other@example.com", "password123"))
+	testutil.NoError(t, targetUsers.Create("reader", "reader@example.com", "password123"))
+	// Dune already exists in the target catalog and must be linked by ISBN instead of created.
+	existingId, err := targetBooks.Create("Dune", "Frank Herbert", "9780441013593", "reading", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	covers := 0
+	saveCover := func(book BackupBook) (string, error) {
+		covers++
+		return "/uploads/restored.jpg", nil
+	}
+
+	result, err := model.Restore(2, backup, saveCover)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, ImportResult{Created: 1, Linked: 1, Reviews: 1, Notes: 1})
+	testutil.Equal(t, covers, 1)
+
+	var pageCount int
+	err = target.QueryRow("SELECT page_count FROM books WHERE title = 'Filler'").Scan(&pageCount)
+	testutil.NoError(t, err)
+	testutil.Equal(t, pageCount, 300)
+
+	var bookId int
+	err = target.QueryRow("SELECT book_id FROM notes WHERE user_id = 2").Scan(&bookId)
+	testutil.NoError(t, err)
+	testutil.Equal(t, bookId, existingId)
+
+	// Restoring the same backup again must not duplicate anything.
+	result, err = model.Restore(2, backup, saveCover)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, ImportResult{Skipped: 2})
+}
+
+// TestBackupModel_Restore_InvalidStatus tests that a book with an unknown reading status is restored as want to read.
+func TestBackupModel_Restore_InvalidStatus(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := BackupModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+
+	backup := Backup{Books: []BackupBook{
+		{ID: 1, Title: "Dune", Author: "Frank Herbert", Status: "abandoned"},
+		{ID: 2, Title: "Emma", Author: "Jane Austen", Status: "finished"},
+	}}
+	result, err := model.Restore(1, backup, nil)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, ImportResult{Created: 2})
+
+	var status string
+	err = db.QueryRow("SELECT status FROM user_books JOIN books ON books.id = user_books.book_id WHERE title = 'Dune'").Scan(&status)
+	testutil.NoError(t, err)
+	testutil.Equal(t, status, "want_to_read")
+
+	err = db.QueryRow("SELECT status FROM user_books JOIN books ON books.id = user_books.book_id WHERE title = 'Emma'").Scan(&status)
+	testutil.NoError(t, err)
+	testutil.Equal(t, status, "finished")
+}
@@ -23,6 +23,12 @@ var (
 
 	// ErrDuplicateIsbn indicates that the provided ISBN already exists in the system and cannot be used again.
 	ErrDuplicateIsbn = errors.New("models: duplicate isbn")
+
+	// ErrDuplicateShelfName indicates that the user already has a smart shelf with the provided name.
+	ErrDuplicateShelfName = errors.New("models: duplicate shelf name")
+
+	// ErrInvalidCursor indicates that a cursor of a paginated list was changed or belongs to another order of the list.
+	ErrInvalidCursor = errors.New("models: invalid cursor")
 )
 
 // LookupField represents an enumeration used to specify fields for lookup operations in user-related database queries.
@@ -41,6 +47,21 @@ type Models struct {
 	Books   BookModel
 	Notes   NoteModel
 	Reviews ReviewModel
+
+	KindleClippings KindleClippingModel
+	Backups         BackupModel
+	Tags            TagModel
+	Calibre         CalibreModel
+	APITokens       APITokenModel
+	Kosync          KosyncModel
+	Activity        ActivityModel
+	MetadataCache   MetadataCacheModel
+	Covers          CoverModel
+	Uploads         UploadModel
+	RecentSearches  RecentSearchModel
+	SmartShelves    SmartShelfModel
+	Recommendations RecommendationModel
+	Stats           StatsModel
 }
 
 // NewModels initializes and returns a Models instance with the provided database connection.
@@ -50,5 +71,20 @@ func NewModels(db *sql.DB, logger *slog.Logger) *Models {
 		Books:   BookModel{DB: db, Logger: logger},
 		Notes:   NoteModel{DB: db, Logger: logger},
 		Reviews: ReviewModel{DB: db, Logger: logger},
+
+		KindleClippings: KindleClippingModel{DB: db, Logger: logger},
+		Backups:         BackupModel{DB: db, Logger: logger},
+		Tags:            TagModel{DB: db, Logger: logger},
+		Calibre:         CalibreModel{DB: db, Logger: logger},
+		APITokens:       APITokenModel{DB: db, Logger: logger},
+		Kosync:          KosyncModel{DB: db, Logger: logger},
+		Activity:        ActivityModel{DB: db, Logger: logger},
+		MetadataCache:   MetadataCacheModel{DB: db, Logger: logger},
+		Covers:          CoverModel{DB: db, Logger: logger},
+		Uploads:         UploadModel{DB: db, Logger: logger},
+		RecentSearches:  RecentSearchModel{DB: db, Logger: logger},
+		SmartShelves:    SmartShelfModel{DB: db, Logger: logger},
+		Recommendations: RecommendationModel{DB: db, Logger: logger},
+		Stats:           StatsModel{DB: db, Logger: logger},
 	}
 }
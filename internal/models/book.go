@@ -27,20 +27,36 @@ type Book struct {
 	ISBN            string
 	PublicationYear int
 	Status          string
+	Progress        int
 	ImageURL        string
+	Publisher       string
+	Language        string
+	Description     string
+	PageCount       int
+	Tags            []string
+	Rating          float64
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 	UserId          int
 }
 
+// BookDetails holds the publisher, language, description and number of pages of a book.
+type BookDetails struct {
+	Publisher   string
+	Language    string
+	Description string
+	PageCount   int
+}
+
 // BookModel represents the data structure for accessing book-related data in the database.
 type BookModel struct {
 	DB     *sql.DB
 	Logger *slog.Logger
 }
 
-// Create inserts a new book into the database, associates it with a user, and returns the book's ID or an error.
-func (m *BookModel) Create(title, author, isbn, status, imageUrl string, publicationYear, userId int) (int, error) {
+// Create inserts a new book with its details into the database, associates it with a user, and returns the book's ID
+// or an error.
+func (m *BookModel) Create(title, author, isbn, status, imageUrl string, publicationYear, userId int, details BookDetails) (int, error) {
 
 	// Start a transaction
 	tx, err := m.DB.Begin()
@@ -49,11 +65,12 @@ func (m *BookModel) Create(title, author, isbn, status, imageUrl string, publica
 	}
 
 	// Prepare the statement for inserting a book
-	stmt := `INSERT INTO books (title, author, isbn, publication_year, image_url) 
-             VALUES (?, ?, ?, ?, ?)`
+	stmt := `INSERT INTO books (title, author, isbn, publication_year, image_url, publisher, language, description, page_count) 
+             VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	// Execute the statement and get the result
-	result, err := tx.Exec(stmt, title, author, isbn, publicationYear, imageUrl)
+	result, err := tx.Exec(stmt, title, author, isbn, publicationYear, imageUrl,
+		details.Publisher, details.Language, details.Description, details.PageCount)
 	if err != nil {
 		var sqliteError sqlite3.Error
 		if errors.As(err, &sqliteError) && errors.Is(sqliteError.ExtendedCode, sqlite3.ErrConstraintUnique) {
@@ -88,7 +105,8 @@ func (m *BookModel) Create(title, author, isbn, status, imageUrl string, publica
 	}
 
 	// Create the user-book relationship
-	stmt = `INSERT INTO user_books (user_id, book_id, status) VALUES (?, ?, ?)`
+	stmt = `INSERT INTO user_books (user_id, book_id, status, finished_at)
+		VALUES (?1, ?2, ?3, CASE WHEN ?3 = 'finished' THEN CURRENT_TIMESTAMP END)`
 	_, err = tx.Exec(stmt, userId, bookId, status)
 	if err != nil {
 		if err = tx.Rollback(); err != nil {
@@ -107,15 +125,24 @@ func (m *BookModel) Create(title, author, isbn, status, imageUrl string, publica
 }
 
 // Retrieve fetches a book by its ID from the database and returns the book or an error if not found.
+// The user, status and progress of the book are those of its owner, the user who first added it to their library.
 func (m *BookModel) Retrieve(id int) (Book, error) {
+	return m.RetrieveFor(id, 0)
+}
+
+// RetrieveFor fetches a book by its ID like Retrieve, with the user, status and progress of the book in the library
+// of the user with ID userId when it is in theirs.
+func (m *BookModel) RetrieveFor(id, userId int) (Book, error) {
 	var book Book
 
-	stmt := `SELECT b.id, b.title, b.author, b.isbn, b.publication_year, b.created_at, b.updated_at, b.image_url, ub.user_id, ub.status
+	stmt := `SELECT b.id, b.title, b.author, COALESCE(b.isbn, ''), b.publication_year, b.created_at, b.updated_at, b.image_url, b.publisher, b.language, b.description, b.page_count, ub.user_id, ub.status, COALESCE(ub.progress, 0)
 		FROM books b
-		LEFT JOIN user_books ub ON b.id = ub.book_id
+		LEFT JOIN user_books ub ON ub.id = COALESCE(
+			(SELECT id FROM user_books WHERE book_id = b.id AND user_id = ?),
+			(SELECT MIN(id) FROM user_books WHERE book_id = b.id))
         WHERE b.id = ?`
 
-	err := m.DB.QueryRow(stmt, id).Scan(
+	err := m.DB.QueryRow(stmt, userId, id).Scan(
 		&book.ID,
 		&book.Title,
 		&book.Author,
@@ -124,8 +151,13 @@ func (m *BookModel) Retrieve(id int) (Book, error) {
 		&book.CreatedAt,
 		&book.UpdatedAt,
 		&book.ImageURL,
+		&book.Publisher,
+		&book.Language,
+		&book.Description,
+		&book.PageCount,
 		&book.UserId,
 		&book.Status,
+		&book.Progress,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -134,6 +166,12 @@ func (m *BookModel) Retrieve(id int) (Book, error) {
 			return Book{}, err
 		}
 	}
+
+	tags := TagModel{DB: m.DB, Logger: m.Logger}
+	book.Tags, err = tags.ForBook(book.ID)
+	if err != nil {
+		return Book{}, err
+	}
 	return book, nil
 }
 
@@ -183,9 +221,10 @@ func (m *BookModel) Delete(id, userId int) error {
 	return nil
 }
 
-// Update modifies an existing book's data in the database based on the provided ID and new field values.
-// Returns ErrDuplicateIsbn if the ISBN is already in use or ErrNoRecord if no record was updated.
-func (m *BookModel) Update(id int, title, author, isbn, status, imageUrl string, publicationYear int) error {
+// Update modifies an existing book's data and details in the database based on the provided ID and new field values,
+// and its status in the library of the user with ID userId. Returns ErrDuplicateIsbn if the ISBN is already in use
+// or ErrNoRecord if no record was updated.
+func (m *BookModel) Update(id int, title, author, isbn, status, imageUrl string, publicationYear, userId int, details BookDetails) error {
 	// Start a transaction
 	tx, err := m.DB.Begin()
 	if err != nil {
@@ -200,8 +239,10 @@ func (m *BookModel) Update(id int, title, author, isbn, status, imageUrl string,
 	}(tx)
 
 	// Update books table
-	stmt := `UPDATE books SET title = ?, author = ?, isbn = ?, publication_year = ?, image_url = ? WHERE id = ?`
-	result, err := tx.Exec(stmt, title, author, isbn, publicationYear, imageUrl, id)
+	stmt := `UPDATE books SET title = ?, author = ?, isbn = ?, publication_year = ?, image_url = ?,
+		publisher = ?, language = ?, description = ?, page_count = ? WHERE id = ?`
+	result, err := tx.Exec(stmt, title, author, isbn, publicationYear, imageUrl,
+		details.Publisher, details.Language, details.Description, details.PageCount, id)
 	if err != nil {
 		var sqliteError sqlite3.Error
 		if errors.As(err, &sqliteError) && errors.Is(sqliteError.ExtendedCode, sqlite3.ErrConstraintUnique) {
@@ -221,7 +262,10 @@ func (m *BookModel) Update(id int, title, author, isbn, status, imageUrl string,
 	}
 
 	// Update user_books table
-	result, err = tx.Exec(`UPDATE user_books SET status = ? WHERE book_id = ?`, status, id)
+	result, err = tx.Exec(`UPDATE user_books SET
+		finished_at = CASE WHEN ?1 != 'finished' THEN NULL WHEN status = 'finished' THEN finished_at ELSE CURRENT_TIMESTAMP END,
+		status = ?1
+		WHERE book_id = ?2 AND user_id = ?3`, status, id, userId)
 	if err != nil {
 		return err
 	}
@@ -242,11 +286,12 @@ func (m *BookModel) Update(id int, title, author, isbn, status, imageUrl string,
 	return nil
 }
 
-// List retrieves a paginated collection of books from the database, including total count and pagination metadata.
-func (m *BookModel) List(page, pageSize int) (PaginatedBooks, error) {
+// List retrieves a page of the books matching the filter, in its order, including total count and pagination metadata.
+func (m *BookModel) List(f BookFilter, page, pageSize int) (PaginatedBooks, error) {
+	where, args := f.where("")
 
 	var total int
-	err := m.DB.QueryRow("SELECT COUNT(*) FROM books").Scan(&total)
+	err := m.DB.QueryRow("SELECT COUNT(*)"+filterFrom+where, args...).Scan(&total)
 	if err != nil {
 		return PaginatedBooks{}, err
 	}
@@ -260,14 +305,13 @@ func (m *BookModel) List(page, pageSize int) (PaginatedBooks, error) {
 	offset := (page - 1) * pageSize
 
 	stmt := `
-        SELECT b.id, b.title, b.author, b.isbn, b.publication_year, b.created_at, b.updated_at, b.image_url , ub.user_id
-        FROM books b
-        LEFT JOIN user_books ub ON b.id = ub.book_id
-        ORDER BY created_at DESC
+        SELECT b.id, b.title, b.author, COALESCE(b.isbn, ''), b.publication_year, b.created_at, b.updated_at, b.image_url , ub.user_id,
+               COALESCE(r.rating, 0)` + filterFrom + where + `
+        ` + f.orderBy() + `
         LIMIT ? OFFSET ?
     `
 
-	rows, err := m.DB.Query(stmt, pageSize, offset)
+	rows, err := m.DB.Query(stmt, append(args, pageSize, offset)...)
 	if err != nil {
 		return PaginatedBooks{}, err
 	}
@@ -291,6 +335,7 @@ func (m *BookModel) List(page, pageSize int) (PaginatedBooks, error) {
 			&book.UpdatedAt,
 			&book.ImageURL,
 			&book.UserId,
+			&book.Rating,
 		)
 		if err != nil {
 			return PaginatedBooks{}, err
@@ -311,24 +356,35 @@ func (m *BookModel) List(page, pageSize int) (PaginatedBooks, error) {
 	}, nil
 }
 
-// Filter retrieves books where the title, notes, or reviews contain the given search term.
-func (m *BookModel) Filter(searchTerm string) ([]Book, error) {
-	searchTerm = "%" + searchTerm + "%"
+// Count retrieves the total number of book records in the database and returns the count or an error if the query fails.
+func (m *BookModel) Count() (int, error) {
+	var count int
+	err := m.DB.QueryRow("SELECT COUNT(*) FROM books").Scan(&count)
+	if err != nil {
+		var sqliteError sqlite3.Error
+		if errors.As(err, &sqliteError) {
+			return 0, sqliteError
+		}
+		return 0, err
+	}
+	return count, nil
+}
 
-	stmt := `
-	SELECT DISTINCT b.id, b.title, b.author, b.isbn, b.publication_year, b.created_at, b.updated_at, b.image_url
-	FROM books b
-	LEFT JOIN notes n ON b.id = n.book_id
-	LEFT JOIN reviews r ON b.id = r.book_id
-	WHERE b.title LIKE ? COLLATE NOCASE 
-   		OR n.note_text LIKE ? COLLATE NOCASE 
-   		OR r.review_text LIKE ? COLLATE NOCASE;
-	`
-
-	rows, err := m.DB.Query(stmt, searchTerm, searchTerm, searchTerm)
+// RetrieveRecentBooks fetches the two most recently created book records from the database and returns them or an error.
+func (m *BookModel) RetrieveRecentBooks(limit int) ([]Book, error) {
+	var books []Book
+	stmt := `SELECT id, title, author, COALESCE(isbn, ''), publication_year, created_at, updated_at, image_url 
+			FROM books ORDER BY created_at DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, limit)
 	if err != nil {
+		var sqliteError sqlite3.Error
+		if errors.As(err, &sqliteError) {
+			return nil, sqliteError
+		}
 		return nil, err
 	}
+
 	defer func() {
 		err = rows.Close()
 		if err != nil {
@@ -336,10 +392,9 @@ func (m *BookModel) Filter(searchTerm string) ([]Book, error) {
 		}
 	}()
 
-	var books []Book
 	for rows.Next() {
 		var book Book
-		if err := rows.Scan(
+		err = rows.Scan(
 			&book.ID,
 			&book.Title,
 			&book.Author,
@@ -347,8 +402,8 @@ func (m *BookModel) Filter(searchTerm string) ([]Book, error) {
 			&book.PublicationYear,
 			&book.CreatedAt,
 			&book.UpdatedAt,
-			&book.ImageURL,
-		); err != nil {
+			&book.ImageURL)
+		if err != nil {
 			return nil, err
 		}
 		books = append(books, book)
@@ -357,14 +412,14 @@ func (m *BookModel) Filter(searchTerm string) ([]Book, error) {
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-
 	return books, nil
 }
 
-// Count retrieves the total number of book records in the database and returns the count or an error if the query fails.
-func (m *BookModel) Count() (int, error) {
+// CountFinishedBooks returns the number of books marked as 'finished' by a specific user, identified by userId.
+func (m *BookModel) CountFinishedBooks(userId int) (int, error) {
 	var count int
-	err := m.DB.QueryRow("SELECT COUNT(*) FROM books").Scan(&count)
+	stmt := `SELECT COUNT(*) FROM user_books WHERE user_id = ? AND status = 'finished'`
+	err := m.DB.QueryRow(stmt, userId).Scan(&count)
 	if err != nil {
 		var sqliteError sqlite3.Error
 		if errors.As(err, &sqliteError) {
@@ -375,21 +430,15 @@ func (m *BookModel) Count() (int, error) {
 	return count, nil
 }
 
-// RetrieveRecentBooks fetches the two most recently created book records from the database and returns them or an error.
-func (m *BookModel) RetrieveRecentBooks(limit int) ([]Book, error) {
-	var books []Book
-	stmt := `SELECT id, title, author, isbn, publication_year, created_at, updated_at, image_url 
-			FROM books ORDER BY created_at DESC LIMIT ?`
+// All retrieves every book ordered by title, for places that need a complete selection list such as import matching.
+func (m *BookModel) All() ([]Book, error) {
+	stmt := `SELECT id, title, author, COALESCE(isbn, ''), publication_year, created_at, updated_at, image_url
+			FROM books ORDER BY title COLLATE NOCASE`
 
-	rows, err := m.DB.Query(stmt, limit)
+	rows, err := m.DB.Query(stmt)
 	if err != nil {
-		var sqliteError sqlite3.Error
-		if errors.As(err, &sqliteError) {
-			return nil, sqliteError
-		}
 		return nil, err
 	}
-
 	defer func() {
 		err = rows.Close()
 		if err != nil {
@@ -397,6 +446,7 @@ func (m *BookModel) RetrieveRecentBooks(limit int) ([]Book, error) {
 		}
 	}()
 
+	var books []Book
 	for rows.Next() {
 		var book Book
 		err = rows.Scan(
@@ -420,17 +470,119 @@ func (m *BookModel) RetrieveRecentBooks(limit int) ([]Book, error) {
 	return books, nil
 }
 
-// CountFinishedBooks returns the number of books marked as 'finished' by a specific user, identified by userId.
-func (m *BookModel) CountFinishedBooks(userId int) (int, error) {
-	var count int
-	stmt := `SELECT COUNT(*) FROM user_books WHERE user_id = ? AND status = 'finished'`
-	err := m.DB.QueryRow(stmt, userId).Scan(&count)
+// ImageReferences returns how many books use each image URL, so stored covers no book uses can be removed.
+func (m *BookModel) ImageReferences() (map[string]int, error) {
+	stmt := `SELECT image_url, COUNT(*) FROM books WHERE image_url != '' GROUP BY image_url`
+
+	rows, err := m.DB.Query(stmt)
 	if err != nil {
-		var sqliteError sqlite3.Error
-		if errors.As(err, &sqliteError) {
-			return 0, sqliteError
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
 		}
-		return 0, err
+	}()
+
+	references := make(map[string]int)
+	for rows.Next() {
+		var imageUrl string
+		var count int
+		if err := rows.Scan(&imageUrl, &count); err != nil {
+			return nil, err
+		}
+		references[imageUrl] = count
 	}
-	return count, nil
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// Shelf retrieves a paginated collection of the books in a user's library, newest first.
+// A non-empty status limits the books to that reading status and a non-empty tag to books with that tag.
+func (m *BookModel) Shelf(userId int, status, tag string, page, pageSize int) (PaginatedBooks, error) {
+	where := `WHERE ub.user_id = ?`
+	args := []any{userId}
+	if status != "" {
+		where += ` AND ub.status = ?`
+		args = append(args, status)
+	}
+	if tag != "" {
+		where += ` AND EXISTS (SELECT 1 FROM book_tags bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id = b.id AND t.name = ?)`
+		args = append(args, tag)
+	}
+
+	var total int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM books b JOIN user_books ub ON b.id = ub.book_id `+where, args...).Scan(&total)
+	if err != nil {
+		return PaginatedBooks{}, err
+	}
+
+	totalPages := (total + pageSize - 1) / pageSize
+
+	if page > totalPages && totalPages > 0 {
+		page = totalPages
+	}
+
+	offset := (page - 1) * pageSize
+
+	stmt := `
+        SELECT b.id, b.title, b.author, COALESCE(b.isbn, ''), COALESCE(b.publication_year, 0), b.created_at, b.updated_at,
+               COALESCE(b.image_url, ''), b.publisher, b.language, b.description, ub.user_id, ub.status
+        FROM books b
+        JOIN user_books ub ON b.id = ub.book_id
+        ` + where + `
+        ORDER BY ub.added_at DESC, b.id DESC
+        LIMIT ? OFFSET ?
+    `
+
+	rows, err := m.DB.Query(stmt, append(args, pageSize, offset)...)
+	if err != nil {
+		return PaginatedBooks{}, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var books []Book
+	for rows.Next() {
+		var book Book
+		err = rows.Scan(
+			&book.ID,
+			&book.Title,
+			&book.Author,
+			&book.ISBN,
+			&book.PublicationYear,
+			&book.CreatedAt,
+			&book.UpdatedAt,
+			&book.ImageURL,
+			&book.Publisher,
+			&book.Language,
+			&book.Description,
+			&book.UserId,
+			&book.Status,
+		)
+		if err != nil {
+			return PaginatedBooks{}, err
+		}
+		books = append(books, book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return PaginatedBooks{}, err
+	}
+
+	return PaginatedBooks{
+		Books:      books,
+		Total:      total,
+		Page:       page,
+		TotalPages: totalPages,
+		PageSize:   pageSize,
+	}, nil
 }
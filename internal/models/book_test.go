@@ -0,0 +1,173 @@
+package models
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestBookModel_Shelf tests that shelves only list the user's books, filtered by status and tag and paginated.
+func TestBookModel_Shelf(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	duneId, err := model.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = model.Create("The Hobbit", "J.R.R. Tolkien", "9780261102217", "reading", "", 1937, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = model.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 2, BookDetails{})
+	testutil.NoError(t, err)
+
+	tx, err := db.Begin()
+	testutil.NoError(t, err)
+	testutil.NoError(t, addBookTags(tx, int64(duneId), []string{"Science Fiction"}))
+	testutil.NoError(t, tx.Commit())
+
+	tests := []struct {
+		name       string
+		status     string
+		tag        string
+		page       int
+		pageSize   int
+		wantTitles []string
+		wantTotal  int
+	}{
+		{name: "whole library", page: 1, pageSize: 10, wantTitles: []string{"The Hobbit", "Dune"}, wantTotal: 2},
+		{name: "by status", status: "reading", page: 1, pageSize: 10, wantTitles: []string{"The Hobbit"}, wantTotal: 1},
+		{name: "by tag", tag: "science fiction", page: 1, pageSize: 10, wantTitles: []string{"Dune"}, wantTotal: 1},
+		{name: "second page", page: 2, pageSize: 1, wantTitles: []string{"Dune"}, wantTotal: 2},
+		{name: "page past the end", page: 5, pageSize: 1, wantTitles: []string{"Dune"}, wantTotal: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shelf, err := model.Shelf(1, tt.status, tt.tag, tt.page, tt.pageSize)
+			testutil.NoError(t, err)
+			testutil.Equal(t, shelf.Total, tt.wantTotal)
+
+			var titles []string
+			for _, book := range shelf.Books {
+				titles = append(titles, book.Title)
+			}
+			testutil.Equal(t, len(titles), len(tt.wantTitles))
+			for i := range titles {
+				testutil.Equal(t, titles[i], tt.wantTitles[i])
+			}
+		})
+	}
+}
+
+// TestBookModel_ImageReferences tests that books sharing a cover are counted and deleted books no longer are.
+func TestBookModel_ImageReferences(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	duneId, err := model.Create("Dune", "Frank Herbert", "9780441013593", "finished", "/uploads/a.jpg", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = model.Create("Dune Messiah", "Frank Herbert", "9780593098233", "reading", "/uploads/a.jpg", 1969, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = model.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	references, err := model.ImageReferences()
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(references), 1)
+	testutil.Equal(t, references["/uploads/a.jpg"], 2)
+
+	testutil.NoError(t, model.Delete(duneId, 1))
+	references, err = model.ImageReferences()
+	testutil.NoError(t, err)
+	testutil.Equal(t, references["/uploads/a.jpg"], 1)
+}
+
+// TestBookModel_SharedBook tests that a book in the libraries of two users is listed once, that each user retrieves
+// and filters it by their own status and that updating it leaves the status of the other user alone.
+func TestBookModel_SharedBook(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	duneId, err := model.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO user_books (user_id, book_id, status) VALUES (2, ?, 'reading')`, duneId)
+	testutil.NoError(t, err)
+
+	paginated, err := model.List(BookFilter{}, 1, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, paginated.Total, 1)
+	testutil.Equal(t, len(paginated.Books), 1)
+	testutil.Equal(t, paginated.Books[0].UserId, 1)
+
+	cursor, err := model.ListAfter(BookFilter{}, "", 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(cursor.Books), 1)
+
+	tests := []struct {
+		name       string
+		userId     int
+		wantUserId int
+		wantStatus string
+	}{
+		{name: "owner", userId: 1, wantUserId: 1, wantStatus: "finished"},
+		{name: "second reader", userId: 2, wantUserId: 2, wantStatus: "reading"},
+		{name: "anonymous", userId: 0, wantUserId: 1, wantStatus: "finished"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book, err := model.RetrieveFor(duneId, tt.userId)
+			testutil.NoError(t, err)
+			testutil.Equal(t, book.UserId, tt.wantUserId)
+			testutil.Equal(t, book.Status, tt.wantStatus)
+
+			if tt.userId == 0 {
+				return
+			}
+			paginated, err := model.List(BookFilter{Status: tt.wantStatus, Reader: tt.userId}, 1, 10)
+			testutil.NoError(t, err)
+			testutil.Equal(t, paginated.Total, 1)
+
+			facets, err := model.Facets(BookFilter{Reader: tt.userId})
+			testutil.NoError(t, err)
+			for _, facet := range facets.Statuses {
+				want := 0
+				if facet.Value == tt.wantStatus {
+					want = 1
+				}
+				testutil.Equal(t, facet.Count, want)
+			}
+		})
+	}
+
+	paginated, err = model.List(BookFilter{User: "other"}, 1, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, paginated.Total, 1)
+
+	testutil.NoError(t, model.Update(duneId, "Dune", "Frank Herbert", "9780441013593", "want_to_read", "", 1965, 2, BookDetails{}))
+	book, err := model.RetrieveFor(duneId, 1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Status, "finished")
+	book, err = model.RetrieveFor(duneId, 2)
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Status, "want_to_read")
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"log/slog"
+)
+
+// CalibreBook represents a book read from a Calibre library, identified by the UUID Calibre assigned to it.
+type CalibreBook struct {
+	UUID            string
+	Title           string
+	Author          string
+	ISBN            string
+	PublicationYear int
+	Tags            []string
+	LastModified    string
+	CoverPath       string
+}
+
+// CalibreModel provides methods to import Calibre libraries and remember which books they were imported into.
+type CalibreModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Import adds the books of a Calibre library to the library of a user within a single transaction.
+// Books imported before are found again by their Calibre UUID and updated when Calibre changed them since,
+// other books are matched by ISBN or by title and author, or created. Only books the import created are updated,
+// books it linked are shared with other readers and keep their catalog metadata, like with the Goodreads import. Tags are added to the books and
+// saveCover is called for books without a cover to store the Calibre cover and return its image URL.
+func (m *CalibreModel) Import(userId int, books []CalibreBook, saveCover func(CalibreBook) (string, error)) (ImportResult, error) {
+	var result ImportResult
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	for _, book := range books {
+		if book.UUID == "" || book.Title == "" || book.Author == "" {
+			result.Skipped++
+			continue
+		}
+
+		var bookId int64
+		var lastModified string
+		var created bool
+		err = tx.QueryRow(`SELECT book_id, last_modified, created FROM calibre_books WHERE user_id = ? AND calibre_uuid = ?`,
+			userId, book.UUID).Scan(&bookId, &lastModified, &created)
+		switch {
+		case err == nil:
+			if lastModified == book.LastModified {
+				result.Skipped++
+				continue
+			}
+			if !created {
+				result.Skipped++
+				break
+			}
+			if err = updateCalibreBook(tx, bookId, book); err != nil {
+				return ImportResult{}, err
+			}
+			result.Updated++
+		case errors.Is(err, sql.ErrNoRows):
+			bookId, err = findImportBook(tx, book.Title, book.Author, book.ISBN)
+			if err != nil {
+				return ImportResult{}, err
+			}
+			if bookId == 0 {
+				bookId, err = createCalibreBook(tx, book)
+				if err != nil {
+					return ImportResult{}, err
+				}
+				created = true
+				result.Created++
+			} else {
+				result.Linked++
+			}
+		default:
+			return ImportResult{}, err
+		}
+
+		if err = addBookTags(tx, bookId, book.Tags); err != nil {
+			return ImportResult{}, err
+		}
+
+		if book.CoverPath != "" && saveCover != nil {
+			var hasCover bool
+			err = tx.QueryRow(`SELECT COALESCE(image_url, '') != '' FROM books WHERE id = ?`, bookId).Scan(&hasCover)
+			if err != nil {
+				return ImportResult{}, err
+			}
+			if !hasCover {
+				var imageUrl string
+				imageUrl, err = saveCover(book)
+				if err != nil {
+					return ImportResult{}, err
+				}
+				if _, err = tx.Exec(`UPDATE books SET image_url = ? WHERE id = ?`, imageUrl, bookId); err != nil {
+					return ImportResult{}, err
+				}
+			}
+		}
+
+		_, err = tx.Exec(`INSERT OR IGNORE INTO user_books (user_id, book_id) VALUES (?, ?)`, userId, bookId)
+		if err != nil {
+			return ImportResult{}, err
+		}
+
+		_, err = tx.Exec(`INSERT INTO calibre_books (user_id, book_id, calibre_uuid, last_modified, created) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (user_id, calibre_uuid)
+			DO UPDATE SET book_id = excluded.book_id, last_modified = excluded.last_modified, updated_at = CURRENT_TIMESTAMP`,
+			userId, bookId, book.UUID, book.LastModified, created)
+		if err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// createCalibreBook inserts a new book for a Calibre entry and returns its ID.
+func createCalibreBook(tx *sql.Tx, book CalibreBook) (int64, error) {
+	var isbn sql.NullString
+	if book.ISBN != "" {
+		isbn = sql.NullString{String: book.ISBN, Valid: true}
+	}
+
+	result, err := tx.Exec(`INSERT INTO books (title, author, isbn, publication_year, image_url) VALUES (?, ?, ?, ?, '')`,
+		book.Title, book.Author, isbn, book.PublicationYear)
+	if err != nil {
+		var sqliteError sqlite3.Error
+		if errors.As(err, &sqliteError) {
+			return 0, sqliteError
+		}
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// updateCalibreBook updates a book created by an earlier import with the current Calibre metadata.
+// The ISBN is only changed when no other book uses it, since ISBNs are unique.
+func updateCalibreBook(tx *sql.Tx, bookId int64, book CalibreBook) error {
+	stmt := `UPDATE books SET title = ?, author = ?, publication_year = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := tx.Exec(stmt, book.Title, book.Author, book.PublicationYear, bookId); err != nil {
+		return err
+	}
+
+	if book.ISBN == "" {
+		return nil
+	}
+	stmt = `UPDATE books SET isbn = ? WHERE id = ? AND NOT EXISTS (SELECT 1 FROM books WHERE isbn = ? AND id != ?)`
+	_, err := tx.Exec(stmt, book.ISBN, bookId, book.ISBN, bookId)
+	return err
+}
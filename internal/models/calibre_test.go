@@ -0,0 +1,74 @@
+package models
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestCalibreModel_Import tests that a re-run updates previously imported books instead of duplicating them.
+func TestCalibreModel_Import(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := CalibreModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	_, err := books.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	library := []CalibreBook{
+		{UUID: "uuid-1", Title: "Good Omens", Author: "Terry Pratchett & Neil Gaiman", Tags: []string{"Fantasy", "Humor"}, LastModified: "1", CoverPath: "cover.jpg"},
+		{UUID: "uuid-2", Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Tags: []string{"Science Fiction"}, LastModified: "1"},
+	}
+
+	covers := 0
+	saveCover := func(book CalibreBook) (string, error) {
+		covers++
+		return "/uploads/cover.jpg", nil
+	}
+
+	result, err := model.Import(1, library, saveCover)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, ImportResult{Created: 1, Linked: 1})
+	testutil.Equal(t, covers, 1)
+
+	omens, err := books.Retrieve(2)
+	testutil.NoError(t, err)
+	testutil.Equal(t, omens.ImageURL, "/uploads/cover.jpg")
+	testutil.Equal(t, len(omens.Tags), 2)
+
+	// A changed book the import created is updated in place, a changed book it linked keeps its catalog metadata.
+	library[0].Title = "Good Omens: The Nice and Accurate Prophecies"
+	library[0].LastModified = "2"
+	library[1].Title = "Dune (Deluxe Edition)"
+	library[1].PublicationYear = 2019
+	library[1].LastModified = "2"
+	result, err = model.Import(1, library, saveCover)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, ImportResult{Updated: 1, Skipped: 1})
+	testutil.Equal(t, covers, 1)
+
+	count, err := books.Count()
+	testutil.NoError(t, err)
+	testutil.Equal(t, count, 2)
+
+	omens, err = books.Retrieve(2)
+	testutil.NoError(t, err)
+	testutil.Equal(t, omens.Title, "Good Omens: The Nice and Accurate Prophecies")
+
+	dune, err := books.Retrieve(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, dune.Title, "Dune")
+	testutil.Equal(t, dune.PublicationYear, 1965)
+
+	// An unchanged library is skipped.
+	result, err = model.Import(1, library, saveCover)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, ImportResult{Skipped: 2})
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"log/slog"
+	"time"
+)
+
+// KindleClipping represents a highlight, note or bookmark imported from a Kindle "My Clippings.txt" file.
+type KindleClipping struct {
+	ID            int
+	UserId        int
+	BookId        int
+	NoteId        int
+	Hash          string
+	Title         string
+	Author        string
+	Kind          string
+	PageNumber    int
+	LocationStart int
+	LocationEnd   int
+	Text          string
+	ClippedAt     time.Time
+	Status        string
+	CreatedAt     time.Time
+}
+
+// KindleTitle groups the pending clippings of a single Kindle title together with the book it was matched to, if any.
+type KindleTitle struct {
+	Title  string
+	Author string
+	Count  int
+	BookId int
+}
+
+// KindleClippingModel provides methods to stage Kindle clippings and turn them into notes.
+type KindleClippingModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Stage stores the given clippings for a user as pending, ignoring any clipping whose hash was already imported.
+// Returns the number of newly staged clippings.
+func (m *KindleClippingModel) Stage(userId int, clippings []KindleClipping) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO kindle_clippings
+    	(user_id, hash, title, author, kind, page_number, location_start, location_end, clipping_text, clipped_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	staged := 0
+	for _, c := range clippings {
+		var clippedAt sql.NullTime
+		if !c.ClippedAt.IsZero() {
+			clippedAt = sql.NullTime{Time: c.ClippedAt, Valid: true}
+		}
+
+		var result sql.Result
+		result, err = stmt.Exec(userId, c.Hash, c.Title, c.Author, c.Kind, c.PageNumber, c.LocationStart, c.LocationEnd, c.Text, clippedAt)
+		if err != nil {
+			var sqliteError sqlite3.Error
+			if errors.As(err, &sqliteError) {
+				return 0, sqliteError
+			}
+			return 0, err
+		}
+
+		var affected int64
+		affected, err = result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		staged += int(affected)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return staged, nil
+}
+
+// PendingTitles returns the titles that still have pending clippings for a user.
+// BookId is set when an earlier import of the same title and author was matched to a book.
+func (m *KindleClippingModel) PendingTitles(userId int) ([]KindleTitle, error) {
+	stmt := `
+		SELECT k.title, k.author, COUNT(*),
+		       COALESCE((SELECT m.book_id FROM kindle_clippings m
+		                 WHERE m.user_id = k.user_id AND m.title = k.title AND m.author = k.author AND m.book_id IS NOT NULL
+		                 ORDER BY m.updated_at DESC LIMIT 1), 0)
+		FROM kindle_clippings k
+		WHERE k.user_id = ? AND k.status = 'pending'
+		GROUP BY k.title, k.author
+		ORDER BY k.title`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var titles []KindleTitle
+	for rows.Next() {
+		var title KindleTitle
+		if err := rows.Scan(&title.Title, &title.Author, &title.Count, &title.BookId); err != nil {
+			return nil, err
+		}
+		titles = append(titles, title)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
+
+// ImportTitle creates a note with the text noteText returns for every pending clipping of a title and author, and
+// links the clippings to the book, within a single transaction. Returns the number of notes created, or
+// ErrNoRecord if the book does not exist.
+func (m *KindleClippingModel) ImportTitle(userId, bookId int, title, author string, noteText func(KindleClipping) string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM books WHERE id = ?)`, bookId).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		err = ErrNoRecord
+		return 0, err
+	}
+
+	rows, err := tx.Query(pendingStmt, userId, title, author)
+	if err != nil {
+		return 0, err
+	}
+	clippings, err := m.scanClippings(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	notes := NoteModel{DB: m.DB, Logger: m.Logger}
+	for _, c := range clippings {
+		var noteId int
+		noteId, err = notes.CreateTx(tx, userId, bookId, noteText(c), c.PageNumber)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(`UPDATE kindle_clippings SET status = 'imported', book_id = ?, note_id = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND user_id = ?`, bookId, noteId, c.ID, userId)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(clippings), nil
+}
+
+// pendingStmt selects the pending clippings of a user for a title and author, ordered by their position in the book.
+const pendingStmt = `SELECT id, user_id, hash, title, author, kind, page_number, location_start, location_end,
+       		clipping_text, clipped_at, status, created_at
+		FROM kindle_clippings
+		WHERE user_id = ? AND title = ? AND author = ? AND status = 'pending'
+		ORDER BY location_start, page_number, id`
+
+// scanClippings reads the clippings pendingStmt selects and closes rows.
+func (m *KindleClippingModel) scanClippings(rows *sql.Rows) ([]KindleClipping, error) {
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var clippings []KindleClipping
+	for rows.Next() {
+		var c KindleClipping
+		var clippedAt sql.NullTime
+		err := rows.Scan(
+			&c.ID,
+			&c.UserId,
+			&c.Hash,
+			&c.Title,
+			&c.Author,
+			&c.Kind,
+			&c.PageNumber,
+			&c.LocationStart,
+			&c.LocationEnd,
+			&c.Text,
+			&clippedAt,
+			&c.Status,
+			&c.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		c.ClippedAt = clippedAt.Time
+		clippings = append(clippings, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return clippings, nil
+}
+
+// Skip marks every pending clipping of a title and author as skipped so it is no longer offered for matching.
+// Skipped clippings keep their hash, so importing the same file again does not bring them back.
+func (m *KindleClippingModel) Skip(userId int, title, author string) error {
+	stmt := `UPDATE kindle_clippings SET status = 'skipped', updated_at = CURRENT_TIMESTAMP
+        	WHERE user_id = ? AND title = ? AND author = ? AND status = 'pending'`
+
+	_, err := m.DB.Exec(stmt, userId, title, author)
+	return err
+}
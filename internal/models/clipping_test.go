@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestKindleClippingModel_Stage tests that staging skips clippings already imported and groups pending titles.
+func TestKindleClippingModel_Stage(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := KindleClippingModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+
+	clippings := []KindleClipping{
+		{Hash: "a", Title: "The Hobbit", Author: "Tolkien", Kind: "highlight", LocationStart: 10, Text: "In a hole", ClippedAt: time.Now()},
+		{Hash: "b", Title: "The Hobbit", Author: "Tolkien", Kind: "note", LocationStart: 12, Text: "Nice"},
+		{Hash: "c", Title: "Dune", Author: "Herbert", Kind: "bookmark", LocationStart: 99},
+	}
+
+	staged, err := model.Stage(1, clippings)
+	testutil.NoError(t, err)
+	testutil.Equal(t, staged, 3)
+
+	// A repeated import of the same file must not stage anything again.
+	staged, err = model.Stage(1, clippings)
+	testutil.NoError(t, err)
+	testutil.Equal(t, staged, 0)
+
+	titles, err := model.PendingTitles(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(titles), 2)
+	testutil.Equal(t, titles[0].Title, "Dune")
+	testutil.Equal(t, titles[1].Count, 2)
+
+	testutil.NoError(t, model.Skip(1, "Dune", "Herbert"))
+
+	titles, err = model.PendingTitles(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(titles), 1)
+}
+
+// TestKindleClippingModel_ImportTitle tests that the clippings of a title and author are turned into notes together,
+// leaving those of another author with the same title pending, and that nothing is imported into a missing book.
+func TestKindleClippingModel_ImportTitle(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := KindleClippingModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	bookId, err := books.Create("Poems", "Emily Dickinson", "9780316184137", "reading", "", 1890, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	_, err = model.Stage(1, []KindleClipping{
+		{Hash: "a", Title: "Poems", Author: "Dickinson", Kind: "highlight", LocationStart: 20, Text: "Hope is the thing"},
+		{Hash: "b", Title: "Poems", Author: "Dickinson", Kind: "highlight", LocationStart: 10, Text: "Because I could not stop"},
+		{Hash: "c", Title: "Poems", Author: "Frost", Kind: "highlight", LocationStart: 5, Text: "Two roads diverged"},
+	})
+	testutil.NoError(t, err)
+
+	text := func(c KindleClipping) string { return c.Text }
+	_, err = model.ImportTitle(1, bookId+1, "Poems", "Dickinson", text)
+	testutil.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	imported, err := model.ImportTitle(1, bookId, "Poems", "Dickinson", text)
+	testutil.NoError(t, err)
+	testutil.Equal(t, imported, 2)
+
+	var first string
+	err = db.QueryRow(`SELECT note_text FROM notes WHERE book_id = ? ORDER BY id LIMIT 1`, bookId).Scan(&first)
+	testutil.NoError(t, err)
+	testutil.Equal(t, first, "Because I could not stop")
+
+	titles, err := model.PendingTitles(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(titles), 1)
+	testutil.Equal(t, titles[0].Author, "Frost")
+	testutil.Equal(t, titles[0].BookId, 0)
+}
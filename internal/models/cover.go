@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// sqliteTime is the layout of the times SQLite stores for CURRENT_TIMESTAMP, which times are compared with.
+const sqliteTime = "2006-01-02 15:04:05"
+
+// CoverCandidate represents a book with an ISBN but no cover, and the number of failed attempts to download one.
+type CoverCandidate struct {
+	BookId   int
+	ISBN     string
+	Attempts int
+}
+
+// CoverModel provides methods to find books without a cover and to record downloads of their covers.
+type CoverModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Pending returns up to limit books with an ISBN and no cover that are due for a download at now,
+// books that were never tried first.
+func (m *CoverModel) Pending(now time.Time, limit int) ([]CoverCandidate, error) {
+	stmt := `SELECT b.id, b.isbn, COALESCE(cf.attempts, 0)
+		FROM books b
+		LEFT JOIN cover_fetches cf ON cf.book_id = b.id
+		WHERE COALESCE(b.isbn, '') != '' AND COALESCE(b.image_url, '') = ''
+		  AND (cf.book_id IS NULL OR cf.next_attempt_at <= ?)
+		ORDER BY COALESCE(cf.attempts, 0), b.id
+		LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, now.UTC().Format(sqliteTime), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var candidates []CoverCandidate
+	for rows.Next() {
+		var c CoverCandidate
+		if err := rows.Scan(&c.BookId, &c.ISBN, &c.Attempts); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// SetImage sets the downloaded cover of a book and forgets its failed attempts. Returns false, without changing
+// the book, if it got a cover in the meantime or no longer exists.
+func (m *CoverModel) SetImage(bookId int, imageURL string) (bool, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	result, err := tx.Exec(`UPDATE books SET image_url = ? WHERE id = ? AND COALESCE(image_url, '') = ''`, imageURL, bookId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM cover_fetches WHERE book_id = ?`, bookId); err != nil {
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RecordFailure records a failed download of the cover of a book, the attempts made so far
+// and when to try again.
+func (m *CoverModel) RecordFailure(bookId, attempts int, nextAttempt time.Time, reason string) error {
+	stmt := `INSERT INTO cover_fetches (book_id, attempts, next_attempt_at, last_error) VALUES (?, ?, ?, ?)
+		ON CONFLICT (book_id) DO UPDATE SET attempts = excluded.attempts, next_attempt_at = excluded.next_attempt_at,
+		    last_error = excluded.last_error, updated_at = CURRENT_TIMESTAMP`
+	_, err := m.DB.Exec(stmt, bookId, attempts, nextAttempt.UTC().Format(sqliteTime), reason)
+	return err
+}
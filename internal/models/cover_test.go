@@ -0,0 +1,63 @@
+package models
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestCoverModel tests that books with an ISBN and no cover are pending until they get one,
+// that failed books wait until their next attempt and that covers set by users are not replaced.
+func TestCoverModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := CoverModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	duneId, err := books.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	emmaId, err := books.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = books.Create("The Hobbit", "J.R.R. Tolkien", "9780261102217", "reading", "/uploads/1-hobbit.png", 1937, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	now := time.Now()
+	pending, err := model.Pending(now, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(pending), 2)
+	testutil.Equal(t, pending[0].BookId, duneId)
+
+	testutil.NoError(t, model.RecordFailure(duneId, 1, now.Add(time.Hour), "timeout"))
+	pending, err = model.Pending(now, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(pending), 1)
+	testutil.Equal(t, pending[0].BookId, emmaId)
+
+	pending, err = model.Pending(now.Add(2*time.Hour), 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(pending), 2)
+	testutil.Equal(t, pending[0].BookId, emmaId)
+	testutil.Equal(t, pending[1].Attempts, 1)
+
+	ok, err := model.SetImage(duneId, "/uploads/2-cover-9780441013593.jpg")
+	testutil.NoError(t, err)
+	testutil.Equal(t, ok, true)
+	ok, err = model.SetImage(duneId, "/uploads/3-cover-9780441013593.jpg")
+	testutil.NoError(t, err)
+	testutil.Equal(t, ok, false)
+
+	book, err := books.Retrieve(duneId)
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.ImageURL, "/uploads/2-cover-9780441013593.jpg")
+
+	var failures int
+	testutil.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM cover_fetches`).Scan(&failures))
+	testutil.Equal(t, failures, 0)
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// CursorBooks is a page of the book list read after a cursor, which stays in place while books are added or removed.
+type CursorBooks struct {
+	Books []Book
+
+	// Next is the cursor of the following page, or empty on the last page.
+	Next string
+}
+
+// cursor is the position of a book in the book list, as the values its order sorts the book by.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+// ListAfter retrieves up to pageSize books matching the filter that come after the cursor in its order, starting
+// from the first book when the cursor is empty. Unlike List, it neither counts the books nor skips over those of
+// the previous pages, so deep pages are as fast as the first. Returns ErrInvalidCursor when the cursor is not one
+// returned by ListAfter for the order of the filter.
+func (m *BookModel) ListAfter(f BookFilter, after string, pageSize int) (CursorBooks, error) {
+	keys := f.keys()
+	where, args := f.where("")
+
+	if after != "" {
+		values, err := decodeCursor(after, f.Sort, len(keys))
+		if err != nil {
+			return CursorBooks{}, err
+		}
+
+		// The books after the cursor sort after it by their first key, or by the same first key and after it by
+		// the second, and so on.
+		var alternatives []string
+		for i, key := range keys {
+			var terms []string
+			for _, prev := range keys[:i] {
+				terms = append(terms, `(`+prev.expr+`) = ?`)
+			}
+			op := ` > ?`
+			if key.desc {
+				op = ` < ?`
+			}
+			terms = append(terms, `(`+key.expr+`)`+op)
+			alternatives = append(alternatives, `(`+strings.Join(terms, ` AND `)+`)`)
+			args = append(args, values[:i+1]...)
+		}
+		where += ` AND (` + strings.Join(alternatives, ` OR `) + `)`
+	}
+
+	var columns []string
+	for _, key := range keys {
+		columns = append(columns, `(`+key.expr+`)`)
+	}
+
+	// One more book than fits the page is read to know whether there is a following page.
+	stmt := `
+        SELECT b.id, b.title, b.author, COALESCE(b.isbn, ''), b.publication_year, b.created_at, b.updated_at, b.image_url, ub.user_id,
+               COALESCE(r.rating, 0), ` + strings.Join(columns, ", ") + filterFrom + where + `
+        ` + f.orderBy() + `
+        LIMIT ?
+    `
+
+	rows, err := m.DB.Query(stmt, append(args, pageSize+1)...)
+	if err != nil {
+		return CursorBooks{}, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var page CursorBooks
+	var last []any
+	for rows.Next() {
+		if len(page.Books) == pageSize {
+			page.Next, err = encodeCursor(f.Sort, last)
+			if err != nil {
+				return CursorBooks{}, err
+			}
+			break
+		}
+
+		var book Book
+		values := make([]any, len(keys))
+		dest := []any{
+			&book.ID,
+			&book.Title,
+			&book.Author,
+			&book.ISBN,
+			&book.PublicationYear,
+			&book.CreatedAt,
+			&book.UpdatedAt,
+			&book.ImageURL,
+			&book.UserId,
+			&book.Rating,
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return CursorBooks{}, err
+		}
+		page.Books = append(page.Books, book)
+		last = values
+	}
+
+	if err := rows.Err(); err != nil {
+		return CursorBooks{}, err
+	}
+	return page, nil
+}
+
+// encodeCursor encodes the position of a book in the order named sort as an opaque, URL safe string.
+func encodeCursor(sort string, values []any) (string, error) {
+	data, err := json.Marshal(cursor{Sort: sort, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the values of a cursor encoded by encodeCursor, checking that it is a position in the order
+// named sort, which sorts by n values.
+func decodeCursor(s, sort string, n int) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil || c.Sort != sort || len(c.Values) != n {
+		return nil, ErrInvalidCursor
+	}
+
+	// Numbers are passed on as integers when they are, so they compare with the IDs and years as they are stored.
+	for i, v := range c.Values {
+		switch v := v.(type) {
+		case json.Number:
+			if integer, err := v.Int64(); err == nil {
+				c.Values[i] = integer
+			} else if f, err := v.Float64(); err == nil {
+				c.Values[i] = f
+			} else {
+				return nil, ErrInvalidCursor
+			}
+		case string:
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return c.Values, nil
+}
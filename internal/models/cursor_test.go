@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestBookModel_ListAfter tests that paging with cursors lists the same books as List in every order, and
+// that books added while paging neither repeat nor shift the books of the following pages.
+func TestBookModel_ListAfter(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	seedFilterBooks(t, &model, &reviews)
+	_, err := model.Create("Unknown Year", "Frank Herbert", "9780000000002", "reading", "", 0, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	// Every book is added in the same second, as happens on imports, so books sort by their ID as well.
+	_, err = db.Exec(`UPDATE books SET created_at = '2026-01-01 10:00:00'`)
+	testutil.NoError(t, err)
+
+	pages := func(filter BookFilter) string {
+		var titles []string
+		after := ""
+		for {
+			page, err := model.ListAfter(filter, after, 2)
+			testutil.NoError(t, err)
+			if len(page.Books) > 2 {
+				t.Fatalf("got %d books; want at most 2", len(page.Books))
+			}
+			for _, book := range page.Books {
+				titles = append(titles, book.Title)
+			}
+			if page.Next == "" {
+				return strings.Join(titles, ",")
+			}
+			after = page.Next
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter BookFilter
+	}{
+		{name: "newest first"},
+		{name: "by title", filter: BookFilter{Sort: SortTitle}},
+		{name: "by author", filter: BookFilter{Sort: SortAuthor}},
+		{name: "by year", filter: BookFilter{Sort: SortYear}},
+		{name: "by rating", filter: BookFilter{Sort: SortRating}},
+		{name: "filtered", filter: BookFilter{Author: "Frank Herbert", Sort: SortYear}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := model.List(tt.filter, 1, 10)
+			testutil.NoError(t, err)
+			var want []string
+			for _, book := range list.Books {
+				want = append(want, book.Title)
+			}
+			testutil.Equal(t, pages(tt.filter), strings.Join(want, ","))
+		})
+	}
+
+	first, err := model.ListAfter(BookFilter{}, "", 2)
+	testutil.NoError(t, err)
+	testutil.Equal(t, first.Books[0].Title, "Unknown Year")
+
+	_, err = model.Create("Persuasion", "Jane Austen", "9780141439686", "reading", "", 1817, 1, BookDetails{})
+	testutil.NoError(t, err)
+	second, err := model.ListAfter(BookFilter{}, first.Next, 2)
+	testutil.NoError(t, err)
+	testutil.Equal(t, second.Books[0].Title, "The Hobbit")
+}
+
+// TestBookModel_ListAfterInvalidCursor tests that cursors that were changed or belong to another order are refused.
+func TestBookModel_ListAfterInvalidCursor(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	seedFilterBooks(t, &model, &reviews)
+
+	page, err := model.ListAfter(BookFilter{Sort: SortTitle}, "", 1)
+	testutil.NoError(t, err)
+
+	object, err := encodeCursor(SortTitle, []any{map[string]string{}, 1})
+	testutil.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		filter BookFilter
+		after  string
+	}{
+		{name: "other order", filter: BookFilter{Sort: SortYear}, after: page.Next},
+		{name: "not base64", filter: BookFilter{Sort: SortTitle}, after: "not a cursor!"},
+		{name: "not json", filter: BookFilter{Sort: SortTitle}, after: "bm90IGpzb24"},
+		{name: "object value", filter: BookFilter{Sort: SortTitle}, after: object},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := model.ListAfter(tt.filter, tt.after, 1)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("got %v; want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LibraryEntry represents a book in a user's library together with the user's status, dates and latest review.
+type LibraryEntry struct {
+	BookId          int
+	Title           string
+	Author          string
+	ISBN            string
+	PublicationYear int
+	PageCount       int
+	Status          string
+	Progress        int
+	ImageURL        string
+	Publisher       string
+	Language        string
+	Description     string
+	Rating          int
+	Review          string
+	AddedAt         time.Time
+	FinishedAt      time.Time
+}
+
+// EachInLibrary calls fn for every book in the library of a user, in the order the books were added.
+// Rows are passed on as they are read, so callers can stream large libraries without holding them in memory.
+// Iteration stops at the first error returned by fn.
+func (m *BookModel) EachInLibrary(userId int, fn func(LibraryEntry) error) error {
+	stmt := `
+		SELECT b.id, b.title, b.author, COALESCE(b.isbn, ''), COALESCE(b.publication_year, 0), b.page_count, COALESCE(ub.status, 'want_to_read'), ub.progress,
+		       COALESCE(b.image_url, ''), b.publisher, b.language, b.description, COALESCE(r.rating, 0), COALESCE(r.review_text, ''), ub.added_at, ub.finished_at
+		FROM user_books ub
+		JOIN books b ON b.id = ub.book_id
+		LEFT JOIN reviews r ON r.id = (SELECT id FROM reviews
+		                               WHERE user_id = ub.user_id AND book_id = ub.book_id
+		                               ORDER BY updated_at DESC, id DESC LIMIT 1)
+		WHERE ub.user_id = ?
+		ORDER BY ub.added_at, b.id`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	for rows.Next() {
+		var entry LibraryEntry
+		var addedAt, finishedAt sql.NullTime
+		err := rows.Scan(
+			&entry.BookId,
+			&entry.Title,
+			&entry.Author,
+			&entry.ISBN,
+			&entry.PublicationYear,
+			&entry.PageCount,
+			&entry.Status,
+			&entry.Progress,
+			&entry.ImageURL,
+			&entry.Publisher,
+			&entry.Language,
+			&entry.Description,
+			&entry.Rating,
+			&entry.Review,
+			&addedAt,
+			&finishedAt,
+		)
+		if err != nil {
+			return err
+		}
+		entry.AddedAt = addedAt.Time
+		entry.FinishedAt = finishedAt.Time
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
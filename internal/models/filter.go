@@ -0,0 +1,388 @@
+package models
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/rules"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Orders the book list can be sorted in. Titles and authors are sorted alphabetically, years, dates added
+// and ratings with the highest first.
+const (
+	SortAdded  = "added"
+	SortTitle  = "title"
+	SortAuthor = "author"
+	SortYear   = "year"
+	SortRating = "rating"
+)
+
+// Values of BookFilter.Cover.
+const (
+	CoverWith    = "yes"
+	CoverWithout = "no"
+)
+
+// dateLayout is the layout of the dates added in query strings.
+const dateLayout = "2006-01-02"
+
+// facetAuthors is the most authors the author facet lists.
+const facetAuthors = 10
+
+// sortKey is an expression the book list is sorted by.
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// sortKeys maps the orders of the book list to the expressions they sort by, ending with the ID of the books so that
+// every book has its own place in the list.
+var sortKeys = map[string][]sortKey{
+	SortAdded:  {{expr: `julianday(b.created_at)`, desc: true}, {expr: `b.id`, desc: true}},
+	SortTitle:  {{expr: `b.title COLLATE NOCASE`}, {expr: `b.id`}},
+	SortAuthor: {{expr: `b.author COLLATE NOCASE`}, {expr: `b.title COLLATE NOCASE`}, {expr: `b.id`}},
+	SortYear: {
+		{expr: `COALESCE(b.publication_year, 0) = 0`},
+		{expr: `COALESCE(b.publication_year, 0)`, desc: true},
+		{expr: `b.id`, desc: true},
+	},
+	SortRating: {{expr: `r.rating IS NULL`}, {expr: `COALESCE(r.rating, 0)`, desc: true}, {expr: `b.id`, desc: true}},
+}
+
+// BookFilter narrows down and orders the book list. Zero fields do not filter.
+type BookFilter struct {
+	Author string
+
+	// YearFrom and YearTo are the first and the last publication year, inclusive.
+	YearFrom int
+	YearTo   int
+
+	Status string
+
+	// MinRating is the lowest average rating of the reviews of a book.
+	MinRating int
+
+	// Cover is CoverWith or CoverWithout to only list books with or without a cover.
+	Cover string
+
+	// AddedFrom and AddedTo are the first and the last day books were added, inclusive.
+	AddedFrom time.Time
+	AddedTo   time.Time
+
+	// Tag and User only list the books with a tag and those in the library of a user, by name.
+	Tag  string
+	User string
+
+	// Reader is the ID of the user listing the books, whose library Status and the status facet refer to; without
+	// one, they refer to the library of the user who added each book.
+	Reader int
+
+	// Shelf is the ID of a smart shelf to only list the books on it, once SmartShelf.Apply has applied its rule.
+	Shelf int
+	rule  *shelfRule
+
+	// Sort is the order of the list, one of the Sort constants; the newest books come first by default.
+	Sort string
+}
+
+// FacetCount is an option of a filter and the number of books it leaves.
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// BookFacets holds the options of the filters of the book list, with the number of books each would leave
+// combined with the other filters.
+type BookFacets struct {
+
+	// Authors holds the authors with the most books, and the selected author.
+	Authors []FacetCount
+
+	// Decades holds the decades books were published in, such as "1960", oldest first.
+	Decades []FacetCount
+
+	Statuses []FacetCount
+
+	// Ratings holds the lowest average ratings from 4 down to 1, counting the books rated at least as high.
+	Ratings []FacetCount
+
+	// Covers holds CoverWith and CoverWithout.
+	Covers []FacetCount
+}
+
+// ParseBookFilter returns the filter encoded in a query string by BookFilter.Encode. Values that are not valid
+// are ignored.
+func ParseBookFilter(query url.Values) BookFilter {
+	var f BookFilter
+	f.Author = strings.TrimSpace(query.Get("author"))
+	f.YearFrom, _ = strconv.Atoi(query.Get("year_from"))
+	f.YearTo, _ = strconv.Atoi(query.Get("year_to"))
+
+	switch status := query.Get("status"); status {
+	case "want_to_read", "reading", "finished":
+		f.Status = status
+	}
+	if rating, err := strconv.Atoi(query.Get("rating")); err == nil && rating >= 1 && rating <= 5 {
+		f.MinRating = rating
+	}
+	switch cover := query.Get("cover"); cover {
+	case CoverWith, CoverWithout:
+		f.Cover = cover
+	}
+
+	f.Tag = strings.TrimSpace(query.Get("tag"))
+	f.User = strings.TrimSpace(query.Get("user"))
+	f.Shelf, _ = strconv.Atoi(query.Get("shelf"))
+	f.AddedFrom, _ = time.Parse(dateLayout, query.Get("added_from"))
+	f.AddedTo, _ = time.Parse(dateLayout, query.Get("added_to"))
+
+	if sort := query.Get("sort"); sort != SortAdded {
+		if _, ok := sortKeys[sort]; ok {
+			f.Sort = sort
+		}
+	}
+	return f
+}
+
+// Encode encodes the filter as a query string for ParseBookFilter, leaving out the fields that do not filter.
+func (f BookFilter) Encode() string {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	setInt := func(key string, value int) {
+		if value != 0 {
+			query.Set(key, strconv.Itoa(value))
+		}
+	}
+	set("author", f.Author)
+	setInt("year_from", f.YearFrom)
+	setInt("year_to", f.YearTo)
+	set("status", f.Status)
+	setInt("rating", f.MinRating)
+	set("cover", f.Cover)
+	set("tag", f.Tag)
+	set("user", f.User)
+	setInt("shelf", f.Shelf)
+	if !f.AddedFrom.IsZero() {
+		set("added_from", f.AddedFrom.Format(dateLayout))
+	}
+	if !f.AddedTo.IsZero() {
+		set("added_to", f.AddedTo.Format(dateLayout))
+	}
+	set("sort", f.Sort)
+	return query.Encode()
+}
+
+// IsZero reports whether the filter lists every book.
+func (f BookFilter) IsZero() bool {
+	f.Sort = ""
+	f.Reader = 0
+	return f == BookFilter{}
+}
+
+// Rule returns the filter as the rule of a smart shelf, joined to the rule of the shelf it lists the books of.
+func (f BookFilter) Rule() string {
+	var conditions []string
+	add := func(field, op, value string) {
+		if value != "" && value != "0" {
+			conditions = append(conditions, field+op+rules.Quote(value))
+		}
+	}
+	if f.rule != nil {
+		conditions = append(conditions, "("+f.rule.text+")")
+	}
+	add(rules.FieldAuthor, rules.OpEqual, f.Author)
+	add(rules.FieldYear, rules.OpGreaterEqual, strconv.Itoa(f.YearFrom))
+	add(rules.FieldYear, rules.OpLessEqual, strconv.Itoa(f.YearTo))
+	add(rules.FieldStatus, rules.OpHas, f.Status)
+	add(rules.FieldRating, rules.OpGreaterEqual, strconv.Itoa(f.MinRating))
+	add(rules.FieldCover, rules.OpHas, f.Cover)
+	add(rules.FieldTag, rules.OpHas, f.Tag)
+	add(rules.FieldUser, rules.OpHas, f.User)
+	if !f.AddedFrom.IsZero() {
+		add(rules.FieldAdded, rules.OpGreaterEqual, f.AddedFrom.Format(rules.DateLayout))
+	}
+	if !f.AddedTo.IsZero() {
+		add(rules.FieldAdded, rules.OpLessEqual, f.AddedTo.Format(rules.DateLayout))
+	}
+	return strings.Join(conditions, " ")
+}
+
+// where returns the WHERE clause of the filter and its arguments, leaving out the filter named by skip
+// so the options of that filter can be counted.
+func (f BookFilter) where(skip string) (string, []any) {
+	conditions := []string{"1 = 1"}
+	var args []any
+	add := func(condition string, arg any) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if f.Author != "" && skip != "author" {
+		add(`b.author = ? COLLATE NOCASE`, f.Author)
+	}
+	if f.YearFrom != 0 && skip != "year" {
+		add(`b.publication_year >= ?`, f.YearFrom)
+	}
+	if f.YearTo != 0 && skip != "year" {
+		add(`b.publication_year <= ?`, f.YearTo)
+	}
+	if f.Status != "" && skip != "status" {
+		if f.Reader != 0 {
+			conditions = append(conditions, `EXISTS (SELECT 1 FROM user_books WHERE book_id = b.id AND user_id = ? AND status = ?)`)
+			args = append(args, f.Reader, f.Status)
+		} else {
+			add(`ub.status = ?`, f.Status)
+		}
+	}
+	if f.MinRating != 0 && skip != "rating" {
+		add(`r.rating >= ?`, f.MinRating)
+	}
+	if f.Cover == CoverWith && skip != "cover" {
+		conditions = append(conditions, `COALESCE(b.image_url, '') != ''`)
+	}
+	if f.Cover == CoverWithout && skip != "cover" {
+		conditions = append(conditions, `COALESCE(b.image_url, '') = ''`)
+	}
+	if f.Tag != "" {
+		add(`EXISTS (SELECT 1 FROM book_tags bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id = b.id AND t.name = ?)`, f.Tag)
+	}
+	if f.User != "" {
+		add(`EXISTS (SELECT 1 FROM user_books WHERE book_id = b.id AND user_id = (SELECT id FROM users WHERE username = ? COLLATE NOCASE))`, f.User)
+	}
+	if !f.AddedFrom.IsZero() {
+		add(`date(b.created_at) >= ?`, f.AddedFrom.Format(dateLayout))
+	}
+	if !f.AddedTo.IsZero() {
+		add(`date(b.created_at) <= ?`, f.AddedTo.Format(dateLayout))
+	}
+	if f.rule != nil {
+		conditions = append(conditions, f.rule.where)
+		args = append(args, f.rule.args...)
+	}
+	return `WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// keys returns the expressions the order of the filter sorts by.
+func (f BookFilter) keys() []sortKey {
+	if keys, ok := sortKeys[f.Sort]; ok {
+		return keys
+	}
+	return sortKeys[SortAdded]
+}
+
+// orderBy returns the ORDER BY clause of the order of the filter.
+func (f BookFilter) orderBy() string {
+	var terms []string
+	for _, key := range f.keys() {
+		term := key.expr
+		if key.desc {
+			term += ` DESC`
+		}
+		terms = append(terms, term)
+	}
+	return `ORDER BY ` + strings.Join(terms, ", ")
+}
+
+// filterFrom joins books to their owner, the user who first added them to their library, and to the average rating
+// of their reviews, for the filters of the book list. Books in the libraries of several users are joined once.
+const filterFrom = `
+        FROM books b
+        LEFT JOIN user_books ub ON ub.id = (SELECT MIN(id) FROM user_books WHERE book_id = b.id)
+        LEFT JOIN (SELECT book_id, AVG(rating) AS rating FROM reviews GROUP BY book_id) r ON r.book_id = b.id
+    `
+
+// Facets counts the books each option of the filters of the book list would leave, combined with the other filters
+// of f.
+func (m *BookModel) Facets(f BookFilter) (BookFacets, error) {
+	var facets BookFacets
+	var err error
+
+	where, args := f.where("author")
+	facets.Authors, err = m.facetCounts(`SELECT b.author, COUNT(*)`+filterFrom+where+`
+        GROUP BY b.author COLLATE NOCASE
+        ORDER BY b.author = ? COLLATE NOCASE DESC, COUNT(*) DESC, b.author COLLATE NOCASE
+        LIMIT ?`, append(args, f.Author, facetAuthors)...)
+	if err != nil {
+		return BookFacets{}, err
+	}
+
+	where, args = f.where("year")
+	facets.Decades, err = m.facetCounts(`SELECT b.publication_year / 10 * 10 AS decade, COUNT(*)`+filterFrom+where+`
+        AND b.publication_year > 0
+        GROUP BY decade
+        ORDER BY decade`, args...)
+	if err != nil {
+		return BookFacets{}, err
+	}
+
+	where, args = f.where("status")
+	status := `ub.status`
+	if f.Reader != 0 {
+		status = `(SELECT status FROM user_books WHERE book_id = b.id AND user_id = ?)`
+		args = append([]any{f.Reader}, args...)
+	}
+	facets.Statuses, err = m.facetCounts(`SELECT s.status, COUNT(b.id)
+        FROM (SELECT 'want_to_read' AS status UNION ALL SELECT 'reading' UNION ALL SELECT 'finished') s
+        LEFT JOIN (SELECT b.id, `+status+` AS status`+filterFrom+where+`) b ON b.status = s.status
+        GROUP BY s.status
+        ORDER BY CASE s.status WHEN 'want_to_read' THEN 1 WHEN 'reading' THEN 2 ELSE 3 END`, args...)
+	if err != nil {
+		return BookFacets{}, err
+	}
+
+	where, args = f.where("rating")
+	facets.Ratings, err = m.facetCounts(`SELECT s.rating, COUNT(b.id)
+        FROM (SELECT 4 AS rating UNION ALL SELECT 3 UNION ALL SELECT 2 UNION ALL SELECT 1) s
+        LEFT JOIN (SELECT b.id, r.rating`+filterFrom+where+`) b ON b.rating >= s.rating
+        GROUP BY s.rating
+        ORDER BY s.rating DESC`, args...)
+	if err != nil {
+		return BookFacets{}, err
+	}
+
+	where, args = f.where("cover")
+	facets.Covers, err = m.facetCounts(`SELECT s.cover, COUNT(b.id)
+        FROM (SELECT '`+CoverWith+`' AS cover UNION ALL SELECT '`+CoverWithout+`') s
+        LEFT JOIN (SELECT b.id, CASE WHEN COALESCE(b.image_url, '') != '' THEN '`+CoverWith+`' ELSE '`+CoverWithout+`' END AS cover`+
+		filterFrom+where+`) b ON b.cover = s.cover
+        GROUP BY s.cover
+        ORDER BY s.cover = '`+CoverWithout+`'`, args...)
+	if err != nil {
+		return BookFacets{}, err
+	}
+
+	return facets, nil
+}
+
+// facetCounts runs a query returning the options of a filter and their number of books.
+func (m *BookModel) facetCounts(stmt string, args ...any) ([]FacetCount, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var counts []FacetCount
+	for rows.Next() {
+		var c FacetCount
+		if err := rows.Scan(&c.Value, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
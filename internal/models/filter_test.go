@@ -0,0 +1,177 @@
+package models
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestParseBookFilter tests that filters survive a round trip through a query string and invalid values are ignored.
+func TestParseBookFilter(t *testing.T) {
+	filter := BookFilter{
+		Author:    "Frank Herbert",
+		YearFrom:  1960,
+		YearTo:    1969,
+		Status:    "finished",
+		MinRating: 4,
+		Cover:     CoverWith,
+		AddedFrom: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		AddedTo:   time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		Tag:       "Science Fiction",
+		User:      "reader",
+		Sort:      SortRating,
+	}
+	query, err := url.ParseQuery(filter.Encode())
+	testutil.NoError(t, err)
+	testutil.Equal(t, ParseBookFilter(query), filter)
+
+	testutil.Equal(t, BookFilter{}.Encode(), "")
+	testutil.Equal(t, BookFilter{}.IsZero(), true)
+	testutil.Equal(t, BookFilter{Sort: SortTitle}.IsZero(), true)
+	testutil.Equal(t, filter.IsZero(), false)
+
+	invalid := url.Values{
+		"year_from":  {"soon"},
+		"status":     {"lost"},
+		"rating":     {"9"},
+		"cover":      {"maybe"},
+		"added_from": {"yesterday"},
+		"sort":       {"random"},
+	}
+	testutil.Equal(t, ParseBookFilter(invalid), BookFilter{})
+	testutil.Equal(t, ParseBookFilter(url.Values{"sort": {SortAdded}}), BookFilter{})
+}
+
+// TestBookModel_List tests that the book list is filtered, sorted and paginated.
+func TestBookModel_List(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	duneId := seedFilterBooks(t, &model, &reviews)
+
+	_, err := db.Exec(`UPDATE books SET created_at = '2025-06-01 10:00:00' WHERE id = ?`, duneId)
+	testutil.NoError(t, err)
+	tx, err := db.Begin()
+	testutil.NoError(t, err)
+	testutil.NoError(t, addBookTags(tx, int64(duneId), []string{"Science Fiction"}))
+	testutil.NoError(t, tx.Commit())
+
+	tests := []struct {
+		name     string
+		filter   BookFilter
+		page     int
+		pageSize int
+		want     string
+		total    int
+	}{
+		{name: "newest first", page: 1, pageSize: 10, want: "Emma,The Hobbit,Dune Messiah,Dune", total: 4},
+		{name: "author", filter: BookFilter{Author: "frank herbert"}, page: 1, pageSize: 10, want: "Dune Messiah,Dune", total: 2},
+		{name: "years", filter: BookFilter{YearFrom: 1900, YearTo: 1965}, page: 1, pageSize: 10, want: "The Hobbit,Dune", total: 2},
+		{name: "status", filter: BookFilter{Status: "reading"}, page: 1, pageSize: 10, want: "Emma,Dune Messiah", total: 2},
+		{name: "rating", filter: BookFilter{MinRating: 4}, page: 1, pageSize: 10, want: "The Hobbit,Dune", total: 2},
+		{name: "with a cover", filter: BookFilter{Cover: CoverWith}, page: 1, pageSize: 10, want: "Dune", total: 1},
+		{name: "without a cover", filter: BookFilter{Cover: CoverWithout}, page: 1, pageSize: 10, want: "Emma,The Hobbit,Dune Messiah", total: 3},
+		{name: "added from", filter: BookFilter{AddedFrom: time.Now().UTC().AddDate(0, 0, -1)}, page: 1, pageSize: 10,
+			want: "Emma,The Hobbit,Dune Messiah", total: 3},
+		{name: "added until", filter: BookFilter{AddedTo: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}, page: 1, pageSize: 10,
+			want: "Dune", total: 1},
+		{name: "tag", filter: BookFilter{Tag: "science fiction"}, page: 1, pageSize: 10, want: "Dune", total: 1},
+		{name: "user", filter: BookFilter{User: "READER", Sort: SortTitle}, page: 1, pageSize: 10,
+			want: "Dune,Dune Messiah,Emma,The Hobbit", total: 4},
+		{name: "unknown user", filter: BookFilter{User: "nobody"}, page: 1, pageSize: 10, want: "", total: 0},
+		{name: "combined", filter: BookFilter{Author: "Frank Herbert", Status: "finished"}, page: 1, pageSize: 10, want: "Dune", total: 1},
+		{name: "by title", filter: BookFilter{Sort: SortTitle}, page: 1, pageSize: 10, want: "Dune,Dune Messiah,Emma,The Hobbit", total: 4},
+		{name: "by author", filter: BookFilter{Sort: SortAuthor}, page: 1, pageSize: 10, want: "Dune,Dune Messiah,The Hobbit,Emma", total: 4},
+		{name: "by year", filter: BookFilter{Sort: SortYear}, page: 1, pageSize: 10, want: "Dune Messiah,Dune,The Hobbit,Emma", total: 4},
+		{name: "by rating", filter: BookFilter{Sort: SortRating}, page: 1, pageSize: 10, want: "The Hobbit,Dune,Dune Messiah,Emma", total: 4},
+		{name: "filtered page", filter: BookFilter{Cover: CoverWithout, Sort: SortTitle}, page: 2, pageSize: 2, want: "The Hobbit", total: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := model.List(tt.filter, tt.page, tt.pageSize)
+			testutil.NoError(t, err)
+			testutil.Equal(t, list.Total, tt.total)
+
+			var titles []string
+			for _, book := range list.Books {
+				titles = append(titles, book.Title)
+			}
+			testutil.Equal(t, strings.Join(titles, ","), tt.want)
+		})
+	}
+
+	list, err := model.List(BookFilter{Sort: SortRating}, 2, 1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, list.Books[0].Rating, 4.5)
+}
+
+// TestBookModel_Facets tests that the options of every filter are counted combined with the other filters only.
+func TestBookModel_Facets(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	seedFilterBooks(t, &model, &reviews)
+
+	counts := func(counts []FacetCount) string {
+		var parts []string
+		for _, c := range counts {
+			parts = append(parts, fmt.Sprintf("%s:%d", c.Value, c.Count))
+		}
+		return strings.Join(parts, ",")
+	}
+
+	facets, err := model.Facets(BookFilter{})
+	testutil.NoError(t, err)
+	testutil.Equal(t, counts(facets.Authors), "Frank Herbert:2,J.R.R. Tolkien:1,Jane Austen:1")
+	testutil.Equal(t, counts(facets.Decades), "1810:1,1930:1,1960:2")
+	testutil.Equal(t, counts(facets.Statuses), "want_to_read:0,reading:2,finished:2")
+	testutil.Equal(t, counts(facets.Ratings), "4:2,3:2,2:3,1:3")
+	testutil.Equal(t, counts(facets.Covers), "yes:1,no:3")
+
+	// A filter does not narrow down its own options, only those of the other filters.
+	facets, err = model.Facets(BookFilter{Author: "Frank Herbert", Status: "reading"})
+	testutil.NoError(t, err)
+	testutil.Equal(t, counts(facets.Authors), "Frank Herbert:1,Jane Austen:1")
+	testutil.Equal(t, counts(facets.Statuses), "want_to_read:0,reading:1,finished:1")
+	testutil.Equal(t, counts(facets.Decades), "1960:1")
+	testutil.Equal(t, counts(facets.Covers), "yes:0,no:1")
+}
+
+// seedFilterBooks adds four books of user 1 with reviews and a cover, and returns the ID of the first one, Dune.
+func seedFilterBooks(t *testing.T, model *BookModel, reviews *ReviewModel) int {
+	t.Helper()
+
+	duneId, err := model.Create("Dune", "Frank Herbert", "9780441013593", "finished", "/uploads/dune.jpg", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	messiahId, err := model.Create("Dune Messiah", "Frank Herbert", "9780593098233", "reading", "", 1969, 1, BookDetails{})
+	testutil.NoError(t, err)
+	hobbitId, err := model.Create("The Hobbit", "J.R.R. Tolkien", "9780261102217", "finished", "", 1937, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = model.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	for _, r := range []struct{ bookId, rating int }{{duneId, 4}, {duneId, 5}, {hobbitId, 5}, {messiahId, 2}} {
+		_, err = reviews.Create(1, r.bookId, r.rating, "")
+		testutil.NoError(t, err)
+	}
+	return duneId
+}
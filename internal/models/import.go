@@ -0,0 +1,170 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+// ImportBook represents a book read from another service together with the user's shelf data for it.
+type ImportBook struct {
+	Title           string
+	Author          string
+	ISBN            string
+	PublicationYear int
+	PageCount       int
+	Status          string
+	Rating          int
+	Review          string
+	AddedAt         time.Time
+	FinishedAt      time.Time
+}
+
+// ImportResult summarizes what an import did, or would do when run as a dry run.
+// Created books were new to the catalog, linked books already existed and were added to the user's library,
+// updated books were imported before and changed since, skipped books were already in the library or lacked
+// a title or author.
+type ImportResult struct {
+	Created int
+	Linked  int
+	Updated int
+	Skipped int
+	Reviews int
+	Notes   int
+}
+
+// Total returns the number of books that were processed.
+func (r ImportResult) Total() int {
+	return r.Created + r.Linked + r.Updated + r.Skipped
+}
+
+// Import adds the given books to the library of a user within a single transaction.
+// Existing books are matched by ISBN, or by title and author when the ISBN is missing.
+// A review is created for every new library entry with a rating or a review, without a rating when it has none.
+// When dryRun is true the transaction is rolled back, so the result is a preview of the import.
+func (m *BookModel) Import(userId int, books []ImportBook, dryRun bool) (ImportResult, error) {
+	var result ImportResult
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		if err != nil || dryRun {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	for _, book := range books {
+		title := strings.TrimSpace(book.Title)
+		author := strings.TrimSpace(book.Author)
+		if title == "" || author == "" {
+			result.Skipped++
+			continue
+		}
+
+		var bookId int64
+		bookId, err = findImportBook(tx, title, author, book.ISBN)
+		if err != nil {
+			return ImportResult{}, err
+		}
+
+		if bookId == 0 {
+			var isbn sql.NullString
+			if book.ISBN != "" {
+				isbn = sql.NullString{String: book.ISBN, Valid: true}
+			}
+
+			var res sql.Result
+			res, err = tx.Exec(`INSERT INTO books (title, author, isbn, publication_year, page_count, image_url) VALUES (?, ?, ?, ?, ?, '')`,
+				title, author, isbn, book.PublicationYear, book.PageCount)
+			if err != nil {
+				var sqliteError sqlite3.Error
+				if errors.As(err, &sqliteError) {
+					return ImportResult{}, sqliteError
+				}
+				return ImportResult{}, err
+			}
+			bookId, err = res.LastInsertId()
+			if err != nil {
+				return ImportResult{}, err
+			}
+			result.Created++
+		} else {
+			var exists bool
+			err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_books WHERE user_id = ? AND book_id = ?)`, userId, bookId).Scan(&exists)
+			if err != nil {
+				return ImportResult{}, err
+			}
+			if exists {
+				result.Skipped++
+				continue
+			}
+			result.Linked++
+		}
+
+		status := book.Status
+		if status == "" {
+			status = "want_to_read"
+		}
+
+		_, err = tx.Exec(`INSERT INTO user_books (user_id, book_id, status, added_at, finished_at)
+			VALUES (?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?)`,
+			userId, bookId, status, nullTime(book.AddedAt), nullTime(book.FinishedAt))
+		if err != nil {
+			return ImportResult{}, err
+		}
+
+		var rating sql.NullInt64
+		if book.Rating >= 1 && book.Rating <= 5 {
+			rating = sql.NullInt64{Int64: int64(book.Rating), Valid: true}
+		}
+		if rating.Valid || strings.TrimSpace(book.Review) != "" {
+			reviewedAt := book.FinishedAt
+			if reviewedAt.IsZero() {
+				reviewedAt = book.AddedAt
+			}
+			_, err = tx.Exec(`INSERT INTO reviews (user_id, book_id, rating, review_text, created_at, updated_at)
+				VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP))`,
+				userId, bookId, rating, book.Review, nullTime(reviewedAt), nullTime(reviewedAt))
+			if err != nil {
+				return ImportResult{}, err
+			}
+			result.Reviews++
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err = tx.Commit(); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// findImportBook looks up an existing book by ISBN, falling back to a case-insensitive title and author match
+// for books without an ISBN. Returns 0 when the book is not in the catalog.
+func findImportBook(tx *sql.Tx, title, author, isbn string) (int64, error) {
+	var id int64
+	var err error
+	if isbn != "" {
+		err = tx.QueryRow(`SELECT id FROM books WHERE isbn = ?`, isbn).Scan(&id)
+	} else {
+		err = tx.QueryRow(`SELECT id FROM books WHERE title = ? COLLATE NOCASE AND author = ? COLLATE NOCASE
+			ORDER BY id LIMIT 1`, title, author).Scan(&id)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// nullTime converts a zero time to NULL so the column default or COALESCE fallback applies.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
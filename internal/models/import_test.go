@@ -0,0 +1,69 @@
+package models
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestBookModel_Import tests that imports create, link and skip books, and that dry runs leave no trace.
+func TestBookModel_Import(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	_, err := model.Create("Dune", "Frank Herbert", "9780441013593", "reading", "", 1965, 2, BookDetails{})
+	testutil.NoError(t, err)
+
+	read := time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)
+	books := []ImportBook{
+		{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Status: "finished", Rating: 5, Review: "Great", FinishedAt: read},
+		{Title: "The Hobbit", Author: "J.R.R. Tolkien", Status: "reading"},
+		{Title: "the hobbit", Author: "j.r.r. tolkien"},
+		{Title: "Emma", Author: "Jane Austen", Review: "Witty."},
+		{Title: "", Author: "Nobody"},
+	}
+
+	preview, err := model.Import(1, books, true)
+	testutil.NoError(t, err)
+	testutil.Equal(t, preview, ImportResult{Created: 2, Linked: 1, Skipped: 2, Reviews: 2})
+
+	count, err := model.Count()
+	testutil.NoError(t, err)
+	testutil.Equal(t, count, 1)
+
+	result, err := model.Import(1, books, false)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, preview)
+
+	var status string
+	var finishedAt time.Time
+	err = db.QueryRow("SELECT status, finished_at FROM user_books WHERE user_id = 1 AND book_id = 1").Scan(&status, &finishedAt)
+	testutil.NoError(t, err)
+	testutil.Equal(t, status, "finished")
+	testutil.Equal(t, finishedAt.Equal(read), true)
+
+	// Reviews without a rating are imported with none.
+	var unrated int
+	err = db.QueryRow("SELECT COUNT(*) FROM reviews WHERE user_id = 1 AND rating IS NULL AND review_text = 'Witty.'").Scan(&unrated)
+	testutil.NoError(t, err)
+	testutil.Equal(t, unrated, 1)
+
+	// Importing the same file again must not duplicate anything.
+	result, err = model.Import(1, books, false)
+	testutil.NoError(t, err)
+	testutil.Equal(t, result, ImportResult{Skipped: 5})
+
+	hobbit, err := model.Retrieve(2)
+	testutil.NoError(t, err)
+	testutil.Equal(t, hobbit.ISBN, "")
+}
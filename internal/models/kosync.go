@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// KosyncProgress represents the reading position KOReader syncs for a document.
+// Document is the hash KOReader computes for a file, Progress its position within it and Percentage is in [0, 1].
+type KosyncProgress struct {
+	Document   string
+	Progress   string
+	Percentage float64
+	Device     string
+	DeviceId   string
+	Timestamp  int64
+}
+
+// KosyncDocument represents a document synced by KOReader that has not been matched to a book yet.
+type KosyncDocument struct {
+	ID int
+	KosyncProgress
+	UpdatedAt time.Time
+}
+
+// KosyncModel provides methods to store KOReader reading positions and to apply them to the matched books.
+type KosyncModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Progress returns the last reading position synced by a user for a document, or ErrNoRecord if there is none.
+func (m *KosyncModel) Progress(userId int, document string) (KosyncProgress, error) {
+	stmt := `SELECT document, progress, percentage, device, device_id, timestamp
+		FROM kosync_documents WHERE user_id = ? AND document = ? AND timestamp > 0`
+
+	var p KosyncProgress
+	err := m.DB.QueryRow(stmt, userId, document).Scan(&p.Document, &p.Progress, &p.Percentage, &p.Device, &p.DeviceId, &p.Timestamp)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return KosyncProgress{}, ErrNoRecord
+		}
+		return KosyncProgress{}, err
+	}
+	return p, nil
+}
+
+// Update stores the reading position of a document and, when the document was matched to a book,
+// updates the reading progress and status of the book in the user's library. Returns the stored timestamp.
+func (m *KosyncModel) Update(userId int, p KosyncProgress) (int64, error) {
+	if p.Timestamp == 0 {
+		p.Timestamp = time.Now().Unix()
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	var bookId sql.NullInt64
+	err = tx.QueryRow(`INSERT INTO kosync_documents (user_id, document, progress, percentage, device, device_id, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, document)
+		DO UPDATE SET progress = excluded.progress, percentage = excluded.percentage, device = excluded.device,
+		              device_id = excluded.device_id, timestamp = excluded.timestamp
+		RETURNING book_id`,
+		userId, p.Document, p.Progress, p.Percentage, p.Device, p.DeviceId, p.Timestamp).Scan(&bookId)
+	if err != nil {
+		return 0, err
+	}
+
+	if bookId.Valid {
+		if err = applyKosyncProgress(tx, userId, bookId.Int64, p.Percentage); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return p.Timestamp, nil
+}
+
+// Pending returns the documents of a user that are neither matched to a book nor ignored, most recently read first.
+func (m *KosyncModel) Pending(userId int) ([]KosyncDocument, error) {
+	stmt := `SELECT id, document, progress, percentage, device, device_id, timestamp
+		FROM kosync_documents
+		WHERE user_id = ? AND book_id IS NULL AND NOT ignored
+		ORDER BY timestamp DESC, id DESC`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var documents []KosyncDocument
+	for rows.Next() {
+		var d KosyncDocument
+		if err := rows.Scan(&d.ID, &d.Document, &d.Progress, &d.Percentage, &d.Device, &d.DeviceId, &d.Timestamp); err != nil {
+			return nil, err
+		}
+		d.UpdatedAt = time.Unix(d.Timestamp, 0)
+		documents = append(documents, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// Match confirms which book a document of a user is, adding the book to the user's library if needed and
+// applying the last synced position to it. Later syncs of the document update the book directly.
+// Returns ErrNoRecord if the user has no such document or the book does not exist.
+func (m *KosyncModel) Match(userId, documentId, bookId int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	var exists bool
+	if err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM books WHERE id = ?)`, bookId).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		err = ErrNoRecord
+		return err
+	}
+
+	var percentage float64
+	err = tx.QueryRow(`UPDATE kosync_documents SET book_id = ?, ignored = 0 WHERE id = ? AND user_id = ? RETURNING percentage`,
+		bookId, documentId, userId).Scan(&percentage)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNoRecord
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR IGNORE INTO user_books (user_id, book_id) VALUES (?, ?)`, userId, bookId)
+	if err != nil {
+		return err
+	}
+	if err = applyKosyncProgress(tx, userId, int64(bookId), percentage); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Ignore sets a document of a user aside so it is no longer offered for matching, while its position keeps syncing.
+// Returns ErrNoRecord if the user has no such document.
+func (m *KosyncModel) Ignore(userId, documentId int) error {
+	result, err := m.DB.Exec(`UPDATE kosync_documents SET ignored = 1 WHERE id = ? AND user_id = ?`, documentId, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// applyKosyncProgress sets the reading progress of a book in a user's library from a KOReader percentage.
+// Starting a book moves it from want to read to reading, and reaching its end marks it finished.
+func applyKosyncProgress(tx *sql.Tx, userId int, bookId int64, percentage float64) error {
+	progress := int(math.Floor(math.Max(0, math.Min(percentage, 1)) * 100))
+
+	stmt := `UPDATE user_books SET
+		progress = ?1,
+		status = CASE WHEN ?1 >= 100 THEN 'finished' WHEN ?1 > 0 AND status = 'want_to_read' THEN 'reading' ELSE status END,
+		finished_at = CASE WHEN ?1 >= 100 AND status != 'finished' THEN CURRENT_TIMESTAMP ELSE finished_at END
+		WHERE user_id = ?2 AND book_id = ?3`
+	_, err := tx.Exec(stmt, progress, userId, bookId)
+	return err
+}
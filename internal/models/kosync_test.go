@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestKosyncModel tests that synced positions are stored per user and update the reading progress
+// and status of a book once the document was matched to it.
+func TestKosyncModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := KosyncModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	bookId, err := books.Create("Dune", "Frank Herbert", "9780441013593", "want_to_read", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	_, err = model.Progress(1, "abc")
+	testutil.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	timestamp, err := model.Update(1, KosyncProgress{Document: "abc", Progress: "/body/DocFragment[3]", Percentage: 0.25, Device: "Kobo"})
+	testutil.NoError(t, err)
+	testutil.Equal(t, timestamp > 0, true)
+
+	p, err := model.Progress(1, "abc")
+	testutil.NoError(t, err)
+	testutil.Equal(t, p.Progress, "/body/DocFragment[3]")
+	testutil.Equal(t, p.Device, "Kobo")
+
+	_, err = model.Progress(2, "abc")
+	testutil.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	pending, err := model.Pending(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(pending), 1)
+	testutil.Equal(t, errors.Is(model.Match(2, pending[0].ID, bookId), ErrNoRecord), true)
+	testutil.Equal(t, errors.Is(model.Match(1, pending[0].ID, bookId+1), ErrNoRecord), true)
+
+	testutil.NoError(t, model.Match(1, pending[0].ID, bookId))
+	book, err := books.Retrieve(bookId)
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Progress, 25)
+	testutil.Equal(t, book.Status, "reading")
+
+	pending, err = model.Pending(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(pending), 0)
+
+	_, err = model.Update(1, KosyncProgress{Document: "abc", Progress: "/body/DocFragment[40]", Percentage: 1})
+	testutil.NoError(t, err)
+	book, err = books.Retrieve(bookId)
+	testutil.NoError(t, err)
+	testutil.Equal(t, book.Progress, 100)
+	testutil.Equal(t, book.Status, "finished")
+
+	_, err = model.Update(1, KosyncProgress{Document: "def", Percentage: 0.5})
+	testutil.NoError(t, err)
+	pending, err = model.Pending(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(pending), 1)
+	testutil.NoError(t, model.Ignore(1, pending[0].ID))
+	pending, err = model.Pending(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(pending), 0)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// MetadataCacheModel stores the results of book lookups in online catalogs, so they are not repeated.
+type MetadataCacheModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Get returns the value stored under key and when it was fetched. Returns ErrNoRecord if there is none.
+func (m *MetadataCacheModel) Get(key string) ([]byte, time.Time, error) {
+	var value []byte
+	var fetchedAt time.Time
+	err := m.DB.QueryRow(`SELECT value, fetched_at FROM metadata_cache WHERE key = ?`, key).Scan(&value, &fetchedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, time.Time{}, ErrNoRecord
+		}
+		return nil, time.Time{}, err
+	}
+	return value, fetchedAt, nil
+}
+
+// Set stores a value under key, replacing the value fetched before.
+func (m *MetadataCacheModel) Set(key string, value []byte) error {
+	stmt := `INSERT INTO metadata_cache (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, fetched_at = CURRENT_TIMESTAMP`
+	_, err := m.DB.Exec(stmt, key, value)
+	return err
+}
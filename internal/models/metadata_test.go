@@ -0,0 +1,29 @@
+package models
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestMetadataCacheModel tests that cached values can be read back and are replaced when stored again.
+func TestMetadataCacheModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	model := MetadataCacheModel{DB: db, Logger: slog.New(slog.NewTextHandler(os.Stdout, nil))}
+
+	_, _, err := model.Get("isbn:9780441013593")
+	testutil.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	testutil.NoError(t, model.Set("isbn:9780441013593", []byte(`{"book":null}`)))
+	testutil.NoError(t, model.Set("isbn:9780441013593", []byte(`{"book":{"title":"Dune"}}`)))
+
+	value, fetchedAt, err := model.Get("isbn:9780441013593")
+	testutil.NoError(t, err)
+	testutil.Equal(t, string(value), `{"book":{"title":"Dune"}}`)
+	testutil.Equal(t, fetchedAt.IsZero(), false)
+}
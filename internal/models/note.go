@@ -28,10 +28,34 @@ type NoteModel struct {
 
 // Create inserts a new note into the database and returns its ID or an error if the operation fails.
 func (n *NoteModel) Create(userId, bookId int, noteText string, pageNumber int) (int, error) {
+	tx, err := n.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				n.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	noteId, err := n.CreateTx(tx, userId, bookId, noteText, pageNumber)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return noteId, nil
+}
 
+// CreateTx inserts a new note like Create, within the transaction tx, so the note is saved together with
+// the other changes of the transaction.
+func (n *NoteModel) CreateTx(tx *sql.Tx, userId, bookId int, noteText string, pageNumber int) (int, error) {
 	stmt := `INSERT INTO notes (user_id, book_id, note_text, page_number) VALUES (?, ?, ?, ?)`
 
-	result, err := n.DB.Exec(stmt, userId, bookId, noteText, pageNumber)
+	result, err := tx.Exec(stmt, userId, bookId, noteText, pageNumber)
 	if err != nil {
 		var sqliteError sqlite3.Error
 		if errors.As(err, &sqliteError) {
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// keptRecentSearches is the number of searches kept for every user; older ones are forgotten.
+const keptRecentSearches = 10
+
+// RecentSearchModel provides methods to remember the last searches of users and suggest them again.
+type RecentSearchModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Add remembers a search of a user as the most recent one, forgetting the oldest searches beyond the last
+// keptRecentSearches. Searching again for the same words, in any case, moves them to the top.
+func (m *RecentSearchModel) Add(userId int, query string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM recent_searches WHERE user_id = ? AND query = ?`, userId, query); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`INSERT INTO recent_searches (user_id, query) VALUES (?, ?)`, userId, query); err != nil {
+		return err
+	}
+
+	stmt := `DELETE FROM recent_searches WHERE user_id = ?1 AND rowid NOT IN (
+            SELECT rowid FROM recent_searches WHERE user_id = ?1 ORDER BY searched_at DESC, rowid DESC LIMIT ?2
+        )`
+	if _, err = tx.Exec(stmt, userId, keptRecentSearches); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	return err
+}
+
+// List returns up to limit of the last searches of a user, most recent first.
+func (m *RecentSearchModel) List(userId, limit int) ([]string, error) {
+	stmt := `SELECT query FROM recent_searches WHERE user_id = ? ORDER BY searched_at DESC, rowid DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var queries []string
+	for rows.Next() {
+		var query string
+		if err := rows.Scan(&query); err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestRecentSearchModel tests that searches are listed most recent first, per user, without repeats and
+// that only the last ones are kept.
+func TestRecentSearchModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := RecentSearchModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	testutil.NoError(t, model.Add(1, "dune"))
+	testutil.NoError(t, model.Add(1, "austen"))
+	testutil.NoError(t, model.Add(2, "tolkien"))
+	testutil.NoError(t, model.Add(1, "Dune"))
+
+	recent, err := model.List(1, 5)
+	testutil.NoError(t, err)
+	testutil.Equal(t, strings.Join(recent, ","), "Dune,austen")
+
+	recent, err = model.List(2, 5)
+	testutil.NoError(t, err)
+	testutil.Equal(t, strings.Join(recent, ","), "tolkien")
+
+	for i := 0; i < keptRecentSearches+3; i++ {
+		testutil.NoError(t, model.Add(1, fmt.Sprintf("search %d", i)))
+	}
+	recent, err = model.List(1, 100)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(recent), keptRecentSearches)
+	testutil.Equal(t, recent[0], fmt.Sprintf("search %d", keptRecentSearches+2))
+
+	recent, err = model.List(2, 5)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(recent), 1)
+}
@@ -0,0 +1,300 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// BookRating represents the rating a user gave a book, averaged over their reviews of it.
+type BookRating struct {
+	UserId int
+	BookId int
+	Rating float64
+}
+
+// BookSimilarity represents how alike two books are, from -1 to 1, by the ratings readers gave them or by their content.
+type BookSimilarity struct {
+	BookId        int
+	SimilarBookId int
+	Score         float64
+}
+
+// BookContent represents what a book is about, which books are compared by when few readers rated them.
+type BookContent struct {
+	ID          int
+	Title       string
+	Author      string
+	Description string
+	Tags        []string
+}
+
+// ratingWeight is the share of the similarity of the ratings of two books in their blended similarity, when
+// readers rated both.
+const ratingWeight = 0.5
+
+// RecommendationModel provides methods to store the similarities of books and to recommend books with them.
+type RecommendationModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Ratings returns the ratings of every user, one per book they rated.
+func (m *RecommendationModel) Ratings() ([]BookRating, error) {
+	stmt := `SELECT user_id, book_id, AVG(rating) FROM reviews WHERE rating IS NOT NULL GROUP BY user_id, book_id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var ratings []BookRating
+	for rows.Next() {
+		var r BookRating
+		if err := rows.Scan(&r.UserId, &r.BookId, &r.Rating); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// ReplaceSimilarities replaces the stored similarities of books with the given ones at once.
+func (m *RecommendationModel) ReplaceSimilarities(similarities []BookSimilarity) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM book_similarities`); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO book_similarities (book_id, similar_book_id, score) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	for _, s := range similarities {
+		if _, err = stmt.Exec(s.BookId, s.SimilarBookId, s.Score); err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	return err
+}
+
+// Similar returns up to limit of the books most similar to a book, the most similar first.
+func (m *RecommendationModel) Similar(bookId, limit int) ([]Book, error) {
+	stmt := `SELECT b.id, b.title, b.author, COALESCE(b.image_url, '')
+		FROM book_similarities s
+		JOIN books b ON b.id = s.similar_book_id
+		WHERE s.book_id = ? AND s.score > 0
+		ORDER BY s.score DESC, b.id
+		LIMIT ?`
+	return m.books(stmt, bookId, limit)
+}
+
+// ForUser returns up to limit books recommended to a user from the books similar to those they rated, leaving
+// out the books on their shelves and the books they reviewed. The similar books of the books a user rated above
+// the middle of the scale count for them, and those of the books they rated below it against them.
+func (m *RecommendationModel) ForUser(userId, limit int) ([]Book, error) {
+	stmt := `SELECT b.id, b.title, b.author, COALESCE(b.image_url, '')
+		FROM (SELECT book_id, AVG(rating) AS rating FROM reviews
+		      WHERE user_id = ? AND rating IS NOT NULL GROUP BY book_id) r
+		JOIN book_similarities s ON s.book_id = r.book_id
+		JOIN books b ON b.id = s.similar_book_id
+		WHERE b.id NOT IN (SELECT book_id FROM user_books WHERE user_id = ?)
+		  AND b.id NOT IN (SELECT book_id FROM reviews WHERE user_id = ?)
+		GROUP BY b.id
+		HAVING SUM(s.score * (r.rating - 3)) > 0
+		ORDER BY SUM(s.score * (r.rating - 3)) DESC, b.id
+		LIMIT ?`
+	return m.books(stmt, userId, userId, userId, limit)
+}
+
+// Contents returns the content of every book, its tags by name.
+func (m *RecommendationModel) Contents() ([]BookContent, error) {
+	rows, err := m.DB.Query(`SELECT id, title, author, description FROM books ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var contents []BookContent
+	index := make(map[int]int)
+	for rows.Next() {
+		var c BookContent
+		if err := rows.Scan(&c.ID, &c.Title, &c.Author, &c.Description); err != nil {
+			return nil, err
+		}
+		index[c.ID] = len(contents)
+		contents = append(contents, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagRows, err := m.DB.Query(`SELECT bt.book_id, t.name FROM book_tags bt JOIN tags t ON t.id = bt.tag_id ORDER BY t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = tagRows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	for tagRows.Next() {
+		var bookId int
+		var name string
+		if err := tagRows.Scan(&bookId, &name); err != nil {
+			return nil, err
+		}
+		if i, ok := index[bookId]; ok {
+			contents[i].Tags = append(contents[i].Tags, name)
+		}
+	}
+
+	if err := tagRows.Err(); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// ChangedBooks returns the IDs of up to limit books whose content changed since their similarities were computed.
+// The IDs of deleted books may be among them.
+func (m *RecommendationModel) ChangedBooks(limit int) ([]int, error) {
+	rows, err := m.DB.Query(`SELECT book_id FROM book_content_changes ORDER BY book_id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ReplaceContentSimilarities replaces the content similarities of a book, both to and from it, with the given
+// ones, and takes the book off the books whose content changed.
+func (m *RecommendationModel) ReplaceContentSimilarities(bookId int, similarities []BookSimilarity) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	stmt := `DELETE FROM book_content_similarities WHERE book_id = ?1 OR similar_book_id = ?1`
+	if _, err = tx.Exec(stmt, bookId); err != nil {
+		return err
+	}
+
+	stmt = `INSERT OR REPLACE INTO book_content_similarities (book_id, similar_book_id, score) VALUES (?, ?, ?), (?, ?, ?)`
+	for _, s := range similarities {
+		if _, err = tx.Exec(stmt, s.BookId, s.SimilarBookId, s.Score, s.SimilarBookId, s.BookId, s.Score); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(`DELETE FROM book_content_changes WHERE book_id = ?`, bookId); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	return err
+}
+
+// MoreLikeThis returns up to limit of the books most like a book, the most alike first. Books are alike by their
+// content, blended with the similarity of their ratings when readers rated both, so that books readers rate
+// unlike are left out however alike their content is.
+func (m *RecommendationModel) MoreLikeThis(bookId, limit int) ([]Book, error) {
+	stmt := `SELECT b.id, b.title, b.author, COALESCE(b.image_url, '')
+		FROM (SELECT id, CASE WHEN MAX(rating) IS NULL THEN MAX(content)
+		                 ELSE (1 - ?2) * COALESCE(MAX(content), 0) + ?2 * MAX(rating) END AS score
+		      FROM (SELECT similar_book_id AS id, score AS content, NULL AS rating
+		            FROM book_content_similarities WHERE book_id = ?1
+		            UNION ALL
+		            SELECT similar_book_id, NULL, score FROM book_similarities WHERE book_id = ?1)
+		      GROUP BY id) s
+		JOIN books b ON b.id = s.id
+		WHERE s.score > 0
+		ORDER BY s.score DESC, b.id
+		LIMIT ?3`
+	return m.books(stmt, bookId, ratingWeight, limit)
+}
+
+// books returns the books a query of recommendations selects.
+func (m *RecommendationModel) books(stmt string, args ...any) ([]Book, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var books []Book
+	for rows.Next() {
+		var book Book
+		if err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.ImageURL); err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// bookTitles joins the titles of books with commas.
+func bookTitles(books []Book) string {
+	var titles []string
+	for _, book := range books {
+		titles = append(titles, book.Title)
+	}
+	return strings.Join(titles, ",")
+}
+
+// TestRecommendationModel tests that similar books are listed by similarity and that books are recommended to a
+// user from the books they rated, leaving out the books on their shelves and the books they reviewed.
+func TestRecommendationModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := RecommendationModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("friend", "friend@example.com", "password123"))
+
+	duneId, err := books.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	messiahId, err := books.Create("Dune Messiah", "Frank Herbert", "9780593098233", "want_to_read", "", 1969, 2, BookDetails{})
+	testutil.NoError(t, err)
+	emmaId, err := books.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 2, BookDetails{})
+	testutil.NoError(t, err)
+	hobbitId, err := books.Create("The Hobbit", "J.R.R. Tolkien", "9780261102217", "finished", "", 1937, 2, BookDetails{})
+	testutil.NoError(t, err)
+
+	for _, r := range []struct{ userId, bookId, rating int }{{1, duneId, 4}, {1, duneId, 5}, {2, messiahId, 3}} {
+		_, err = reviews.Create(r.userId, r.bookId, r.rating, "")
+		testutil.NoError(t, err)
+	}
+
+	ratings, err := model.Ratings()
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(ratings), 2)
+	testutil.Equal(t, ratings[0], BookRating{UserId: 1, BookId: duneId, Rating: 4.5})
+
+	testutil.NoError(t, model.ReplaceSimilarities([]BookSimilarity{{BookId: emmaId, SimilarBookId: duneId, Score: 1}}))
+	testutil.NoError(t, model.ReplaceSimilarities([]BookSimilarity{
+		{BookId: duneId, SimilarBookId: emmaId, Score: 0.5},
+		{BookId: duneId, SimilarBookId: messiahId, Score: 0.9},
+		{BookId: duneId, SimilarBookId: hobbitId, Score: -0.2},
+		{BookId: emmaId, SimilarBookId: hobbitId, Score: 0.8},
+	}))
+
+	similar, err := model.Similar(duneId, 5)
+	testutil.NoError(t, err)
+	testutil.Equal(t, bookTitles(similar), "Dune Messiah,Emma")
+	similar, err = model.Similar(emmaId, 5)
+	testutil.NoError(t, err)
+	testutil.Equal(t, bookTitles(similar), "The Hobbit")
+
+	tests := []struct {
+		name   string
+		userId int
+		bookId int
+		rating int
+		want   string
+	}{
+		{name: "similar to a liked book", userId: 1, want: "Dune Messiah,Emma"},
+		{name: "reviewed books and books like disliked ones are left out", userId: 1, bookId: emmaId, rating: 1, want: "Dune Messiah"},
+		{name: "books on the user's shelves are left out", userId: 2, bookId: duneId, rating: 5, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.bookId > 0 {
+				_, err := reviews.Create(tt.userId, tt.bookId, tt.rating, "")
+				testutil.NoError(t, err)
+			}
+
+			recommended, err := model.ForUser(tt.userId, 5)
+			testutil.NoError(t, err)
+			testutil.Equal(t, bookTitles(recommended), tt.want)
+		})
+	}
+}
+
+// TestRecommendationModel_Content tests that changed books are queued, that content similarities are stored both
+// ways and that books more like a book blend them with the similarities of ratings.
+func TestRecommendationModel_Content(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := RecommendationModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+
+	duneId, err := books.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1,
+		BookDetails{Language: "en", Description: "The desert planet Arrakis."})
+	testutil.NoError(t, err)
+	messiahId, err := books.Create("Dune Messiah", "Frank Herbert", "9780593098233", "reading", "", 1969, 1, BookDetails{})
+	testutil.NoError(t, err)
+	emmaId, err := books.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 1, BookDetails{})
+	testutil.NoError(t, err)
+	hobbitId, err := books.Create("The Hobbit", "J.R.R. Tolkien", "9780261102217", "finished", "", 1937, 1, BookDetails{})
+	testutil.NoError(t, err)
+
+	tx, err := db.Begin()
+	testutil.NoError(t, err)
+	testutil.NoError(t, addBookTags(tx, int64(duneId), []string{"Sci-Fi", "Classics"}))
+	testutil.NoError(t, tx.Commit())
+
+	changed, err := model.ChangedBooks(10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(changed), 4)
+
+	contents, err := model.Contents()
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(contents), 4)
+	testutil.Equal(t, contents[0].Description, "The desert planet Arrakis.")
+	testutil.Equal(t, strings.Join(contents[0].Tags, ","), "Classics,Sci-Fi")
+
+	for _, id := range changed[1:] {
+		testutil.NoError(t, model.ReplaceContentSimilarities(id, nil))
+	}
+	testutil.NoError(t, model.ReplaceContentSimilarities(duneId, []BookSimilarity{
+		{BookId: duneId, SimilarBookId: messiahId, Score: 0.4},
+		{BookId: duneId, SimilarBookId: hobbitId, Score: 0.3},
+	}))
+	changed, err = model.ChangedBooks(10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(changed), 0)
+
+	// Changing a book queues it again, while the similarities to it stay until it is computed again.
+	testutil.NoError(t, books.Update(messiahId, "Dune Messiah", "Frank Herbert", "9780593098233", "reading", "", 1969, 1,
+		BookDetails{Language: "en", Description: "Paul rules the universe."}))
+	changed, err = model.ChangedBooks(10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(changed), 1)
+
+	similar, err := model.MoreLikeThis(messiahId, 5)
+	testutil.NoError(t, err)
+	testutil.Equal(t, bookTitles(similar), "Dune")
+
+	tests := []struct {
+		name         string
+		similarities []BookSimilarity
+		want         string
+	}{
+		{name: "content only", want: "Dune Messiah,The Hobbit"},
+		{name: "blended with ratings", similarities: []BookSimilarity{
+			{BookId: duneId, SimilarBookId: hobbitId, Score: 0.9},
+			{BookId: duneId, SimilarBookId: emmaId, Score: 0.5},
+			{BookId: duneId, SimilarBookId: messiahId, Score: -0.5},
+		}, want: "The Hobbit,Emma"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.NoError(t, model.ReplaceSimilarities(tt.similarities))
+			similar, err := model.MoreLikeThis(duneId, 5)
+			testutil.NoError(t, err)
+			testutil.Equal(t, bookTitles(similar), tt.want)
+		})
+	}
+
+	// Deleting a book deletes its similarities.
+	testutil.NoError(t, books.Delete(hobbitId, 1))
+	similar, err = model.MoreLikeThis(duneId, 5)
+	testutil.NoError(t, err)
+	testutil.Equal(t, bookTitles(similar), "Emma")
+}
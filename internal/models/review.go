@@ -55,7 +55,7 @@ func (m *ReviewModel) Create(userId, bookId, rating int, reviewText string) (int
 // Retrieve fetches a review by its ID and associated user ID from the database. Returns the review or an error if not found.
 func (m *ReviewModel) Retrieve(id, userId int) (Review, error) {
 	var review Review
-	stmt := `SELECT id, user_id, book_id, rating, review_text, created_at, updated_at FROM reviews WHERE id = ? AND user_id = ?`
+	stmt := `SELECT id, user_id, book_id, COALESCE(rating, 0), review_text, created_at, updated_at FROM reviews WHERE id = ? AND user_id = ?`
 
 	err := m.DB.QueryRow(stmt, id, userId).Scan(
 		&review.ID,
@@ -79,7 +79,7 @@ func (m *ReviewModel) Retrieve(id, userId int) (Review, error) {
 // Update modifies the rating and review text of an existing review for a specific user and book in the database.
 // Returns an error if the update fails or no matching record is found.
 func (m *ReviewModel) Update(id, userId int, rating int, reviewText string) error {
-	stmt := `UPDATE reviews SET rating = ?, review_text = ? WHERE id = ? AND user_id = ?`
+	stmt := `UPDATE reviews SET rating = ?, review_text = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`
 
 	result, err := m.DB.Exec(stmt, rating, reviewText, id, userId)
 	if err != nil {
@@ -125,7 +125,7 @@ func (m *ReviewModel) Delete(id, userId int) error {
 func (m *ReviewModel) List(bookId int) ([]Review, error) {
 
 	stmt := `
-        SELECT r.id, r.user_id, r.book_id, r.rating, r.review_text, r.created_at, r.updated_at, u.username 
+        SELECT r.id, r.user_id, r.book_id, COALESCE(r.rating, 0), r.review_text, r.created_at, r.updated_at, u.username 
         FROM reviews r 
         LEFT JOIN users u ON r.user_id = u.id 
         WHERE r.book_id = ?
@@ -181,7 +181,7 @@ func (m *ReviewModel) Count(userId int) (int, error) {
 
 // RetrieveRecentReviews fetches the most recent reviews up to a specified limit, ordered by creation date in descending order.
 func (m *ReviewModel) RetrieveRecentReviews(limit int) ([]Review, error) {
-	stmt := `SELECT r.id, r.user_id, r.book_id, r.rating, r.review_text, b.title 
+	stmt := `SELECT r.id, r.user_id, r.book_id, COALESCE(r.rating, 0), r.review_text, b.title 
         FROM reviews r 
         JOIN books b ON r.book_id = b.id 
         ORDER BY r.created_at DESC 
@@ -224,3 +224,66 @@ func (m *ReviewModel) RetrieveRecentReviews(limit int) ([]Review, error) {
 	}
 	return reviews, nil
 }
+
+// Feed returns the most recent reviews up to limit, newest first, with the username of their authors and the titles
+// of their books. Only the reviews of a book are returned when bookId is not 0.
+func (m *ReviewModel) Feed(bookId, limit int) ([]Review, error) {
+	stmt := `SELECT r.id, r.user_id, r.book_id, COALESCE(r.rating, 0), COALESCE(r.review_text, ''), r.created_at, r.updated_at,
+               u.username, b.title
+        FROM reviews r
+        JOIN users u ON r.user_id = u.id
+        JOIN books b ON r.book_id = b.id
+        WHERE ? = 0 OR r.book_id = ?
+        ORDER BY r.created_at DESC, r.id DESC
+        LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, bookId, bookId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var reviews []Review
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&review.ID,
+			&review.UserId,
+			&review.BookId,
+			&review.Rating,
+			&review.ReviewText,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Username,
+			&review.BookTitle,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// FeedVersion returns the number of reviews in the feed of Feed and when the most recently changed one changed,
+// so clients that already have the feed can be answered without building it.
+func (m *ReviewModel) FeedVersion(bookId int) (int, time.Time, error) {
+	stmt := `SELECT COUNT(*), COALESCE(MAX(CAST(strftime('%s', updated_at) AS INTEGER)), 0)
+        FROM reviews WHERE ? = 0 OR book_id = ?`
+
+	var count int
+	var updated int64
+	if err := m.DB.QueryRow(stmt, bookId, bookId).Scan(&count, &updated); err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, unixTime(updated), nil
+}
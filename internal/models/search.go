@@ -0,0 +1,286 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Markers around the terms that matched in the snippets of search results.
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// Sources of the snippets of search results.
+const (
+	SearchSourceBook   = "book"
+	SearchSourceNote   = "note"
+	SearchSourceReview = "review"
+)
+
+// SearchResult represents a book that matched a search, with a snippet of the text that matched best.
+type SearchResult struct {
+	Book
+
+	// Snippet is an excerpt of the text that matched, with the matched terms between HighlightStart and HighlightEnd.
+	Snippet string
+
+	// Source is where the snippet is from: the book itself, a note or a review.
+	Source string
+}
+
+// Suggestions holds the best matches of the start of a search, by type, as it is being typed.
+type Suggestions struct {
+
+	// Books holds books whose title matches.
+	Books []Book
+
+	// Authors and Tags hold the authors and tags that match, with their number of books.
+	Authors []FacetCount
+	Tags    []FacetCount
+
+	// Users holds the names of the users that match.
+	Users []string
+}
+
+// IsEmpty reports whether nothing matched.
+func (s Suggestions) IsEmpty() bool {
+	return len(s.Books) == 0 && len(s.Authors) == 0 && len(s.Tags) == 0 && len(s.Users) == 0
+}
+
+// Suggest returns up to limit books, authors, tags and users each matching a search as it is being typed.
+// Books and authors whose title or name starts with query come first, then those with words starting with
+// the words of query, found through the prefix index of the full-text search in the order they were added.
+// Tags and users match when their name starts with query. Every match is read from an index and nothing is
+// ranked, so suggestions stay fast however large the library is.
+func (m *BookModel) Suggest(query string, limit int) (Suggestions, error) {
+	var s Suggestions
+	prefix := strings.TrimSpace(query)
+	if prefix == "" {
+		return s, nil
+	}
+
+	// Words of one letter start too many words to be looked up in the prefix index, which only holds prefixes
+	// of two and three letters; names starting with them are still suggested.
+	var terms []string
+	for _, word := range ftsWords(prefix) {
+		if utf8.RuneCountInString(word) > 1 {
+			terms = append(terms, `"`+word+`"*`)
+		}
+	}
+	match := strings.Join(terms, " ")
+
+	// Names starting with the prefix sort between the prefix and the prefix followed by the last character,
+	// a range the indexes of the names are read over.
+	stmt := `SELECT id, title, author, COALESCE(image_url, '') FROM books
+        WHERE title COLLATE NOCASE >= ?1 AND title COLLATE NOCASE < ?1 || char(1114111)
+        ORDER BY title COLLATE NOCASE
+        LIMIT ?2`
+	books, err := m.suggestBooks(stmt, prefix, limit)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	if match != "" && len(books) < limit {
+		stmt = `SELECT b.id, b.title, b.author, COALESCE(b.image_url, '')
+            FROM books_fts JOIN books b ON b.id = books_fts.rowid
+            WHERE books_fts MATCH ?1
+            ORDER BY books_fts.rowid DESC
+            LIMIT ?2`
+		more, err := m.suggestBooks(stmt, `{title} : (`+match+`)`, 2*limit)
+		if err != nil {
+			return Suggestions{}, err
+		}
+		books = append(books, more...)
+	}
+	seen := make(map[int]bool)
+	for _, book := range books {
+		if !seen[book.ID] && len(s.Books) < limit {
+			seen[book.ID] = true
+			s.Books = append(s.Books, book)
+		}
+	}
+
+	stmt = `SELECT author, COUNT(*) FROM books
+        WHERE author COLLATE NOCASE >= ?1 AND author COLLATE NOCASE < ?1 || char(1114111)
+        GROUP BY author COLLATE NOCASE
+        ORDER BY author COLLATE NOCASE
+        LIMIT ?2`
+	authors, err := m.facetCounts(stmt, prefix, limit)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	if match != "" && len(authors) < limit {
+		stmt = `SELECT a.author, (SELECT COUNT(*) FROM books c WHERE c.author = a.author COLLATE NOCASE)
+            FROM (
+                SELECT DISTINCT b.author COLLATE NOCASE AS author
+                FROM books_fts JOIN books b ON b.id = books_fts.rowid
+                WHERE books_fts MATCH ?1
+                ORDER BY books_fts.rowid DESC
+                LIMIT ?2
+            ) a`
+		more, err := m.facetCounts(stmt, `{author} : (`+match+`)`, 2*limit)
+		if err != nil {
+			return Suggestions{}, err
+		}
+		authors = append(authors, more...)
+	}
+	seenAuthors := make(map[string]bool)
+	for _, author := range authors {
+		key := strings.ToLower(author.Value)
+		if !seenAuthors[key] && len(s.Authors) < limit {
+			seenAuthors[key] = true
+			s.Authors = append(s.Authors, author)
+		}
+	}
+
+	stmt = `SELECT t.name, (SELECT COUNT(*) FROM book_tags bt WHERE bt.tag_id = t.id)
+        FROM tags t
+        WHERE t.name >= ?1 AND t.name < ?1 || char(1114111)
+        ORDER BY t.name
+        LIMIT ?2`
+	s.Tags, err = m.facetCounts(stmt, prefix, limit)
+	if err != nil {
+		return Suggestions{}, err
+	}
+
+	stmt = `SELECT username, 0 FROM users
+        WHERE username COLLATE NOCASE >= ?1 AND username COLLATE NOCASE < ?1 || char(1114111)
+        ORDER BY username COLLATE NOCASE
+        LIMIT ?2`
+	users, err := m.facetCounts(stmt, prefix, limit)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	for _, u := range users {
+		s.Users = append(s.Users, u.Value)
+	}
+	return s, nil
+}
+
+// suggestBooks runs a query for suggested books with its arguments.
+func (m *BookModel) suggestBooks(stmt string, args ...any) ([]Book, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var books []Book
+	for rows.Next() {
+		var book Book
+		if err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.ImageURL); err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// Search returns up to limit books whose title, author or description, reviews or notes of the user match query,
+// best matches first as ranked by BM25. The notes of other users are never searched. Words in query match words
+// starting with them, so results can be shown while typing, while words in double quotes match as a phrase.
+// Returns no results if query has no words.
+func (m *BookModel) Search(query string, userId, limit int) ([]SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	// The title weighs most in the ranking of books, then the author. A book that matches in several places
+	// ranks higher, and shows the snippet of its best match.
+	stmt := `WITH matches (book_id, rank, snippet, source) AS (
+            SELECT rowid, bm25(books_fts, 10.0, 5.0, 1.0), snippet(books_fts, -1, char(2), char(3), '…', 16), 'book'
+            FROM books_fts
+            WHERE books_fts MATCH ?1
+            UNION ALL
+            SELECT n.book_id, bm25(notes_fts), snippet(notes_fts, 0, char(2), char(3), '…', 16), 'note'
+            FROM notes_fts
+            JOIN notes n ON n.id = notes_fts.rowid
+            WHERE notes_fts MATCH ?1 AND n.user_id = ?2
+            UNION ALL
+            SELECT r.book_id, bm25(reviews_fts), snippet(reviews_fts, 0, char(2), char(3), '…', 16), 'review'
+            FROM reviews_fts
+            JOIN reviews r ON r.id = reviews_fts.rowid
+            WHERE reviews_fts MATCH ?1
+        )
+        SELECT b.id, b.title, b.author, COALESCE(b.isbn, ''), b.publication_year, b.created_at, b.updated_at,
+               COALESCE(b.image_url, ''), m.snippet, m.source
+        FROM (SELECT book_id, SUM(rank) AS score, MIN(rank), snippet, source FROM matches GROUP BY book_id) m
+        JOIN books b ON b.id = m.book_id
+        ORDER BY m.score, b.id
+        LIMIT ?3`
+
+	rows, err := m.DB.Query(stmt, match, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(
+			&r.ID,
+			&r.Title,
+			&r.Author,
+			&r.ISBN,
+			&r.PublicationYear,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+			&r.ImageURL,
+			&r.Snippet,
+			&r.Source,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ftsQuery turns a search as typed into an FTS5 query matching all of its words. Every word is quoted, so the
+// search cannot use the FTS5 query syntax, and matches words starting with it. Words in double quotes are kept
+// together as a phrase, and so are words joined by punctuation, such as "sci-fi".
+func ftsQuery(query string) string {
+	var terms []string
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			if words := ftsWords(part); len(words) > 0 {
+				terms = append(terms, `"`+strings.Join(words, " ")+`"`)
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			if words := ftsWords(field); len(words) > 0 {
+				terms = append(terms, `"`+strings.Join(words, " ")+`"*`)
+			}
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// ftsWords splits text into words the way the unicode61 tokenizer of the search tables does.
+func ftsWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,193 @@
+//go:build sqlite_fts5
+
+package models
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestBookModel_Search tests that books are found by their details, reviews and the user's own notes, ranked
+// with the best matches first, that prefix and phrase queries work and that the index follows changes.
+func TestBookModel_Search(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	notes := NoteModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	duneId, err := model.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1,
+		BookDetails{Language: "en", Description: "Paul Atreides leads the Fremen of the desert planet."})
+	testutil.NoError(t, err)
+	messiahId, err := model.Create("Dune Messiah", "Frank Herbert", "9780593098233", "reading", "", 1969, 1,
+		BookDetails{Language: "en", Description: "Twelve years later, Paul Atreides rules the known universe as its emperor."})
+	testutil.NoError(t, err)
+	emmaId, err := model.Create("Emma", "Jane Austen", "9780141439587", "reading", "", 1815, 2,
+		BookDetails{Language: "en", Description: "A novel about youthful hubris and romantic misunderstandings."})
+	testutil.NoError(t, err)
+
+	_, err = notes.Create(1, duneId, "The spice must flow, says the Guild.", 12)
+	testutil.NoError(t, err)
+	_, err = notes.Create(1, duneId, "The Fremen know the desert.", 40)
+	testutil.NoError(t, err)
+	otherNoteId, err := notes.Create(2, emmaId, "Secret thoughts about Arrakis.", 3)
+	testutil.NoError(t, err)
+	_, err = reviews.Create(2, messiahId, 4, "Darker than the first book, the Fremen empire crumbles.")
+	testutil.NoError(t, err)
+
+	titles := func(results []SearchResult) string {
+		var titles []string
+		for _, r := range results {
+			titles = append(titles, r.Title)
+		}
+		return strings.Join(titles, ",")
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		userId      int
+		want        string
+		wantSource  string
+		wantSnippet string
+	}{
+		{name: "title ranks first", query: "dune", userId: 1, want: "Dune,Dune Messiah", wantSource: SearchSourceBook},
+		{name: "prefix", query: "herb", userId: 1, want: "Dune,Dune Messiah"},
+		{name: "diacritics", query: "emmà", userId: 1, want: "Emma"},
+		{name: "description", query: "hubris", userId: 1, want: "Emma",
+			wantSnippet: "A novel about youthful " + HighlightStart + "hubris" + HighlightEnd + " and romantic misunderstandings."},
+		{name: "own note", query: "guild", userId: 1, want: "Dune", wantSource: SearchSourceNote},
+		{name: "note of another user", query: "arrakis", userId: 1, want: ""},
+		{name: "own note of the other user", query: "arrakis", userId: 2, want: "Emma", wantSource: SearchSourceNote},
+		{name: "anonymous", query: "arrakis", userId: 0, want: ""},
+		{name: "review of another user", query: "empire", userId: 1, want: "Dune Messiah", wantSource: SearchSourceReview},
+		{name: "several places rank higher", query: "fremen", userId: 1, want: "Dune,Dune Messiah"},
+		{name: "phrase", query: `"spice must"`, userId: 1, want: "Dune"},
+		{name: "phrase in another order", query: `"must spice"`, userId: 1, want: ""},
+		{name: "all words", query: "dune messiah", userId: 1, want: "Dune Messiah"},
+		{name: "query syntax is not used", query: "dune OR emma", userId: 1, want: ""},
+		{name: "no words", query: `"" *`, userId: 1, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := model.Search(tt.query, tt.userId, 10)
+			testutil.NoError(t, err)
+			testutil.Equal(t, titles(results), tt.want)
+			if tt.wantSource != "" {
+				testutil.Equal(t, results[0].Source, tt.wantSource)
+			}
+			if tt.wantSnippet != "" {
+				testutil.Equal(t, results[0].Snippet, tt.wantSnippet)
+			}
+		})
+	}
+
+	// The index follows changes to books and notes, and the deletion of books.
+	testutil.NoError(t, model.Update(emmaId, "Persuasion", "Jane Austen", "9780141439587", "reading", "", 1817, 2, BookDetails{}))
+	results, err := model.Search("persuasion", 2, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, titles(results), "Persuasion")
+	results, err = model.Search("emma", 2, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, titles(results), "")
+
+	testutil.NoError(t, notes.Update(2, otherNoteId, "Thoughts about Anne Elliot.", 3))
+	results, err = model.Search("arrakis", 2, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, titles(results), "")
+
+	testutil.NoError(t, model.Delete(emmaId, 2))
+	results, err = model.Search("elliot", 2, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, titles(results), "")
+
+	results, err = model.Search("frank", 1, 1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(results), 1)
+}
+
+// TestBookModel_Suggest tests that books, authors, tags and users are suggested by the start of their words or names.
+func TestBookModel_Suggest(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("Herbie", "herbie@example.com", "password123"))
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+
+	duneId, err := model.Create("Dune", "Frank Herbert", "9780441013593", "finished", "", 1965, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = model.Create("Dune Messiah", "Frank Herbert", "9780593098233", "reading", "", 1969, 1, BookDetails{})
+	testutil.NoError(t, err)
+	_, err = model.Create("The Dispossessed", "Ursula K. Le Guin", "9780061054884", "reading", "", 1974, 2, BookDetails{})
+	testutil.NoError(t, err)
+
+	tx, err := db.Begin()
+	testutil.NoError(t, err)
+	testutil.NoError(t, addBookTags(tx, int64(duneId), []string{"Desert", "Herbs", "Classics"}))
+	testutil.NoError(t, tx.Commit())
+
+	join := func(values []string) string {
+		return strings.Join(values, ",")
+	}
+	counts := func(counts []FacetCount) string {
+		var parts []string
+		for _, c := range counts {
+			parts = append(parts, fmt.Sprintf("%s:%d", c.Value, c.Count))
+		}
+		return join(parts)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantBooks   string
+		wantAuthors string
+		wantTags    string
+		wantUsers   string
+	}{
+		{name: "title", query: "du", wantBooks: "Dune,Dune Messiah"},
+		{name: "second word", query: "mess", wantBooks: "Dune Messiah"},
+		{name: "every word", query: "dune me", wantBooks: "Dune Messiah"},
+		{name: "author, tag and user", query: "herb", wantAuthors: "Frank Herbert:2", wantTags: "Herbs:1", wantUsers: "Herbie"},
+		{name: "case", query: "DES", wantTags: "Desert:1"},
+		{name: "author by any word", query: "gui", wantAuthors: "Ursula K. Le Guin:1"},
+		{name: "diacritics", query: "düne", wantBooks: "Dune Messiah,Dune"},
+		{name: "start of the title first", query: "the di", wantBooks: "The Dispossessed"},
+		{name: "one letter", query: "d", wantBooks: "Dune,Dune Messiah", wantTags: "Desert:1"},
+		{name: "nothing", query: "zz"},
+		{name: "blank", query: "  "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := model.Suggest(tt.query, 5)
+			testutil.NoError(t, err)
+
+			var books []string
+			for _, book := range s.Books {
+				books = append(books, book.Title)
+			}
+			testutil.Equal(t, join(books), tt.wantBooks)
+			testutil.Equal(t, counts(s.Authors), tt.wantAuthors)
+			testutil.Equal(t, counts(s.Tags), tt.wantTags)
+			testutil.Equal(t, join(s.Users), tt.wantUsers)
+			testutil.Equal(t, s.IsEmpty(), tt.wantBooks+tt.wantAuthors+tt.wantTags+tt.wantUsers == "")
+		})
+	}
+}
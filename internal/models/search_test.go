@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestFtsQuery tests that searches are turned into FTS5 queries of quoted words and phrases, so they cannot use
+// the query syntax.
+func TestFtsQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "dune", want: `"dune"*`},
+		{query: "  frank  HERB ", want: `"frank"* "HERB"*`},
+		{query: `"spice must flow" arrakis`, want: `"spice must flow" "arrakis"*`},
+		{query: "sci-fi", want: `"sci fi"*`},
+		{query: `dune OR NOT "`, want: `"dune"* "OR"* "NOT"*`},
+		{query: `title:dune* ^a (b)`, want: `"title dune"* "a"* "b"*`},
+		{query: `"unclosed phrase`, want: `"unclosed phrase"`},
+		{query: "  -- * ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			testutil.Equal(t, ftsQuery(tt.query), tt.want)
+		})
+	}
+}
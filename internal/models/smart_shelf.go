@@ -0,0 +1,293 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/madalinpopa/go-bookreview/internal/rules"
+	"github.com/mattn/go-sqlite3"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SmartShelf represents a named rule of a user, such as `status:want_to_read tag:sci-fi year>2015`, listing the
+// books that match it whenever it is opened.
+type SmartShelf struct {
+	ID     int
+	UserId int
+	Name   string
+	Rule   string
+
+	// Sort is the order of the books on the shelf, one of the Sort constants, or empty for the newest first.
+	Sort string
+
+	// ImageURL is the cover of the shelf, a private upload of its user, or empty when it has none.
+	ImageURL string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SmartShelfModel provides methods to save, list and delete the smart shelves of users.
+type SmartShelfModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// shelfRule is the rule of a smart shelf compiled to a condition of the book list and its arguments.
+type shelfRule struct {
+	text  string
+	where string
+	args  []any
+}
+
+// Create saves a new smart shelf of a user and returns its ID. Returns ErrDuplicateShelfName when the user
+// already has a shelf with the name, in any case.
+func (m *SmartShelfModel) Create(userId int, name, rule, sort, imageUrl string) (int, error) {
+	stmt := `INSERT INTO smart_shelves (user_id, name, rule, sort, image_url) VALUES (?, ?, ?, ?, ?)`
+	result, err := m.DB.Exec(stmt, userId, name, rule, sort, imageUrl)
+	if err != nil {
+		return 0, shelfError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Update changes the name, the rule, the order and the cover of a smart shelf of a user. Returns ErrNoRecord when
+// the user has no such shelf and ErrDuplicateShelfName when another of their shelves has the name.
+func (m *SmartShelfModel) Update(id, userId int, name, rule, sort, imageUrl string) error {
+	stmt := `UPDATE smart_shelves SET name = ?, rule = ?, sort = ?, image_url = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ? AND user_id = ?`
+	result, err := m.DB.Exec(stmt, name, rule, sort, imageUrl, id, userId)
+	if err != nil {
+		return shelfError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// Retrieve returns a smart shelf of a user, or ErrNoRecord when the user has no such shelf.
+func (m *SmartShelfModel) Retrieve(id, userId int) (SmartShelf, error) {
+	stmt := `SELECT id, user_id, name, rule, sort, image_url, created_at, updated_at FROM smart_shelves
+        WHERE id = ? AND user_id = ?`
+
+	var s SmartShelf
+	err := m.DB.QueryRow(stmt, id, userId).Scan(&s.ID, &s.UserId, &s.Name, &s.Rule, &s.Sort, &s.ImageURL, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SmartShelf{}, ErrNoRecord
+		}
+		return SmartShelf{}, err
+	}
+	return s, nil
+}
+
+// List returns the smart shelves of a user by name.
+func (m *SmartShelfModel) List(userId int) ([]SmartShelf, error) {
+	stmt := `SELECT id, user_id, name, rule, sort, image_url, created_at, updated_at FROM smart_shelves
+        WHERE user_id = ? ORDER BY name`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var shelves []SmartShelf
+	for rows.Next() {
+		var s SmartShelf
+		if err := rows.Scan(&s.ID, &s.UserId, &s.Name, &s.Rule, &s.Sort, &s.ImageURL, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		shelves = append(shelves, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return shelves, nil
+}
+
+// Delete deletes a smart shelf of a user, or returns ErrNoRecord when the user has no such shelf.
+func (m *SmartShelfModel) Delete(id, userId int) error {
+	result, err := m.DB.Exec(`DELETE FROM smart_shelves WHERE id = ? AND user_id = ?`, id, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// ImageReferences returns the covers of smart shelves with the number of shelves using each of them.
+func (m *SmartShelfModel) ImageReferences() (map[string]int, error) {
+	stmt := `SELECT image_url, COUNT(*) FROM smart_shelves WHERE image_url != '' GROUP BY image_url`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	references := make(map[string]int)
+	for rows.Next() {
+		var imageUrl string
+		var count int
+		if err := rows.Scan(&imageUrl, &count); err != nil {
+			return nil, err
+		}
+		references[imageUrl] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// shelfError returns ErrDuplicateShelfName for the error of saving a shelf under a name the user already has.
+func shelfError(err error) error {
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) && errors.Is(sqliteError.ExtendedCode, sqlite3.ErrConstraintUnique) {
+		return ErrDuplicateShelfName
+	}
+	return err
+}
+
+// Apply narrows a filter of the book list down to the books on the shelf, evaluating its rule as the books are
+// listed, and sorts them in the order of the shelf unless the filter has one. Returns a *rules.Error when the
+// rule is not valid.
+func (s SmartShelf) Apply(f BookFilter) (BookFilter, error) {
+	n, err := rules.Parse(s.Rule)
+	if err != nil {
+		return BookFilter{}, err
+	}
+
+	rule := &shelfRule{text: s.Rule}
+	rule.where = compileRule(n, s.UserId, &rule.args)
+	f.Shelf = s.ID
+	f.rule = rule
+	if f.Sort == "" {
+		f.Sort = s.Sort
+	}
+	return f, nil
+}
+
+// sqlOperators maps the operators of rules comparing years, ratings and dates to those of SQL.
+var sqlOperators = map[string]string{
+	rules.OpHas:          `=`,
+	rules.OpEqual:        `=`,
+	rules.OpNotEqual:     `!=`,
+	rules.OpLess:         `<`,
+	rules.OpLessEqual:    `<=`,
+	rules.OpGreater:      `>`,
+	rules.OpGreaterEqual: `>=`,
+}
+
+// compileRule compiles a parsed rule to a condition on the books of the book list, appending the values of the
+// rule to args. Every value is passed as an argument, never written into the condition. Statuses are those of the
+// books in the library of the user with ID userId, and the value "me" of the user and reviewer fields stands for
+// that user.
+func compileRule(n rules.Node, userId int, args *[]any) string {
+	switch n := n.(type) {
+	case rules.And:
+		return `(` + compileRule(n.Left, userId, args) + ` AND ` + compileRule(n.Right, userId, args) + `)`
+	case rules.Or:
+		return `(` + compileRule(n.Left, userId, args) + ` OR ` + compileRule(n.Right, userId, args) + `)`
+	case rules.Not:
+		return `NOT ` + compileRule(n.Node, userId, args)
+	case rules.Condition:
+		// Conditions on values books may lack, such as the publication year or the rating, are false rather than
+		// NULL for the books lacking them, so that their negation holds.
+		condition := `COALESCE(` + compileCondition(n, userId, args) + `, 0)`
+		if n.Op == rules.OpNotEqual && n.Field != rules.FieldYear && n.Field != rules.FieldRating && n.Field != rules.FieldAdded {
+			return `NOT ` + condition
+		}
+		return condition
+	}
+	return `0`
+}
+
+// compileCondition compiles a condition of a rule. The names and values of every field but the years, ratings
+// and dates are compared for equality, which compileRule negates for the "!=" operator.
+func compileCondition(c rules.Condition, userId int, args *[]any) string {
+	op, ok := sqlOperators[c.Op]
+	if !ok {
+		return `0`
+	}
+	contains := `%` + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(c.Value) + `%`
+	user := `(SELECT id FROM users WHERE username = ? COLLATE NOCASE)`
+	var userArg any = c.Value
+	if strings.EqualFold(c.Value, rules.Me) {
+		user = `?`
+		userArg = userId
+	}
+
+	switch c.Field {
+	case rules.FieldText:
+		*args = append(*args, contains, contains)
+		return `b.title LIKE ? ESCAPE '\' OR b.author LIKE ? ESCAPE '\'`
+	case rules.FieldTitle, rules.FieldAuthor:
+		column := `b.` + c.Field
+		if c.Op == rules.OpHas {
+			*args = append(*args, contains)
+			return column + ` LIKE ? ESCAPE '\'`
+		}
+		*args = append(*args, c.Value)
+		return column + ` = ? COLLATE NOCASE`
+	case rules.FieldTag:
+		*args = append(*args, c.Value)
+		return `EXISTS (SELECT 1 FROM book_tags bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id = b.id AND t.name = ?)`
+	case rules.FieldStatus:
+		*args = append(*args, userId, c.Value)
+		return `EXISTS (SELECT 1 FROM user_books WHERE book_id = b.id AND user_id = ? AND status = ?)`
+	case rules.FieldYear:
+		*args = append(*args, int(c.Number))
+		return `b.publication_year > 0 AND b.publication_year ` + op + ` ?`
+	case rules.FieldRating:
+		*args = append(*args, c.Number)
+		return `r.rating ` + op + ` ?`
+	case rules.FieldAdded:
+		*args = append(*args, c.Value)
+		return `date(b.created_at) ` + op + ` ?`
+	case rules.FieldCover:
+		if c.Value == CoverWithout {
+			return `COALESCE(b.image_url, '') = ''`
+		}
+		return `COALESCE(b.image_url, '') != ''`
+	case rules.FieldUser:
+		*args = append(*args, userArg)
+		return `EXISTS (SELECT 1 FROM user_books WHERE book_id = b.id AND user_id = ` + user + `)`
+	case rules.FieldReviewer:
+		*args = append(*args, userArg)
+		return `EXISTS (SELECT 1 FROM reviews rv WHERE rv.book_id = b.id AND rv.user_id = ` + user + `)`
+	}
+	return `0`
+}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/rules"
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestSmartShelfModel tests that smart shelves are saved, listed, changed and deleted for their user only.
+func TestSmartShelfModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := SmartShelfModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	sciFiId, err := model.Create(1, "Sci-fi", "tag:sci-fi", SortYear, "")
+	testutil.NoError(t, err)
+	_, err = model.Create(1, "Austen", "author:austen", "", "/uploads/a.jpg")
+	testutil.NoError(t, err)
+	_, err = model.Create(2, "Sci-fi", "tag:sci-fi", "", "")
+	testutil.NoError(t, err)
+
+	_, err = model.Create(1, "SCI-FI", "tag:space", "", "")
+	if !errors.Is(err, ErrDuplicateShelfName) {
+		t.Fatalf("got %v; want %v", err, ErrDuplicateShelfName)
+	}
+
+	shelves, err := model.List(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(shelves), 2)
+	testutil.Equal(t, shelves[0].Name, "Austen")
+	testutil.Equal(t, shelves[0].ImageURL, "/uploads/a.jpg")
+	testutil.Equal(t, shelves[1].Sort, SortYear)
+
+	testutil.NoError(t, model.Update(sciFiId, 1, "Space", "tag:space", SortTitle, "/uploads/a.jpg"))
+	shelf, err := model.Retrieve(sciFiId, 1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, shelf.Name, "Space")
+	testutil.Equal(t, shelf.Rule, "tag:space")
+	testutil.Equal(t, shelf.Sort, SortTitle)
+	testutil.Equal(t, shelf.ImageURL, "/uploads/a.jpg")
+
+	references, err := model.ImageReferences()
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(references), 1)
+	testutil.Equal(t, references["/uploads/a.jpg"], 2)
+
+	if err := model.Update(sciFiId, 1, "Austen", "tag:space", "", ""); !errors.Is(err, ErrDuplicateShelfName) {
+		t.Fatalf("got %v; want %v", err, ErrDuplicateShelfName)
+	}
+	if _, err := model.Retrieve(sciFiId, 2); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("got %v; want %v", err, ErrNoRecord)
+	}
+	if err := model.Update(sciFiId, 2, "Mine", "tag:space", "", ""); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("got %v; want %v", err, ErrNoRecord)
+	}
+	if err := model.Delete(sciFiId, 2); !errors.Is(err, ErrNoRecord) {
+		t.Fatalf("got %v; want %v", err, ErrNoRecord)
+	}
+
+	testutil.NoError(t, model.Delete(sciFiId, 1))
+	shelves, err = model.List(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(shelves), 1)
+}
+
+// TestSmartShelf_Apply tests that the rules of smart shelves select the books they describe as they are listed.
+func TestSmartShelf_Apply(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("friend", "friend@example.com", "password123"))
+	duneId := seedFilterBooks(t, &model, &reviews)
+	_, err := model.Create("100% Unknown_Year", "Anonymous", "9780000000002", "want_to_read", "", 0, 2, BookDetails{})
+	testutil.NoError(t, err)
+
+	tx, err := db.Begin()
+	testutil.NoError(t, err)
+	testutil.NoError(t, addBookTags(tx, int64(duneId), []string{"Science Fiction"}))
+	testutil.NoError(t, tx.Commit())
+	_, err = reviews.Create(2, duneId+2, 3, "")
+	testutil.NoError(t, err)
+	_, err = db.Exec(`UPDATE books SET created_at = '2025-06-01 10:00:00' WHERE id = ?`, duneId)
+	testutil.NoError(t, err)
+	// The friend reads Dune too, which leaves the status of Dune on the shelves of the reader alone.
+	_, err = db.Exec(`INSERT INTO user_books (user_id, book_id, status) VALUES (2, ?, 'reading')`, duneId)
+	testutil.NoError(t, err)
+
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{name: "words", rule: "dune", want: "Dune,Dune Messiah"},
+		{name: "phrase", rule: `"dune messiah"`, want: "Dune Messiah"},
+		{name: "like wildcards are literal", rule: `"%" "_"`, want: "100% Unknown_Year"},
+		{name: "author contains", rule: "author:tolk", want: "The Hobbit"},
+		{name: "author is", rule: `author="frank herbert"`, want: "Dune,Dune Messiah"},
+		{name: "title is not", rule: `title!=dune author:herbert`, want: "Dune Messiah"},
+		{name: "tag", rule: `tag:"science fiction"`, want: "Dune"},
+		{name: "without a tag", rule: `tag!="science fiction" author:herbert`, want: "Dune Messiah"},
+		{name: "status", rule: "status:reading", want: "Dune Messiah,Emma"},
+		{name: "year", rule: "year>1937 year<=1969", want: "Dune,Dune Messiah"},
+		{name: "unknown years never match", rule: "year<1900", want: "Emma"},
+		{name: "negated year", rule: "-year>=1900", want: "100% Unknown_Year,Emma"},
+		{name: "rating", rule: "rating>=4.5", want: "Dune"},
+		{name: "not rated", rule: "not rating>0", want: "100% Unknown_Year,Emma"},
+		{name: "added", rule: "added<2026-01-01", want: "Dune"},
+		{name: "cover", rule: "cover:yes", want: "Dune"},
+		{name: "no cover", rule: "cover!=yes status:finished", want: "The Hobbit"},
+		{name: "user", rule: "user:FRIEND", want: "100% Unknown_Year,Dune"},
+		{name: "me", rule: "-user:me", want: "100% Unknown_Year"},
+		{name: "unknown user", rule: "user!=nobody status:finished", want: "Dune,The Hobbit"},
+		{name: "reviewer", rule: "reviewer:friend or reviewer:nobody", want: "The Hobbit"},
+		{name: "reviewed by me", rule: "reviewer:me rating<3", want: "Dune Messiah"},
+		{name: "or", rule: "tag:\"science fiction\" or author:austen", want: "Dune,Emma"},
+		{name: "groups", rule: "(status:reading or status:want_to_read) -(author:austen or author:anonymous)", want: "Dune Messiah"},
+		{name: "injection", rule: `tag:"x') OR 1=1 --"`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := SmartShelf{ID: 1, UserId: 1, Rule: tt.rule, Sort: SortTitle}.Apply(BookFilter{})
+			testutil.NoError(t, err)
+			testutil.Equal(t, filter.Shelf, 1)
+
+			list, err := model.List(filter, 1, 10)
+			testutil.NoError(t, err)
+			var titles []string
+			for _, book := range list.Books {
+				titles = append(titles, book.Title)
+			}
+			testutil.Equal(t, strings.Join(titles, ","), tt.want)
+			testutil.Equal(t, list.Total, len(titles))
+		})
+	}
+
+	// Other filters narrow the books on a shelf down, sorted in their own order.
+	filter, err := SmartShelf{UserId: 1, Rule: "author:herbert", Sort: SortTitle}.Apply(BookFilter{Status: "finished"})
+	testutil.NoError(t, err)
+	list, err := model.List(filter, 1, 10)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(list.Books), 1)
+	filter, err = SmartShelf{UserId: 1, Rule: "author:herbert", Sort: SortTitle}.Apply(BookFilter{Sort: SortYear})
+	testutil.NoError(t, err)
+	testutil.Equal(t, filter.Sort, SortYear)
+
+	_, err = SmartShelf{UserId: 1, Rule: "colour:red"}.Apply(BookFilter{})
+	var ruleErr *rules.Error
+	if !errors.As(err, &ruleErr) {
+		t.Fatalf("got %v; want a rule error", err)
+	}
+}
+
+// TestBookFilter_Rule tests that a shelf saved from the filters of the book list holds the books the filters list.
+func TestBookFilter_Rule(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	reviews := ReviewModel{DB: db, Logger: logger}
+	model := BookModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	seedFilterBooks(t, &model, &reviews)
+
+	shelf, err := SmartShelf{UserId: 1, Rule: "author:herbert or author:tolkien"}.Apply(BookFilter{})
+	testutil.NoError(t, err)
+	shelf.Status = "finished"
+	shelf.MinRating = 4
+
+	tests := []struct {
+		name   string
+		filter BookFilter
+		want   string
+	}{
+		{name: "no filters", filter: BookFilter{}, want: ""},
+		{name: "every filter", filter: BookFilter{
+			Author: "Frank Herbert", YearFrom: 1960, YearTo: 1969, Status: "finished", MinRating: 4, Cover: CoverWith,
+			AddedFrom: time.Now().UTC().AddDate(0, 0, -1), AddedTo: time.Now().UTC(), Tag: "Science Fiction", User: "reader",
+		}, want: `author="Frank Herbert" year>=1960 year<=1969 status:finished rating>=4 cover:yes tag:"Science Fiction" user:reader added>=` +
+			time.Now().UTC().AddDate(0, 0, -1).Format(rules.DateLayout) + ` added<=` + time.Now().UTC().Format(rules.DateLayout)},
+		{name: "shelf", filter: shelf, want: "(author:herbert or author:tolkien) status:finished rating>=4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Equal(t, tt.filter.Rule(), tt.want)
+			if tt.want == "" {
+				return
+			}
+
+			saved, err := SmartShelf{UserId: 1, Rule: tt.filter.Rule()}.Apply(BookFilter{})
+			testutil.NoError(t, err)
+			want, err := model.List(tt.filter, 1, 10)
+			testutil.NoError(t, err)
+			got, err := model.List(saved, 1, 10)
+			testutil.NoError(t, err)
+			testutil.Equal(t, got.Total, want.Total)
+		})
+	}
+}
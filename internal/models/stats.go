@@ -0,0 +1,330 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// topLimit is the number of authors and genres the reading statistics rank.
+const topLimit = 5
+
+// ReadingCount represents the number of books a user finished in a month or a year and the pages they had.
+type ReadingCount struct {
+	Period int
+	Books  int
+	Pages  int
+}
+
+// RankedName represents an author or a genre with the number of books of it a user finished.
+type RankedName struct {
+	Name  string
+	Books int
+}
+
+// Streak represents the longest run of consecutive days on which a user finished, reviewed or noted a book.
+type Streak struct {
+	Days  int
+	Start time.Time
+	End   time.Time
+}
+
+// DayCount represents the number of books a user finished, reviewed or noted on a day.
+type DayCount struct {
+	Day   time.Time
+	Count int
+}
+
+// ReadingStats represents the reading statistics of a user for a year.
+type ReadingStats struct {
+	Year  int
+	Years []int
+
+	// Books and Pages are the books finished in the year and their pages, by month in Months and by year,
+	// for every year, in PerYear.
+	Books   int
+	Pages   int
+	Months  []ReadingCount
+	PerYear []ReadingCount
+
+	// Ratings is the number of ratings given in the year, AverageRating their average and RatingCounts the
+	// number of each rating from 1 to 5.
+	Ratings       int
+	AverageRating float64
+	RatingCounts  [5]int
+
+	TopAuthors []RankedName
+	TopGenres  []RankedName
+
+	// AverageDays is the average number of days from adding a book to the library to finishing it, of the books
+	// finished in the year whose finishing time was recorded.
+	AverageDays float64
+
+	// Days are the days of the year with reading activity, and Streak the longest run of them.
+	Days   []DayCount
+	Streak Streak
+}
+
+// StatsModel provides methods to compute the reading statistics of a user. Books finished before the finishing
+// time was recorded count as finished when they were added.
+type StatsModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// ForYear returns every reading statistic of a user for a year.
+func (m *StatsModel) ForYear(userId, year int) (ReadingStats, error) {
+	stats := ReadingStats{Year: year}
+
+	var err error
+	if stats.Years, err = m.Years(userId); err != nil {
+		return stats, err
+	}
+	if stats.Months, err = m.PerMonth(userId, year); err != nil {
+		return stats, err
+	}
+	for _, month := range stats.Months {
+		stats.Books += month.Books
+		stats.Pages += month.Pages
+	}
+	if stats.PerYear, err = m.PerYear(userId); err != nil {
+		return stats, err
+	}
+	if stats.RatingCounts, err = m.RatingCounts(userId, year); err != nil {
+		return stats, err
+	}
+	var sum int
+	for i, n := range stats.RatingCounts {
+		stats.Ratings += n
+		sum += (i + 1) * n
+	}
+	if stats.Ratings > 0 {
+		stats.AverageRating = float64(sum) / float64(stats.Ratings)
+	}
+	if stats.TopAuthors, err = m.TopAuthors(userId, year, topLimit); err != nil {
+		return stats, err
+	}
+	if stats.TopGenres, err = m.TopGenres(userId, year, topLimit); err != nil {
+		return stats, err
+	}
+	if stats.AverageDays, err = m.AverageDaysToFinish(userId, year); err != nil {
+		return stats, err
+	}
+	if stats.Days, err = m.ActiveDays(userId, year); err != nil {
+		return stats, err
+	}
+	stats.Streak = longestStreak(stats.Days)
+	return stats, nil
+}
+
+// Years returns the years in which a user finished or rated books, the most recent first.
+func (m *StatsModel) Years(userId int) ([]int, error) {
+	stmt := `SELECT DISTINCT CAST(strftime('%Y', day) AS INTEGER) AS year FROM (
+            SELECT COALESCE(finished_at, added_at) AS day FROM user_books WHERE user_id = ? AND status = 'finished'
+            UNION ALL
+            SELECT created_at FROM reviews WHERE user_id = ? AND rating IS NOT NULL
+        )
+        WHERE year IS NOT NULL
+        ORDER BY year DESC`
+
+	var years []int
+	err := m.scan(stmt, []any{userId, userId}, func(rows *sql.Rows) error {
+		var year int
+		err := rows.Scan(&year)
+		years = append(years, year)
+		return err
+	})
+	return years, err
+}
+
+// PerMonth returns the books a user finished in every month of a year and their pages, January first.
+func (m *StatsModel) PerMonth(userId, year int) ([]ReadingCount, error) {
+	stmt := `SELECT CAST(strftime('%m', COALESCE(ub.finished_at, ub.added_at)) AS INTEGER) AS month,
+               COUNT(*), COALESCE(SUM(b.page_count), 0)
+        FROM user_books ub
+        JOIN books b ON b.id = ub.book_id
+        WHERE ub.user_id = ? AND ub.status = 'finished'
+          AND CAST(strftime('%Y', COALESCE(ub.finished_at, ub.added_at)) AS INTEGER) = ?
+        GROUP BY month`
+
+	months := make([]ReadingCount, 12)
+	for i := range months {
+		months[i].Period = i + 1
+	}
+	err := m.scan(stmt, []any{userId, year}, func(rows *sql.Rows) error {
+		var c ReadingCount
+		if err := rows.Scan(&c.Period, &c.Books, &c.Pages); err != nil {
+			return err
+		}
+		if c.Period >= 1 && c.Period <= 12 {
+			months[c.Period-1] = c
+		}
+		return nil
+	})
+	return months, err
+}
+
+// PerYear returns the books a user finished in every year they finished any and their pages, the most recent first.
+func (m *StatsModel) PerYear(userId int) ([]ReadingCount, error) {
+	stmt := `SELECT CAST(strftime('%Y', COALESCE(ub.finished_at, ub.added_at)) AS INTEGER) AS year,
+               COUNT(*), COALESCE(SUM(b.page_count), 0)
+        FROM user_books ub
+        JOIN books b ON b.id = ub.book_id
+        WHERE ub.user_id = ? AND ub.status = 'finished'
+        GROUP BY year
+        HAVING year IS NOT NULL
+        ORDER BY year DESC`
+
+	var years []ReadingCount
+	err := m.scan(stmt, []any{userId}, func(rows *sql.Rows) error {
+		var c ReadingCount
+		err := rows.Scan(&c.Period, &c.Books, &c.Pages)
+		years = append(years, c)
+		return err
+	})
+	return years, err
+}
+
+// RatingCounts returns the number of ratings of each value from 1 to 5 a user gave in a year.
+func (m *StatsModel) RatingCounts(userId, year int) ([5]int, error) {
+	stmt := `SELECT rating, COUNT(*) FROM reviews
+        WHERE user_id = ? AND rating IS NOT NULL AND CAST(strftime('%Y', created_at) AS INTEGER) = ?
+        GROUP BY rating`
+
+	var counts [5]int
+	err := m.scan(stmt, []any{userId, year}, func(rows *sql.Rows) error {
+		var rating, n int
+		if err := rows.Scan(&rating, &n); err != nil {
+			return err
+		}
+		if rating >= 1 && rating <= 5 {
+			counts[rating-1] = n
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// TopAuthors returns up to limit of the authors a user finished the most books of in a year, the most first.
+func (m *StatsModel) TopAuthors(userId, year, limit int) ([]RankedName, error) {
+	stmt := `SELECT b.author, COUNT(*) AS books
+        FROM user_books ub
+        JOIN books b ON b.id = ub.book_id
+        WHERE ub.user_id = ? AND ub.status = 'finished'
+          AND CAST(strftime('%Y', COALESCE(ub.finished_at, ub.added_at)) AS INTEGER) = ?
+        GROUP BY b.author
+        ORDER BY books DESC, b.author
+        LIMIT ?`
+	return m.ranked(stmt, userId, year, limit)
+}
+
+// TopGenres returns up to limit of the tags of the books a user finished in a year that they finished the most
+// books of, the most first.
+func (m *StatsModel) TopGenres(userId, year, limit int) ([]RankedName, error) {
+	stmt := `SELECT t.name, COUNT(*) AS books
+        FROM user_books ub
+        JOIN book_tags bt ON bt.book_id = ub.book_id
+        JOIN tags t ON t.id = bt.tag_id
+        WHERE ub.user_id = ? AND ub.status = 'finished'
+          AND CAST(strftime('%Y', COALESCE(ub.finished_at, ub.added_at)) AS INTEGER) = ?
+        GROUP BY t.id
+        ORDER BY books DESC, t.name
+        LIMIT ?`
+	return m.ranked(stmt, userId, year, limit)
+}
+
+// AverageDaysToFinish returns the average number of days from adding a book to the library to finishing it, of the
+// books a user finished in a year whose finishing time was recorded, or 0 when there are none.
+func (m *StatsModel) AverageDaysToFinish(userId, year int) (float64, error) {
+	stmt := `SELECT COALESCE(AVG(MAX(julianday(finished_at) - julianday(added_at), 0)), 0)
+        FROM user_books
+        WHERE user_id = ? AND status = 'finished' AND finished_at IS NOT NULL
+          AND CAST(strftime('%Y', finished_at) AS INTEGER) = ?`
+
+	var days float64
+	err := m.DB.QueryRow(stmt, userId, year).Scan(&days)
+	return days, err
+}
+
+// ActiveDays returns the days of a year on which a user finished, reviewed or noted books, with the number of
+// books they finished, reviewed or noted that day, earliest first.
+func (m *StatsModel) ActiveDays(userId, year int) ([]DayCount, error) {
+	stmt := `SELECT date(day) AS day, COUNT(*) FROM (
+            SELECT COALESCE(finished_at, added_at) AS day FROM user_books WHERE user_id = ?1 AND status = 'finished'
+            UNION ALL
+            SELECT created_at FROM reviews WHERE user_id = ?1
+            UNION ALL
+            SELECT created_at FROM notes WHERE user_id = ?1
+        )
+        WHERE CAST(strftime('%Y', day) AS INTEGER) = ?2
+        GROUP BY date(day)
+        ORDER BY day`
+
+	var days []DayCount
+	err := m.scan(stmt, []any{userId, year}, func(rows *sql.Rows) error {
+		var s string
+		var d DayCount
+		if err := rows.Scan(&s, &d.Count); err != nil {
+			return err
+		}
+		day, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return err
+		}
+		d.Day = day
+		days = append(days, d)
+		return nil
+	})
+	return days, err
+}
+
+// longestStreak returns the longest run of consecutive days among days, sorted earliest first, the earliest of the
+// longest runs when there are several.
+func longestStreak(days []DayCount) Streak {
+	var longest, current Streak
+	for _, d := range days {
+		if current.Days > 0 && d.Day.Equal(current.End.AddDate(0, 0, 1)) {
+			current.Days++
+			current.End = d.Day
+		} else {
+			current = Streak{Days: 1, Start: d.Day, End: d.Day}
+		}
+		if current.Days > longest.Days {
+			longest = current
+		}
+	}
+	return longest
+}
+
+// ranked returns the names and numbers of books a query of a user's top authors or genres selects.
+func (m *StatsModel) ranked(stmt string, args ...any) ([]RankedName, error) {
+	var names []RankedName
+	err := m.scan(stmt, args, func(rows *sql.Rows) error {
+		var n RankedName
+		err := rows.Scan(&n.Name, &n.Books)
+		names = append(names, n)
+		return err
+	})
+	return names, err
+}
+
+// scan runs a query and calls row for every row it selects.
+func (m *StatsModel) scan(stmt string, args []any, row func(rows *sql.Rows) error) error {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	for rows.Next() {
+		if err := row(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// rankedNames writes ranked names as "name:books" pairs.
+func rankedNames(names []RankedName) string {
+	var s []string
+	for _, n := range names {
+		s = append(s, fmt.Sprintf("%s:%d", n.Name, n.Books))
+	}
+	return strings.Join(s, ",")
+}
+
+// TestStatsModel tests the reading statistics of a user for a year with books, for a year without and that
+// the books and ratings of other users are left out.
+func TestStatsModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	books := BookModel{DB: db, Logger: logger}
+	model := StatsModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("friend", "friend@example.com", "password123"))
+
+	library := []struct {
+		title, author, isbn, status, tag string
+		pages, userId                    int
+		added, finished                  string
+	}{
+		{"Dune", "Frank Herbert", "9780441013593", "finished", "Sci-Fi", 412, 1, "2024-01-01 10:00:00", "2024-01-05 10:00:00"},
+		{"Dune Messiah", "Frank Herbert", "9780593098233", "finished", "Sci-Fi", 256, 1, "2024-01-04 10:00:00", "2024-01-06 10:00:00"},
+		{"Emma", "Jane Austen", "9780141439587", "finished", "Classics", 474, 1, "2024-02-08 10:00:00", "2024-03-10 10:00:00"},
+		{"The Hobbit", "J.R.R. Tolkien", "9780261102217", "finished", "Fantasy", 310, 1, "2023-06-01 10:00:00", "2023-07-01 10:00:00"},
+		{"Persuasion", "Jane Austen", "9780141439686", "reading", "Classics", 249, 1, "2024-04-01 10:00:00", ""},
+		{"Neuromancer", "William Gibson", "9780441569595", "finished", "Sci-Fi", 271, 2, "2024-01-01 10:00:00", "2024-01-02 10:00:00"},
+	}
+	ids := make(map[string]int)
+	for _, b := range library {
+		id, err := books.Create(b.title, b.author, b.isbn, b.status, "", 2000, b.userId, BookDetails{Language: "en", PageCount: b.pages})
+		testutil.NoError(t, err)
+		ids[b.title] = id
+
+		_, err = db.Exec(`UPDATE user_books SET added_at = ?, finished_at = NULLIF(?, '') WHERE book_id = ?`, b.added, b.finished, id)
+		testutil.NoError(t, err)
+
+		tx, err := db.Begin()
+		testutil.NoError(t, err)
+		testutil.NoError(t, addBookTags(tx, int64(id), []string{b.tag}))
+		testutil.NoError(t, tx.Commit())
+	}
+
+	_, err := db.Exec(`INSERT INTO reviews (user_id, book_id, rating, created_at) VALUES
+		(1, ?, 5, '2024-01-07 09:00:00'),
+		(1, ?, 4, '2024-01-07 21:00:00'),
+		(1, ?, 3, '2024-03-10 09:00:00'),
+		(1, ?, 2, '2023-07-02 09:00:00'),
+		(2, ?, 1, '2024-01-02 09:00:00')`,
+		ids["Dune"], ids["Dune Messiah"], ids["Emma"], ids["The Hobbit"], ids["Neuromancer"])
+	testutil.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO notes (user_id, book_id, note_text, created_at) VALUES (1, ?, 'Clever.', '2024-03-11 09:00:00')`,
+		ids["Emma"])
+	testutil.NoError(t, err)
+
+	years, err := model.Years(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, fmt.Sprint(years), "[2024 2023]")
+
+	tests := []struct {
+		name          string
+		year          int
+		books         int
+		pages         int
+		january       ReadingCount
+		ratings       string
+		averageRating float64
+		authors       string
+		genres        string
+		averageDays   float64
+		streak        int
+	}{
+		{name: "year with books", year: 2024, books: 3, pages: 1142, january: ReadingCount{Period: 1, Books: 2, Pages: 668},
+			ratings: "[0 0 1 1 1]", averageRating: 4, authors: "Frank Herbert:2,Jane Austen:1", genres: "Sci-Fi:2,Classics:1",
+			averageDays: 12.33, streak: 3},
+		{name: "earlier year", year: 2023, books: 1, pages: 310, january: ReadingCount{Period: 1},
+			ratings: "[0 1 0 0 0]", averageRating: 2, authors: "J.R.R. Tolkien:1", genres: "Fantasy:1",
+			averageDays: 30, streak: 2},
+		{name: "year without books", year: 2022, january: ReadingCount{Period: 1}, ratings: "[0 0 0 0 0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := model.ForYear(1, tt.year)
+			testutil.NoError(t, err)
+			testutil.Equal(t, stats.Year, tt.year)
+			testutil.Equal(t, stats.Books, tt.books)
+			testutil.Equal(t, stats.Pages, tt.pages)
+			testutil.Equal(t, len(stats.Months), 12)
+			testutil.Equal(t, stats.Months[0], tt.january)
+			testutil.Equal(t, fmt.Sprint(stats.RatingCounts), tt.ratings)
+			testutil.Equal(t, stats.AverageRating, tt.averageRating)
+			testutil.Equal(t, rankedNames(stats.TopAuthors), tt.authors)
+			testutil.Equal(t, rankedNames(stats.TopGenres), tt.genres)
+			testutil.Equal(t, math.Round(stats.AverageDays*100)/100, tt.averageDays)
+			testutil.Equal(t, stats.Streak.Days, tt.streak)
+		})
+	}
+
+	stats, err := model.ForYear(1, 2024)
+	testutil.NoError(t, err)
+	testutil.Equal(t, stats.Months[2], ReadingCount{Period: 3, Books: 1, Pages: 474})
+	testutil.Equal(t, stats.Streak.Start.Format("2006-01-02"), "2024-01-05")
+	testutil.Equal(t, stats.Streak.End.Format("2006-01-02"), "2024-01-07")
+	testutil.Equal(t, fmt.Sprint(stats.PerYear), "[{2024 3 1142} {2023 1 310}]")
+	testutil.Equal(t, len(stats.Days), 5)
+	testutil.Equal(t, stats.Days[1], DayCount{Day: time.Date(2024, time.January, 6, 0, 0, 0, 0, time.UTC), Count: 1})
+	testutil.Equal(t, stats.Days[2].Count, 2)
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+	"strings"
+)
+
+// TagModel provides methods to read the tags attached to books.
+type TagModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// ForBook returns the names of the tags attached to a book, ordered by name.
+func (m *TagModel) ForBook(bookId int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t
+		JOIN book_tags bt ON bt.tag_id = t.id
+		WHERE bt.book_id = ?
+		ORDER BY t.name COLLATE NOCASE`
+
+	rows, err := m.DB.Query(stmt, bookId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var tags []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// addBookTags attaches the given tags to a book, creating tags that do not exist yet.
+// Tags already attached to the book are kept, so the call can be repeated safely.
+func addBookTags(tx *sql.Tx, bookId int64, tags []string) error {
+	for _, name := range tags {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT OR IGNORE INTO book_tags (book_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`, bookId, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TagCount represents a tag together with the number of books in a library that carry it.
+type TagCount struct {
+	Name  string
+	Books int
+}
+
+// ForLibrary returns the tags used by the books in a user's library with their book counts, ordered by name.
+func (m *TagModel) ForLibrary(userId int) ([]TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+		JOIN book_tags bt ON bt.tag_id = t.id
+		JOIN user_books ub ON ub.book_id = bt.book_id
+		WHERE ub.user_id = ?
+		GROUP BY t.id
+		ORDER BY t.name COLLATE NOCASE`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var tags []TagCount
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Books); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// tokenBytes is the number of random bytes in an API token.
+const tokenBytes = 20
+
+// APIToken represents a named token a user created to access their library without a session.
+type APIToken struct {
+	ID         int
+	UserId     int
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	// SyncKey reports whether the token works as a KOReader sync password. Tokens created before KOReader sync
+	// lack the key until they are used elsewhere.
+	SyncKey bool
+}
+
+// APITokenModel provides methods to create, check and revoke API tokens.
+// Only SHA-256 hashes of each token are stored, so tokens cannot be recovered from the database.
+type APITokenModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// Create generates a new token for a user and returns it. The token is only available at this point.
+func (m *APITokenModel) Create(userId int, name string) (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, kosync_key_hash) VALUES (?, ?, ?, ?)`
+	if _, err := m.DB.Exec(stmt, userId, name, hashToken(token), hashToken(kosyncKey(token))); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Authenticate returns the ID of the user a token belongs to and records when it was used, storing the KOReader
+// sync key of tokens created before KOReader sync. Returns ErrInvalidCredentials if the token does not exist.
+func (m *APITokenModel) Authenticate(token string) (int, error) {
+	var id, userId int
+	err := m.DB.QueryRow(`SELECT id, user_id FROM api_tokens WHERE token_hash = ?`, hashToken(token)).Scan(&id, &userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	stmt := `UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP,
+		kosync_key_hash = CASE WHEN kosync_key_hash = '' THEN ? ELSE kosync_key_hash END
+		WHERE id = ?`
+	if _, err := m.DB.Exec(stmt, hashToken(kosyncKey(token)), id); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// AuthenticateSyncKey returns the ID of the user with the given username when key is the KOReader sync key
+// of one of their tokens, and records when the token was used. Returns ErrInvalidCredentials otherwise.
+func (m *APITokenModel) AuthenticateSyncKey(username, key string) (int, error) {
+	stmt := `SELECT t.id, t.user_id FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE u.username = ? AND t.kosync_key_hash = ?`
+
+	var id, userId int
+	err := m.DB.QueryRow(stmt, username, hashToken(strings.ToLower(key))).Scan(&id, &userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	if _, err := m.DB.Exec(`UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// List returns the tokens of a user, newest first.
+func (m *APITokenModel) List(userId int) ([]APIToken, error) {
+	stmt := `SELECT id, user_id, name, created_at, last_used_at, kosync_key_hash != '' FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC, id DESC`
+
+	rows, err := m.DB.Query(stmt, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			m.Logger.Error(err.Error())
+		}
+	}()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var token APIToken
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.UserId, &token.Name, &token.CreatedAt, &lastUsedAt, &token.SyncKey); err != nil {
+			return nil, err
+		}
+		token.LastUsedAt = lastUsedAt.Time
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete revokes a token of a user. Returns ErrNoRecord if the user has no such token.
+func (m *APITokenModel) Delete(id, userId int) error {
+	result, err := m.DB.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// kosyncKey returns the key KOReader derives from a password, the hex encoded MD5 hash, for a token used as one.
+func kosyncKey(token string) string {
+	sum := md5.Sum([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashToken returns the hex encoded SHA-256 hash under which a token is stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestAPITokenModel tests that tokens and their KOReader sync keys authenticate their user until they are revoked, and only by their owner.
+func TestAPITokenModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := APITokenModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, users.Create("other", "other@example.com", "password123"))
+
+	token, err := model.Create(1, "KOReader")
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(token), 2*tokenBytes)
+
+	var stored string
+	testutil.NoError(t, db.QueryRow(`SELECT token_hash FROM api_tokens`).Scan(&stored))
+	testutil.Equal(t, stored != token, true)
+
+	userId, err := model.Authenticate(token)
+	testutil.NoError(t, err)
+	testutil.Equal(t, userId, 1)
+
+	_, err = model.Authenticate("not-a-token")
+	testutil.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	tokens, err := model.List(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(tokens), 1)
+	testutil.Equal(t, tokens[0].Name, "KOReader")
+	testutil.Equal(t, tokens[0].LastUsedAt.IsZero(), false)
+
+	userId, err = model.AuthenticateSyncKey("reader", strings.ToUpper(kosyncKey(token)))
+	testutil.NoError(t, err)
+	testutil.Equal(t, userId, 1)
+
+	_, err = model.AuthenticateSyncKey("other", kosyncKey(token))
+	testutil.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+	_, err = model.AuthenticateSyncKey("reader", kosyncKey("password123"))
+	testutil.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	testutil.Equal(t, errors.Is(model.Delete(tokens[0].ID, 2), ErrNoRecord), true)
+	testutil.NoError(t, model.Delete(tokens[0].ID, 1))
+
+	_, err = model.Authenticate(token)
+	testutil.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	// Tokens created before KOReader sync get their sync key when they are first used as a token.
+	legacy, err := model.Create(1, "OPDS")
+	testutil.NoError(t, err)
+	_, err = db.Exec(`UPDATE api_tokens SET kosync_key_hash = ''`)
+	testutil.NoError(t, err)
+	tokens, err = model.List(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, tokens[0].SyncKey, false)
+	_, err = model.AuthenticateSyncKey("reader", kosyncKey(legacy))
+	testutil.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	_, err = model.Authenticate(legacy)
+	testutil.NoError(t, err)
+	tokens, err = model.List(1)
+	testutil.NoError(t, err)
+	testutil.Equal(t, tokens[0].SyncKey, true)
+	userId, err = model.AuthenticateSyncKey("reader", kosyncKey(legacy))
+	testutil.NoError(t, err)
+	testutil.Equal(t, userId, 1)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+)
+
+// UploadModel provides methods to mark uploaded files as private and find out who may see them.
+type UploadModel struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+// SetPrivate marks the files stored at keys as private to a user.
+func (m *UploadModel) SetPrivate(userId int, keys ...string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				m.Logger.Error(rbErr.Error())
+			}
+		}
+	}()
+
+	for _, key := range keys {
+		if _, err = tx.Exec(`INSERT OR IGNORE INTO private_uploads (key, user_id) VALUES (?, ?)`, key, userId); err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	return err
+}
+
+// Owner returns the ID of the user a private file belongs to, or ErrNoRecord if the file is not private.
+func (m *UploadModel) Owner(key string) (int, error) {
+	var userId int
+	err := m.DB.QueryRow(`SELECT user_id FROM private_uploads WHERE key = ?`, key).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	return userId, nil
+}
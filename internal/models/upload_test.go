@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestUploadModel tests that private files belong to the user who marked them, until the user is deleted.
+func TestUploadModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	users := UserModel{DB: db, Logger: logger}
+	model := UploadModel{DB: db, Logger: logger}
+
+	testutil.NoError(t, users.Create("reader", "reader@example.com", "password123"))
+	testutil.NoError(t, model.SetPrivate(1, "a.jpg", "a-320w.jpg"))
+	testutil.NoError(t, model.SetPrivate(1, "a.jpg"))
+
+	owner, err := model.Owner("a-320w.jpg")
+	testutil.NoError(t, err)
+	testutil.Equal(t, owner, 1)
+
+	_, err = model.Owner("b.jpg")
+	testutil.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	_, err = db.Exec(`DELETE FROM users WHERE id = 1`)
+	testutil.NoError(t, err)
+	_, err = model.Owner("a.jpg")
+	testutil.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
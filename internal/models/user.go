@@ -105,3 +105,16 @@ func (m *UserModel) Authenticate(username, password string) (int, error) {
 
 	return id, nil
 }
+
+// RetrieveId returns the ID of the user with the given username, or ErrNoRecord if there is no such user.
+func (m *UserModel) RetrieveId(username string) (int, error) {
+	var id int
+	err := m.DB.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	return id, nil
+}
@@ -0,0 +1,442 @@
+// Package opds writes OPDS catalog feeds, as OPDS 1.2 Atom documents and as OPDS 2.0 JSON documents.
+package opds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Media types of the documents written by this package.
+const (
+	NavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OpenSearchType  = "application/opensearchdescription+xml"
+	JSONType        = "application/opds+json"
+)
+
+const (
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+	dcNamespace     = "http://purl.org/dc/terms/"
+	opdsNamespace   = "http://opds-spec.org/2010/catalog"
+	searchNamespace = "http://a9.com/-/spec/opensearch/1.1/"
+	imageRel        = "http://opds-spec.org/image"
+	thumbnailRel    = "http://opds-spec.org/image/thumbnail"
+	schemaBookType  = "http://schema.org/Book"
+	htmlType        = "text/html"
+)
+
+// SearchParameters are the query parameters holding the search terms in OPDS 1.2 and OPDS 2.0 search links.
+var SearchParameters = [2]string{"q", "query"}
+
+// Navigation is an entry of a navigation feed that leads to another feed.
+type Navigation struct {
+	ID      string
+	Title   string
+	Href    string
+	Summary string
+
+	// Acquisition tells whether Href leads to an acquisition feed rather than another navigation feed.
+	Acquisition bool
+
+	// Image links to a cover of the entry, if it has one. Only Atom feeds show it, as OPDS 2.0 navigation
+	// links have no images.
+	Image     string
+	ImageType string
+}
+
+// Publication is a book listed in an acquisition feed.
+type Publication struct {
+	ID              string
+	Title           string
+	Author          string
+	ISBN            string
+	Language        string
+	Publisher       string
+	Description     string
+	PublicationYear int
+	Updated         time.Time
+
+	// Href links to the page of the book in the web interface.
+	Href string
+
+	// Image links to the cover of the book, if it has one.
+	Image     string
+	ImageType string
+}
+
+// Feed is a catalog feed: an acquisition feed when it lists publications or is paginated, a navigation feed otherwise.
+type Feed struct {
+	ID      string
+	Title   string
+	Updated time.Time
+
+	// Self, Start and Up link to this feed, the catalog root and the parent feed. Up may be empty.
+	Self  string
+	Start string
+	Up    string
+
+	// Search links to the search of the catalog: the OpenSearch description for Atom feeds
+	// and the search feed, to be extended with a query, for JSON feeds.
+	Search string
+
+	Navigation   []Navigation
+	Publications []Publication
+
+	// Page, PageSize and Total describe the position of an acquisition feed in a paginated list.
+	// PageHref returns the link to another page of the same list.
+	Page     int
+	PageSize int
+	Total    int
+	PageHref func(page int) string
+}
+
+// Acquisition tells whether the feed lists publications rather than links to other feeds.
+func (f *Feed) Acquisition() bool {
+	return len(f.Publications) > 0 || f.PageSize > 0
+}
+
+// totalPages returns the number of pages of a paginated feed.
+func (f *Feed) totalPages() int {
+	if f.PageSize <= 0 {
+		return 0
+	}
+	return (f.Total + f.PageSize - 1) / f.PageSize
+}
+
+// pageLinks returns the first, previous, next and last links of a paginated feed by relation.
+func (f *Feed) pageLinks() [][2]string {
+	totalPages := f.totalPages()
+	if f.PageHref == nil || totalPages <= 1 {
+		return nil
+	}
+
+	var links [][2]string
+	links = append(links, [2]string{"first", f.PageHref(1)})
+	if f.Page > 1 {
+		links = append(links, [2]string{"previous", f.PageHref(f.Page - 1)})
+	}
+	if f.Page < totalPages {
+		links = append(links, [2]string{"next", f.PageHref(f.Page + 1)})
+	}
+	links = append(links, [2]string{"last", f.PageHref(totalPages)})
+	return links
+}
+
+// atomLink is a link element of an Atom feed or entry.
+type atomLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+// atomText is an Atom text construct.
+type atomText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// atomAuthor is the author of an Atom entry.
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomEntry is an entry of an Atom feed.
+type atomEntry struct {
+	ID         string       `xml:"id"`
+	Title      string       `xml:"title"`
+	Updated    string       `xml:"updated"`
+	Authors    []atomAuthor `xml:"author,omitempty"`
+	Identifier string       `xml:"dc:identifier,omitempty"`
+	Language   string       `xml:"dc:language,omitempty"`
+	Publisher  string       `xml:"dc:publisher,omitempty"`
+	Issued     string       `xml:"dc:issued,omitempty"`
+	Summary    *atomText    `xml:"summary,omitempty"`
+	Content    *atomText    `xml:"content,omitempty"`
+	Links      []atomLink   `xml:"link"`
+}
+
+// atomFeed is an OPDS 1.2 Atom feed.
+type atomFeed struct {
+	XMLName      xml.Name    `xml:"feed"`
+	Xmlns        string      `xml:"xmlns,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS    string      `xml:"xmlns:opds,attr"`
+	XmlnsSearch  string      `xml:"xmlns:opensearch,attr"`
+	ID           string      `xml:"id"`
+	Title        string      `xml:"title"`
+	Updated      string      `xml:"updated"`
+	TotalResults int         `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int         `xml:"opensearch:itemsPerPage,omitempty"`
+	Links        []atomLink  `xml:"link"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+// WriteAtom writes the feed as an OPDS 1.2 Atom document.
+func (f *Feed) WriteAtom(w io.Writer) error {
+	kind := NavigationType
+	if f.Acquisition() {
+		kind = AcquisitionType
+	}
+	updated := atomTime(f.Updated)
+
+	feed := atomFeed{
+		Xmlns:       atomNamespace,
+		XmlnsDC:     dcNamespace,
+		XmlnsOPDS:   opdsNamespace,
+		XmlnsSearch: searchNamespace,
+		ID:          f.ID,
+		Title:       f.Title,
+		Updated:     updated,
+		Links: []atomLink{
+			{Rel: "self", Href: f.Self, Type: kind},
+			{Rel: "start", Href: f.Start, Type: NavigationType},
+		},
+	}
+	if f.Up != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "up", Href: f.Up, Type: NavigationType})
+	}
+	if f.Search != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "search", Href: f.Search, Type: OpenSearchType})
+	}
+	for _, link := range f.pageLinks() {
+		feed.Links = append(feed.Links, atomLink{Rel: link[0], Href: link[1], Type: kind})
+	}
+	if f.Acquisition() && f.PageSize > 0 {
+		feed.TotalResults = f.Total
+		feed.ItemsPerPage = f.PageSize
+	}
+
+	for _, n := range f.Navigation {
+		linkType := NavigationType
+		if n.Acquisition {
+			linkType = AcquisitionType
+		}
+		entry := atomEntry{
+			ID:      n.ID,
+			Title:   n.Title,
+			Updated: updated,
+			Links:   []atomLink{{Rel: "subsection", Href: n.Href, Type: linkType}},
+		}
+		if n.Summary != "" {
+			entry.Content = &atomText{Type: "text", Value: n.Summary}
+		}
+		if n.Image != "" {
+			entry.Links = append(entry.Links,
+				atomLink{Rel: imageRel, Href: n.Image, Type: n.ImageType},
+				atomLink{Rel: thumbnailRel, Href: n.Image, Type: n.ImageType},
+			)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	for _, p := range f.Publications {
+		entry := atomEntry{
+			ID:        p.ID,
+			Title:     p.Title,
+			Updated:   atomTime(p.Updated),
+			Language:  p.Language,
+			Publisher: p.Publisher,
+			Links:     []atomLink{{Rel: "alternate", Href: p.Href, Type: htmlType, Title: "View in the library"}},
+		}
+		if p.Author != "" {
+			entry.Authors = []atomAuthor{{Name: p.Author}}
+		}
+		if p.ISBN != "" {
+			entry.Identifier = "urn:isbn:" + p.ISBN
+		}
+		if p.PublicationYear > 0 {
+			entry.Issued = fmt.Sprintf("%04d", p.PublicationYear)
+		}
+		if p.Description != "" {
+			entry.Summary = &atomText{Type: "text", Value: p.Description}
+		}
+		if p.Image != "" {
+			entry.Links = append(entry.Links,
+				atomLink{Rel: imageRel, Href: p.Image, Type: p.ImageType},
+				atomLink{Rel: thumbnailRel, Href: p.Image, Type: p.ImageType},
+			)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+// jsonLink is a link of an OPDS 2.0 document.
+type jsonLink struct {
+	Rel       string `json:"rel,omitempty"`
+	Href      string `json:"href"`
+	Type      string `json:"type,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+// jsonContributor is an author of an OPDS 2.0 publication.
+type jsonContributor struct {
+	Name string `json:"name"`
+}
+
+// jsonPublicationMetadata is the metadata of an OPDS 2.0 publication.
+type jsonPublicationMetadata struct {
+	Type        string            `json:"@type"`
+	Identifier  string            `json:"identifier,omitempty"`
+	Title       string            `json:"title"`
+	Author      []jsonContributor `json:"author,omitempty"`
+	Language    string            `json:"language,omitempty"`
+	Publisher   string            `json:"publisher,omitempty"`
+	Published   string            `json:"published,omitempty"`
+	Modified    string            `json:"modified,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+// jsonPublication is a publication of an OPDS 2.0 feed.
+type jsonPublication struct {
+	Metadata jsonPublicationMetadata `json:"metadata"`
+	Links    []jsonLink              `json:"links"`
+	Images   []jsonLink              `json:"images,omitempty"`
+}
+
+// jsonFeedMetadata is the metadata of an OPDS 2.0 feed.
+type jsonFeedMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified,omitempty"`
+	NumberOfItems int    `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int    `json:"itemsPerPage,omitempty"`
+	CurrentPage   int    `json:"currentPage,omitempty"`
+}
+
+// jsonFeed is an OPDS 2.0 feed.
+type jsonFeed struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+}
+
+// WriteJSON writes the feed as an OPDS 2.0 JSON document.
+func (f *Feed) WriteJSON(w io.Writer) error {
+	feed := jsonFeed{
+		Metadata: jsonFeedMetadata{Title: f.Title, Modified: atomTime(f.Updated)},
+		Links: []jsonLink{
+			{Rel: "self", Href: f.Self, Type: JSONType},
+			{Rel: "start", Href: f.Start, Type: JSONType},
+		},
+		Navigation:   []jsonLink{},
+		Publications: []jsonPublication{},
+	}
+	if f.Up != "" {
+		feed.Links = append(feed.Links, jsonLink{Rel: "up", Href: f.Up, Type: JSONType})
+	}
+	if f.Search != "" {
+		feed.Links = append(feed.Links, jsonLink{Rel: "search", Href: f.Search + "{?" + SearchParameters[1] + "}", Type: JSONType, Templated: true})
+	}
+	for _, link := range f.pageLinks() {
+		feed.Links = append(feed.Links, jsonLink{Rel: link[0], Href: link[1], Type: JSONType})
+	}
+
+	if f.Acquisition() {
+		feed.Navigation = nil
+		if f.PageSize > 0 {
+			feed.Metadata.NumberOfItems = f.Total
+			feed.Metadata.ItemsPerPage = f.PageSize
+			feed.Metadata.CurrentPage = f.Page
+		}
+	} else {
+		feed.Publications = nil
+	}
+
+	for _, n := range f.Navigation {
+		feed.Navigation = append(feed.Navigation, jsonLink{Rel: "subsection", Href: n.Href, Type: JSONType, Title: n.Title})
+	}
+
+	for _, p := range f.Publications {
+		pub := jsonPublication{
+			Metadata: jsonPublicationMetadata{
+				Type:        schemaBookType,
+				Title:       p.Title,
+				Language:    p.Language,
+				Publisher:   p.Publisher,
+				Modified:    atomTime(p.Updated),
+				Description: p.Description,
+			},
+			Links: []jsonLink{{Rel: "alternate", Href: p.Href, Type: htmlType}},
+		}
+		if p.Author != "" {
+			pub.Metadata.Author = []jsonContributor{{Name: p.Author}}
+		}
+		if p.ISBN != "" {
+			pub.Metadata.Identifier = "urn:isbn:" + p.ISBN
+		}
+		if p.PublicationYear > 0 {
+			pub.Metadata.Published = fmt.Sprintf("%04d", p.PublicationYear)
+		}
+		if p.Image != "" {
+			pub.Images = []jsonLink{{Href: p.Image, Type: p.ImageType}}
+		}
+		feed.Publications = append(feed.Publications, pub)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
+
+// openSearchURL is the URL template of an OpenSearch description.
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// openSearchDescription is an OpenSearch 1.1 description document.
+type openSearchDescription struct {
+	XMLName     xml.Name      `xml:"OpenSearchDescription"`
+	Xmlns       string        `xml:"xmlns,attr"`
+	ShortName   string        `xml:"ShortName"`
+	Description string        `xml:"Description"`
+	URL         openSearchURL `xml:"Url"`
+}
+
+// WriteOpenSearch writes the OpenSearch description of a catalog whose acquisition feed of
+// search results is served at searchHref.
+func WriteOpenSearch(w io.Writer, title, searchHref string) error {
+	doc := openSearchDescription{
+		Xmlns:       searchNamespace,
+		ShortName:   title,
+		Description: "Search the books of " + title,
+		URL:         openSearchURL{Type: AcquisitionType, Template: searchHref + "?" + SearchParameters[0] + "={searchTerms}"},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+// ImageType returns the media type of a cover image from its file extension.
+func ImageType(href string) string {
+	switch {
+	case strings.HasSuffix(strings.ToLower(href), ".png"):
+		return "image/png"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// atomTime formats a time the way Atom and OPDS 2.0 expect it.
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,164 @@
+package opds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// testFeed returns the second page of a paginated acquisition feed with one publication.
+func testFeed() *Feed {
+	return &Feed{
+		ID:       "urn:test:shelf",
+		Title:    "Finished",
+		Updated:  time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC),
+		Self:     "/opds/shelves/finished?page=2",
+		Start:    "/opds/",
+		Up:       "/opds/",
+		Search:   "/opds/search.xml",
+		Page:     2,
+		PageSize: 1,
+		Total:    3,
+		PageHref: func(page int) string { return fmt.Sprintf("/opds/shelves/finished?page=%d", page) },
+		Publications: []Publication{{
+			ID:              "urn:test:book:1",
+			Title:           "Dune",
+			Author:          "Frank Herbert",
+			ISBN:            "9780441013593",
+			Language:        "en",
+			PublicationYear: 1965,
+			Href:            "/books/1",
+			Image:           "/uploads/1-dune.png",
+			ImageType:       ImageType("/uploads/1-dune.png"),
+		}},
+	}
+}
+
+// TestFeed_WriteAtom verifies the OPDS 1.2 document of an acquisition feed, including pagination and cover links.
+func TestFeed_WriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	testutil.NoError(t, testFeed().WriteAtom(&buf))
+
+	var doc struct {
+		Title string `xml:"title"`
+		Total int    `xml:"http://a9.com/-/spec/opensearch/1.1/ totalResults"`
+		Links []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+		Entries []struct {
+			Title      string `xml:"title"`
+			Author     string `xml:"author>name"`
+			Identifier string `xml:"http://purl.org/dc/terms/ identifier"`
+			Links      []struct {
+				Rel  string `xml:"rel,attr"`
+				Href string `xml:"href,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	testutil.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	testutil.Equal(t, doc.Title, "Finished")
+	testutil.Equal(t, doc.Total, 3)
+
+	links := make(map[string]string)
+	for _, l := range doc.Links {
+		links[l.Rel] = l.Href
+		if l.Rel == "self" {
+			testutil.Equal(t, l.Type, AcquisitionType)
+		}
+		if l.Rel == "search" {
+			testutil.Equal(t, l.Type, OpenSearchType)
+		}
+	}
+	testutil.Equal(t, links["previous"], "/opds/shelves/finished?page=1")
+	testutil.Equal(t, links["next"], "/opds/shelves/finished?page=3")
+	testutil.Equal(t, links["last"], "/opds/shelves/finished?page=3")
+
+	testutil.Equal(t, len(doc.Entries), 1)
+	entry := doc.Entries[0]
+	testutil.Equal(t, entry.Author, "Frank Herbert")
+	testutil.Equal(t, entry.Identifier, "urn:isbn:9780441013593")
+	testutil.Equal(t, len(entry.Links), 3)
+	testutil.Equal(t, entry.Links[1].Rel, "http://opds-spec.org/image")
+	testutil.Equal(t, entry.Links[1].Type, "image/png")
+
+	nav := &Feed{Title: "Root", Self: "/opds/", Start: "/opds/", Navigation: []Navigation{
+		{Title: "Sci-fi", Href: "/opds/smart/1", Image: "/uploads/a.jpg?expires=1", ImageType: ImageType("/uploads/a.jpg")},
+	}}
+	buf.Reset()
+	testutil.NoError(t, nav.WriteAtom(&buf))
+	doc.Entries = nil
+	testutil.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	entry = doc.Entries[0]
+	testutil.Equal(t, len(entry.Links), 3)
+	testutil.Equal(t, entry.Links[2].Rel, "http://opds-spec.org/image/thumbnail")
+	testutil.Equal(t, entry.Links[2].Href, "/uploads/a.jpg?expires=1")
+	testutil.Equal(t, entry.Links[2].Type, "image/jpeg")
+}
+
+// TestFeed_WriteJSON verifies the OPDS 2.0 document of navigation and acquisition feeds.
+func TestFeed_WriteJSON(t *testing.T) {
+	feed := testFeed()
+	feed.Search = "/opds/v2/search"
+
+	var buf bytes.Buffer
+	testutil.NoError(t, feed.WriteJSON(&buf))
+
+	var doc struct {
+		Metadata struct {
+			NumberOfItems int `json:"numberOfItems"`
+			CurrentPage   int `json:"currentPage"`
+		} `json:"metadata"`
+		Links []struct {
+			Rel       string `json:"rel"`
+			Href      string `json:"href"`
+			Templated bool   `json:"templated"`
+		} `json:"links"`
+		Navigation   []json.RawMessage `json:"navigation"`
+		Publications []struct {
+			Metadata struct {
+				Title     string `json:"title"`
+				Published string `json:"published"`
+			} `json:"metadata"`
+			Images []struct {
+				Href string `json:"href"`
+			} `json:"images"`
+		} `json:"publications"`
+	}
+	testutil.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	testutil.Equal(t, doc.Metadata.NumberOfItems, 3)
+	testutil.Equal(t, doc.Metadata.CurrentPage, 2)
+	testutil.Equal(t, doc.Navigation == nil, true)
+	testutil.Equal(t, doc.Publications[0].Metadata.Title, "Dune")
+	testutil.Equal(t, doc.Publications[0].Metadata.Published, "1965")
+	testutil.Equal(t, doc.Publications[0].Images[0].Href, "/uploads/1-dune.png")
+
+	for _, l := range doc.Links {
+		if l.Rel == "search" {
+			testutil.Equal(t, l.Href, "/opds/v2/search{?query}")
+			testutil.Equal(t, l.Templated, true)
+		}
+	}
+
+	nav := &Feed{Title: "Root", Self: "/opds/v2/", Start: "/opds/v2/", Navigation: []Navigation{{Title: "Tags", Href: "/opds/v2/tags"}}}
+	buf.Reset()
+	testutil.NoError(t, nav.WriteJSON(&buf))
+	testutil.Equal(t, nav.Acquisition(), false)
+	testutil.Equal(t, strings.Contains(buf.String(), `"publications"`), false)
+	testutil.Equal(t, strings.Contains(buf.String(), `"href": "/opds/v2/tags"`), true)
+}
+
+// TestWriteOpenSearch verifies the search template of the OpenSearch description.
+func TestWriteOpenSearch(t *testing.T) {
+	var buf bytes.Buffer
+	testutil.NoError(t, WriteOpenSearch(&buf, "Book Review", "/opds/search"))
+	testutil.Equal(t, strings.Contains(buf.String(), `template="/opds/search?q={searchTerms}"`), true)
+}
@@ -0,0 +1,186 @@
+package recommend
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Weights of the parts of the content of books in their similarity, which add up to 1.
+const (
+	authorWeight      = 0.3
+	seriesWeight      = 0.3
+	tagWeight         = 0.2
+	descriptionWeight = 0.2
+)
+
+// minContentScore is the least similarity of the content of two books for them to be alike.
+const minContentScore = 0.1
+
+// seriesPattern matches the series at the end of a title the way Goodreads writes it, as in
+// "Dune Messiah (Dune Chronicles, #2)".
+var seriesPattern = regexp.MustCompile(`\(([^()#]+?),?\s*#\s*[0-9.\-]+\)\s*$`)
+
+// authorSeparators splits the authors of a book written as "Terry Pratchett & Neil Gaiman" or with commas.
+var authorSeparators = regexp.MustCompile(`(?i)\s*(?:,|&|;|\band\b)\s*`)
+
+// stopWords are common English words that say nothing about what a book is about.
+var stopWords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "and": true, "are": true, "but": true,
+	"book": true, "can": true, "for": true, "from": true, "had": true, "has": true, "have": true, "her": true,
+	"his": true, "how": true, "into": true, "its": true, "more": true, "not": true, "novel": true, "one": true,
+	"only": true, "our": true, "out": true, "she": true, "story": true, "than": true, "that": true, "the": true,
+	"their": true, "them": true, "then": true, "there": true, "they": true, "this": true, "was": true,
+	"were": true, "what": true, "when": true, "which": true, "who": true, "will": true, "with": true,
+	"would": true, "you": true, "your": true,
+}
+
+// features are the parts of the content of a book it is compared by.
+type features struct {
+	authors map[string]bool
+	series  string
+	tags    map[string]bool
+	terms   map[string]float64
+}
+
+// series returns the lowercase name of the series in a title, or an empty string when it has none.
+func series(title string) string {
+	m := seriesPattern.FindStringSubmatch(title)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(m[1]))
+}
+
+// descriptionWords returns the lowercase words of a description, leaving out short and common words.
+func descriptionWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if utf8.RuneCountInString(word) > 2 && !stopWords[word] {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// extract returns the features of every book. Descriptions are weighted by TF-IDF, so that the words few
+// books use count most, and scaled to a length of 1 so that their dot product is their cosine.
+func extract(books []models.BookContent) map[int]features {
+	counts := make(map[int]map[string]int, len(books))
+	documents := make(map[string]int)
+	for _, b := range books {
+		count := make(map[string]int)
+		for _, word := range descriptionWords(b.Description) {
+			count[word]++
+		}
+		for word := range count {
+			documents[word]++
+		}
+		counts[b.ID] = count
+	}
+
+	all := make(map[int]features, len(books))
+	for _, b := range books {
+		f := features{
+			authors: make(map[string]bool),
+			series:  series(b.Title),
+			tags:    make(map[string]bool),
+			terms:   make(map[string]float64),
+		}
+		for _, author := range authorSeparators.Split(b.Author, -1) {
+			if author = strings.ToLower(strings.TrimSpace(author)); author != "" {
+				f.authors[author] = true
+			}
+		}
+		for _, tag := range b.Tags {
+			f.tags[strings.ToLower(tag)] = true
+		}
+
+		var norm float64
+		for word, n := range counts[b.ID] {
+			weight := float64(n) * (math.Log(float64(1+len(books))/float64(1+documents[word])) + 1)
+			f.terms[word] = weight
+			norm += weight * weight
+		}
+		for word := range f.terms {
+			f.terms[word] /= math.Sqrt(norm)
+		}
+		all[b.ID] = f
+	}
+	return all
+}
+
+// jaccard returns the share of the values of a and b that both have.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	both := 0
+	for v := range a {
+		if b[v] {
+			both++
+		}
+	}
+	return float64(both) / float64(len(a)+len(b)-both)
+}
+
+// contentScore returns how alike the content of two books is, from 0 to 1.
+func contentScore(a, b features) float64 {
+	var score float64
+	for author := range a.authors {
+		if b.authors[author] {
+			score += authorWeight
+			break
+		}
+	}
+	if a.series != "" && a.series == b.series {
+		score += seriesWeight
+	}
+	score += tagWeight * jaccard(a.tags, b.tags)
+
+	var cosine float64
+	for word, weight := range a.terms {
+		cosine += weight * b.terms[word]
+	}
+	return score + descriptionWeight*cosine
+}
+
+// ContentSimilarities returns up to neighbors of the books most alike in content to each of the changed books,
+// by their shared authors, series and tags and the TF-IDF cosine of their descriptions. The result has an entry
+// for every changed book, empty for books that are alike to none or no longer exist.
+func ContentSimilarities(books []models.BookContent, changed []int, neighbors int) map[int][]models.BookSimilarity {
+	all := extract(books)
+
+	result := make(map[int][]models.BookSimilarity, len(changed))
+	for _, id := range changed {
+		f, ok := all[id]
+		if !ok {
+			result[id] = nil
+			continue
+		}
+
+		var similar []models.BookSimilarity
+		for _, other := range books {
+			if other.ID == id {
+				continue
+			}
+			if score := contentScore(f, all[other.ID]); score >= minContentScore {
+				similar = append(similar, models.BookSimilarity{BookId: id, SimilarBookId: other.ID, Score: score})
+			}
+		}
+		sort.Slice(similar, func(i, j int) bool {
+			if similar[i].Score != similar[j].Score {
+				return similar[i].Score > similar[j].Score
+			}
+			return similar[i].SimilarBookId < similar[j].SimilarBookId
+		})
+		result[id] = similar[:min(len(similar), neighbors)]
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package recommend
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// contentBooks are books of a library, some by the same authors, in the same series or about the same things.
+var contentBooks = []models.BookContent{
+	{ID: 1, Title: "Dune (Dune Chronicles, #1)", Author: "Frank Herbert", Tags: []string{"Sci-Fi"},
+		Description: "Paul Atreides and the Fremen fight for the spice of the desert planet Arrakis."},
+	{ID: 2, Title: "Dune Messiah (Dune Chronicles #2)", Author: "Frank Herbert", Tags: []string{"sci-fi", "Classics"},
+		Description: "Twelve years later, Paul Atreides rules the known universe from Arrakis."},
+	{ID: 3, Title: "The Dosadi Experiment", Author: "Frank Herbert",
+		Description: "Aliens and humans are confined to a toxic world."},
+	{ID: 4, Title: "Emma", Author: "Jane Austen", Tags: []string{"Romance"},
+		Description: "A young woman meddles in the romances of her village."},
+	{ID: 5, Title: "Good Omens", Author: "Terry Pratchett & Neil Gaiman",
+		Description: "An angel and a demon team up to stop the end of the world."},
+	{ID: 6, Title: "Mort", Author: "Terry Pratchett"},
+	{ID: 7, Title: "Arrakis Cookbook", Author: "Anonymous",
+		Description: "Recipes with the spice of Arrakis, the desert planet."},
+}
+
+// TestSeries tests that the series of Goodreads titles is found.
+func TestSeries(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Dune (Dune Chronicles, #1)", want: "dune chronicles"},
+		{title: "Mort (Discworld #4)", want: "discworld"},
+		{title: "Guards! Guards! (Discworld, #8; City Watch, #1)", want: ""},
+		{title: "The Hobbit", want: ""},
+		{title: "Foundation (Foundation #0.5)", want: "foundation"},
+		{title: "Emma (Penguin Classics)", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			testutil.Equal(t, series(tt.title), tt.want)
+		})
+	}
+}
+
+// TestContentSimilarities tests that books by the same authors, in the same series, with the same tags or with
+// descriptions using the same rare words are alike, the most alike first.
+func TestContentSimilarities(t *testing.T) {
+	tests := []struct {
+		name      string
+		changed   int
+		neighbors int
+		want      string
+	}{
+		{name: "author, series, tags and description", changed: 1, neighbors: 5, want: "1>2,1>3,1>7"},
+		{name: "nearest neighbors", changed: 1, neighbors: 1, want: "1>2"},
+		{name: "one of the authors", changed: 6, neighbors: 5, want: "6>5"},
+		{name: "alike to none", changed: 4, neighbors: 5, want: ""},
+		{name: "deleted book", changed: 99, neighbors: 5, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similarities := ContentSimilarities(contentBooks, []int{tt.changed}, tt.neighbors)
+			similar, ok := similarities[tt.changed]
+			if !ok {
+				t.Fatalf("no entry for book %d", tt.changed)
+			}
+			testutil.Equal(t, neighbors(similar), tt.want)
+		})
+	}
+}
+
+// TestJob_UpdateContent tests that the content similarities of the changed books are replaced and the books taken
+// off the changed books.
+func TestJob_UpdateContent(t *testing.T) {
+	store := &memoryStore{contents: contentBooks, changed: []int{5, 6}, content: make(map[int][]models.BookSimilarity)}
+	job := Job{Store: store, Logger: slog.New(slog.NewTextHandler(os.Stdout, nil)), Neighbors: 5}
+
+	n, err := job.UpdateContent()
+	testutil.NoError(t, err)
+	testutil.Equal(t, n, 2)
+	testutil.Equal(t, neighbors(store.content[5]), "5>6")
+	testutil.Equal(t, neighbors(store.content[6]), "6>5")
+	testutil.Equal(t, len(store.changed), 0)
+
+	n, err = job.UpdateContent()
+	testutil.NoError(t, err)
+	testutil.Equal(t, n, 0)
+}
@@ -0,0 +1,185 @@
+// Package recommend finds the books readers rate alike from the ratings of their reviews, and the books alike
+// in their authors, series, tags and description, in the background, so that books can be recommended from them.
+package recommend
+
+import (
+	"context"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+)
+
+// contentBatch is the number of changed books whose content similarities a round computes.
+const contentBatch = 100
+
+// Store reads the ratings of reviews and the content of books, and stores the similarities of books. It is
+// implemented by models.RecommendationModel.
+type Store interface {
+	Ratings() ([]models.BookRating, error)
+	ReplaceSimilarities(similarities []models.BookSimilarity) error
+	Contents() ([]models.BookContent, error)
+	ChangedBooks(limit int) ([]int, error)
+	ReplaceContentSimilarities(bookId int, similarities []models.BookSimilarity) error
+}
+
+// Job computes the similarities of books by their ratings from time to time, and by their content as books change.
+type Job struct {
+	Store  Store
+	Logger *slog.Logger
+
+	// Neighbors is the number of similar books kept for every book, and MinRaters the least number of readers
+	// who rated two books for them to be compared by their ratings.
+	Neighbors int
+	MinRaters int
+
+	// Interval is the time between two computations of the similarities of ratings, and Poll the time between
+	// two looks for changed books.
+	Interval time.Duration
+	Poll     time.Duration
+}
+
+// Run computes the similarities of books right away, then those of ratings every Interval and those of the
+// content of the books that changed every Poll, until ctx is done.
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Poll)
+	defer ticker.Stop()
+
+	var updated time.Time
+	for {
+		if time.Since(updated) >= j.Interval {
+			n, err := j.Update()
+			if err != nil {
+				j.Logger.Error("book similarities failed", "error", err)
+			} else {
+				j.Logger.Info("book similarities updated", "similarities", n)
+			}
+			updated = time.Now()
+		}
+
+		if n, err := j.UpdateContent(); err != nil {
+			j.Logger.Error("book content similarities failed", "error", err)
+		} else if n > 0 {
+			j.Logger.Info("book content similarities updated", "books", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update computes the similarities of books from the ratings in the store, replaces the stored ones with them
+// and returns how many were stored.
+func (j *Job) Update() (int, error) {
+	ratings, err := j.Store.Ratings()
+	if err != nil {
+		return 0, err
+	}
+
+	similarities := Similarities(ratings, j.Neighbors, j.MinRaters)
+	if err := j.Store.ReplaceSimilarities(similarities); err != nil {
+		return 0, err
+	}
+	return len(similarities), nil
+}
+
+// UpdateContent computes the content similarities of a batch of the books that changed, against every book,
+// and returns the number of books it computed them for. The similarities of the other books are left as they
+// are, though the weights of the words of descriptions shift a little as books are added.
+func (j *Job) UpdateContent() (int, error) {
+	changed, err := j.Store.ChangedBooks(contentBatch)
+	if err != nil || len(changed) == 0 {
+		return 0, err
+	}
+
+	books, err := j.Store.Contents()
+	if err != nil {
+		return 0, err
+	}
+
+	similarities := ContentSimilarities(books, changed, j.Neighbors)
+	for _, id := range changed {
+		if err := j.Store.ReplaceContentSimilarities(id, similarities[id]); err != nil {
+			return 0, err
+		}
+	}
+	return len(changed), nil
+}
+
+// pair accumulates the ratings of the readers who rated two books, less the average rating of every reader.
+type pair struct {
+	product float64
+	first   float64
+	second  float64
+	raters  int
+}
+
+// Similarities returns up to neighbors of the books most similar to every book, by the adjusted cosine of their
+// ratings: the cosine of the ratings of the readers who rated both books, less the average rating of each reader,
+// so that generous and harsh readers count alike. Books rated by fewer than minRaters of the same readers are not
+// compared, and only books with a positive similarity are returned.
+func Similarities(ratings []models.BookRating, neighbors, minRaters int) []models.BookSimilarity {
+	byUser := make(map[int][]models.BookRating)
+	for _, r := range ratings {
+		byUser[r.UserId] = append(byUser[r.UserId], r)
+	}
+
+	pairs := make(map[[2]int]*pair)
+	for _, rated := range byUser {
+		var mean float64
+		for _, r := range rated {
+			mean += r.Rating
+		}
+		mean /= float64(len(rated))
+
+		for i, a := range rated {
+			for _, b := range rated[i+1:] {
+				key, da, db := [2]int{a.BookId, b.BookId}, a.Rating-mean, b.Rating-mean
+				if a.BookId > b.BookId {
+					key, da, db = [2]int{b.BookId, a.BookId}, db, da
+				}
+				p := pairs[key]
+				if p == nil {
+					p = &pair{}
+					pairs[key] = p
+				}
+				p.product += da * db
+				p.first += da * da
+				p.second += db * db
+				p.raters++
+			}
+		}
+	}
+
+	byBook := make(map[int][]models.BookSimilarity)
+	for key, p := range pairs {
+		if p.raters < minRaters || p.first == 0 || p.second == 0 {
+			continue
+		}
+		score := p.product / math.Sqrt(p.first*p.second)
+		if score <= 0 {
+			continue
+		}
+		byBook[key[0]] = append(byBook[key[0]], models.BookSimilarity{BookId: key[0], SimilarBookId: key[1], Score: score})
+		byBook[key[1]] = append(byBook[key[1]], models.BookSimilarity{BookId: key[1], SimilarBookId: key[0], Score: score})
+	}
+
+	var similarities []models.BookSimilarity
+	for _, similar := range byBook {
+		sort.Slice(similar, func(i, j int) bool {
+			if similar[i].Score != similar[j].Score {
+				return similar[i].Score > similar[j].Score
+			}
+			return similar[i].SimilarBookId < similar[j].SimilarBookId
+		})
+		similarities = append(similarities, similar[:min(len(similar), neighbors)]...)
+	}
+	sort.SliceStable(similarities, func(i, j int) bool {
+		return similarities[i].BookId < similarities[j].BookId
+	})
+	return similarities
+}
@@ -0,0 +1,131 @@
+package recommend
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// ratings returns the ratings of users, each given as the ratings of books 1, 2 and so on, 0 for books they did not rate.
+func ratings(users ...[]float64) []models.BookRating {
+	var rs []models.BookRating
+	for u, books := range users {
+		for b, rating := range books {
+			if rating > 0 {
+				rs = append(rs, models.BookRating{UserId: u + 1, BookId: b + 1, Rating: rating})
+			}
+		}
+	}
+	return rs
+}
+
+// neighbors writes similarities as "book>similar" pairs.
+func neighbors(similarities []models.BookSimilarity) string {
+	var s []string
+	for _, sim := range similarities {
+		s = append(s, fmt.Sprintf("%d>%d", sim.BookId, sim.SimilarBookId))
+	}
+	return strings.Join(s, ",")
+}
+
+// TestSimilarities tests that books rated alike by the same readers are similar, whatever the readers' average rating.
+func TestSimilarities(t *testing.T) {
+	tests := []struct {
+		name      string
+		ratings   []models.BookRating
+		neighbors int
+		minRaters int
+		want      string
+	}{
+		{name: "no ratings", ratings: nil, neighbors: 5, minRaters: 1, want: ""},
+		{name: "rated alike", ratings: ratings([]float64{5, 5, 1}, []float64{1, 1, 5}), neighbors: 5, minRaters: 2,
+			want: "1>2,2>1"},
+		{name: "harsh and generous readers", ratings: ratings([]float64{5, 5, 4}, []float64{2, 2, 1}), neighbors: 5, minRaters: 2,
+			want: "1>2,2>1"},
+		{name: "too few readers", ratings: ratings([]float64{5, 5, 1}, []float64{1, 1, 5}), neighbors: 5, minRaters: 3,
+			want: ""},
+		{name: "one rating each", ratings: ratings([]float64{5}, []float64{0, 4}), neighbors: 5, minRaters: 1, want: ""},
+		{name: "nearest neighbors", ratings: ratings([]float64{5, 5, 5, 1}, []float64{1, 1, 1, 5}), neighbors: 1, minRaters: 2,
+			want: "1>2,2>1,3>1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Equal(t, neighbors(Similarities(tt.ratings, tt.neighbors, tt.minRaters)), tt.want)
+		})
+	}
+
+	similarities := Similarities(ratings([]float64{5, 5, 1}, []float64{1, 1, 5}), 5, 2)
+	testutil.Equal(t, math.Round(similarities[0].Score*1000)/1000, 1.0)
+}
+
+// memoryStore is a Store kept in memory.
+type memoryStore struct {
+	ratings      []models.BookRating
+	similarities []models.BookSimilarity
+	err          error
+
+	contents []models.BookContent
+	changed  []int
+	content  map[int][]models.BookSimilarity
+}
+
+// Ratings returns the ratings of the store.
+func (s *memoryStore) Ratings() ([]models.BookRating, error) {
+	return s.ratings, s.err
+}
+
+// ReplaceSimilarities keeps the similarities.
+func (s *memoryStore) ReplaceSimilarities(similarities []models.BookSimilarity) error {
+	s.similarities = similarities
+	return nil
+}
+
+// Contents returns the content of the books of the store.
+func (s *memoryStore) Contents() ([]models.BookContent, error) {
+	return s.contents, nil
+}
+
+// ChangedBooks returns up to limit of the changed books.
+func (s *memoryStore) ChangedBooks(limit int) ([]int, error) {
+	return append([]int(nil), s.changed[:min(len(s.changed), limit)]...), nil
+}
+
+// ReplaceContentSimilarities keeps the content similarities of a book and takes it off the changed books.
+func (s *memoryStore) ReplaceContentSimilarities(bookId int, similarities []models.BookSimilarity) error {
+	s.content[bookId] = similarities
+	for i, id := range s.changed {
+		if id == bookId {
+			s.changed = append(s.changed[:i], s.changed[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// TestJob_Update tests that the similarities computed from the ratings of the store replace those it had.
+func TestJob_Update(t *testing.T) {
+	store := &memoryStore{
+		ratings:      ratings([]float64{5, 5, 1}, []float64{1, 1, 5}),
+		similarities: []models.BookSimilarity{{BookId: 3, SimilarBookId: 1, Score: 1}},
+	}
+	job := Job{Store: store, Logger: slog.New(slog.NewTextHandler(os.Stdout, nil)), Neighbors: 5, MinRaters: 2}
+
+	n, err := job.Update()
+	testutil.NoError(t, err)
+	testutil.Equal(t, n, 2)
+	testutil.Equal(t, neighbors(store.similarities), "1>2,2>1")
+
+	store.err = errors.New("database is locked")
+	if _, err := job.Update(); !errors.Is(err, store.err) {
+		t.Fatalf("got %v; want %v", err, store.err)
+	}
+	testutil.Equal(t, neighbors(store.similarities), "1>2,2>1")
+}
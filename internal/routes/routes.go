@@ -24,7 +24,7 @@ func UrlPatterns(app *app.App) http.Handler {
 
 	// Serve static and media files under /static/ and /uploads/ path.
 	mux.Handle("GET /static/", http.FileServerFS(ui.Files))
-	mux.Handle("GET /uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir(app.Config.UploadDir))))
+	mux.Handle("GET /uploads/", views.Uploads(app))
 
 	// Public routes for pages
 	mux.Handle("GET /{$}", dynamic.Then(views.HomePage(app)))
@@ -37,13 +37,17 @@ func UrlPatterns(app *app.App) http.Handler {
 	mux.Handle("GET /books/{id}", dynamic.Then(views.BooksDetailPage(app)))
 	mux.Handle("GET /books/{id}/reviews", dynamic.Then(views.ListReviews(app)))
 	mux.Handle("GET /books/{id}/notes", dynamic.Then(views.ListNotes(app)))
+	mux.Handle("GET /books/{id}/more", dynamic.Then(views.MoreLikeThis(app)))
+	mux.Handle("GET /books/{id}/similar", dynamic.Then(views.SimilarBooks(app)))
 
 	// Public routes for HTMX
 	mux.Handle("GET /api/search", dynamic.Then(views.GetFilteredBooks(app)))
+	mux.Handle("GET /api/suggestions", dynamic.Then(views.GetSuggestions(app)))
 	mux.Handle("GET /api/books/count", dynamic.Then(views.GetBooksCount(app)))
 	mux.Handle("GET /api/reviews/count", dynamic.Then(views.GetReviewsCount(app)))
 	mux.Handle("GET /api/notes/count", dynamic.Then(views.GetNotesCount(app)))
 	mux.Handle("GET /api/books/recent", dynamic.Then(views.GetRecentBooks(app)))
+	mux.Handle("GET /api/books/recommended", dynamic.Then(views.GetRecommendedBooks(app)))
 	mux.Handle("GET /api/books/read", dynamic.Then(views.GetFinishedBooks(app)))
 	mux.Handle("GET /api/reviews/recent", dynamic.Then(views.GetRecentReviews(app)))
 
@@ -51,6 +55,9 @@ func UrlPatterns(app *app.App) http.Handler {
 
 	mux.Handle("GET /books/new", protected.Then(views.BooksAddPage(app)))
 	mux.Handle("POST /books/new", protected.Then(views.CreateBookPost(app)))
+	mux.Handle("POST /books/new/epub", protected.Then(views.BookEpubPost(app)))
+	mux.Handle("POST /books/new/isbn", protected.Then(views.BookISBNPost(app)))
+	mux.Handle("POST /books/new/barcode", protected.Then(views.BookBarcodePost(app)))
 	mux.Handle("GET /books/{id}/edit", protected.Then(views.UpdateBookPage(app)))
 	mux.Handle("POST /books/{id}/edit", protected.Then(views.UpdateBookPost(app)))
 	mux.Handle("POST /books/delete", protected.Then(views.DeleteBookPost(app)))
@@ -64,6 +71,51 @@ func UrlPatterns(app *app.App) http.Handler {
 	mux.Handle("GET /books/note/{id}/edit", protected.Then(views.UpdateNote(app)))
 	mux.Handle("POST /books/note/edit", protected.Then(views.UpdateNotePost(app)))
 	mux.Handle("POST /books/note/delete", protected.Then(views.DeleteNotePost(app)))
+	mux.Handle("GET /stats", protected.Then(views.StatsPage(app)))
+	mux.Handle("GET /import", protected.Then(views.ImportPage(app)))
+	mux.Handle("POST /import/kindle", protected.Then(views.KindleUploadPost(app)))
+	mux.Handle("GET /import/kindle/matches", protected.Then(views.KindleMatches(app)))
+	mux.Handle("POST /import/kindle/matches", protected.Then(views.KindleMatchPost(app)))
+	mux.Handle("POST /import/goodreads", protected.Then(views.GoodreadsImportPost(app)))
+	mux.Handle("GET /export/goodreads.csv", protected.Then(views.GoodreadsExport(app)))
+	mux.Handle("POST /import/backup", protected.Then(views.BackupRestorePost(app)))
+	mux.Handle("GET /export/backup.zip", protected.Then(views.BackupExport(app)))
+	mux.Handle("POST /account/tokens", protected.Then(views.APITokenPost(app)))
+	mux.Handle("POST /account/tokens/delete", protected.Then(views.APITokenDeletePost(app)))
+	mux.Handle("GET /shelves/new", protected.Then(views.CreateSmartShelf(app)))
+	mux.Handle("GET /shelves/{id}/edit", protected.Then(views.UpdateSmartShelf(app)))
+	mux.Handle("POST /shelves", protected.Then(views.SmartShelfPost(app)))
+	mux.Handle("POST /shelves/delete", protected.Then(views.DeleteSmartShelfPost(app)))
+	mux.Handle("GET /import/kosync/matches", protected.Then(views.KosyncMatches(app)))
+	mux.Handle("POST /import/kosync/matches", protected.Then(views.KosyncMatchPost(app)))
+
+	// OPDS catalog for e-reader apps, authenticated with HTTP Basic or an API token instead of a session
+	api := alice.New(m.APIAuthenticate)
+
+	for _, prefix := range []string{"/opds", "/opds/v2"} {
+		mux.Handle("GET "+prefix+"/{$}", api.Then(views.OPDSRoot(app)))
+		mux.Handle("GET "+prefix+"/books", api.Then(views.OPDSShelf(app)))
+		mux.Handle("GET "+prefix+"/shelves/{status}", api.Then(views.OPDSShelf(app)))
+		mux.Handle("GET "+prefix+"/smart/{id}", api.Then(views.OPDSSmartShelf(app)))
+		mux.Handle("GET "+prefix+"/tags", api.Then(views.OPDSTags(app)))
+		mux.Handle("GET "+prefix+"/tags/{tag}", api.Then(views.OPDSTag(app)))
+		mux.Handle("GET "+prefix+"/search", api.Then(views.OPDSSearch(app)))
+	}
+	mux.Handle("GET /opds/search.xml", api.Then(views.OPDSSearchDescription(app)))
+
+	// Atom feeds for feed readers, public and answered with 304 Not Modified when unchanged
+	mux.Handle("GET /feeds/reviews.atom", views.ReviewsFeed(app))
+	mux.Handle("GET /books/{id}/reviews.atom", views.BookReviewsFeed(app))
+	mux.Handle("GET /users/{username}/activity.atom", views.UserActivityFeed(app))
+
+	// KOReader progress sync server, authenticated with the username and the MD5 hash of an API token
+	kosync := alice.New(m.KosyncAuthenticate)
+
+	mux.Handle("GET /kosync/healthcheck", views.KosyncHealthcheck(app))
+	mux.Handle("POST /kosync/users/create", views.KosyncCreateUser(app))
+	mux.Handle("GET /kosync/users/auth", kosync.Then(views.KosyncAuth(app)))
+	mux.Handle("PUT /kosync/syncs/progress", kosync.Then(views.KosyncUpdateProgress(app)))
+	mux.Handle("GET /kosync/syncs/progress/{document}", kosync.Then(views.KosyncGetProgress(app)))
 
 	// Setup standard middleware
 	standardMiddleware := alice.New(m.Recover, m.Logging, m.Headers)
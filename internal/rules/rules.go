@@ -0,0 +1,489 @@
+// Package rules parses the rules of smart shelves, a small language describing the books a shelf holds such as
+// `status:want_to_read tag:sci-fi year>2015 rating>=4`.
+//
+// A rule is a list of conditions that must all hold. Conditions are joined with "and" and "or", negated with "not"
+// or a leading "-" and grouped with parentheses; "and" binds tighter than "or" and may be left out. A condition is
+// a field, an operator and a value, such as `author:herbert` or `added>=2026-01-01`. Values with spaces are quoted
+// with double quotes, in which \" and \\ stand for a quote and a backslash. A word or a quoted phrase without a
+// field matches books with it in their title or author.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fields a condition can test.
+const (
+	FieldText     = "text"
+	FieldTitle    = "title"
+	FieldAuthor   = "author"
+	FieldTag      = "tag"
+	FieldStatus   = "status"
+	FieldYear     = "year"
+	FieldRating   = "rating"
+	FieldAdded    = "added"
+	FieldCover    = "cover"
+	FieldUser     = "user"
+	FieldReviewer = "reviewer"
+)
+
+// Operators of a condition. OpHas means "contains" for titles and authors and "is" for every other field.
+const (
+	OpHas          = ":"
+	OpEqual        = "="
+	OpNotEqual     = "!="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+)
+
+// Me is the value of the user and reviewer fields standing for the owner of the shelf.
+const Me = "me"
+
+// DateLayout is the layout of the dates of the added field.
+const DateLayout = "2006-01-02"
+
+// MaxLength is the most characters a rule can have, and MaxConditions the most conditions.
+const (
+	MaxLength     = 1000
+	MaxConditions = 32
+)
+
+// kind is the type of the values of a field, which decides the operators and values it accepts.
+type kind int
+
+const (
+	kindText kind = iota
+	kindName
+	kindNumber
+	kindDate
+	kindChoice
+)
+
+// field describes a field conditions can test.
+type field struct {
+	kind    kind
+	min     float64
+	max     float64
+	choices []string
+}
+
+// fields maps the names of the fields to their description.
+var fields = map[string]field{
+	FieldTitle:    {kind: kindText},
+	FieldAuthor:   {kind: kindText},
+	FieldTag:      {kind: kindName},
+	FieldStatus:   {kind: kindChoice, choices: []string{"want_to_read", "reading", "finished"}},
+	FieldYear:     {kind: kindNumber, min: 0, max: 9999},
+	FieldRating:   {kind: kindNumber, min: 0, max: 5},
+	FieldAdded:    {kind: kindDate},
+	FieldCover:    {kind: kindChoice, choices: []string{"yes", "no"}},
+	FieldUser:     {kind: kindName},
+	FieldReviewer: {kind: kindName},
+}
+
+// operators maps the operators to their usual spelling, including the symbols they may be typed as.
+var operators = map[string]string{
+	":": OpHas, "=": OpEqual, "!=": OpNotEqual, "≠": OpNotEqual,
+	"<": OpLess, "<=": OpLessEqual, "≤": OpLessEqual,
+	">": OpGreater, ">=": OpGreaterEqual, "≥": OpGreaterEqual,
+}
+
+// wordRX matches the values that can be written without quotes.
+var wordRX = regexp.MustCompile(`^[\p{L}\p{N}_.'/+-]+$`)
+
+// Node is a part of a parsed rule: an And, an Or, a Not or a Condition.
+type Node interface {
+	fmt.Stringer
+	node()
+}
+
+// And holds when both of its parts hold.
+type And struct {
+	Left, Right Node
+}
+
+// Or holds when either of its parts holds.
+type Or struct {
+	Left, Right Node
+}
+
+// Not holds when its part does not.
+type Not struct {
+	Node Node
+}
+
+// Condition tests a field of a book against a value.
+type Condition struct {
+	Field string
+	Op    string
+
+	// Value is the value as written, and Number the value of the numeric fields, year and rating.
+	Value  string
+	Number float64
+}
+
+func (And) node()       {}
+func (Or) node()        {}
+func (Not) node()       {}
+func (Condition) node() {}
+
+// String returns the node as a rule, which parses back to an equivalent node.
+func (n And) String() string {
+	return group(n.Left, false) + " " + group(n.Right, false)
+}
+
+// String returns the node as a rule, which parses back to an equivalent node.
+func (n Or) String() string {
+	return group(n.Left, true) + " or " + group(n.Right, true)
+}
+
+// String returns the node as a rule, which parses back to an equivalent node.
+func (n Not) String() string {
+	if _, ok := n.Node.(Condition); ok {
+		return "-" + n.Node.String()
+	}
+	return "not (" + n.Node.String() + ")"
+}
+
+// String returns the node as a rule, which parses back to an equivalent node.
+func (n Condition) String() string {
+	if n.Field == FieldText {
+		return Quote(n.Value)
+	}
+	return n.Field + n.Op + Quote(n.Value)
+}
+
+// group returns the rule of a part of an And or an Or, in parentheses when it binds looser than the whole.
+func group(n Node, inOr bool) string {
+	if _, ok := n.(Or); ok && !inOr {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+// Quote returns a value as it is written in a rule, quoted when it is not a single word.
+func Quote(value string) string {
+	if wordRX.MatchString(value) && !strings.HasPrefix(value, "-") && !isKeyword(value) {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Error is a mistake in a rule, found at a position counted in characters from the start of the rule.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+// Error returns the message of the error and where it was found.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at character %d", e.Msg, e.Pos+1)
+}
+
+// Parse parses a rule, returning an *Error describing the first mistake of a rule that is not valid.
+func Parse(rule string) (Node, error) {
+	if utf8.RuneCountInString(rule) > MaxLength {
+		return nil, &Error{Pos: MaxLength, Msg: fmt.Sprintf("Rules can have at most %d characters", MaxLength)}
+	}
+	tokens, err := lex(rule)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEnd {
+		return nil, &Error{Pos: p.peek().pos, Msg: "The rule is empty"}
+	}
+
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("Unexpected %s", t)}
+	}
+	return n, nil
+}
+
+// tokenKind is the type of a token of a rule.
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenQuoted
+	tokenOp
+	tokenOpen
+	tokenClose
+	tokenMinus
+)
+
+// token is a word, a quoted value, an operator, a parenthesis or a leading minus of a rule.
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	space bool
+}
+
+// String describes the token in error messages.
+func (t token) String() string {
+	switch t.kind {
+	case tokenEnd:
+		return "end of the rule"
+	default:
+		return `"` + t.text + `"`
+	}
+}
+
+// isKeyword reports whether a word is one of the keywords joining and negating conditions.
+func isKeyword(word string) bool {
+	switch strings.ToLower(word) {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
+
+// lex splits a rule into tokens. Each token records whether it follows a space, as a field is only a field when
+// its operator follows it directly.
+func lex(rule string) ([]token, error) {
+	runes := []rune(rule)
+	var tokens []token
+	i := 0
+	for {
+		space := false
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			space = true
+			i++
+		}
+		if i == len(runes) {
+			return append(tokens, token{kind: tokenEnd, pos: i, space: space}), nil
+		}
+
+		start := i
+		r := runes[i]
+		switch {
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", pos: i, space: space})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", pos: i, space: space})
+			i++
+		case r == '-' && (len(tokens) == 0 || space || tokens[len(tokens)-1].kind == tokenOpen):
+			tokens = append(tokens, token{kind: tokenMinus, text: "-", pos: i, space: space})
+			i++
+		case r == '"':
+			var value strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, &Error{Pos: start, Msg: "The quote is not closed"}
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenQuoted, text: value.String(), pos: start, space: space})
+		case strings.ContainsRune(":=!<>≠≤≥", r):
+			i++
+			if i < len(runes) && runes[i] == '=' && strings.ContainsRune("!<>", r) {
+				i++
+			}
+			op, ok := operators[string(runes[start:i])]
+			if !ok {
+				return nil, &Error{Pos: start, Msg: fmt.Sprintf("Unknown operator %q", string(runes[start:i]))}
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: start, space: space})
+		default:
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()":=!<>≠≤≥`, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[start:i]), pos: start, space: space})
+		}
+	}
+}
+
+// parser builds the nodes of a rule from its tokens.
+type parser struct {
+	tokens     []token
+	next       int
+	conditions int
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// keyword consumes the next token when it is the keyword kw.
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokenWord && strings.EqualFold(t.text, kw) && p.tokens[p.next+1].kind != tokenOp {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// or parses conditions joined with "or".
+func (p *parser) or() (Node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// and parses conditions joined with "and" or following each other.
+func (p *parser) and() (Node, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokenEnd || t.kind == tokenClose {
+			return left, nil
+		}
+		if t.kind == tokenWord && strings.EqualFold(t.text, "or") && p.tokens[p.next+1].kind != tokenOp {
+			return left, nil
+		}
+		p.keyword("and")
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+}
+
+// not parses a condition or a group, negated by "not" or a leading "-".
+func (p *parser) not() (Node, error) {
+	if p.keyword("not") {
+		n, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: n}, nil
+	}
+	if minus := p.peek(); minus.kind == tokenMinus {
+		p.next++
+		if p.peek().space {
+			return nil, &Error{Pos: minus.pos, Msg: `A space follows the "-"`}
+		}
+		n, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: n}, nil
+	}
+	return p.primary()
+}
+
+// primary parses a condition or a group of conditions in parentheses.
+func (p *parser) primary() (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenOpen:
+		p.next++
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.peek(); c.kind != tokenClose {
+			return nil, &Error{Pos: c.pos, Msg: fmt.Sprintf(`Expected ")" instead of %s`, c)}
+		}
+		p.next++
+		return n, nil
+	case tokenWord, tokenQuoted:
+		return p.condition()
+	case tokenEnd:
+		return nil, &Error{Pos: t.pos, Msg: "The rule ends too early"}
+	default:
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("Unexpected %s", t)}
+	}
+}
+
+// condition parses a field, an operator and a value, or a word or a quoted phrase without a field.
+func (p *parser) condition() (Node, error) {
+	name := p.peek()
+	p.next++
+	p.conditions++
+	if p.conditions > MaxConditions {
+		return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("Rules can have at most %d conditions", MaxConditions)}
+	}
+
+	op := p.peek()
+	if op.kind != tokenOp || op.space || name.kind == tokenQuoted {
+		if isKeyword(name.text) && name.kind == tokenWord {
+			return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("Expected a condition instead of %s", name)}
+		}
+		return Condition{Field: FieldText, Op: OpHas, Value: name.text}, nil
+	}
+	p.next++
+
+	key := strings.ToLower(name.text)
+	f, ok := fields[key]
+	if !ok {
+		return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("Unknown field %s", name)}
+	}
+
+	value := p.peek()
+	if (value.kind != tokenWord && value.kind != tokenQuoted) || value.space {
+		return nil, &Error{Pos: value.pos, Msg: fmt.Sprintf("Expected a value after %s", op)}
+	}
+	p.next++
+
+	c := Condition{Field: key, Op: op.text, Value: value.text}
+	ordered := op.text != OpHas && op.text != OpEqual && op.text != OpNotEqual
+	if ordered && f.kind != kindNumber && f.kind != kindDate {
+		return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("The %s field cannot be compared with %s", key, op)}
+	}
+
+	invalid := func(format string, args ...any) error {
+		return &Error{Pos: value.pos, Msg: fmt.Sprintf(format, args...)}
+	}
+	switch f.kind {
+	case kindText, kindName:
+		if strings.TrimSpace(c.Value) == "" {
+			return nil, invalid("The %s field needs a value", key)
+		}
+	case kindNumber:
+		n, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil || n < f.min || n > f.max {
+			return nil, invalid("The %s field needs a number from %g to %g", key, f.min, f.max)
+		}
+		c.Number = n
+	case kindDate:
+		if _, err := time.Parse(DateLayout, c.Value); err != nil {
+			return nil, invalid("The %s field needs a date such as 2026-01-31", key)
+		}
+	case kindChoice:
+		c.Value = strings.ToLower(c.Value)
+		valid := false
+		for _, choice := range f.choices {
+			valid = valid || c.Value == choice
+		}
+		if !valid {
+			return nil, invalid("The %s field needs one of %s", key, strings.Join(f.choices, ", "))
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestParse tests that rules parse into the conditions they describe, written back in their usual form.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{name: "condition", rule: "author:herbert", want: "author:herbert"},
+		{name: "implicit and", rule: "status:want_to_read tag:sci-fi year>2015 rating≥4",
+			want: "status:want_to_read tag:sci-fi year>2015 rating>=4"},
+		{name: "keywords", rule: "tag:a AND tag:b Or NOT tag:c", want: "tag:a tag:b or -tag:c"},
+		{name: "and binds tighter", rule: "tag:a or tag:b tag:c", want: "tag:a or tag:b tag:c"},
+		{name: "parentheses", rule: "(tag:a or tag:b) tag:c", want: "(tag:a or tag:b) tag:c"},
+		{name: "negated group", rule: "-(tag:a tag:b)", want: "not (tag:a tag:b)"},
+		{name: "quoted value", rule: `tag:"Science Fiction" author="Le Guin"`, want: `tag:"Science Fiction" author="Le Guin"`},
+		{name: "escaped quote", rule: `title:"The \"Best\" \\ Book"`, want: `title:"The \"Best\" \\ Book"`},
+		{name: "words", rule: `dune "frank herbert"`, want: `dune "frank herbert"`},
+		{name: "keyword as value", rule: `title:"or"`, want: `title:"or"`},
+		{name: "field names ignore case", rule: "Status:Finished", want: "status:finished"},
+		{name: "dates", rule: "added>=2026-01-01 added<2026-02-01", want: "added>=2026-01-01 added<2026-02-01"},
+		{name: "symbols", rule: "year≠1965 year≤2000", want: "year!=1965 year<=2000"},
+		{name: "me", rule: "user:me -reviewer:me", want: "user:me -reviewer:me"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse(tt.rule)
+			testutil.NoError(t, err)
+			testutil.Equal(t, n.String(), tt.want)
+
+			again, err := Parse(n.String())
+			testutil.NoError(t, err)
+			testutil.Equal(t, again.String(), tt.want)
+		})
+	}
+
+	n, err := Parse("rating>=3.5")
+	testutil.NoError(t, err)
+	testutil.Equal(t, n.(Condition).Number, 3.5)
+}
+
+// TestParseErrors tests that mistakes are reported with their position in the rule.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{name: "empty", rule: "  ", want: "The rule is empty at character 3"},
+		{name: "unknown field", rule: "tag:x colour:red", want: `Unknown field "colour" at character 7`},
+		{name: "missing value", rule: "tag:", want: `Expected a value after ":" at character 5`},
+		{name: "space before the value", rule: "tag: x", want: `Expected a value after ":" at character 6`},
+		{name: "not comparable", rule: "author>b", want: `The author field cannot be compared with ">" at character 7`},
+		{name: "number", rule: "year>soon", want: "The year field needs a number from 0 to 9999 at character 6"},
+		{name: "rating out of range", rule: "rating>=6", want: "The rating field needs a number from 0 to 5 at character 9"},
+		{name: "date", rule: "added>yesterday", want: "The added field needs a date such as 2026-01-31 at character 7"},
+		{name: "choice", rule: "status:lost", want: "The status field needs one of want_to_read, reading, finished at character 8"},
+		{name: "blank value", rule: `tag:" "`, want: "The tag field needs a value at character 5"},
+		{name: "unclosed quote", rule: `tag:"sci`, want: "The quote is not closed at character 5"},
+		{name: "unclosed group", rule: "(tag:a", want: `Expected ")" instead of end of the rule at character 7`},
+		{name: "stray parenthesis", rule: "tag:a)", want: `Unexpected ")" at character 6`},
+		{name: "dangling keyword", rule: "tag:a or", want: "The rule ends too early at character 9"},
+		{name: "keyword without condition", rule: "tag:a and or tag:b", want: `Expected a condition instead of "or" at character 11`},
+		{name: "unknown operator", rule: "year!2000", want: `Unknown operator "!" at character 5`},
+		{name: "space after minus", rule: "- tag:a", want: `A space follows the "-" at character 1`},
+		{name: "too many conditions", rule: strings.Repeat("tag:a ", MaxConditions+1), want: "Rules can have at most 32 conditions at character 193"},
+		{name: "too long", rule: strings.Repeat("a", MaxLength+1), want: "Rules can have at most 1000 characters at character 1001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.rule)
+			var ruleErr *Error
+			if !errors.As(err, &ruleErr) {
+				t.Fatalf("got %v; want a rule error", err)
+			}
+			testutil.Equal(t, err.Error(), tt.want)
+		})
+	}
+}
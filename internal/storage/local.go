@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix starts the names of files that are still being written.
+const tempPrefix = ".upload-"
+
+// Local stores files in a directory on the local disk and serves them itself.
+type Local struct {
+	Dir string
+}
+
+// NewLocal returns a backend storing files in dir, creating dir if it does not exist.
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{Dir: dir}, nil
+}
+
+// Put writes the file to a temporary file first, so a file is never seen half written.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(l.Dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(l.Dir, key))
+}
+
+// Get opens the file stored under key.
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(l.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return f, err
+}
+
+// Stat describes the file stored under key.
+func (l *Local) Stat(ctx context.Context, key string) (Object, error) {
+	if err := checkKey(key); err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(filepath.Join(l.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) || err == nil && !info.Mode().IsRegular() {
+		return Object{}, ErrNotExist
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Key: key, Size: info.Size(), Modified: info.ModTime()}, nil
+}
+
+// Delete removes the file stored under key.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(l.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// URL returns the path the file is served at by ServeHTTP.
+func (l *Local) URL(ctx context.Context, key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return URLPrefix + key, nil
+}
+
+// List returns the files in the directory.
+func (l *Local) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), tempPrefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		objects = append(objects, Object{Key: e.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// ServeHTTP serves the file whose key is the last element of the request path.
+// Only regular files are served, so the directory is never listed.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := filepath.Base(r.URL.Path)
+	if checkKey(key) != nil || strings.HasPrefix(key, tempPrefix) {
+		notFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(l.Dir, key))
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		notFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, key, info.ModTime(), f)
+}
+
+// notFound responds that a file does not exist, without the caching headers meant for the file.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Del("Cache-Control")
+	http.NotFound(w, r)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestLocal tests storing, listing, serving and deleting files in a directory.
+func TestLocal(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	l, err := NewLocal(dir)
+	testutil.NoError(t, err)
+	ctx := context.Background()
+
+	key := ContentKey([]byte("cover"), ".JPG")
+	testutil.Equal(t, strings.HasSuffix(key, ".jpg"), true)
+	testutil.NoError(t, l.Put(ctx, key, strings.NewReader("cover")))
+	imageURL := URLPrefix + key
+
+	content, err := os.ReadFile(filepath.Join(dir, key))
+	testutil.NoError(t, err)
+	testutil.Equal(t, string(content), "cover")
+
+	u, err := l.URL(ctx, key)
+	testutil.NoError(t, err)
+	testutil.Equal(t, u, imageURL)
+
+	objects, err := l.List(ctx)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(objects), 1)
+	testutil.Equal(t, objects[0].Key, key)
+	testutil.Equal(t, objects[0].Size, int64(5))
+
+	object, err := l.Stat(ctx, key)
+	testutil.NoError(t, err)
+	testutil.Equal(t, object.Size, int64(5))
+	exists, err := Exists(ctx, l, key)
+	testutil.NoError(t, err)
+	testutil.Equal(t, exists, true)
+
+	rc, err := l.Get(ctx, key)
+	testutil.NoError(t, err)
+	body, err := io.ReadAll(rc)
+	testutil.NoError(t, err)
+	testutil.NoError(t, rc.Close())
+	testutil.Equal(t, string(body), "cover")
+
+	rec := httptest.NewRecorder()
+	l.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, imageURL, nil))
+	testutil.Equal(t, rec.Code, http.StatusOK)
+	testutil.Equal(t, rec.Body.String(), "cover")
+
+	testutil.NoError(t, l.Delete(ctx, key))
+	testutil.NoError(t, l.Delete(ctx, key))
+	_, err = l.Get(ctx, key)
+	testutil.Equal(t, errors.Is(err, ErrNotExist), true)
+	exists, err = Exists(ctx, l, key)
+	testutil.NoError(t, err)
+	testutil.Equal(t, exists, false)
+
+	rec = httptest.NewRecorder()
+	l.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, imageURL, nil))
+	testutil.Equal(t, rec.Code, http.StatusNotFound)
+
+	// Directories are not listed.
+	testutil.NoError(t, os.Mkdir(filepath.Join(dir, "covers"), 0o755))
+	for _, target := range []string{"/uploads/", "/uploads/covers", "/uploads/covers/"} {
+		rec = httptest.NewRecorder()
+		l.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		testutil.Equal(t, rec.Code, http.StatusNotFound)
+	}
+
+	for _, key := range []string{"", "..", "../db.sqlite", `a\b`} {
+		err := l.Put(ctx, key, strings.NewReader("x"))
+		testutil.Equal(t, errors.Is(err, ErrInvalidKey), true)
+	}
+}
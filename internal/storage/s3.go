@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxObjectSize limits the size of a file stored in S3, which is read into memory to sign it.
+const maxObjectSize = 32 << 20
+
+// unsignedPayload stands for the payload hash of presigned requests.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// presignExpiry is how long a presigned URL is valid. URLs are signed as of the start of their hour,
+// so a cover keeps the same URL, and stays in the browser cache, for an hour.
+const presignExpiry = 2 * time.Hour
+
+// S3 stores files in a bucket of an S3 compatible object store, such as Amazon S3 or MinIO,
+// addressing the bucket by path. Browsers fetch files by presigned URLs, so the bucket can stay private.
+type S3 struct {
+	// Endpoint is the base URL of the object store, such as https://s3.eu-central-1.amazonaws.com.
+	Endpoint string
+	Bucket   string
+	Region   string
+
+	AccessKey string
+	SecretKey string
+
+	Client *http.Client
+
+	// now returns the current time; it is replaced in tests.
+	now func() time.Time
+}
+
+// NewS3 returns a backend storing files in bucket at endpoint.
+func NewS3(endpoint, bucket, region, accessKey, secretKey string) (*S3, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("storage: invalid S3 endpoint %q", endpoint)
+	}
+	if bucket == "" {
+		return nil, errors.New("storage: no S3 bucket")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("storage: no S3 credentials")
+	}
+	return &S3{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Bucket:    bucket,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{Timeout: time.Minute},
+		now:       time.Now,
+	}, nil
+}
+
+// s3Error is the error document of S3.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// listResult is the response of a ListObjectsV2 request.
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Put uploads the file with the content type of its extension.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(io.LimitReader(r, maxObjectSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxObjectSize {
+		return fmt.Errorf("storage: file larger than %d bytes", maxObjectSize)
+	}
+
+	header := http.Header{}
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(key), header, body)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get downloads the file stored under key.
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat describes the file stored under key with a HEAD request, which leaves its content in the bucket.
+func (s *S3) Stat(ctx context.Context, key string) (Object, error) {
+	if err := checkKey(key); err != nil {
+		return Object{}, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	if err := resp.Body.Close(); err != nil {
+		return Object{}, err
+	}
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return Object{Key: key, Size: resp.ContentLength, Modified: modified}, nil
+}
+
+// Delete removes the file stored under key.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, nil)
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// URL returns a presigned URL to download the file stored under key.
+func (s *S3) URL(ctx context.Context, key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(s.objectURL(key))
+	if err != nil {
+		return "", err
+	}
+	return s.presign(u, s.now().UTC().Truncate(time.Hour), presignExpiry), nil
+}
+
+// List returns the files in the bucket.
+func (s *S3) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, s.Endpoint+"/"+awsEscape(s.Bucket)+"?"+query.Encode(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		if closeErr := resp.Body.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size, Modified: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// objectURL returns the URL of the object stored under key.
+func (s *S3) objectURL(key string) string {
+	return s.Endpoint + "/" + awsEscape(s.Bucket) + "/" + awsEscape(key)
+}
+
+// do sends a signed request and returns the response if it succeeded.
+// A missing object is reported as ErrNotExist, other failures, such as a missing bucket, with the error S3 responded with.
+func (s *S3) do(ctx context.Context, method, rawURL string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body, s.now().UTC())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var e s3Error
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	if resp.StatusCode == http.StatusNotFound && (e.Code == "NoSuchKey" || e.Code == "" && (method == http.MethodGet || method == http.MethodHead)) {
+		return nil, ErrNotExist
+	}
+	if e.Code != "" {
+		return nil, fmt.Errorf("storage: S3 %s %s: %s: %s", method, req.URL.Path, e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("storage: S3 %s %s: %s", method, req.URL.Path, resp.Status)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to a request.
+func (s *S3) sign(req *http.Request, body []byte, t time.Time) {
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// The host, range and all x-amz-* and content headers are signed.
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || strings.HasPrefix(lower, "content-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	signedHeaders, canonicalHeaders := canonicalizeHeaders(headers)
+
+	scope := s.scope(t)
+	signature := s.signature(t, scope, canonicalRequest(req.Method, req.URL, canonicalHeaders, signedHeaders, payloadHash))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+// presign returns u with the query parameters that let anyone GET it until expiry after t.
+func (s *S3) presign(u *url.URL, t time.Time, expiry time.Duration) string {
+	scope := s.scope(t)
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	signed := *u
+	signed.RawQuery = canonicalQuery(query)
+	signedHeaders, canonicalHeaders := canonicalizeHeaders(map[string]string{"host": u.Host})
+	signature := s.signature(t, scope, canonicalRequest(http.MethodGet, &signed, canonicalHeaders, signedHeaders, unsignedPayload))
+
+	signed.RawQuery += "&X-Amz-Signature=" + signature
+	return signed.String()
+}
+
+// scope returns the credential scope of requests signed at t.
+func (s *S3) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request with a key derived from the secret key for the day of t.
+func (s *S3) signature(t time.Time, scope, canonical string) string {
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalRequest returns the canonical form of a request that is signed.
+func canonicalRequest(method string, u *url.URL, canonicalHeaders, signedHeaders, payloadHash string) string {
+	uri := u.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	return strings.Join([]string{method, uri, canonicalQuery(u.Query()), canonicalHeaders, signedHeaders, payloadHash}, "\n")
+}
+
+// canonicalizeHeaders returns the names of the signed headers and their canonical form.
+func canonicalizeHeaders(headers map[string]string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// canonicalQuery returns a query string sorted by name with names and values escaped the way AWS expects.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(name)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes every byte of s except the unreserved characters of RFC 3986.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sha256Hex returns the hex encoded SHA-256 hash of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
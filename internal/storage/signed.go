@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// contentKeyRX matches the keys Save stores files at, and those of the variants of images stored with them.
+var contentKeyRX = regexp.MustCompile(`^[0-9a-f]{32}(-[0-9]+w)?\.[a-z0-9]+$`)
+
+// IsContentKey reports whether key is named after the content of its file, which therefore never changes.
+func IsContentKey(key string) bool {
+	return contentKeyRX.MatchString(key)
+}
+
+// Signer signs the URLs of stored files, so whoever holds a signed URL is served the file until the URL expires.
+type Signer struct {
+	Secret []byte
+
+	// now returns the current time; it is replaced in tests.
+	now func() time.Time
+}
+
+// NewSigner returns a Signer signing with secret.
+func NewSigner(secret []byte) *Signer {
+	return &Signer{Secret: secret, now: time.Now}
+}
+
+// URL returns the URL of the file stored at key, signed to be valid for ttl.
+func (s *Signer) URL(key string, ttl time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(s.now().Add(ttl).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {s.signature(key, expires)}}
+	return URLPrefix + key + "?" + query.Encode(), nil
+}
+
+// Verify reports whether query holds a signature of key that has not expired, and when it expires.
+func (s *Signer) Verify(key string, query url.Values) (time.Time, bool) {
+	expires := query.Get("expires")
+	sec, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	at := time.Unix(sec, 0)
+	if !s.now().Before(at) {
+		return time.Time{}, false
+	}
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	expected, _ := hex.DecodeString(s.signature(key, expires))
+	return at, hmac.Equal(signature, expected)
+}
+
+// signature returns the hex encoded HMAC-SHA256 of key and the time it expires at.
+func (s *Signer) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
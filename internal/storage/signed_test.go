@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestSigner tests that signed URLs are valid for their own key until they expire, and not once tampered with.
+func TestSigner(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s := NewSigner([]byte("secret"))
+	s.now = func() time.Time { return now }
+
+	signed, err := s.URL("cover.jpg", 10*time.Minute)
+	testutil.NoError(t, err)
+	u, err := url.Parse(signed)
+	testutil.NoError(t, err)
+	testutil.Equal(t, u.Path, URLPrefix+"cover.jpg")
+
+	expires, ok := s.Verify("cover.jpg", u.Query())
+	testutil.Equal(t, ok, true)
+	testutil.Equal(t, expires.Equal(now.Add(10*time.Minute)), true)
+
+	_, ok = s.Verify("other.jpg", u.Query())
+	testutil.Equal(t, ok, false)
+
+	later := u.Query()
+	later.Set("expires", "1999999999")
+	_, ok = s.Verify("cover.jpg", later)
+	testutil.Equal(t, ok, false)
+
+	_, ok = NewSigner([]byte("other")).Verify("cover.jpg", u.Query())
+	testutil.Equal(t, ok, false)
+
+	now = now.Add(10 * time.Minute)
+	_, ok = s.Verify("cover.jpg", u.Query())
+	testutil.Equal(t, ok, false)
+
+	_, err = s.URL("../db.sqlite", time.Minute)
+	testutil.Equal(t, err != nil, true)
+}
+
+// TestIsContentKey tests that keys named after their content and their variants are recognized.
+func TestIsContentKey(t *testing.T) {
+	key := ContentKey([]byte("cover"), ".JPG")
+	testutil.Equal(t, IsContentKey(key), true)
+	testutil.Equal(t, IsContentKey(key[:32]+"-320w.jpg"), true)
+	testutil.Equal(t, IsContentKey("1700000000-dune.jpg"), false)
+	testutil.Equal(t, IsContentKey(key[:32]), false)
+}
@@ -0,0 +1,128 @@
+// Package storage stores uploaded files, such as covers, on the local disk or in an S3 compatible object store.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// URLPrefix is the path uploaded files are served under. The image URL of a book is URLPrefix followed by the key of its cover.
+const URLPrefix = "/uploads/"
+
+// ErrNotExist is returned when a file is not stored in a backend.
+var ErrNotExist = errors.New("storage: file does not exist")
+
+// ErrInvalidKey is returned for keys that are not plain file names.
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// Backend stores files by key. Keys are plain file names, without directories.
+type Backend interface {
+	// Put stores the content of r under key, replacing the file stored under it.
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Get opens the file stored under key. It returns ErrNotExist if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the file stored under key. Removing a file that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// URL returns the URL browsers fetch the file stored under key from.
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// Object describes a stored file.
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Lister is implemented by backends that can list the files they store.
+type Lister interface {
+	List(ctx context.Context) ([]Object, error)
+}
+
+// Stater is implemented by backends that can describe a stored file without reading it.
+type Stater interface {
+	// Stat returns the description of the file stored under key. It returns ErrNotExist if there is none.
+	Stat(ctx context.Context, key string) (Object, error)
+}
+
+// Exists reports whether a file is stored under key, without reading it from backends implementing Stater.
+func Exists(ctx context.Context, b Backend, key string) (bool, error) {
+	var err error
+	if stater, ok := b.(Stater); ok {
+		_, err = stater.Stat(ctx, key)
+	} else {
+		var rc io.ReadCloser
+		if rc, err = b.Get(ctx, key); err == nil {
+			err = rc.Close()
+		}
+	}
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ContentKey returns the key of a file with the given content and extension: a hash of the content,
+// so files with the same content share a key.
+func ContentKey(data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]) + strings.ToLower(ext)
+}
+
+// Key returns the key of the file an image URL refers to.
+func Key(imageURL string) string {
+	return path.Base(imageURL)
+}
+
+// checkKey returns ErrInvalidKey for keys that are not plain file names.
+func checkKey(key string) error {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+// Migrate copies every file of from to to and, unless keep is set, removes it from from once it is copied,
+// so a migration that was interrupted can be run again. It returns the number of files copied.
+func Migrate(ctx context.Context, from, to Backend, keep bool) (int, error) {
+	lister, ok := from.(Lister)
+	if !ok {
+		return 0, errors.New("storage: the files of the source backend cannot be listed")
+	}
+	objects, err := lister.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, o := range objects {
+		if err := migrateFile(ctx, from, to, o.Key); err != nil {
+			return i, fmt.Errorf("storage: migrating %s: %w", o.Key, err)
+		}
+		if !keep {
+			if err := from.Delete(ctx, o.Key); err != nil {
+				return i + 1, err
+			}
+		}
+	}
+	return len(objects), nil
+}
+
+// migrateFile copies the file stored under key in from to to.
+func migrateFile(ctx context.Context, from, to Backend, key string) error {
+	rc, err := from.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return to.Put(ctx, key, rc)
+}
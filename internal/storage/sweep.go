@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Sweeper removes the stored files no book refers to any more, such as the covers of deleted books.
+// Files are only removed once they are older than a grace period, so files that were just stored
+// for a book that is not saved yet are kept.
+type Sweeper struct {
+	Backend Backend
+	Logger  *slog.Logger
+
+	// References returns the keys of the files that are in use.
+	References func(ctx context.Context) (map[string]bool, error)
+
+	// Grace is the least age of a file that is removed, and Interval the time between sweeps.
+	Grace    time.Duration
+	Interval time.Duration
+}
+
+// SweepReport describes the outcome of a sweep.
+type SweepReport struct {
+	// Files is the number of stored files and Referenced the number of them in use.
+	Files      int
+	Referenced int
+
+	// Orphans are the files not in use that are older than the grace period, Bytes is their total size
+	// and Recent the number of younger ones.
+	Orphans []Object
+	Bytes   int64
+	Recent  int
+
+	// Removed is the number of orphans that were removed. Orphans stored again since they were listed are kept.
+	Removed int
+}
+
+// Run sweeps the backend every Interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := s.Sweep(ctx, false)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.Logger.Error("upload sweep failed", "error", err)
+			}
+			continue
+		}
+		s.Logger.Info("upload sweep finished", "files", report.Files, "removed", report.Removed, "bytes", report.Bytes)
+	}
+}
+
+// Sweep removes the files not in use that are older than the grace period. With dryRun set, the report
+// lists those files without removing them.
+func (s *Sweeper) Sweep(ctx context.Context, dryRun bool) (SweepReport, error) {
+	var report SweepReport
+
+	lister, ok := s.Backend.(Lister)
+	if !ok {
+		return report, errors.New("storage: the files of the backend cannot be listed")
+	}
+
+	// The files are listed before the references are read, so a file stored for a book in between
+	// is seen as in use, or is too young to be removed.
+	objects, err := lister.List(ctx)
+	if err != nil {
+		return report, err
+	}
+	references, err := s.References(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	cutoff := time.Now().Add(-s.Grace)
+	for _, o := range objects {
+		report.Files++
+		switch {
+		case references[o.Key]:
+			report.Referenced++
+		case o.Modified.After(cutoff):
+			report.Recent++
+		default:
+			report.Orphans = append(report.Orphans, o)
+			report.Bytes += o.Size
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for _, o := range report.Orphans {
+		// The same content may have been stored again for a book saved after the references were read,
+		// which makes the file young again, so its age is checked once more right before it is removed.
+		if stater, ok := s.Backend.(Stater); ok {
+			current, err := stater.Stat(ctx, o.Key)
+			if errors.Is(err, ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return report, err
+			}
+			if current.Modified.After(cutoff) {
+				report.Recent++
+				continue
+			}
+		}
+
+		if err := s.Backend.Delete(ctx, o.Key); err != nil {
+			return report, err
+		}
+		report.Removed++
+	}
+	return report, nil
+}
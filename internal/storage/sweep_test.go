@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madalinpopa/go-bookreview/internal/testutil"
+)
+
+// TestSweeper_Sweep tests that only old files no book refers to are removed, and none in a dry run.
+func TestSweeper_Sweep(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLocal(dir)
+	testutil.NoError(t, err)
+	ctx := context.Background()
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{"used.jpg", "used-320w.jpg", "orphan.jpg", "recent.jpg"} {
+		testutil.NoError(t, l.Put(ctx, key, strings.NewReader("cover of "+key)))
+		if key != "recent.jpg" {
+			testutil.NoError(t, os.Chtimes(filepath.Join(dir, key), old, old))
+		}
+	}
+
+	sweeper := &Sweeper{
+		Backend: l,
+		Logger:  slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		References: func(ctx context.Context) (map[string]bool, error) {
+			return map[string]bool{"used.jpg": true, "used-320w.jpg": true}, nil
+		},
+		Grace: 24 * time.Hour,
+	}
+
+	report, err := sweeper.Sweep(ctx, true)
+	testutil.NoError(t, err)
+	testutil.Equal(t, report.Files, 4)
+	testutil.Equal(t, report.Referenced, 2)
+	testutil.Equal(t, report.Recent, 1)
+	testutil.Equal(t, len(report.Orphans), 1)
+	testutil.Equal(t, report.Orphans[0].Key, "orphan.jpg")
+	testutil.Equal(t, report.Bytes, int64(len("cover of orphan.jpg")))
+	testutil.Equal(t, report.Removed, 0)
+	_, err = os.Stat(filepath.Join(dir, "orphan.jpg"))
+	testutil.NoError(t, err)
+
+	report, err = sweeper.Sweep(ctx, false)
+	testutil.NoError(t, err)
+	testutil.Equal(t, report.Removed, 1)
+	_, err = os.Stat(filepath.Join(dir, "orphan.jpg"))
+	testutil.Equal(t, errors.Is(err, os.ErrNotExist), true)
+
+	objects, err := l.List(ctx)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(objects), 3)
+}
+
+// TestSweeper_Sweep_StoredAgain tests that an orphan stored again after the files were listed is not removed,
+// as the book it was stored for may have been saved after the references were read.
+func TestSweeper_Sweep_StoredAgain(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLocal(dir)
+	testutil.NoError(t, err)
+	ctx := context.Background()
+
+	old := time.Now().Add(-48 * time.Hour)
+	testutil.NoError(t, l.Put(ctx, "orphan.jpg", strings.NewReader("cover")))
+	testutil.NoError(t, os.Chtimes(filepath.Join(dir, "orphan.jpg"), old, old))
+
+	sweeper := &Sweeper{
+		Backend: l,
+		Logger:  slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		References: func(ctx context.Context) (map[string]bool, error) {
+			// The file was listed as old and is uploaded again before the book using it is saved.
+			if err := l.Put(ctx, "orphan.jpg", strings.NewReader("cover")); err != nil {
+				return nil, err
+			}
+			return map[string]bool{}, nil
+		},
+		Grace: 24 * time.Hour,
+	}
+
+	report, err := sweeper.Sweep(ctx, false)
+	testutil.NoError(t, err)
+	testutil.Equal(t, len(report.Orphans), 1)
+	testutil.Equal(t, report.Recent, 1)
+	testutil.Equal(t, report.Removed, 0)
+	_, err = os.Stat(filepath.Join(dir, "orphan.jpg"))
+	testutil.NoError(t, err)
+}
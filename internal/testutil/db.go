@@ -55,6 +55,10 @@ func setupTestTables(db *sql.DB) error {
             isbn             TEXT UNIQUE,
             publication_year INTEGER,
             image_url        TEXT DEFAULT NULL,
+            publisher        TEXT NOT NULL DEFAULT '',
+            language         TEXT NOT NULL DEFAULT '',
+            description      TEXT NOT NULL DEFAULT '',
+            page_count       INTEGER NOT NULL DEFAULT 0,
             created_at       DATETIME DEFAULT CURRENT_TIMESTAMP,
             updated_at       DATETIME DEFAULT CURRENT_TIMESTAMP
         )`,
@@ -64,11 +68,185 @@ func setupTestTables(db *sql.DB) error {
             book_id  INTEGER NOT NULL,
             status   TEXT CHECK (status IN ('want_to_read', 'reading', 'finished')) DEFAULT 'want_to_read',
             added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            finished_at DATETIME DEFAULT NULL,
+            progress INTEGER NOT NULL DEFAULT 0 CHECK (progress >= 0 AND progress <= 100),
             FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
             FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE,
             UNIQUE (user_id, book_id)
+        )`,
+		`CREATE TABLE reviews (
+            id          INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id     INTEGER NOT NULL,
+            book_id     INTEGER NOT NULL,
+            rating      INTEGER CHECK (rating >= 1 AND rating <= 5),
+            review_text TEXT,
+            created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE notes (
+            id          INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id     INTEGER NOT NULL,
+            book_id     INTEGER NOT NULL,
+            note_text   TEXT    NOT NULL,
+            page_number INTEGER,
+            created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE kindle_clippings (
+            id             INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id        INTEGER NOT NULL,
+            book_id        INTEGER,
+            note_id        INTEGER,
+            hash           TEXT    NOT NULL,
+            title          TEXT    NOT NULL,
+            author         TEXT    NOT NULL DEFAULT '',
+            kind           TEXT    NOT NULL CHECK (kind IN ('highlight', 'note', 'bookmark')),
+            page_number    INTEGER NOT NULL DEFAULT 0,
+            location_start INTEGER NOT NULL DEFAULT 0,
+            location_end   INTEGER NOT NULL DEFAULT 0,
+            clipping_text  TEXT    NOT NULL DEFAULT '',
+            clipped_at     DATETIME,
+            status         TEXT    NOT NULL CHECK (status IN ('pending', 'imported', 'skipped')) DEFAULT 'pending',
+            created_at     DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at     DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE SET NULL,
+            FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE SET NULL,
+            UNIQUE (user_id, hash)
+        )`,
+		`CREATE TABLE tags (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            name       TEXT NOT NULL UNIQUE COLLATE NOCASE,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE book_tags (
+            book_id INTEGER NOT NULL,
+            tag_id  INTEGER NOT NULL,
+            PRIMARY KEY (book_id, tag_id),
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE,
+            FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE api_tokens (
+            id           INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id      INTEGER NOT NULL,
+            name         TEXT    NOT NULL,
+            token_hash   TEXT    NOT NULL UNIQUE,
+            created_at   DATETIME DEFAULT CURRENT_TIMESTAMP,
+            last_used_at DATETIME DEFAULT NULL,
+            kosync_key_hash TEXT NOT NULL DEFAULT '',
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE kosync_documents (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id    INTEGER NOT NULL,
+            book_id    INTEGER,
+            document   TEXT    NOT NULL,
+            ignored    BOOLEAN NOT NULL DEFAULT 0,
+            progress   TEXT    NOT NULL DEFAULT '',
+            percentage REAL    NOT NULL DEFAULT 0,
+            device     TEXT    NOT NULL DEFAULT '',
+            device_id  TEXT    NOT NULL DEFAULT '',
+            timestamp  INTEGER NOT NULL DEFAULT 0,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE SET NULL,
+            UNIQUE (user_id, document)
+        )`,
+		`CREATE TABLE metadata_cache (
+            key        TEXT PRIMARY KEY,
+            value      BLOB     NOT NULL,
+            fetched_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE cover_fetches (
+            book_id         INTEGER PRIMARY KEY,
+            attempts        INTEGER  NOT NULL DEFAULT 0,
+            next_attempt_at DATETIME NOT NULL,
+            last_error      TEXT     NOT NULL DEFAULT '',
+            updated_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE private_uploads (
+            key        TEXT PRIMARY KEY,
+            user_id    INTEGER NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE recent_searches (
+            user_id     INTEGER NOT NULL,
+            query       TEXT    NOT NULL COLLATE NOCASE,
+            searched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (user_id, query),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        )`,
+		`CREATE INDEX books_title_nocase ON books (title COLLATE NOCASE)`,
+		`CREATE INDEX books_author_nocase ON books (author COLLATE NOCASE)`,
+		`CREATE INDEX users_username_nocase ON users (username COLLATE NOCASE)`,
+		`CREATE TABLE smart_shelves (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id    INTEGER NOT NULL,
+            name       TEXT    NOT NULL COLLATE NOCASE,
+            rule       TEXT    NOT NULL,
+            sort       TEXT    NOT NULL DEFAULT '',
+            image_url  TEXT    NOT NULL DEFAULT '',
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (user_id, name),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE book_similarities (
+            book_id         INTEGER NOT NULL,
+            similar_book_id INTEGER NOT NULL,
+            score           REAL    NOT NULL,
+            PRIMARY KEY (book_id, similar_book_id),
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE,
+            FOREIGN KEY (similar_book_id) REFERENCES books (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE book_content_similarities (
+            book_id         INTEGER NOT NULL,
+            similar_book_id INTEGER NOT NULL,
+            score           REAL    NOT NULL,
+            PRIMARY KEY (book_id, similar_book_id),
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE,
+            FOREIGN KEY (similar_book_id) REFERENCES books (id) ON DELETE CASCADE
+        )`,
+		`CREATE TABLE book_content_changes (
+            book_id INTEGER PRIMARY KEY
+        )`,
+		`CREATE TRIGGER book_content_insert AFTER INSERT ON books
+        BEGIN
+            INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (new.id);
+        END`,
+		`CREATE TRIGGER book_content_update AFTER UPDATE OF title, author, description ON books
+        BEGIN
+            INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (new.id);
+        END`,
+		`CREATE TRIGGER book_content_tag_insert AFTER INSERT ON book_tags
+        BEGIN
+            INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (new.book_id);
+        END`,
+		`CREATE TRIGGER book_content_tag_delete AFTER DELETE ON book_tags
+        BEGIN
+            INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (old.book_id);
+        END`,
+		`CREATE TABLE calibre_books (
+            id            INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id       INTEGER NOT NULL,
+            book_id       INTEGER NOT NULL,
+            calibre_uuid  TEXT    NOT NULL,
+            last_modified TEXT    NOT NULL DEFAULT '',
+            created       BOOLEAN NOT NULL DEFAULT 0,
+            created_at    DATETIME DEFAULT CURRENT_TIMESTAMP,
+            updated_at    DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE,
+            UNIQUE (user_id, calibre_uuid)
         )`,
 	}
+	statements = append(statements, searchStatements...)
 
 	for _, stmt := range statements {
 		if _, err := db.Exec(stmt); err != nil {
@@ -0,0 +1,7 @@
+//go:build !sqlite_fts5
+
+package testutil
+
+// searchStatements is empty without the sqlite_fts5 build tag, as SQLite has no FTS5 then, so the tests
+// of full-text search only run with the tag.
+var searchStatements []string
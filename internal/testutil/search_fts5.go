@@ -0,0 +1,50 @@
+//go:build sqlite_fts5
+
+package testutil
+
+// searchStatements create the full-text search tables and the triggers keeping them in sync, as in the migrations.
+// SQLite only has FTS5 when it is built with the sqlite_fts5 tag.
+var searchStatements = []string{
+	`CREATE VIRTUAL TABLE books_fts USING fts5(title, author, description, content='books', content_rowid='id', prefix='2 3', tokenize='unicode61 remove_diacritics 2')`,
+	`CREATE TRIGGER books_fts_insert AFTER INSERT ON books
+        BEGIN
+            INSERT INTO books_fts (rowid, title, author, description) VALUES (new.id, new.title, new.author, new.description);
+        END`,
+	`CREATE TRIGGER books_fts_delete AFTER DELETE ON books
+        BEGIN
+            INSERT INTO books_fts (books_fts, rowid, title, author, description) VALUES ('delete', old.id, old.title, old.author, old.description);
+        END`,
+	`CREATE TRIGGER books_fts_update AFTER UPDATE OF title, author, description ON books
+        BEGIN
+            INSERT INTO books_fts (books_fts, rowid, title, author, description) VALUES ('delete', old.id, old.title, old.author, old.description);
+            INSERT INTO books_fts (rowid, title, author, description) VALUES (new.id, new.title, new.author, new.description);
+        END`,
+	`CREATE VIRTUAL TABLE notes_fts USING fts5(note_text, content='notes', content_rowid='id', prefix='2 3', tokenize='unicode61 remove_diacritics 2')`,
+	`CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes
+        BEGIN
+            INSERT INTO notes_fts (rowid, note_text) VALUES (new.id, new.note_text);
+        END`,
+	`CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes
+        BEGIN
+            INSERT INTO notes_fts (notes_fts, rowid, note_text) VALUES ('delete', old.id, old.note_text);
+        END`,
+	`CREATE TRIGGER notes_fts_update AFTER UPDATE OF note_text ON notes
+        BEGIN
+            INSERT INTO notes_fts (notes_fts, rowid, note_text) VALUES ('delete', old.id, old.note_text);
+            INSERT INTO notes_fts (rowid, note_text) VALUES (new.id, new.note_text);
+        END`,
+	`CREATE VIRTUAL TABLE reviews_fts USING fts5(review_text, content='reviews', content_rowid='id', prefix='2 3', tokenize='unicode61 remove_diacritics 2')`,
+	`CREATE TRIGGER reviews_fts_insert AFTER INSERT ON reviews
+        BEGIN
+            INSERT INTO reviews_fts (rowid, review_text) VALUES (new.id, new.review_text);
+        END`,
+	`CREATE TRIGGER reviews_fts_delete AFTER DELETE ON reviews
+        BEGIN
+            INSERT INTO reviews_fts (reviews_fts, rowid, review_text) VALUES ('delete', old.id, old.review_text);
+        END`,
+	`CREATE TRIGGER reviews_fts_update AFTER UPDATE OF review_text ON reviews
+        BEGIN
+            INSERT INTO reviews_fts (reviews_fts, rowid, review_text) VALUES ('delete', old.id, old.review_text);
+            INSERT INTO reviews_fts (rowid, review_text) VALUES (new.id, new.review_text);
+        END`,
+}
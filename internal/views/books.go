@@ -4,38 +4,92 @@ import (
 	"errors"
 	"fmt"
 	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/barcode"
+	"github.com/madalinpopa/go-bookreview/internal/epub"
 	"github.com/madalinpopa/go-bookreview/internal/forms"
+	"github.com/madalinpopa/go-bookreview/internal/images"
+	"github.com/madalinpopa/go-bookreview/internal/metadata"
 	"github.com/madalinpopa/go-bookreview/internal/models"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
+// maxEPUBSize limits the size of an uploaded EPUB file.
+const maxEPUBSize = 50 << 20
+
+// maxPhotoSize limits the size of an uploaded photo of a barcode.
+const maxPhotoSize = 20 << 20
+
+// booksPageSize is the number of books in a page of the book list.
+const booksPageSize = 8
+
+// searchLimit is the most books a search shows.
+const searchLimit = 50
+
+// suggestLimit is the most books, authors, tags, users and recent searches suggested each while typing a search.
+const suggestLimit = 5
+
 // BooksPage handles HTTP requests to display a paginated list of books using the given app's data and templates.
+// The books are filtered and sorted by the query string, and HTMX requests that change the list push its URL,
+// so the filters and the page survive reloads and the back button. With scroll set, the books are listed with
+// cursors instead of numbered pages, and the books after the cursor in after are rendered as they are scrolled to.
 func BooksPage(app *app.App) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := app.GetTemplateData(r)
+		query := r.URL.Query()
 
 		page := 1
-		if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if pageStr := query.Get("page"); pageStr != "" {
 			if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
 				page = p
 			}
 		}
 
-		pageSize := 8
-		paginated, err := app.Models.Books.List(page, pageSize)
+		filter, err := applySmartShelf(app, r, &data, models.ParseBookFilter(query))
 		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
 			app.ServerError(w, r, err)
 			return
 		}
+		scroll := query.Get("scroll") != ""
 
-		data.Books = paginated.Books
-		data.Page = paginated.Page
-		data.PageSize = paginated.PageSize
-		data.Total = paginated.Total
-		data.TotalPages = paginated.TotalPages
+		if after := query.Get("after"); after != "" {
+			books, err := app.Models.Books.ListAfter(filter, after, booksPageSize)
+			if err != nil {
+				if errors.Is(err, models.ErrInvalidCursor) {
+					app.ClientError(w, r, http.StatusBadRequest, err)
+					return
+				}
+				app.ServerError(w, r, err)
+				return
+			}
+			data.Books = books.Books
+			data.NextCursor = books.Next
+			data.Filter = filter
+			app.Render(w, r, "htmxMoreBookCards", data, http.StatusOK)
+			return
+		}
+
+		if scroll {
+			err = scrollBooks(app, &data, filter)
+		} else {
+			err = listBooks(app, &data, filter, page)
+		}
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		data.BooksURL = booksURL(filter, data.Page, scroll)
 
 		if app.IsHtmxRequest(r) {
+			if current, err := url.Parse(r.Header.Get("HX-Current-URL")); err != nil || current.RequestURI() != data.BooksURL {
+				w.Header().Set("HX-Push-Url", data.BooksURL)
+			}
 			app.Render(w, r, "htmxBookCard", data, http.StatusOK)
 			return
 		}
@@ -43,6 +97,87 @@ func BooksPage(app *app.App) http.HandlerFunc {
 	}
 }
 
+// applySmartShelf sets the smart shelves of the authenticated user in data and narrows filter down to the books
+// on the one it names, if any, filtering statuses by those of the user. Returns models.ErrNoRecord when the shelf
+// is not one of the user's.
+func applySmartShelf(app *app.App, r *http.Request, data *app.TemplateData, filter models.BookFilter) (models.BookFilter, error) {
+	userId := app.GetAuthenticatedUserId(r)
+	filter.Reader = userId
+	if userId != 0 {
+		shelves, err := app.Models.SmartShelves.List(userId)
+		if err != nil {
+			return filter, err
+		}
+		data.SmartShelves = shelves
+	}
+	if filter.Shelf == 0 {
+		return filter, nil
+	}
+
+	shelf, err := app.Models.SmartShelves.Retrieve(filter.Shelf, userId)
+	if err != nil {
+		return filter, err
+	}
+	data.SmartShelf = shelf
+	return shelf.Apply(filter)
+}
+
+// listBooks sets a page of the books matching filter and the options of the filters in data.
+func listBooks(app *app.App, data *app.TemplateData, filter models.BookFilter, page int) error {
+	paginated, err := app.Models.Books.List(filter, page, booksPageSize)
+	if err != nil {
+		return err
+	}
+	facets, err := app.Models.Books.Facets(filter)
+	if err != nil {
+		return err
+	}
+
+	data.Books = paginated.Books
+	data.Page = paginated.Page
+	data.PageSize = paginated.PageSize
+	data.Total = paginated.Total
+	data.TotalPages = paginated.TotalPages
+	data.Filter = filter
+	data.Facets = facets
+	return nil
+}
+
+// scrollBooks sets the first books matching filter, the cursor of the books after them and the options
+// of the filters in data, for a list that loads more books as it is scrolled.
+func scrollBooks(app *app.App, data *app.TemplateData, filter models.BookFilter) error {
+	books, err := app.Models.Books.ListAfter(filter, "", booksPageSize)
+	if err != nil {
+		return err
+	}
+	facets, err := app.Models.Books.Facets(filter)
+	if err != nil {
+		return err
+	}
+
+	data.Books = books.Books
+	data.NextCursor = books.Next
+	data.Scroll = true
+	data.Filter = filter
+	data.Facets = facets
+	return nil
+}
+
+// booksURL returns the URL of a page of the book list with a filter, or of the first books of an infinite
+// scroll when scroll is set.
+func booksURL(filter models.BookFilter, page int, scroll bool) string {
+	query, _ := url.ParseQuery(filter.Encode())
+	if scroll {
+		query.Set("scroll", "1")
+	} else if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if len(query) == 0 {
+		return "/books"
+	}
+	return "/books?" + query.Encode()
+}
+
 // BooksAddPage renders the "htmxCreateBook" template with the provided request-specific data using a 200 OK status.
 func BooksAddPage(app *app.App) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -67,7 +202,7 @@ func BooksDetailPage(app *app.App) http.HandlerFunc {
 			return
 		}
 
-		book, err := app.Models.Books.Retrieve(id)
+		book, err := app.Models.Books.RetrieveFor(id, app.GetAuthenticatedUserId(r))
 		if err != nil {
 			app.ServerError(w, r, err)
 		}
@@ -140,7 +275,8 @@ func CreateBookPost(app *app.App) http.HandlerFunc {
 			return
 		}
 
-		bookId, err := app.Models.Books.Create(form.Title, form.Author, form.ISBN, form.Status, form.ImageURL, form.PublicationYear, userId)
+		details := models.BookDetails{Publisher: form.Publisher, Language: form.Language, Description: form.Description, PageCount: form.PageCount}
+		bookId, err := app.Models.Books.Create(form.Title, form.Author, form.ISBN, form.Status, form.ImageURL, form.PublicationYear, userId, details)
 		if err != nil {
 			if errors.Is(err, models.ErrDuplicateIsbn) {
 				form.AddFieldError("isbn", "This ISBN is already registered.")
@@ -159,28 +295,251 @@ func CreateBookPost(app *app.App) http.HandlerFunc {
 	}
 }
 
-// UpdateBookPage handles HTTP requests to render the book update page, populating form and book data from the database.
-func UpdateBookPage(app *app.App) http.HandlerFunc {
+// BookEpubPost handles the upload of an EPUB file on the add book page. It reads the book metadata,
+// saves the cover into the upload directory and renders the book form prefilled with both.
+func BookEpubPost(app *app.App) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		bookId, err := strconv.Atoi(r.PathValue("id"))
+		r.Body = http.MaxBytesReader(w, r.Body, maxEPUBSize)
+		if err := r.ParseMultipartForm(maxEPUBSize); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := r.MultipartForm.RemoveAll(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		var form forms.BookForm
+		data := app.GetTemplateData(r)
+
+		file, header, err := r.FormFile("epub")
 		if err != nil {
-			http.NotFound(w, r)
+			if errors.Is(err, http.ErrMissingFile) {
+				form.AddNonFieldError("Please choose an EPUB file.")
+				data.Form = form
+				app.Render(w, r, "htmxBookForm", data, http.StatusUnprocessableEntity)
+				return
+			}
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		book, err := epub.Read(file, header.Size)
+		if err != nil {
+			if errors.Is(err, epub.ErrInvalidEPUB) || errors.Is(err, epub.ErrTooLarge) {
+				app.Logger.Error("epub upload failed", "error", err)
+				form.AddNonFieldError("The file could not be read. Please upload a valid EPUB file.")
+				data.Form = form
+				app.Render(w, r, "htmxBookForm", data, http.StatusUnprocessableEntity)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		imageUrl, err := book.SaveCover(r.Context(), app.SaveImage)
+		if err != nil {
+			app.ServerError(w, r, err)
 			return
 		}
+
+		form.Title = book.Title
+		form.Author = book.Author()
+		form.ISBN = book.ISBN
+		form.PublicationYear = book.PublicationYear
+		form.Publisher = book.Publisher
+		form.Language = book.Language
+		form.Description = book.Description
+		data.Form = form
+		data.Book = models.Book{ImageURL: imageUrl}
+		app.Render(w, r, "htmxBookForm", data, http.StatusOK)
+	}
+}
+
+// BookISBNPost looks up the ISBN entered on the add book page in the book catalog and renders the book form
+// with the details found. When the catalog does not know the book or cannot be reached, the form is kept
+// as entered so the details can be filled in by hand.
+func BookISBNPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 5<<20)
+		if err := r.ParseMultipartForm(5 << 20); err != nil {
+			if !errors.Is(err, http.ErrNotMultipart) {
+				app.ClientError(w, r, http.StatusBadRequest, err)
+				return
+			}
+		} else {
+			defer func() {
+				if err := r.MultipartForm.RemoveAll(); err != nil {
+					app.Logger.Error(err.Error())
+				}
+			}()
+		}
+
 		var form forms.BookForm
+		if err := app.FormDecoder.Decode(&form, r.PostForm); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
 		data := app.GetTemplateData(r)
-		book, err := app.Models.Books.Retrieve(bookId)
+		data.Book = models.Book{ImageURL: r.PostForm.Get("current_image_url")}
+
+		isbn, err := metadata.NormalizeISBN(form.ISBN)
 		if err != nil {
+			form.AddFieldError("isbn", "Please enter a valid ISBN-10 or ISBN-13.")
+			data.Form = form
+			app.Render(w, r, "htmxBookForm", data, http.StatusUnprocessableEntity)
+			return
+		}
+
+		renderISBNLookup(app, w, r, form, data, isbn)
+	}
+}
+
+// BookBarcodePost reads the ISBN from the barcode in a photo of the back cover of a book, uploaded on the add book
+// page, and renders the book form with the details found for it in the book catalog, like BookISBNPost.
+func BookBarcodePost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxPhotoSize)
+		if err := r.ParseMultipartForm(maxPhotoSize); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := r.MultipartForm.RemoveAll(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		var form forms.BookForm
+		data := app.GetTemplateData(r)
+
+		file, _, err := r.FormFile("photo")
+		if err != nil {
+			if errors.Is(err, http.ErrMissingFile) {
+				form.AddNonFieldError("Please take a photo of the barcode.")
+				data.Form = form
+				app.Render(w, r, "htmxBookForm", data, http.StatusUnprocessableEntity)
+				return
+			}
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		img, _, err := images.Decode(file)
+		if err != nil {
+			if errors.Is(err, images.ErrUnsupported) || errors.Is(err, images.ErrTooLarge) {
+				form.AddNonFieldError("The photo could not be read. Please upload a JPEG or PNG image.")
+				data.Form = form
+				app.Render(w, r, "htmxBookForm", data, http.StatusUnprocessableEntity)
+				return
+			}
 			app.ServerError(w, r, err)
 			return
 		}
 
+		code, err := barcode.Decode(img)
+		if err != nil {
+			form.AddNonFieldError("No barcode could be read in the photo. Please try again with the barcode in focus, or enter the ISBN.")
+			data.Form = form
+			app.Render(w, r, "htmxBookForm", data, http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Books have barcodes starting with 978 or 979, other products those of their country.
+		isbn, err := metadata.NormalizeISBN(code)
+		if err != nil || !strings.HasPrefix(isbn, "978") && !strings.HasPrefix(isbn, "979") {
+			form.AddNonFieldError(fmt.Sprintf("The barcode %s is not an ISBN. Please use the barcode of the book.", code))
+			data.Form = form
+			app.Render(w, r, "htmxBookForm", data, http.StatusUnprocessableEntity)
+			return
+		}
+
+		form.ISBN = isbn
+		renderISBNLookup(app, w, r, form, data, isbn)
+	}
+}
+
+// renderISBNLookup looks up an ISBN in the book catalog and renders the book form with the details found.
+// When the catalog does not know the book or cannot be reached, the form is kept as it is.
+func renderISBNLookup(app *app.App, w http.ResponseWriter, r *http.Request, form forms.BookForm, data app.TemplateData, isbn string) {
+	if app.Metadata == nil {
+		form.AddNonFieldError("Looking up books is not available. Please fill in the details.")
+		data.Form = form
+		app.Render(w, r, "htmxBookForm", data, http.StatusOK)
+		return
+	}
+
+	book, err := app.Metadata.LookupISBN(r.Context(), isbn)
+	if err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			form.AddNonFieldError("No book was found for this ISBN. Please fill in the details.")
+		} else {
+			app.Logger.Error("isbn lookup failed", "isbn", isbn, "error", err)
+			form.AddNonFieldError("The book catalog could not be reached. Please fill in the details.")
+		}
+		data.Form = form
+		app.Render(w, r, "htmxBookForm", data, http.StatusOK)
+		return
+	}
+
+	form.ISBN = isbn
+	form.Title = book.Title
+	if book.Author != "" {
+		form.Author = book.Author
+	}
+	if book.PublicationYear != 0 {
+		form.PublicationYear = book.PublicationYear
+	}
+	if book.Publisher != "" {
+		form.Publisher = book.Publisher
+	}
+	if book.Language != "" {
+		form.Language = book.Language
+	}
+	if book.Description != "" {
+		form.Description = book.Description
+	}
+	if book.PageCount != 0 {
+		form.PageCount = book.PageCount
+	}
+	data.Form = form
+	app.Render(w, r, "htmxBookForm", data, http.StatusOK)
+}
+
+// UpdateBookPage handles HTTP requests to render the book update page, populating form and book data from the database.
+func UpdateBookPage(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bookId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		var form forms.BookForm
+		data := app.GetTemplateData(r)
 		userId := app.GetAuthenticatedUserId(r)
 		if userId == 0 {
 			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
 			return
 		}
 
+		book, err := app.Models.Books.RetrieveFor(bookId, userId)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
 		data.Book = book
 		data.Form = form
 		if app.IsHtmxRequest(r) {
@@ -273,9 +632,13 @@ func UpdateBookPost(app *app.App) http.HandlerFunc {
 			return
 		}
 
-		err = app.Models.Books.Update(bookId, form.Title, form.Author, form.ISBN, form.Status, form.ImageURL, form.PublicationYear)
+		details := models.BookDetails{Publisher: form.Publisher, Language: form.Language, Description: form.Description, PageCount: form.PageCount}
+		err = app.Models.Books.Update(bookId, form.Title, form.Author, form.ISBN, form.Status, form.ImageURL, form.PublicationYear, userId, details)
 		if err != nil {
-			if errors.Is(err, models.ErrDuplicateIsbn) {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusForbidden, err)
+				return
+			} else if errors.Is(err, models.ErrDuplicateIsbn) {
 				form.AddFieldError("isbn", "This ISBN is already registered.")
 				data.Form = form
 				app.Render(w, r, "htmxBookEdit", data, http.StatusUnprocessableEntity)
@@ -326,8 +689,9 @@ func DeleteBookPost(app *app.App) http.HandlerFunc {
 	}
 }
 
-// GetFilteredBooks handles requests to search for books using a search term and renders the filtered results as HTML templates.
-// It parses form data, retrieves matching books from the application's data model, and handles errors accordingly.
+// GetFilteredBooks renders the books matching the search entered in the books header, best matches first,
+// with a snippet of what matched, and remembers it among the recent searches of the user. The notes of other
+// users are not searched. An empty search renders the first page of books again.
 func GetFilteredBooks(app *app.App) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := r.ParseForm()
@@ -336,16 +700,69 @@ func GetFilteredBooks(app *app.App) http.HandlerFunc {
 			return
 		}
 
-		searchTerm := r.FormValue("search")
+		searchTerm := strings.TrimSpace(r.FormValue("search"))
+		data := app.GetTemplateData(r)
 
-		books, err := app.Models.Books.Filter(searchTerm)
+		if searchTerm == "" {
+			filter, err := applySmartShelf(app, r, &data, models.BookFilter{})
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+			if err := listBooks(app, &data, filter, 1); err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+			app.Render(w, r, "htmxBookCard", data, http.StatusOK)
+			return
+		}
+
+		results, err := app.Models.Books.Search(searchTerm, app.GetAuthenticatedUserId(r), searchLimit)
 		if err != nil {
 			app.ServerError(w, r, err)
 			return
 		}
+
+		// A search that cannot be remembered still shows its results.
+		if userId := app.GetAuthenticatedUserId(r); userId != 0 {
+			if err := app.Models.RecentSearches.Add(userId, searchTerm); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}
+		data.Search = searchTerm
+		data.SearchResults = results
+		app.Render(w, r, "htmxSearchResults", data, http.StatusOK)
+	}
+}
+
+// GetSuggestions renders the books, authors, tags and users matching the search being typed in the books header,
+// grouped by type, or the recent searches of the user while nothing is typed yet.
+func GetSuggestions(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		searchTerm := strings.TrimSpace(r.URL.Query().Get("search"))
 		data := app.GetTemplateData(r)
-		data.Books = books
-		app.Render(w, r, "htmxBookCard", data, http.StatusOK)
+		data.Search = searchTerm
+
+		if searchTerm == "" {
+			if userId := app.GetAuthenticatedUserId(r); userId != 0 {
+				recent, err := app.Models.RecentSearches.List(userId, suggestLimit)
+				if err != nil {
+					app.ServerError(w, r, err)
+					return
+				}
+				data.RecentSearches = recent
+			}
+			app.Render(w, r, "htmxSuggestions", data, http.StatusOK)
+			return
+		}
+
+		suggestions, err := app.Models.Books.Suggest(searchTerm, suggestLimit)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		data.Suggestions = suggestions
+		app.Render(w, r, "htmxSuggestions", data, http.StatusOK)
 	}
 }
 
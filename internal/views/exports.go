@@ -0,0 +1,68 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/backup"
+	"github.com/madalinpopa/go-bookreview/internal/goodreads"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"net/http"
+	"time"
+)
+
+// GoodreadsExport streams the library of the authenticated user as a Goodreads compatible CSV file.
+func GoodreadsExport(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="goodreads_library_export.csv"`)
+
+		writer := goodreads.NewWriter(w)
+		err := writer.WriteHeader()
+		if err == nil {
+			err = app.Models.Books.EachInLibrary(userId, func(entry models.LibraryEntry) error {
+				return writer.Write(entry)
+			})
+		}
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			// The response has already started, so the error can only be logged.
+			app.Logger.Error("goodreads export failed", "error", err, "user_id", userId)
+		}
+	}
+}
+
+// BackupExport streams a zip archive with the books, shelves, reviews, notes and covers of the authenticated user.
+func BackupExport(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		data, err := app.Models.Backups.Collect(userId)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		filename := fmt.Sprintf("bookreview-backup-%s.zip", time.Now().Format("2006-01-02"))
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		err = backup.Write(r.Context(), w, app.GetAuthenticatedUserName(r), data, app.Storage)
+		if err != nil {
+			// The response has already started, so the error can only be logged.
+			app.Logger.Error("backup export failed", "error", err, "user_id", userId)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/atom"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// feedSize is the number of entries in an Atom feed.
+const feedSize = 50
+
+// feedBase returns the absolute URL of the site as requested, which links in feeds are resolved against.
+func feedBase(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/"
+}
+
+// reviewEntry returns the feed entry of a review.
+func reviewEntry(review models.Review) atom.Entry {
+	return atom.Entry{
+		ID:        fmt.Sprintf("urn:go-bookreview:review:%d", review.ID),
+		Title:     fmt.Sprintf("%s rated %s %d/5", review.Username, review.BookTitle, review.Rating),
+		Author:    review.Username,
+		Published: review.CreatedAt,
+		Updated:   review.UpdatedAt,
+		Link:      fmt.Sprintf("/books/%d", review.BookId),
+		Content:   review.ReviewText,
+	}
+}
+
+// writeFeed writes an Atom feed in version v, letting feed readers cache it for a few minutes.
+func writeFeed(app *app.App, w http.ResponseWriter, r *http.Request, feed *atom.Feed, v atom.Version) {
+	feed.Base = feedBase(r)
+	feed.Self = r.URL.Path
+	feed.Updated = v.Updated
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Now()
+	}
+
+	w.Header().Set("Content-Type", atom.ContentType)
+	if err := feed.Write(w); err != nil {
+		app.Logger.Error("atom feed failed", "error", err)
+	}
+}
+
+// ReviewsFeed renders the Atom feed of the most recent reviews of the site.
+func ReviewsFeed(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		count, updated, err := app.Models.Reviews.FeedVersion(0)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		v := atom.Version{Count: count, Updated: updated}
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if atom.NotModified(w, r, v) {
+			return
+		}
+
+		reviews, err := app.Models.Reviews.Feed(0, feedSize)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		feed := &atom.Feed{
+			ID:        "urn:go-bookreview:feed:reviews",
+			Title:     "Book Review - Recent reviews",
+			Subtitle:  "The latest reviews written by readers.",
+			Alternate: "/",
+		}
+		for _, review := range reviews {
+			feed.Entries = append(feed.Entries, reviewEntry(review))
+		}
+		writeFeed(app, w, r, feed, v)
+	}
+}
+
+// BookReviewsFeed renders the Atom feed of the reviews of a book.
+func BookReviewsFeed(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil || id < 1 {
+			app.ClientError(w, r, http.StatusNotFound, errors.New("invalid book id"))
+			return
+		}
+
+		book, err := app.Models.Books.Retrieve(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		count, updated, err := app.Models.Reviews.FeedVersion(book.ID)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		v := atom.Version{Count: count, Updated: updated}
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if atom.NotModified(w, r, v) {
+			return
+		}
+
+		reviews, err := app.Models.Reviews.Feed(book.ID, feedSize)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		feed := &atom.Feed{
+			ID:        fmt.Sprintf("urn:go-bookreview:feed:book:%d:reviews", book.ID),
+			Title:     fmt.Sprintf("Reviews of %s", book.Title),
+			Subtitle:  fmt.Sprintf("Reviews of %s by %s.", book.Title, book.Author),
+			Alternate: fmt.Sprintf("/books/%d", book.ID),
+		}
+		for _, review := range reviews {
+			feed.Entries = append(feed.Entries, reviewEntry(review))
+		}
+		writeFeed(app, w, r, feed, v)
+	}
+}
+
+// UserActivityFeed renders the Atom feed of the public activity of a user: the books they finished and reviewed.
+func UserActivityFeed(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		userId, err := app.Models.Users.RetrieveId(username)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		count, updated, err := app.Models.Activity.Version(userId)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		v := atom.Version{Count: count, Updated: updated}
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if atom.NotModified(w, r, v) {
+			return
+		}
+
+		activities, err := app.Models.Activity.ForUser(userId, feedSize)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		feed := &atom.Feed{
+			ID:       fmt.Sprintf("urn:go-bookreview:feed:user:%d:activity", userId),
+			Title:    fmt.Sprintf("%s on Book Review", username),
+			Subtitle: fmt.Sprintf("Books %s finished and reviewed.", username),
+		}
+		for _, a := range activities {
+			if a.Kind == models.ActivityReview {
+				feed.Entries = append(feed.Entries, reviewEntry(models.Review{
+					Base:       models.Base{ID: a.ID},
+					BookId:     a.BookId,
+					Rating:     a.Rating,
+					ReviewText: a.ReviewText,
+					CreatedAt:  a.CreatedAt,
+					UpdatedAt:  a.UpdatedAt,
+					Username:   username,
+					BookTitle:  a.BookTitle,
+				}))
+				continue
+			}
+			feed.Entries = append(feed.Entries, atom.Entry{
+				ID:        fmt.Sprintf("urn:go-bookreview:finished:%d", a.ID),
+				Title:     fmt.Sprintf("%s finished %s by %s", username, a.BookTitle, a.BookAuthor),
+				Author:    username,
+				Published: a.CreatedAt,
+				Updated:   a.UpdatedAt,
+				Link:      fmt.Sprintf("/books/%d", a.BookId),
+			})
+		}
+		writeFeed(app, w, r, feed, v)
+	}
+}
@@ -0,0 +1,342 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/backup"
+	"github.com/madalinpopa/go-bookreview/internal/forms"
+	"github.com/madalinpopa/go-bookreview/internal/goodreads"
+	"github.com/madalinpopa/go-bookreview/internal/kindle"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"net/http"
+)
+
+// maxClippingsSize limits the size of an uploaded Kindle clippings file.
+const maxClippingsSize = 10 << 20
+
+// maxBackupSize limits the size of an uploaded backup archive, covers included.
+const maxBackupSize = 200 << 20
+
+// maxLibraryExportSize limits the size of an uploaded library export such as a Goodreads CSV file.
+const maxLibraryExportSize = 20 << 20
+
+// ImportPage renders the import page that hosts the different library importers.
+func ImportPage(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := app.GetTemplateData(r)
+		tokens, err := app.Models.APITokens.List(app.GetAuthenticatedUserId(r))
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		data.APITokens = tokens
+		data.Form = forms.APITokenForm{}
+		app.Render(w, r, "import.tmpl", data, http.StatusOK)
+	}
+}
+
+// KindleUploadPost handles the upload of a Kindle "My Clippings.txt" file.
+// It parses the file, stages new clippings for the authenticated user and renders the matching step.
+func KindleUploadPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxClippingsSize)
+		if err := r.ParseMultipartForm(maxClippingsSize); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := r.MultipartForm.RemoveAll(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		file, _, err := r.FormFile("clippings")
+		if err != nil {
+			if errors.Is(err, http.ErrMissingFile) {
+				renderKindleMatches(app, w, r, userId, "Please choose a My Clippings.txt file.", http.StatusUnprocessableEntity)
+				return
+			}
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		parsed, err := kindle.Parse(file)
+		if err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		clippings := make([]models.KindleClipping, 0, len(parsed))
+		for _, c := range parsed {
+			clippings = append(clippings, models.KindleClipping{
+				Hash:          c.Hash(),
+				Title:         c.Title,
+				Author:        c.Author,
+				Kind:          string(c.Kind),
+				PageNumber:    c.Page,
+				LocationStart: c.LocationStart,
+				LocationEnd:   c.LocationEnd,
+				Text:          c.Text,
+				ClippedAt:     c.AddedAt,
+			})
+		}
+
+		staged, err := app.Models.KindleClippings.Stage(userId, clippings)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		msg := fmt.Sprintf("Found %d clippings, %d new and %d already imported.", len(parsed), staged, len(parsed)-staged)
+		renderKindleMatches(app, w, r, userId, msg, http.StatusOK)
+	}
+}
+
+// KindleMatches renders the pending Kindle titles of the authenticated user together with their suggested books.
+func KindleMatches(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+		renderKindleMatches(app, w, r, userId, "", http.StatusOK)
+	}
+}
+
+// KindleMatchPost handles the manual matching step of a Kindle import.
+// Titles matched to a book have their pending clippings created as notes, skipped titles are set aside.
+func KindleMatchPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var form forms.KindleMatchForm
+		if err := app.FormDecoder.Decode(&form, r.PostForm); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		form.Validate()
+		if !form.Valid() {
+			renderKindleMatches(app, w, r, userId, "The matches could not be saved, please try again.", http.StatusUnprocessableEntity)
+			return
+		}
+
+		imported := 0
+		for i, title := range form.Titles {
+			bookId := form.BookIds[i]
+			switch {
+			case bookId < 0:
+				if err := app.Models.KindleClippings.Skip(userId, title, form.Authors[i]); err != nil {
+					app.ServerError(w, r, err)
+					return
+				}
+			case bookId > 0:
+				n, err := app.Models.KindleClippings.ImportTitle(userId, bookId, title, form.Authors[i], kindleNoteText)
+				if err != nil {
+					if errors.Is(err, models.ErrNoRecord) {
+						app.ClientError(w, r, http.StatusNotFound, err)
+						return
+					}
+					app.ServerError(w, r, err)
+					return
+				}
+				imported += n
+			}
+		}
+
+		w.Header().Set("HX-Trigger", "update-notes")
+		renderKindleMatches(app, w, r, userId, fmt.Sprintf("Imported %d notes.", imported), http.StatusOK)
+	}
+}
+
+// kindleNoteText builds the note text for a clipping, keeping the Kindle location when there is no page number.
+func kindleNoteText(c models.KindleClipping) string {
+	text := c.Text
+	if c.Kind == string(kindle.KindBookmark) {
+		text = "Bookmark"
+	}
+
+	if c.PageNumber == 0 && c.LocationStart > 0 {
+		location := fmt.Sprintf("%d", c.LocationStart)
+		if c.LocationEnd > c.LocationStart {
+			location = fmt.Sprintf("%d-%d", c.LocationStart, c.LocationEnd)
+		}
+		text = fmt.Sprintf("%s\n\n(Kindle location %s)", text, location)
+	}
+	return text
+}
+
+// renderKindleMatches renders the pending titles of a user with a suggested book for titles that were never matched.
+func renderKindleMatches(app *app.App, w http.ResponseWriter, r *http.Request, userId int, msg string, status int) {
+	titles, err := app.Models.KindleClippings.PendingTitles(userId)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	books, err := app.Models.Books.All()
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	byTitle := make(map[string]int, len(books))
+	for _, book := range books {
+		byTitle[kindle.NormalizeTitle(book.Title)] = book.ID
+	}
+	for i := range titles {
+		if titles[i].BookId == 0 {
+			titles[i].BookId = byTitle[kindle.NormalizeTitle(titles[i].Title)]
+		}
+	}
+
+	data := app.GetTemplateData(r)
+	data.KindleTitles = titles
+	data.Books = books
+	data.Flash = msg
+	app.Render(w, r, "htmxKindleMatches", data, status)
+}
+
+// GoodreadsImportPost handles the upload of a Goodreads library export CSV.
+// With dry_run set it only previews how many books would be created, linked and skipped.
+func GoodreadsImportPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxLibraryExportSize)
+		if err := r.ParseMultipartForm(maxLibraryExportSize); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := r.MultipartForm.RemoveAll(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		data := app.GetTemplateData(r)
+		data.ImportPreview = r.PostForm.Get("dry_run") == "true"
+
+		file, _, err := r.FormFile("library")
+		if err != nil {
+			if errors.Is(err, http.ErrMissingFile) {
+				data.Flash = "Please choose a Goodreads export file."
+				app.Render(w, r, "htmxGoodreadsResult", data, http.StatusUnprocessableEntity)
+				return
+			}
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		records, err := goodreads.Parse(file)
+		if err != nil {
+			app.Logger.Error("goodreads import failed", "error", err)
+			data.Flash = "The file could not be read. Please upload the CSV exported from Goodreads."
+			app.Render(w, r, "htmxGoodreadsResult", data, http.StatusUnprocessableEntity)
+			return
+		}
+
+		result, err := app.Models.Books.Import(userId, goodreads.ImportBooks(records), data.ImportPreview)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data.ImportResult = &result
+		app.Render(w, r, "htmxGoodreadsResult", data, http.StatusOK)
+	}
+}
+
+// BackupRestorePost handles the upload of a backup archive and restores it into the library of the authenticated user.
+func BackupRestorePost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBackupSize)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := r.MultipartForm.RemoveAll(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		data := app.GetTemplateData(r)
+
+		file, header, err := r.FormFile("archive")
+		if err != nil {
+			if errors.Is(err, http.ErrMissingFile) {
+				data.Flash = "Please choose a backup archive."
+				app.Render(w, r, "htmxBackupResult", data, http.StatusUnprocessableEntity)
+				return
+			}
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				app.Logger.Error(err.Error())
+			}
+		}()
+
+		archive, err := backup.Open(file, header.Size)
+		if err != nil {
+			app.Logger.Error("backup restore failed", "error", err)
+			data.Flash = "The file is not a backup archive of this application."
+			if errors.Is(err, backup.ErrUnsupportedVersion) {
+				data.Flash = "The backup was made by a newer version of the application. Please update before restoring it."
+			}
+			app.Render(w, r, "htmxBackupResult", data, http.StatusUnprocessableEntity)
+			return
+		}
+
+		result, err := app.Models.Backups.Restore(userId, archive.Backup, func(b models.BackupBook) (string, error) {
+			return archive.SaveCover(r.Context(), b, app.SaveImage)
+		})
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data.ImportResult = &result
+		app.Render(w, r, "htmxBackupResult", data, http.StatusOK)
+	}
+}
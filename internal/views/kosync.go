@@ -0,0 +1,245 @@
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/forms"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"net/http"
+	"strings"
+)
+
+// maxKosyncBodySize limits the size of a request body of the KOReader sync protocol.
+const maxKosyncBodySize = 64 << 10
+
+// Error codes of the KOReader sync protocol.
+const (
+	kosyncErrorUnknown         = 1000
+	kosyncErrorUnauthorized    = 2001
+	kosyncErrorUserExists      = 2002
+	kosyncErrorInvalidFields   = 2003
+	kosyncErrorDocumentMissing = 2004
+)
+
+// kosyncError is the body of an error response of the KOReader sync protocol.
+type kosyncError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// kosyncProgress is the reading position of a document as KOReader sends and receives it.
+// Progress is an XPointer for reflowable documents and a page number for paged ones, so both forms are accepted.
+type kosyncProgress struct {
+	Document   string          `json:"document"`
+	Progress   json.RawMessage `json:"progress,omitempty"`
+	Percentage float64         `json:"percentage"`
+	Device     string          `json:"device"`
+	DeviceId   string          `json:"device_id"`
+	Timestamp  int64           `json:"timestamp,omitempty"`
+}
+
+// writeKosyncJSON writes a JSON response of the KOReader sync protocol.
+func writeKosyncJSON(app *app.App, w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		app.Logger.Error("kosync response failed", "error", err)
+	}
+}
+
+// KosyncHealthcheck reports that the sync server is running.
+func KosyncHealthcheck(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeKosyncJSON(app, w, http.StatusOK, map[string]string{"state": "OK"})
+	}
+}
+
+// KosyncCreateUser answers the registration request of KOReader. Accounts are created on the website,
+// so existing usernames are reported as registered and other registrations are refused.
+func KosyncCreateUser(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Username string `json:"username"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxKosyncBodySize)).Decode(&body); err != nil || body.Username == "" {
+			writeKosyncJSON(app, w, http.StatusForbidden, kosyncError{kosyncErrorInvalidFields, "Invalid request"})
+			return
+		}
+
+		exists, err := app.Models.Users.Exists(models.Username, body.Username)
+		if err != nil {
+			app.Logger.Error("kosync registration failed", "error", err)
+			writeKosyncJSON(app, w, http.StatusInternalServerError, kosyncError{kosyncErrorUnknown, "Unknown server error"})
+			return
+		}
+		if exists {
+			writeKosyncJSON(app, w, http.StatusPaymentRequired, kosyncError{kosyncErrorUserExists, "Username is already registered. Log in with an access token as the password."})
+			return
+		}
+		writeKosyncJSON(app, w, http.StatusForbidden, kosyncError{kosyncErrorUnauthorized, "Create an account on the website, then log in with an access token as the password."})
+	}
+}
+
+// KosyncAuth confirms the credentials of KOReader, which were already checked by the KosyncAuthenticate middleware.
+func KosyncAuth(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeKosyncJSON(app, w, http.StatusOK, map[string]string{"authorized": "OK"})
+	}
+}
+
+// KosyncGetProgress returns the last reading position synced for a document, or an empty object if there is none.
+func KosyncGetProgress(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		document := r.PathValue("document")
+		if document == "" {
+			writeKosyncJSON(app, w, http.StatusForbidden, kosyncError{kosyncErrorDocumentMissing, "Field 'document' not provided."})
+			return
+		}
+
+		p, err := app.Models.Kosync.Progress(app.GetAPIUserId(r), document)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				writeKosyncJSON(app, w, http.StatusOK, struct{}{})
+				return
+			}
+			app.Logger.Error("kosync progress failed", "error", err)
+			writeKosyncJSON(app, w, http.StatusInternalServerError, kosyncError{kosyncErrorUnknown, "Unknown server error"})
+			return
+		}
+
+		progress, err := json.Marshal(p.Progress)
+		if err != nil {
+			app.Logger.Error("kosync progress failed", "error", err)
+			writeKosyncJSON(app, w, http.StatusInternalServerError, kosyncError{kosyncErrorUnknown, "Unknown server error"})
+			return
+		}
+		writeKosyncJSON(app, w, http.StatusOK, kosyncProgress{
+			Document:   p.Document,
+			Progress:   progress,
+			Percentage: p.Percentage,
+			Device:     p.Device,
+			DeviceId:   p.DeviceId,
+			Timestamp:  p.Timestamp,
+		})
+	}
+}
+
+// KosyncUpdateProgress stores the reading position KOReader sends for a document and updates the matched book.
+func KosyncUpdateProgress(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body kosyncProgress
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxKosyncBodySize)).Decode(&body); err != nil {
+			writeKosyncJSON(app, w, http.StatusForbidden, kosyncError{kosyncErrorInvalidFields, "Invalid request"})
+			return
+		}
+		if body.Document == "" {
+			writeKosyncJSON(app, w, http.StatusForbidden, kosyncError{kosyncErrorDocumentMissing, "Field 'document' not provided."})
+			return
+		}
+		if body.Percentage < 0 || body.Percentage > 1 {
+			writeKosyncJSON(app, w, http.StatusForbidden, kosyncError{kosyncErrorInvalidFields, "Invalid request"})
+			return
+		}
+
+		// Page numbers arrive as JSON numbers and XPointers as strings; both are stored as text.
+		progress := strings.TrimSpace(string(body.Progress))
+		var s string
+		if err := json.Unmarshal(body.Progress, &s); err == nil {
+			progress = s
+		}
+
+		timestamp, err := app.Models.Kosync.Update(app.GetAPIUserId(r), models.KosyncProgress{
+			Document:   body.Document,
+			Progress:   progress,
+			Percentage: body.Percentage,
+			Device:     body.Device,
+			DeviceId:   body.DeviceId,
+		})
+		if err != nil {
+			app.Logger.Error("kosync update failed", "error", err)
+			writeKosyncJSON(app, w, http.StatusInternalServerError, kosyncError{kosyncErrorUnknown, "Unknown server error"})
+			return
+		}
+		writeKosyncJSON(app, w, http.StatusOK, map[string]any{"document": body.Document, "timestamp": timestamp})
+	}
+}
+
+// KosyncMatches renders the KOReader documents of the authenticated user that are waiting to be matched to a book.
+func KosyncMatches(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+		renderKosyncDocuments(app, w, r, userId, "", http.StatusOK)
+	}
+}
+
+// KosyncMatchPost handles the one-time confirmation of which book a KOReader document is, or sets the document aside.
+func KosyncMatchPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var form forms.KosyncMatchForm
+		if err := app.FormDecoder.Decode(&form, r.PostForm); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		form.Validate()
+		if !form.Valid() {
+			renderKosyncDocuments(app, w, r, userId, "Please choose a book for the document.", http.StatusUnprocessableEntity)
+			return
+		}
+
+		var err error
+		msg := "The document was set aside."
+		if form.BookId < 0 {
+			err = app.Models.Kosync.Ignore(userId, form.Id)
+		} else {
+			err = app.Models.Kosync.Match(userId, form.Id, form.BookId)
+			msg = "The document was matched, its reading progress is now synced to the book."
+		}
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+		renderKosyncDocuments(app, w, r, userId, msg, http.StatusOK)
+	}
+}
+
+// renderKosyncDocuments renders the KOReader documents of a user that still wait to be matched to a book.
+func renderKosyncDocuments(app *app.App, w http.ResponseWriter, r *http.Request, userId int, msg string, status int) {
+	documents, err := app.Models.Kosync.Pending(userId)
+	if err != nil {
+		app.ServerError(w, r, err)
+		return
+	}
+
+	data := app.GetTemplateData(r)
+	data.KosyncDocuments = documents
+	data.Flash = msg
+	if len(documents) > 0 {
+		data.Books, err = app.Models.Books.All()
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+	}
+	app.Render(w, r, "htmxKosyncDocuments", data, status)
+}
@@ -0,0 +1,442 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/forms"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/opds"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// opdsTitle is the title of the catalog shown by e-reader apps.
+const opdsTitle = "Book Review"
+
+// opdsPageSize is the number of books in a page of an acquisition feed.
+const opdsPageSize = 20
+
+// opdsSearchLimit is the most books the feed of a search lists, over all of its pages.
+const opdsSearchLimit = 200
+
+// opdsImageTTL is how long the signed URLs of private covers in a feed stay valid.
+const opdsImageTTL = 24 * time.Hour
+
+// opdsShelves lists the reading statuses offered as shelves, in the order they are shown.
+var opdsShelves = []struct {
+	Status string
+	Title  string
+}{
+	{"want_to_read", "Want to Read"},
+	{"reading", "Currently Reading"},
+	{"finished", "Finished"},
+}
+
+// opdsPrefix returns the path prefix of the catalog version requested: /opds/v2 for OPDS 2.0 and /opds for OPDS 1.2.
+func opdsPrefix(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/opds/v2/") {
+		return "/opds/v2"
+	}
+	return "/opds"
+}
+
+// opdsPage returns the page number requested in the query string, defaulting to the first page.
+func opdsPage(r *http.Request) int {
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		return p
+	}
+	return 1
+}
+
+// newOPDSFeed returns a feed with the links every feed of the catalog shares.
+func newOPDSFeed(r *http.Request, title string) *opds.Feed {
+	prefix := opdsPrefix(r)
+	feed := &opds.Feed{
+		ID:      "urn:go-bookreview:opds:" + strings.TrimPrefix(r.URL.Path, prefix),
+		Title:   title,
+		Updated: time.Now(),
+		Self:    r.URL.RequestURI(),
+		Start:   prefix + "/",
+		Search:  prefix + "/search",
+	}
+	if prefix == "/opds" {
+		feed.Search = prefix + "/search.xml"
+	}
+	return feed
+}
+
+// paginateOPDSFeed adds a page of books to an acquisition feed, with links to the other pages at basePath.
+func paginateOPDSFeed(feed *opds.Feed, basePath string, query url.Values, paginated models.PaginatedBooks) {
+	feed.Page = paginated.Page
+	feed.PageSize = paginated.PageSize
+	feed.Total = paginated.Total
+	feed.PageHref = func(page int) string {
+		q := url.Values{}
+		for key, values := range query {
+			q[key] = values
+		}
+		q.Set("page", strconv.Itoa(page))
+		return basePath + "?" + q.Encode()
+	}
+
+	for _, book := range paginated.Books {
+		publication := opds.Publication{
+			ID:              fmt.Sprintf("urn:go-bookreview:book:%d", book.ID),
+			Title:           book.Title,
+			Author:          book.Author,
+			ISBN:            book.ISBN,
+			Language:        book.Language,
+			Publisher:       book.Publisher,
+			Description:     book.Description,
+			PublicationYear: book.PublicationYear,
+			Updated:         book.UpdatedAt,
+			Href:            fmt.Sprintf("/books/%d", book.ID),
+		}
+		if book.ImageURL != "" {
+			publication.Image = book.ImageURL
+			publication.ImageType = opds.ImageType(book.ImageURL)
+		}
+		feed.Publications = append(feed.Publications, publication)
+	}
+}
+
+// writeOPDSFeed writes a feed in the format of the catalog version requested.
+func writeOPDSFeed(app *app.App, w http.ResponseWriter, r *http.Request, feed *opds.Feed) {
+	var err error
+	if opdsPrefix(r) == "/opds/v2" {
+		w.Header().Set("Content-Type", opds.JSONType)
+		err = feed.WriteJSON(w)
+	} else {
+		contentType := opds.NavigationType
+		if feed.Acquisition() {
+			contentType = opds.AcquisitionType
+		}
+		w.Header().Set("Content-Type", contentType)
+		err = feed.WriteAtom(w)
+	}
+	if err != nil {
+		app.Logger.Error("opds feed failed", "error", err)
+	}
+}
+
+// OPDSRoot renders the navigation feed at the root of the catalog, leading to the reading status shelves, the
+// user's smart shelves and tags.
+func OPDSRoot(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		smartShelves, err := app.Models.SmartShelves.List(app.GetAPIUserId(r))
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		prefix := opdsPrefix(r)
+		feed := newOPDSFeed(r, opdsTitle)
+		for _, shelf := range opdsShelves {
+			feed.Navigation = append(feed.Navigation, opds.Navigation{
+				ID:          "urn:go-bookreview:opds:shelf:" + shelf.Status,
+				Title:       shelf.Title,
+				Href:        prefix + "/shelves/" + shelf.Status,
+				Summary:     "Books on your " + shelf.Title + " shelf",
+				Acquisition: true,
+			})
+		}
+		for _, shelf := range smartShelves {
+			navigation := opds.Navigation{
+				ID:          fmt.Sprintf("urn:go-bookreview:opds:smart:%d", shelf.ID),
+				Title:       shelf.Name,
+				Href:        fmt.Sprintf("%s/smart/%d", prefix, shelf.ID),
+				Summary:     shelf.Rule,
+				Acquisition: true,
+			}
+			// Readers load covers without the session of the user, so private covers are linked with a signed URL
+			if shelf.ImageURL != "" {
+				image, err := app.SignedUploadURL(shelf.UserId, shelf.ImageURL, opdsImageTTL)
+				if err != nil && !errors.Is(err, models.ErrNoRecord) {
+					app.ServerError(w, r, err)
+					return
+				}
+				if err == nil {
+					navigation.Image = image
+					navigation.ImageType = opds.ImageType(shelf.ImageURL)
+				}
+			}
+			feed.Navigation = append(feed.Navigation, navigation)
+		}
+		feed.Navigation = append(feed.Navigation,
+			opds.Navigation{
+				ID:          "urn:go-bookreview:opds:books",
+				Title:       "All Books",
+				Href:        prefix + "/books",
+				Summary:     "Every book in your library",
+				Acquisition: true,
+			},
+			opds.Navigation{
+				ID:      "urn:go-bookreview:opds:tags",
+				Title:   "Tags",
+				Href:    prefix + "/tags",
+				Summary: "Books grouped by tag",
+			},
+		)
+		writeOPDSFeed(app, w, r, feed)
+	}
+}
+
+// OPDSShelf renders the acquisition feed of the books in the user's library, limited to a reading status
+// when the path has one.
+func OPDSShelf(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.PathValue("status")
+		title := "All Books"
+		if status != "" {
+			title = ""
+			for _, shelf := range opdsShelves {
+				if shelf.Status == status {
+					title = shelf.Title
+				}
+			}
+			if title == "" {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		paginated, err := app.Models.Books.Shelf(app.GetAPIUserId(r), status, "", opdsPage(r), opdsPageSize)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		feed := newOPDSFeed(r, title)
+		feed.Up = feed.Start
+		paginateOPDSFeed(feed, r.URL.Path, url.Values{}, paginated)
+		writeOPDSFeed(app, w, r, feed)
+	}
+}
+
+// OPDSSmartShelf renders the acquisition feed of the books matching the rule of one of the user's smart shelves.
+func OPDSSmartShelf(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shelfId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		shelf, err := app.Models.SmartShelves.Retrieve(shelfId, app.GetAPIUserId(r))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				http.NotFound(w, r)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		filter, err := shelf.Apply(models.BookFilter{Reader: shelf.UserId})
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		paginated, err := app.Models.Books.List(filter, opdsPage(r), opdsPageSize)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		feed := newOPDSFeed(r, shelf.Name)
+		feed.Up = feed.Start
+		paginateOPDSFeed(feed, r.URL.Path, url.Values{}, paginated)
+		writeOPDSFeed(app, w, r, feed)
+	}
+}
+
+// OPDSTags renders the navigation feed of the tags used in the user's library.
+func OPDSTags(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tags, err := app.Models.Tags.ForLibrary(app.GetAPIUserId(r))
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		prefix := opdsPrefix(r)
+		feed := newOPDSFeed(r, "Tags")
+		feed.Up = feed.Start
+		for _, tag := range tags {
+			feed.Navigation = append(feed.Navigation, opds.Navigation{
+				ID:          "urn:go-bookreview:opds:tag:" + url.PathEscape(tag.Name),
+				Title:       tag.Name,
+				Href:        prefix + "/tags/" + url.PathEscape(tag.Name),
+				Summary:     fmt.Sprintf("%d books", tag.Books),
+				Acquisition: true,
+			})
+		}
+		writeOPDSFeed(app, w, r, feed)
+	}
+}
+
+// OPDSTag renders the acquisition feed of the books in the user's library that carry a tag.
+func OPDSTag(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag := r.PathValue("tag")
+		paginated, err := app.Models.Books.Shelf(app.GetAPIUserId(r), "", tag, opdsPage(r), opdsPageSize)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		feed := newOPDSFeed(r, tag)
+		feed.Up = opdsPrefix(r) + "/tags"
+		paginateOPDSFeed(feed, r.URL.EscapedPath(), url.Values{}, paginated)
+		writeOPDSFeed(app, w, r, feed)
+	}
+}
+
+// OPDSSearch renders the acquisition feed of the books matching a search, using the same search as the web interface.
+func OPDSSearch(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var term, param string
+		for _, p := range opds.SearchParameters {
+			if term = strings.TrimSpace(r.URL.Query().Get(p)); term != "" {
+				param = p
+				break
+			}
+		}
+
+		var books []models.Book
+		if term != "" {
+			results, err := app.Models.Books.Search(term, app.GetAPIUserId(r), opdsSearchLimit)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+			for _, result := range results {
+				books = append(books, result.Book)
+			}
+		}
+
+		paginated := models.PaginatedBooks{Total: len(books), Page: opdsPage(r), PageSize: opdsPageSize}
+		paginated.TotalPages = (paginated.Total + paginated.PageSize - 1) / paginated.PageSize
+		if paginated.Page > paginated.TotalPages && paginated.TotalPages > 0 {
+			paginated.Page = paginated.TotalPages
+		}
+		start := (paginated.Page - 1) * paginated.PageSize
+		end := min(start+paginated.PageSize, len(books))
+		if start < end {
+			paginated.Books = books[start:end]
+		}
+
+		feed := newOPDSFeed(r, fmt.Sprintf("Search: %s", term))
+		feed.Up = feed.Start
+		query := url.Values{}
+		if term != "" {
+			query.Set(param, term)
+		}
+		paginateOPDSFeed(feed, r.URL.Path, query, paginated)
+		writeOPDSFeed(app, w, r, feed)
+	}
+}
+
+// OPDSSearchDescription renders the OpenSearch description that tells OPDS 1.2 clients how to search the catalog.
+func OPDSSearchDescription(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", opds.OpenSearchType)
+		if err := opds.WriteOpenSearch(w, opdsTitle, "/opds/search"); err != nil {
+			app.Logger.Error("opensearch description failed", "error", err)
+		}
+	}
+}
+
+// APITokenPost creates an API token for the authenticated user and renders it once, together with their other tokens.
+func APITokenPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var form forms.APITokenForm
+		if err := app.FormDecoder.Decode(&form, r.PostForm); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		data := app.GetTemplateData(r)
+		status := http.StatusOK
+		form.Name = strings.TrimSpace(form.Name)
+		form.Validate()
+		if form.Valid() {
+			token, err := app.Models.APITokens.Create(userId, form.Name)
+			if err != nil {
+				app.ServerError(w, r, err)
+				return
+			}
+			data.NewAPIToken = token
+			form = forms.APITokenForm{}
+		} else {
+			status = http.StatusUnprocessableEntity
+		}
+
+		tokens, err := app.Models.APITokens.List(userId)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		data.APITokens = tokens
+		data.Form = form
+		app.Render(w, r, "htmxAPITokens", data, status)
+	}
+}
+
+// APITokenDeletePost revokes an API token of the authenticated user and renders the remaining tokens.
+func APITokenDeletePost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var form forms.APITokenForm
+		if err := app.FormDecoder.Decode(&form, r.PostForm); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		err := app.Models.APITokens.Delete(form.Id, userId)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		tokens, err := app.Models.APITokens.List(userId)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		data := app.GetTemplateData(r)
+		data.APITokens = tokens
+		data.Form = forms.APITokenForm{}
+		app.Render(w, r, "htmxAPITokens", data, http.StatusOK)
+	}
+}
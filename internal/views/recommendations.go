@@ -0,0 +1,77 @@
+package views
+
+import (
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"net/http"
+	"strconv"
+)
+
+// similarBooksLimit is the number of similar books shown with a book.
+const similarBooksLimit = 6
+
+// recommendedBooksLimit is the number of books recommended on the home page.
+const recommendedBooksLimit = 4
+
+// MoreLikeThis renders the books most like a book in content and in the ratings readers gave them.
+func MoreLikeThis(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		books, err := app.Models.Recommendations.MoreLikeThis(id, similarBooksLimit)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data := app.GetTemplateData(r)
+		data.Books = books
+		app.Render(w, r, "htmxMoreLikeThis", data, http.StatusOK)
+	}
+}
+
+// SimilarBooks renders the books readers who liked a book also liked.
+func SimilarBooks(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		books, err := app.Models.Recommendations.Similar(id, similarBooksLimit)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data := app.GetTemplateData(r)
+		data.Books = books
+		app.Render(w, r, "htmxSimilarBooks", data, http.StatusOK)
+	}
+}
+
+// GetRecommendedBooks renders the books recommended to the authenticated user from the books they rated,
+// or answers with a 204 status if the user is not authenticated.
+func GetRecommendedBooks(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		books, err := app.Models.Recommendations.ForUser(userId, recommendedBooksLimit)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data := app.GetTemplateData(r)
+		data.Books = books
+		app.Render(w, r, "htmxRecommendedBooks", data, http.StatusOK)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/madalinpopa/go-bookreview/internal/app"
 	"github.com/madalinpopa/go-bookreview/internal/forms"
 	"github.com/madalinpopa/go-bookreview/internal/models"
+	"html/template"
 	"net/http"
 	"strconv"
 )
@@ -257,6 +258,16 @@ func ListReviews(app *app.App) http.HandlerFunc {
 			return
 		}
 		data.Reviews = reviews
+
+		var counts [5]int
+		for _, review := range reviews {
+			if review.Rating >= 1 && review.Rating <= 5 {
+				counts[review.Rating-1]++
+			}
+		}
+		if counts != [5]int{} {
+			data.Charts = map[string]template.HTML{"ratings": ratingHistogram("chart-book-ratings", "Ratings of "+book.Title, counts)}
+		}
 		app.Render(w, r, "htmxBookReviews", data, http.StatusOK)
 	}
 }
@@ -0,0 +1,230 @@
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/forms"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/rules"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CreateSmartShelf renders the form saving the book list as a smart shelf, with the rule of its filters, or of
+// the words of the search in search, filled in.
+func CreateSmartShelf(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := app.GetTemplateData(r)
+		query := r.URL.Query()
+
+		filter, err := applySmartShelf(app, r, &data, models.ParseBookFilter(query))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		form := forms.SmartShelfForm{Rule: filter.Rule(), Sort: filter.Sort}
+		if search := strings.TrimSpace(query.Get("search")); search != "" {
+			var words []string
+			for _, word := range strings.Fields(search) {
+				words = append(words, rules.Quote(word))
+			}
+			form.Name = search
+			form.Rule = strings.Join(words, " ")
+		}
+
+		data.BooksURL = booksURL(filter, 1, false)
+		data.Form = form
+		app.Render(w, r, "htmxSmartShelfForm", data, http.StatusOK)
+	}
+}
+
+// UpdateSmartShelf renders the form changing a smart shelf of the authenticated user.
+func UpdateSmartShelf(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shelfId, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		shelf, err := app.Models.SmartShelves.Retrieve(shelfId, app.GetAuthenticatedUserId(r))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		data := app.GetTemplateData(r)
+		data.SmartShelf = shelf
+		data.BooksURL = booksURL(models.BookFilter{Shelf: shelf.ID}, 1, false)
+		data.Form = forms.SmartShelfForm{Id: shelf.ID, Name: shelf.Name, Rule: shelf.Rule, Sort: shelf.Sort, ImageURL: shelf.ImageURL}
+		app.Render(w, r, "htmxSmartShelfForm", data, http.StatusOK)
+	}
+}
+
+// SmartShelfPost saves a new smart shelf of the authenticated user, or changes one when the form has its ID,
+// and shows the books on it. Mistakes in the rule are shown with the form like those of the other fields.
+// An uploaded cover is saved as a private image of the user.
+func SmartShelfPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := parseShelfForm(w, r); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer removeShelfForm(app, r)
+
+		var form forms.SmartShelfForm
+		if err := app.FormDecoder.Decode(&form, r.PostForm); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		form.Name = strings.TrimSpace(form.Name)
+		form.Rule = strings.TrimSpace(form.Rule)
+		if form.Sort == models.SortAdded {
+			form.Sort = ""
+		}
+		form.Validate()
+
+		// The cover is taken from the saved shelf, so a shelf never points at an upload of someone else
+		if form.Id != 0 {
+			shelf, err := app.Models.SmartShelves.Retrieve(form.Id, userId)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					app.ClientError(w, r, http.StatusNotFound, err)
+					return
+				}
+				app.ServerError(w, r, err)
+				return
+			}
+			form.ImageURL = shelf.ImageURL
+		}
+		if form.RemoveImage {
+			form.ImageURL = ""
+		}
+
+		var err error
+		if form.Valid() {
+			err = form.HandleFileUpload(app, r, userId)
+			switch {
+			case errors.Is(err, forms.ErrFormBadRequest):
+				app.ClientError(w, r, http.StatusBadRequest, err)
+				return
+			case err != nil && !errors.Is(err, forms.ErrInvalidFileType):
+				app.ServerError(w, r, err)
+				return
+			}
+		}
+
+		if form.Valid() {
+			if form.Id == 0 {
+				form.Id, err = app.Models.SmartShelves.Create(userId, form.Name, form.Rule, form.Sort, form.ImageURL)
+			} else {
+				err = app.Models.SmartShelves.Update(form.Id, userId, form.Name, form.Rule, form.Sort, form.ImageURL)
+			}
+			switch {
+			case errors.Is(err, models.ErrDuplicateShelfName):
+				form.AddFieldError("name", "You already have a shelf with this name")
+			case errors.Is(err, models.ErrNoRecord):
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			case err != nil:
+				app.ServerError(w, r, err)
+				return
+			}
+		}
+
+		if !form.Valid() {
+			data := app.GetTemplateData(r)
+			data.BooksURL = "/books"
+			if form.Id != 0 {
+				data.BooksURL = booksURL(models.BookFilter{Shelf: form.Id}, 1, false)
+			}
+			data.Form = form
+			app.Render(w, r, "htmxSmartShelfForm", data, http.StatusUnprocessableEntity)
+			return
+		}
+
+		location, err := json.Marshal(map[string]string{
+			"path":   booksURL(models.BookFilter{Shelf: form.Id}, 1, false),
+			"target": "#books-content",
+		})
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+		w.Header().Set("HX-Location", string(location))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteSmartShelfPost deletes a smart shelf of the authenticated user and shows every book again.
+func DeleteSmartShelfPost(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := parseShelfForm(w, r); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		defer removeShelfForm(app, r)
+
+		var form forms.SmartShelfForm
+		if err := app.FormDecoder.Decode(&form, r.PostForm); err != nil {
+			app.ClientError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		userId := app.GetAuthenticatedUserId(r)
+		if userId == 0 {
+			app.ClientError(w, r, http.StatusUnauthorized, errors.New("user not authenticated"))
+			return
+		}
+
+		if err := app.Models.SmartShelves.Delete(form.Id, userId); err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.ClientError(w, r, http.StatusNotFound, err)
+				return
+			}
+			app.ServerError(w, r, err)
+			return
+		}
+
+		w.Header().Set("HX-Location", `{"path": "/books", "target": "#books-content"}`)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseShelfForm parses the smart shelf form, which is sent as a multipart form when it may carry a cover.
+// Like the book form, the request body is limited to 5MB.
+func parseShelfForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 5<<20)
+	if err := r.ParseMultipartForm(5 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	return nil
+}
+
+// removeShelfForm removes the temporary files of a multipart shelf form.
+func removeShelfForm(app *app.App, r *http.Request) {
+	if r.MultipartForm == nil {
+		return
+	}
+	if err := r.MultipartForm.RemoveAll(); err != nil {
+		app.Logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,155 @@
+package views
+
+import (
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/chart"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// StatsPage renders the reading statistics of the authenticated user for the year in the "year" query parameter,
+// the current year when there is none. HTMX requests from the year selector get the statistics alone.
+func StatsPage(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := app.GetTemplateData(r)
+
+		year := data.CurrentYear
+		if s := r.URL.Query().Get("year"); s != "" {
+			var err error
+			year, err = strconv.Atoi(s)
+			if err != nil || year < 1 || year > 9999 {
+				app.ClientError(w, r, http.StatusBadRequest, fmt.Errorf("invalid year %q", s))
+				return
+			}
+		}
+
+		stats, err := app.Models.Stats.ForYear(app.GetAuthenticatedUserId(r), year)
+		if err != nil {
+			app.ServerError(w, r, err)
+			return
+		}
+
+		// The selector always offers the current year and the one shown, even before any book was finished in them.
+		stats.Years = yearChoices(stats.Years, data.CurrentYear, year)
+		data.Stats = stats
+		data.Charts = statsCharts(stats)
+
+		if app.IsHtmxRequest(r) {
+			w.Header().Set("HX-Push-Url", "/stats?year="+strconv.Itoa(year))
+			app.Render(w, r, "htmxStats", data, http.StatusOK)
+			return
+		}
+		app.Render(w, r, "stats.tmpl", data, http.StatusOK)
+	}
+}
+
+// yearChoices adds the given years to years, sorted with the most recent first, when they are missing.
+func yearChoices(years []int, add ...int) []int {
+	for _, year := range add {
+		if !slices.Contains(years, year) {
+			years = append(years, year)
+		}
+	}
+	slices.SortFunc(years, func(a, b int) int { return b - a })
+	return years
+}
+
+// statsCharts returns the charts of the reading statistics of a year: the books finished every month and every
+// year, the ratings given and the days with reading activity.
+func statsCharts(stats models.ReadingStats) map[string]template.HTML {
+	var months []chart.Point
+	for _, m := range stats.Months {
+		month := time.Month(m.Period)
+		months = append(months, chart.Point{
+			Label: month.String()[:3],
+			Value: float64(m.Books),
+			Text:  fmt.Sprintf("%s: %s, %s", month, plural(m.Books, "book"), plural(m.Pages, "page")),
+		})
+	}
+
+	var years []chart.Point
+	for i := len(stats.PerYear) - 1; i >= 0; i-- {
+		y := stats.PerYear[i]
+		years = append(years, chart.Point{
+			Label: strconv.Itoa(y.Period),
+			Value: float64(y.Books),
+			Text:  fmt.Sprintf("%d: %s, %s", y.Period, plural(y.Books, "book"), plural(y.Pages, "page")),
+		})
+	}
+
+	activity := fmt.Sprintf("%s with books finished, reviewed or noted in %d, at most %s in a row.",
+		plural(len(stats.Days), "day"), stats.Year, plural(stats.Streak.Days, "day"))
+
+	return map[string]template.HTML{
+		"months": chart.BarChart{
+			ID:          "chart-months",
+			Title:       fmt.Sprintf("Books finished per month in %d", stats.Year),
+			Description: fmt.Sprintf("%s and %s finished in %d.", plural(stats.Books, "book"), plural(stats.Pages, "page"), stats.Year),
+			Color:       "#14b8a6",
+			Points:      months,
+		}.SVG(),
+		"years": chart.LineChart{
+			ID:          "chart-years",
+			Title:       "Books finished per year",
+			Description: fmt.Sprintf("Books finished in each of %s.", plural(len(years), "year")),
+			Color:       "#0d9488",
+			Points:      years,
+		}.SVG(),
+		"ratings": ratingHistogram("chart-ratings", fmt.Sprintf("Ratings given in %d", stats.Year), stats.RatingCounts),
+		"days": chart.Heatmap{
+			ID:          "chart-days",
+			Title:       fmt.Sprintf("Reading activity in %d", stats.Year),
+			Description: activity,
+			Start:       time.Date(stats.Year, time.January, 1, 0, 0, 0, 0, time.UTC),
+			End:         time.Date(stats.Year, time.December, 31, 0, 0, 0, 0, time.UTC),
+			Days:        activityDays(stats.Days),
+		}.SVG(),
+	}
+}
+
+// activityDays returns the days of a heatmap of the days with reading activity.
+func activityDays(days []models.DayCount) []chart.Day {
+	var result []chart.Day
+	for _, d := range days {
+		result = append(result, chart.Day{
+			Date:  d.Day,
+			Count: d.Count,
+			Text:  fmt.Sprintf("%s: %s finished, reviewed or noted", d.Day.Format("2 Jan 2006"), plural(d.Count, "book")),
+		})
+	}
+	return result
+}
+
+// ratingHistogram returns the histogram of the number of each rating from 1 to 5.
+func ratingHistogram(id, title string, counts [5]int) template.HTML {
+	var points []chart.Point
+	total := 0
+	for i, n := range counts {
+		total += n
+		points = append(points, chart.Point{
+			Label: strconv.Itoa(i+1) + "★",
+			Value: float64(n),
+			Text:  fmt.Sprintf("%s: %s", plural(i+1, "star"), plural(n, "rating")),
+		})
+	}
+	return chart.BarChart{
+		ID:          id,
+		Title:       title,
+		Description: fmt.Sprintf("%s from 1 to 5 stars.", plural(total, "rating")),
+		Color:       "#fbbf24",
+		Points:      points,
+	}.SVG()
+}
+
+// plural returns a number followed by a noun, with an s when the number is not 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,95 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"github.com/madalinpopa/go-bookreview/internal/app"
+	"github.com/madalinpopa/go-bookreview/internal/models"
+	"github.com/madalinpopa/go-bookreview/internal/storage"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cache lifetimes of uploaded files. Files named after their content never change, so they are cached for a year,
+// while files uploaded before that, and redirects to the backend whose URLs are only valid for a while, are not.
+const (
+	immutableMaxAge = 365 * 24 * time.Hour
+	uploadMaxAge    = 24 * time.Hour
+	redirectMaxAge  = time.Hour
+)
+
+// Uploads serves uploaded files. Backends that serve files themselves, like the local one, handle the request,
+// while for the others the browser is redirected to the URL of the file in the backend.
+// Private files are only served to their owner or with a signed URL that has not expired.
+func Uploads(app *app.App) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := storage.Key(r.URL.Path)
+
+		owner, err := app.Models.Uploads.Owner(key)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.ServerError(w, r, err)
+			return
+		}
+		if err == nil {
+			privateUpload(app, key, owner).ServeHTTP(w, r)
+			return
+		}
+
+		maxAge := uploadMaxAge
+		if storage.IsContentKey(key) {
+			maxAge = immutableMaxAge
+		}
+		cacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+		if maxAge == immutableMaxAge {
+			cacheControl += ", immutable"
+		}
+		serveUpload(app, w, r, key, cacheControl)
+	}
+}
+
+// privateUpload returns the handler of a private file, which loads the session to find out who is asking for the file.
+// Responses are only cached by the browser, for as long as the signed URL is valid.
+func privateUpload(app *app.App, key string, owner int) http.Handler {
+	return app.SessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if expires, ok := app.Signer.Verify(key, r.URL.Query()); ok {
+			maxAge := int(time.Until(expires).Seconds())
+			serveUpload(app, w, r, key, fmt.Sprintf("private, max-age=%d", maxAge))
+			return
+		}
+		if app.GetAuthenticatedUserId(r) == owner {
+			serveUpload(app, w, r, key, "private, no-cache")
+			return
+		}
+
+		// Others are not told the file exists.
+		app.ClientError(w, r, http.StatusNotFound, fmt.Errorf("private upload %q", key))
+	}))
+}
+
+// serveUpload serves the file stored at key with the given Cache-Control header.
+func serveUpload(app *app.App, w http.ResponseWriter, r *http.Request, key, cacheControl string) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if h, ok := app.Storage.(http.Handler); ok {
+		w.Header().Set("Cache-Control", cacheControl)
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	url, err := app.Storage.URL(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidKey) {
+			app.ClientError(w, r, http.StatusNotFound, err)
+			return
+		}
+		app.ServerError(w, r, err)
+		return
+	}
+	if strings.HasPrefix(cacheControl, "private") {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(redirectMaxAge.Seconds())))
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+}
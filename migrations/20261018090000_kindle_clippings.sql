@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Create kindle_clippings table holding parsed "My Clippings.txt" entries until they are matched to a book
+CREATE TABLE kindle_clippings
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id        INTEGER NOT NULL,
+    book_id        INTEGER,
+    note_id        INTEGER,
+    hash           TEXT    NOT NULL,
+    title          TEXT    NOT NULL,
+    author         TEXT    NOT NULL DEFAULT '',
+    kind           TEXT    NOT NULL CHECK (kind IN ('highlight', 'note', 'bookmark')),
+    page_number    INTEGER NOT NULL DEFAULT 0,
+    location_start INTEGER NOT NULL DEFAULT 0,
+    location_end   INTEGER NOT NULL DEFAULT 0,
+    clipping_text  TEXT    NOT NULL DEFAULT '',
+    clipped_at     DATETIME,
+    status         TEXT    NOT NULL CHECK (status IN ('pending', 'imported', 'skipped')) DEFAULT 'pending',
+    created_at     DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at     DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE SET NULL,
+    FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE SET NULL,
+    UNIQUE (user_id, hash)
+);
+
+CREATE INDEX idx_kindle_clippings_user_status ON kindle_clippings (user_id, status);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP INDEX IF EXISTS idx_kindle_clippings_user_status;
+DROP TABLE IF EXISTS kindle_clippings;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Add the date a user finished reading a book to user_books table
+ALTER TABLE user_books
+    ADD COLUMN finished_at DATETIME DEFAULT NULL;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+-- Remove finished_at column from user_books table
+ALTER TABLE user_books
+    DROP COLUMN finished_at;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Add publisher, language and description columns to books table
+ALTER TABLE books
+    ADD COLUMN publisher TEXT NOT NULL DEFAULT '';
+ALTER TABLE books
+    ADD COLUMN language TEXT NOT NULL DEFAULT '';
+ALTER TABLE books
+    ADD COLUMN description TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+-- Remove publisher, language and description columns from books table
+ALTER TABLE books
+    DROP COLUMN description;
+ALTER TABLE books
+    DROP COLUMN language;
+ALTER TABLE books
+    DROP COLUMN publisher;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Add the key KOReader sends for an API token, which is the MD5 hash of the token
+ALTER TABLE api_tokens
+    ADD COLUMN kosync_key_hash TEXT NOT NULL DEFAULT '';
+
+-- Create kosync_documents table holding the reading position KOReader syncs for each document
+CREATE TABLE kosync_documents
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL,
+    book_id    INTEGER,
+    document   TEXT    NOT NULL,
+    ignored    BOOLEAN NOT NULL DEFAULT 0,
+    progress   TEXT    NOT NULL DEFAULT '',
+    percentage REAL    NOT NULL DEFAULT 0,
+    device     TEXT    NOT NULL DEFAULT '',
+    device_id  TEXT    NOT NULL DEFAULT '',
+    timestamp  INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE SET NULL,
+    UNIQUE (user_id, document)
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS kosync_documents;
+
+ALTER TABLE api_tokens
+    DROP COLUMN kosync_key_hash;
@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Index the title, author and description of books for full-text search, kept in sync by triggers
+CREATE VIRTUAL TABLE books_fts USING fts5(title, author, description, content='books', content_rowid='id', prefix='2 3', tokenize='unicode61 remove_diacritics 2');
+-- +goose StatementBegin
+CREATE TRIGGER books_fts_insert AFTER INSERT ON books
+BEGIN
+    INSERT INTO books_fts (rowid, title, author, description) VALUES (new.id, new.title, new.author, new.description);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER books_fts_delete AFTER DELETE ON books
+BEGIN
+    INSERT INTO books_fts (books_fts, rowid, title, author, description) VALUES ('delete', old.id, old.title, old.author, old.description);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER books_fts_update AFTER UPDATE OF title, author, description ON books
+BEGIN
+    INSERT INTO books_fts (books_fts, rowid, title, author, description) VALUES ('delete', old.id, old.title, old.author, old.description);
+    INSERT INTO books_fts (rowid, title, author, description) VALUES (new.id, new.title, new.author, new.description);
+END;
+-- +goose StatementEnd
+
+-- Index notes, which are only searched by their author
+CREATE VIRTUAL TABLE notes_fts USING fts5(note_text, content='notes', content_rowid='id', prefix='2 3', tokenize='unicode61 remove_diacritics 2');
+-- +goose StatementBegin
+CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes
+BEGIN
+    INSERT INTO notes_fts (rowid, note_text) VALUES (new.id, new.note_text);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes
+BEGIN
+    INSERT INTO notes_fts (notes_fts, rowid, note_text) VALUES ('delete', old.id, old.note_text);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF note_text ON notes
+BEGIN
+    INSERT INTO notes_fts (notes_fts, rowid, note_text) VALUES ('delete', old.id, old.note_text);
+    INSERT INTO notes_fts (rowid, note_text) VALUES (new.id, new.note_text);
+END;
+-- +goose StatementEnd
+
+-- Index reviews
+CREATE VIRTUAL TABLE reviews_fts USING fts5(review_text, content='reviews', content_rowid='id', prefix='2 3', tokenize='unicode61 remove_diacritics 2');
+-- +goose StatementBegin
+CREATE TRIGGER reviews_fts_insert AFTER INSERT ON reviews
+BEGIN
+    INSERT INTO reviews_fts (rowid, review_text) VALUES (new.id, new.review_text);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER reviews_fts_delete AFTER DELETE ON reviews
+BEGIN
+    INSERT INTO reviews_fts (reviews_fts, rowid, review_text) VALUES ('delete', old.id, old.review_text);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER reviews_fts_update AFTER UPDATE OF review_text ON reviews
+BEGIN
+    INSERT INTO reviews_fts (reviews_fts, rowid, review_text) VALUES ('delete', old.id, old.review_text);
+    INSERT INTO reviews_fts (rowid, review_text) VALUES (new.id, new.review_text);
+END;
+-- +goose StatementEnd
+
+-- Index the existing rows
+INSERT INTO books_fts (books_fts) VALUES ('rebuild');
+INSERT INTO notes_fts (notes_fts) VALUES ('rebuild');
+INSERT INTO reviews_fts (reviews_fts) VALUES ('rebuild');
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS books_fts_insert;
+DROP TRIGGER IF EXISTS books_fts_delete;
+DROP TRIGGER IF EXISTS books_fts_update;
+DROP TABLE IF EXISTS books_fts;
+DROP TRIGGER IF EXISTS notes_fts_insert;
+DROP TRIGGER IF EXISTS notes_fts_delete;
+DROP TRIGGER IF EXISTS notes_fts_update;
+DROP TABLE IF EXISTS notes_fts;
+DROP TRIGGER IF EXISTS reviews_fts_insert;
+DROP TRIGGER IF EXISTS reviews_fts_delete;
+DROP TRIGGER IF EXISTS reviews_fts_update;
+DROP TABLE IF EXISTS reviews_fts;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Create recent_searches table holding the last searches of every user, suggested again when they start a search
+CREATE TABLE recent_searches
+(
+    user_id     INTEGER NOT NULL,
+    query       TEXT    NOT NULL COLLATE NOCASE,
+    searched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, query),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Index titles, authors and usernames ignoring case, so they can be suggested by their start
+CREATE INDEX books_title_nocase ON books (title COLLATE NOCASE);
+CREATE INDEX books_author_nocase ON books (author COLLATE NOCASE);
+CREATE INDEX users_username_nocase ON users (username COLLATE NOCASE);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP INDEX IF EXISTS users_username_nocase;
+DROP INDEX IF EXISTS books_author_nocase;
+DROP INDEX IF EXISTS books_title_nocase;
+DROP TABLE IF EXISTS recent_searches;
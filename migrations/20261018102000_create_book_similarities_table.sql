@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Create book_similarities table holding the books most similar to every book, computed from the ratings of reviews
+CREATE TABLE book_similarities
+(
+    book_id         INTEGER NOT NULL,
+    similar_book_id INTEGER NOT NULL,
+    score           REAL    NOT NULL,
+    PRIMARY KEY (book_id, similar_book_id),
+    FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE,
+    FOREIGN KEY (similar_book_id) REFERENCES books (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS book_similarities;
@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Create book_content_similarities table holding the books most alike in their authors, series, tags and description
+CREATE TABLE book_content_similarities
+(
+    book_id         INTEGER NOT NULL,
+    similar_book_id INTEGER NOT NULL,
+    score           REAL    NOT NULL,
+    PRIMARY KEY (book_id, similar_book_id),
+    FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE,
+    FOREIGN KEY (similar_book_id) REFERENCES books (id) ON DELETE CASCADE
+);
+
+-- Queue the books whose similarities are to be computed again, kept up to date by triggers
+CREATE TABLE book_content_changes
+(
+    book_id INTEGER PRIMARY KEY
+);
+-- +goose StatementBegin
+CREATE TRIGGER book_content_insert AFTER INSERT ON books
+BEGIN
+    INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (new.id);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER book_content_update AFTER UPDATE OF title, author, description ON books
+BEGIN
+    INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (new.id);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER book_content_tag_insert AFTER INSERT ON book_tags
+BEGIN
+    INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (new.book_id);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER book_content_tag_delete AFTER DELETE ON book_tags
+BEGIN
+    INSERT OR IGNORE INTO book_content_changes (book_id) VALUES (old.book_id);
+END;
+-- +goose StatementEnd
+
+-- Queue the existing books
+INSERT INTO book_content_changes (book_id) SELECT id FROM books;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS book_content_tag_delete;
+DROP TRIGGER IF EXISTS book_content_tag_insert;
+DROP TRIGGER IF EXISTS book_content_update;
+DROP TRIGGER IF EXISTS book_content_insert;
+DROP TABLE IF EXISTS book_content_changes;
+DROP TABLE IF EXISTS book_content_similarities;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Add page_count column to books table, 0 when the number of pages is not known
+ALTER TABLE books
+    ADD COLUMN page_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+-- Remove page_count column from books table
+ALTER TABLE books
+    DROP COLUMN page_count;
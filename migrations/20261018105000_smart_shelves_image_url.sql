@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Add image_url column to smart_shelves table holding the private cover of a shelf, empty when it has none
+ALTER TABLE smart_shelves
+    ADD COLUMN image_url TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+-- Remove image_url column from smart_shelves table
+ALTER TABLE smart_shelves
+    DROP COLUMN image_url;
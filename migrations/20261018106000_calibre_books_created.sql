@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- Add created column to calibre_books table, set when the import created the book instead of linking an existing one
+ALTER TABLE calibre_books
+    ADD COLUMN created BOOLEAN NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+-- Remove created column from calibre_books table
+ALTER TABLE calibre_books
+    DROP COLUMN created;
@@ -0,0 +1,94 @@
+// Keyboard navigation of the search suggestions in the books header.
+(function () {
+    "use strict";
+
+    function input() {
+        return document.getElementById("search");
+    }
+
+    function listbox() {
+        return document.getElementById("search-suggestions");
+    }
+
+    function options() {
+        return Array.from(listbox().querySelectorAll('[role="option"]'));
+    }
+
+    function select(option) {
+        options().forEach(function (o) {
+            o.setAttribute("aria-selected", o === option ? "true" : "false");
+        });
+        if (option) {
+            input().setAttribute("aria-activedescendant", option.id);
+            option.scrollIntoView({block: "nearest"});
+        } else {
+            input().removeAttribute("aria-activedescendant");
+        }
+    }
+
+    function close() {
+        if (!listbox()) {
+            return;
+        }
+        listbox().innerHTML = "";
+        input().setAttribute("aria-expanded", "false");
+        input().removeAttribute("aria-activedescendant");
+    }
+
+    document.addEventListener("keydown", function (event) {
+        if (event.target !== input()) {
+            return;
+        }
+        var all = options();
+        var current = all.findIndex(function (o) {
+            return o.getAttribute("aria-selected") === "true";
+        });
+
+        switch (event.key) {
+            case "ArrowDown":
+            case "ArrowUp":
+                if (all.length === 0) {
+                    return;
+                }
+                event.preventDefault();
+                if (event.key === "ArrowDown") {
+                    select(all[(current + 1) % all.length]);
+                } else {
+                    select(all[current <= 0 ? all.length - 1 : current - 1]);
+                }
+                break;
+            case "Enter":
+                if (current !== -1) {
+                    event.preventDefault();
+                    all[current].click();
+                } else {
+                    close();
+                }
+                break;
+            case "Escape":
+                close();
+                break;
+        }
+    });
+
+    document.addEventListener("click", function (event) {
+        if (!listbox()) {
+            return;
+        }
+        var option = event.target.closest('#search-suggestions [role="option"]');
+        if (option && option.name === "search") {
+            input().value = option.value;
+        }
+        // The list is closed after htmx has sent the request of the option.
+        if (option || !event.target.closest("#search-form")) {
+            setTimeout(close);
+        }
+    });
+
+    document.addEventListener("htmx:afterSwap", function (event) {
+        if (event.detail.target === listbox()) {
+            input().setAttribute("aria-expanded", options().length > 0 ? "true" : "false");
+            input().removeAttribute("aria-activedescendant");
+        }
+    });
+})();